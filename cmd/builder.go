@@ -45,6 +45,13 @@ var (
 
 		This command extracts files from existing images to use as input to a build.
 		It expects to be run inside of a container.`)
+
+	buildAgentLong = templates.LongDesc(`
+		Run a long-lived build agent
+
+		This command runs the builder as an agent on a dedicated build machine.
+		Build objects are submitted over HTTP and executed with the regular
+		Docker or Source-to-Image build logic.`)
 )
 
 // NewCmdVersion provides a shim around version for
@@ -123,6 +130,22 @@ func NewCommandManageDockerfile(name string) *cobra.Command {
 	return cmd
 }
 
+// NewCommandBuildAgent runs the builder as a long-lived agent serving
+// builds submitted over HTTP.
+func NewCommandBuildAgent(name string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: "Run a long-lived build agent",
+		Long:  buildAgentLong,
+		Run: func(c *cobra.Command, args []string) {
+			err := cmd.RunBuildAgent(c.OutOrStderr())
+			kcmdutil.CheckErr(err)
+		},
+	}
+	cmd.AddCommand(NewCmdVersion(name, version.Get(), os.Stdout))
+	return cmd
+}
+
 func NewCommandExtractImageContent(name string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   name,
@@ -96,6 +96,8 @@ func CommandFor(basename string) *cobra.Command {
 		cmd = NewCommandManageDockerfile(basename)
 	case "openshift-extract-image-content":
 		cmd = NewCommandExtractImageContent(basename)
+	case "openshift-build-agent":
+		cmd = NewCommandBuildAgent(basename)
 	default:
 		fmt.Printf("unknown command name: %s\n", basename)
 		os.Exit(1)
@@ -0,0 +1,76 @@
+#!/bin/bash
+
+# Developer-facing conformance harness: builds the Dockerfile corpus in
+# pkg/build/builder/testdata/conformance with two container engines and diffs
+# the resulting image configs and filesystem listings, to catch behavior drift
+# between the daemonless build path and docker build semantics.
+#
+# Usage:
+#   hack/conformance.sh
+#
+# Environment:
+#   CONFORMANCE_BUILDER_A   first build command (default: podman)
+#   CONFORMANCE_BUILDER_B   second build command (default: docker)
+#   CONFORMANCE_KEEP        keep built images instead of removing them
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+cd "$(dirname "${BASH_SOURCE}")/.."
+
+corpus=pkg/build/builder/testdata/conformance
+builder_a="${CONFORMANCE_BUILDER_A:-podman}"
+builder_b="${CONFORMANCE_BUILDER_B:-docker}"
+
+for builder in "${builder_a}" "${builder_b}"; do
+  if ! command -v "${builder%% *}" >/dev/null 2>&1; then
+    echo "SKIP: ${builder%% *} not available" 1>&2
+    exit 0
+  fi
+done
+
+image_config() {
+  # Normalize the parts of the image config that docker build guarantees;
+  # fields like Created and engine-specific extras are excluded.
+  ${1} inspect --format '{{json .Config}}' "${2}" | \
+    python3 -c 'import json,sys; c=json.load(sys.stdin); print(json.dumps({k: c.get(k) for k in ("User","WorkingDir","Entrypoint","Cmd","Env","ExposedPorts","Volumes","Labels")}, indent=2, sort_keys=True))'
+}
+
+filesystem_listing() {
+  local cid
+  cid=$(${1} create "${2}")
+  ${1} export "${cid}" | tar -tf - | sort
+  ${1} rm "${cid}" >/dev/null
+}
+
+failures=0
+for dockerfile in "${corpus}"/Dockerfile.*; do
+  name=$(basename "${dockerfile}" | tr '[:upper:].' '[:lower:]-')
+  tag_a="localhost/conformance-a:${name}"
+  tag_b="localhost/conformance-b:${name}"
+  echo "=== ${dockerfile}"
+
+  ${builder_a} build -f "${dockerfile}" -t "${tag_a}" "${corpus}" >/dev/null
+  ${builder_b} build -f "${dockerfile}" -t "${tag_b}" "${corpus}" >/dev/null
+
+  if ! diff -u <(image_config "${builder_a}" "${tag_a}") <(image_config "${builder_b}" "${tag_b}"); then
+    echo "FAIL: image config mismatch for ${dockerfile}" 1>&2
+    failures=$((failures + 1))
+  fi
+  if ! diff -u <(filesystem_listing "${builder_a}" "${tag_a}") <(filesystem_listing "${builder_b}" "${tag_b}"); then
+    echo "FAIL: filesystem mismatch for ${dockerfile}" 1>&2
+    failures=$((failures + 1))
+  fi
+
+  if [ -z "${CONFORMANCE_KEEP-}" ]; then
+    ${builder_a} rmi "${tag_a}" >/dev/null || true
+    ${builder_b} rmi "${tag_b}" >/dev/null || true
+  fi
+done
+
+if [ "${failures}" -gt 0 ]; then
+  echo "${failures} conformance failure(s)" 1>&2
+  exit 1
+fi
+echo "conformance: all Dockerfiles matched"
@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	s2iapi "github.com/openshift/source-to-image/pkg/api"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+)
+
+// This file is the supported surface for embedding the builder in other
+// programs. Construct a DockerClient (GetDaemonlessClient injects the
+// storage store, GetDockerClient connects to an engine daemon), fetch
+// sources with FetchSource, and run a build through a Builder created from
+// BuilderOptions — no exec of the openshift-builder binaries required.
+
+// Builder runs a single build to completion, updating the Build object's
+// status along the way.
+type Builder interface {
+	Build() error
+}
+
+// BuilderOptions collects the dependencies of a Builder, so callers inject
+// their own clients instead of relying on process environment wiring.
+type BuilderOptions struct {
+	// DockerClient executes the image build, tag, pull and push operations.
+	// Required.
+	DockerClient DockerClient
+	// BuildsClient receives build status updates. Required.
+	BuildsClient buildclientv1.BuildInterface
+	// Build is the build to execute. Required; its status is mutated.
+	Build *buildapiv1.Build
+	// CGroupLimits caps the resource usage of build containers. Optional.
+	CGroupLimits *s2iapi.CGroupLimits
+	// DockerSocket is the engine endpoint handed to source-to-image for S2I
+	// builds. Optional for daemonless builds.
+	DockerSocket string
+	// InputDir is the directory holding the fetched input sources. Defaults
+	// to InputContentPath, where the git-clone step places them.
+	InputDir string
+}
+
+func (o *BuilderOptions) validate() error {
+	if o.DockerClient == nil {
+		return fmt.Errorf("a DockerClient is required")
+	}
+	if o.BuildsClient == nil {
+		return fmt.Errorf("a builds client is required")
+	}
+	if o.Build == nil {
+		return fmt.Errorf("a build is required")
+	}
+	return nil
+}
+
+// NewDockerBuilderWithOptions returns a Builder executing the build with the
+// Docker strategy.
+func NewDockerBuilderWithOptions(options BuilderOptions) (Builder, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	builder := NewDockerBuilder(options.DockerClient, options.BuildsClient, options.Build, options.CGroupLimits)
+	if len(options.InputDir) != 0 {
+		builder.inputDir = options.InputDir
+	}
+	return builder, nil
+}
+
+// NewS2IBuilderWithOptions returns a Builder executing the build with the
+// Source (source-to-image) strategy.
+func NewS2IBuilderWithOptions(options BuilderOptions) (Builder, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	return NewS2IBuilder(options.DockerClient, options.DockerSocket, options.BuildsClient, options.Build, options.CGroupLimits), nil
+}
+
+// PullImage fetches an image through the given client. name may carry a tag
+// or a digest.
+func PullImage(dockerClient DockerClient, name string, authConfig docker.AuthConfiguration) error {
+	repository, tag := docker.ParseRepositoryTag(name)
+	options := docker.PullImageOptions{
+		Repository: repository,
+		Tag:        tag,
+	}
+	if options.Tag == "" && strings.Contains(name, "@") {
+		options.Repository = name
+	}
+	return dockerClient.PullImage(options, authConfig)
+}
+
+// PushImage pushes an image through the given client.
+func PushImage(dockerClient DockerClient, name string, authConfig docker.AuthConfiguration) error {
+	repository, tag := docker.ParseRepositoryTag(name)
+	options := docker.PushImageOptions{
+		Name: repository,
+		Tag:  tag,
+	}
+	return dockerClient.PushImage(options, authConfig)
+}
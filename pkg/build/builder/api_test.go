@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	buildfake "github.com/openshift/client-go/build/clientset/versioned/fake"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+)
+
+func testBuildsClient() buildclientv1.BuildInterface {
+	client := &buildfake.Clientset{}
+	return client.Build().Builds("")
+}
+
+func TestBuilderOptionsValidate(t *testing.T) {
+	valid := BuilderOptions{
+		DockerClient: &FakeDocker{},
+		BuildsClient: testBuildsClient(),
+		Build:        &buildapiv1.Build{},
+	}
+	if _, err := NewDockerBuilderWithOptions(valid); err != nil {
+		t.Errorf("docker: unexpected error: %v", err)
+	}
+	if _, err := NewS2IBuilderWithOptions(valid); err != nil {
+		t.Errorf("s2i: unexpected error: %v", err)
+	}
+
+	for name, invalidate := range map[string]func(*BuilderOptions){
+		"missing docker client": func(o *BuilderOptions) { o.DockerClient = nil },
+		"missing builds client": func(o *BuilderOptions) { o.BuildsClient = nil },
+		"missing build":         func(o *BuilderOptions) { o.Build = nil },
+	} {
+		options := valid
+		invalidate(&options)
+		if _, err := NewDockerBuilderWithOptions(options); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBuilderOptionsInputDir(t *testing.T) {
+	options := BuilderOptions{
+		DockerClient: &FakeDocker{},
+		BuildsClient: testBuildsClient(),
+		Build:        &buildapiv1.Build{},
+		InputDir:     "/custom/input",
+	}
+	builder, err := NewDockerBuilderWithOptions(options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if builder.(*DockerBuilder).inputDir != "/custom/input" {
+		t.Errorf("expected the input directory override to be applied")
+	}
+}
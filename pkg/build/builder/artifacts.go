@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/openshift/source-to-image/pkg/tar"
+	s2ifs "github.com/openshift/source-to-image/pkg/util/fs"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+const (
+	// extractArtifactsEnvVar names the environment variable carrying a JSON
+	// list of buildapiv1.ImageSourcePath entries describing paths to copy
+	// out of the built image after a successful build.
+	extractArtifactsEnvVar = "BUILD_EXTRACT_ARTIFACTS"
+
+	// artifactsResultMountPath is the path where the build controller mounts
+	// the results volume that receives extracted artifacts. Destination
+	// directories in the extraction list are interpreted relative to it.
+	artifactsResultMountPath = "/var/run/openshift.io/build-results"
+)
+
+// artifactPathsFromEnvironment reads the artifact extraction list from the
+// environment. An unset or empty variable means no extraction was requested.
+func artifactPathsFromEnvironment() ([]buildapiv1.ImageSourcePath, error) {
+	value := os.Getenv(extractArtifactsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var paths []buildapiv1.ImageSourcePath
+	if err := json.Unmarshal([]byte(value), &paths); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", extractArtifactsEnvVar, err)
+	}
+	return paths, nil
+}
+
+// extractBuildArtifacts copies the declared paths out of the built image into
+// the mounted results volume, so content such as test reports can be published
+// from builds without pushing a separate image.
+func extractBuildArtifacts(ctx context.Context, dockerClient DockerClient, image, containerName string, paths []buildapiv1.ImageSourcePath) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	container, err := dockerClient.CreateContainer(docker.CreateContainerOptions{
+		Name: containerName,
+		Config: &docker.Config{
+			Image: image,
+		},
+		Context: ctx,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating container to extract artifacts from image %s: %v", image, err)
+	}
+	defer func() {
+		if err := dockerClient.RemoveContainer(docker.RemoveContainerOptions{ID: container.ID}); err != nil {
+			glog.V(0).Infof("warning: Failed to remove artifact extraction container %q: %v", container.ID, err)
+		}
+	}()
+
+	tarHelper := tar.New(s2ifs.NewFileSystem())
+	for _, path := range paths {
+		destDir := filepath.Join(artifactsResultMountPath, path.DestinationDir)
+		glog.V(0).Infof("Extracting artifact %s from the built image to %s ...", path.SourcePath, destDir)
+		if err := streamImageSource(dockerClient, container.ID, path.SourcePath, destDir, tarHelper); err != nil {
+			return fmt.Errorf("error extracting artifact path %s from image %s: %v", path.SourcePath, image, err)
+		}
+	}
+	return nil
+}
+
+// streamImageSource extracts sourceDir from a container directly into
+// destDir. Unlike copyImageSource it never buffers the archive in a temp
+// file; the download is extracted as it streams.
+func streamImageSource(dockerClient DockerClient, containerID, sourceDir, destDir string, tarHelper tar.Tar) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(dockerClient.DownloadFromContainer(containerID, docker.DownloadFromContainerOptions{
+			OutputStream: pw,
+			Path:         sourceDir,
+		}))
+	}()
+	var tarOutput io.Writer
+	if glog.Is(4) {
+		tarOutput = os.Stdout
+	}
+	return tarHelper.ExtractTarStreamWithLogging(destDir, pr, tarOutput)
+}
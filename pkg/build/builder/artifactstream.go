@@ -0,0 +1,175 @@
+package builder
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The artifact stream format is the builder side of the save-artifacts v2
+// protocol: a plain tar stream whose last entry is an index listing every
+// file with its size and SHA-256 checksum. Both ends process the stream as it
+// flows, so incremental artifact sets never hit the disk as intermediate
+// full-tar temp files, and the receiver can detect truncated or corrupted
+// streams instead of restoring bad artifacts.
+
+// artifactStreamIndexName is the name of the trailing index entry. A leading
+// dot keeps it from colliding with real artifact paths.
+const artifactStreamIndexName = ".s2i-artifact-index.json"
+
+// artifactStreamEntry describes one artifact file in the stream index.
+type artifactStreamEntry struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// writeArtifactStream streams the content of dir as a save-artifacts v2
+// stream: files are written in tar format while their checksums are computed,
+// followed by the index entry.
+func writeArtifactStream(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	var index []artifactStreamEntry
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			return tw.WriteHeader(header)
+		}
+		if !info.Mode().IsRegular() {
+			// Symlinks and other special files carry no content to checksum.
+			if info.Mode()&os.ModeSymlink != 0 {
+				if header.Linkname, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+			return tw.WriteHeader(header)
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(tw, hash), file)
+		if err != nil {
+			return err
+		}
+		index = append(index, artifactStreamEntry{
+			Path:     header.Name,
+			Size:     size,
+			Checksum: fmt.Sprintf("sha256:%x", hash.Sum(nil)),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name: artifactStreamIndexName,
+		Mode: 0644,
+		Size: int64(len(indexJSON)),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(indexJSON); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readArtifactStream restores a save-artifacts v2 stream into dir, computing
+// checksums as the entries are extracted and verifying them against the
+// trailing index. A stream without an index, or whose content does not match
+// it, is rejected.
+func readArtifactStream(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	checksums := map[string]string{}
+	var index []artifactStreamEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if strings.HasPrefix(name, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+			return fmt.Errorf("artifact stream entry %q escapes the destination directory", header.Name)
+		}
+		if header.Name == artifactStreamIndexName {
+			if err := json.NewDecoder(tr).Decode(&index); err != nil {
+				return fmt.Errorf("unable to parse artifact stream index: %v", err)
+			}
+			continue
+		}
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode))
+			if err != nil {
+				return err
+			}
+			hash := sha256.New()
+			_, err = io.Copy(io.MultiWriter(file, hash), tr)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			checksums[header.Name] = fmt.Sprintf("sha256:%x", hash.Sum(nil))
+		default:
+			glog.V(4).Infof("Skipping artifact stream entry %q of type %c", header.Name, header.Typeflag)
+		}
+	}
+	if index == nil {
+		return fmt.Errorf("artifact stream carries no index; refusing to restore unverifiable artifacts")
+	}
+	for _, entry := range index {
+		checksum, ok := checksums[entry.Path]
+		if !ok {
+			return fmt.Errorf("artifact %q is listed in the index but missing from the stream", entry.Path)
+		}
+		if checksum != entry.Checksum {
+			return fmt.Errorf("artifact %q checksum mismatch: stream has %s, index has %s", entry.Path, checksum, entry.Checksum)
+		}
+	}
+	return nil
+}
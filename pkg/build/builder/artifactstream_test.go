@@ -0,0 +1,97 @@
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifactStreamRoundTrip(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", "artifactsrc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+	if err := os.MkdirAll(filepath.Join(sourceDir, "lib"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := map[string]string{
+		"ruby-version":  "2.5",
+		"lib/cache.bin": "cached artifact content",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stream := &bytes.Buffer{}
+	if err := writeArtifactStream(stream, sourceDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	destDir, err := ioutil.TempDir("", "artifactdest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+	if err := readArtifactStream(bytes.NewReader(stream.Bytes()), destDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, content := range files {
+		restored, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(restored) != content {
+			t.Errorf("file %s: got %q; want %q", name, restored, content)
+		}
+	}
+}
+
+func TestReadArtifactStreamRejectsCorruption(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", "artifactsrc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+	if err := ioutil.WriteFile(filepath.Join(sourceDir, "artifact"), []byte("payload-bytes"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream := &bytes.Buffer{}
+	if err := writeArtifactStream(stream, sourceDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Flip a payload byte without changing the stream length.
+	corrupted := stream.Bytes()
+	offset := bytes.Index(corrupted, []byte("payload-bytes"))
+	if offset < 0 {
+		t.Fatal("could not locate payload in stream")
+	}
+	corrupted[offset] ^= 0xff
+
+	destDir, err := ioutil.TempDir("", "artifactdest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+	if err := readArtifactStream(bytes.NewReader(corrupted), destDir); err == nil {
+		t.Error("expected a checksum mismatch error")
+	}
+}
+
+func TestReadArtifactStreamRequiresIndex(t *testing.T) {
+	destDir, err := ioutil.TempDir("", "artifactdest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+	// An empty tar stream has no index entry.
+	if err := readArtifactStream(bytes.NewReader(make([]byte, 1024)), destDir); err == nil {
+		t.Error("expected an error for a stream without an index")
+	}
+}
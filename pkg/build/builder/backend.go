@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containers/image/types"
+	"github.com/containers/storage"
+)
+
+// BuildBackendEnvVar selects which daemonless backend executes Docker-strategy
+// builds. The default backend drives buildah; alternative backends (such as a
+// BuildKit frontend supporting newer Dockerfile syntax extensions) register
+// themselves here when compiled in.
+const BuildBackendEnvVar = "BUILD_BACKEND"
+
+// defaultBuildBackend is used when BUILD_BACKEND is unset.
+const defaultBuildBackend = "buildah"
+
+// buildBackendFactory constructs the DockerClient implementation for a
+// backend. It receives the same configuration GetDaemonlessClient does.
+type buildBackendFactory func(systemContext types.SystemContext, store storage.Store, isolationSpec string) (DockerClient, error)
+
+var buildBackends = map[string]buildBackendFactory{
+	defaultBuildBackend: GetDaemonlessClient,
+}
+
+// unavailableBuildBackends names backends the builder knows of but does not
+// compile in, so requesting one yields a precise error instead of a generic
+// unknown-backend one. BuildKit - heredocs, RUN --mount, FROM --platform -
+// needs moby/buildkit vendored and remains unimplemented.
+var unavailableBuildBackends = map[string]string{
+	"buildkit": "BuildKit is not compiled into this builder; Dockerfile features beyond what buildah supports (heredocs, RUN --mount, FROM --platform) are not available",
+}
+
+// RegisterBuildBackend makes an alternative build backend selectable via
+// BUILD_BACKEND. It is intended to be called from init functions of backends
+// that are conditionally compiled in.
+func RegisterBuildBackend(name string, factory buildBackendFactory) {
+	buildBackends[name] = factory
+}
+
+// GetBuildBackendClient returns the client for the named backend, defaulting
+// to buildah when name is empty. Unknown names report the available choices so
+// a cluster misconfiguration is easy to diagnose.
+func GetBuildBackendClient(name string, systemContext types.SystemContext, store storage.Store, isolationSpec string) (DockerClient, error) {
+	if len(name) == 0 {
+		name = defaultBuildBackend
+	}
+	factory, ok := buildBackends[strings.ToLower(name)]
+	if !ok {
+		if reason, known := unavailableBuildBackends[strings.ToLower(name)]; known {
+			return nil, fmt.Errorf("%s=%s: %s", BuildBackendEnvVar, name, reason)
+		}
+		known := make([]string, 0, len(buildBackends))
+		for backend := range buildBackends {
+			known = append(known, backend)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unrecognized %s setting %q, compiled-in backends: %s", BuildBackendEnvVar, name, strings.Join(known, ", "))
+	}
+	return factory(systemContext, store, isolationSpec)
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+	docker "github.com/fsouza/go-dockerclient"
+
+	"github.com/openshift/imagebuilder"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/util/dockerfile"
+)
+
+// PinBaseImageDigestsEnvVar enables resolving mutable FROM tags to digests
+// after the base images are pulled, so the actual build runs against exact
+// digests even if the tags move mid-build.
+const PinBaseImageDigestsEnvVar = "BUILD_PIN_BASE_IMAGE_DIGESTS"
+
+// BaseImageDigestsAnnotation records, as a JSON object, the digest reference
+// each base image name resolved to, for reproducibility audits.
+const BaseImageDigestsAnnotation = "build.openshift.io/base-image-digests"
+
+func pinBaseImageDigests() bool {
+	return strings.EqualFold(os.Getenv(PinBaseImageDigestsEnvVar), "true")
+}
+
+// resolveImageDigest returns a repository@digest reference for a locally
+// present image. Names that already carry a digest are returned unchanged.
+func resolveImageDigest(dockerClient DockerClient, imageName string) (string, error) {
+	if strings.Contains(imageName, "@") {
+		return imageName, nil
+	}
+	image, err := dockerClient.InspectImage(imageName)
+	if err != nil {
+		return "", err
+	}
+	repository, _ := docker.ParseRepositoryTag(imageName)
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasPrefix(repoDigest, repository+"@") {
+			return repoDigest, nil
+		}
+	}
+	// The engine may record the repository under a normalized name; reuse its
+	// digest with the name the Dockerfile pulls from, so the rewritten FROM
+	// keeps resolving against the same registry.
+	if len(image.RepoDigests) > 0 {
+		if i := strings.LastIndex(image.RepoDigests[0], "@"); i >= 0 {
+			return repository + image.RepoDigests[0][i:], nil
+		}
+	}
+	return "", fmt.Errorf("no digest recorded for image %q", imageName)
+}
+
+// pinDockerfileBaseImages rewrites FROM instructions whose image appears in
+// pinned to use the digest reference instead.
+func pinDockerfileBaseImages(dockerfilePath string, pinned map[string]string) error {
+	if len(pinned) == 0 {
+		return nil
+	}
+	in, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	node, err := imagebuilder.ParseDockerfile(bytes.NewBuffer(in))
+	if err != nil {
+		return err
+	}
+	for _, child := range node.Children {
+		if child.Value == dockercmd.From && child.Next != nil {
+			if digested, ok := pinned[child.Next.Value]; ok {
+				glog.V(0).Infof("Pinned FROM image %s to %s", child.Next.Value, digested)
+				child.Next.Value = digested
+			}
+		}
+	}
+	return overwriteFile(dockerfilePath, dockerfile.Write(node))
+}
+
+// recordBaseImageDigests publishes the resolved digests on the build, so they
+// end up in the status even if the build fails later.
+func recordBaseImageDigests(build *buildapiv1.Build, pinned map[string]string) {
+	if len(pinned) == 0 {
+		return
+	}
+	pinnedJSON, err := json.Marshal(pinned)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to encode base image digests: %v", err)
+		return
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[BaseImageDigestsAnnotation] = string(pinnedJSON)
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestResolveImageDigest(t *testing.T) {
+	fake := &FakeDocker{
+		inspectImageFunc: func(name string) (*docker.Image, error) {
+			return &docker.Image{
+				RepoDigests: []string{"registry.example.com/ns/base@sha256:0000000000000000000000000000000000000000000000000000000000000000"},
+			}, nil
+		},
+	}
+
+	digested, err := resolveImageDigest(fake, "registry.example.com/ns/base:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digested != "registry.example.com/ns/base@sha256:0000000000000000000000000000000000000000000000000000000000000000" {
+		t.Errorf("unexpected digest reference %q", digested)
+	}
+
+	// Already-digested references pass through without an inspect.
+	digested, err = resolveImageDigest(nil, digested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(digested, "@sha256:") {
+		t.Errorf("unexpected digest reference %q", digested)
+	}
+}
+
+func TestPinDockerfileBaseImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "basepin-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	original := "FROM centos:7 AS base\nRUN true\nFROM centos:7\nCOPY --from=base /a /a\n"
+	if err := ioutil.WriteFile(dockerfilePath, []byte(original), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pinned := map[string]string{"centos:7": "centos@sha256:0000000000000000000000000000000000000000000000000000000000000000"}
+	if err := pinDockerfileBaseImages(dockerfilePath, pinned); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(out), "centos:7") {
+		t.Errorf("Dockerfile still references the mutable tag:\n%s", string(out))
+	}
+	if got := strings.Count(string(out), pinned["centos:7"]); got != 2 {
+		t.Errorf("expected 2 pinned FROM references, got %d:\n%s", got, string(out))
+	}
+}
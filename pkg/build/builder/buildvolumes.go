@@ -0,0 +1,49 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BuildVolumesEnvVar names the environment variable carrying a JSON list of
+// build volumes. The build controller mounts the declared ConfigMap, Secret,
+// or CSI volumes into the build pod and points the builder at them here; the
+// builder then bind-mounts each one read-only into the build steps so their
+// content is available to RUN and assemble without being committed into
+// image layers.
+const BuildVolumesEnvVar = "BUILD_VOLUMES"
+
+// buildVolume declares one path in the build pod to expose to the build
+// steps.
+type buildVolume struct {
+	Name            string `json:"name"`
+	SourcePath      string `json:"sourcePath"`
+	DestinationPath string `json:"destinationPath"`
+}
+
+// buildVolumesFromEnvironment reads the build volume declarations from the
+// environment. An unset variable means none were requested.
+func buildVolumesFromEnvironment() ([]buildVolume, error) {
+	value := os.Getenv(BuildVolumesEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var volumes []buildVolume
+	if err := json.Unmarshal([]byte(value), &volumes); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", BuildVolumesEnvVar, err)
+	}
+	for _, volume := range volumes {
+		if len(volume.Name) == 0 {
+			return nil, fmt.Errorf("build volumes must declare a name")
+		}
+		if !filepath.IsAbs(volume.SourcePath) || !filepath.IsAbs(volume.DestinationPath) {
+			return nil, fmt.Errorf("build volume %q must declare absolute source and destination paths", volume.Name)
+		}
+		if _, err := os.Stat(volume.SourcePath); err != nil {
+			return nil, fmt.Errorf("build volume %q source is not available: %v", volume.Name, err)
+		}
+	}
+	return volumes, nil
+}
@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestBuildVolumesFromEnvironment(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", "buildvolume")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	testCases := []struct {
+		name      string
+		value     string
+		expected  int
+		expectErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "valid",
+			value:    fmt.Sprintf(`[{"name":"certs","sourcePath":%q,"destinationPath":"/etc/pki/extra"}]`, sourceDir),
+			expected: 1,
+		},
+		{
+			name:      "missing name",
+			value:     fmt.Sprintf(`[{"sourcePath":%q,"destinationPath":"/etc/pki/extra"}]`, sourceDir),
+			expectErr: true,
+		},
+		{
+			name:      "relative destination",
+			value:     fmt.Sprintf(`[{"name":"certs","sourcePath":%q,"destinationPath":"etc/pki"}]`, sourceDir),
+			expectErr: true,
+		},
+		{
+			name:      "missing source",
+			value:     `[{"name":"certs","sourcePath":"/does/not/exist","destinationPath":"/etc/pki/extra"}]`,
+			expectErr: true,
+		},
+		{
+			name:      "not json",
+			value:     "certs:/etc/pki/extra",
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.value) > 0 {
+				os.Setenv(BuildVolumesEnvVar, tc.value)
+				defer os.Unsetenv(BuildVolumesEnvVar)
+			}
+			volumes, err := buildVolumesFromEnvironment()
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(volumes) != tc.expected {
+				t.Errorf("got %d volumes; want %d", len(volumes), tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+const (
+	// CacheVolumePathEnvVar names the environment variable pointing at a
+	// cache volume shared between builder pods. When set, the build takes a
+	// cooperative lock on the volume before running, so that concurrent
+	// builds cannot corrupt or thrash the shared cache.
+	CacheVolumePathEnvVar = "BUILD_CACHE_VOLUME_PATH"
+
+	// CacheMaxConcurrencyEnvVar names the environment variable capping how
+	// many builds may use the cache volume at once. Defaults to 1.
+	CacheMaxConcurrencyEnvVar = "BUILD_CACHE_MAX_CONCURRENCY"
+
+	// cacheLockDirName is the directory inside the cache volume holding the
+	// per-slot lock files.
+	cacheLockDirName = ".openshift-build-locks"
+
+	// cacheLockRetryDelay is how long a build waits between attempts to
+	// claim a cache slot.
+	cacheLockRetryDelay = 5 * time.Second
+)
+
+// CacheLock represents a held slot on a shared cache volume.
+type CacheLock struct {
+	file *os.File
+}
+
+// Release gives up the cache slot so another build can claim it.
+func (l *CacheLock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		glog.V(0).Infof("warning: Failed to unlock cache slot %s: %v", l.file.Name(), err)
+	}
+	l.file.Close()
+	l.file = nil
+}
+
+// AcquireCacheLock claims one of the concurrency slots on the cache volume
+// named by CacheVolumePathEnvVar, blocking (and reporting "waiting for cache
+// lock" on the build) until a slot frees up. It returns nil when no cache
+// volume is configured. Locks are advisory flock(2) locks on files inside the
+// volume, so they work across builder pods sharing an RWX volume and are
+// released automatically if a builder dies.
+func AcquireCacheLock(build *buildapiv1.Build, client buildclientv1.BuildInterface) (*CacheLock, error) {
+	cachePath := os.Getenv(CacheVolumePathEnvVar)
+	if len(cachePath) == 0 {
+		return nil, nil
+	}
+	slots := 1
+	if value := os.Getenv(CacheMaxConcurrencyEnvVar); len(value) != 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("invalid value %q for %s: must be a positive integer", value, CacheMaxConcurrencyEnvVar)
+		}
+		slots = parsed
+	}
+	lockDir := filepath.Join(cachePath, cacheLockDirName)
+	if err := os.MkdirAll(lockDir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create cache lock directory: %v", err)
+	}
+
+	reported := false
+	for {
+		lock, err := tryAcquireCacheSlot(lockDir, slots)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			if reported {
+				glog.V(0).Infof("Acquired cache lock on %s", cachePath)
+			}
+			return lock, nil
+		}
+		if !reported {
+			glog.V(0).Infof("Waiting for cache lock on %s (%d builds allowed at once) ...", cachePath, slots)
+			build.Status.Message = builderutil.StatusMessageWaitingForCacheLock
+			HandleBuildStatusUpdate(build, client, nil)
+			reported = true
+		}
+		time.Sleep(cacheLockRetryDelay)
+	}
+}
+
+// tryAcquireCacheSlot makes one non-blocking pass over the slot lock files,
+// returning a held lock or nil if every slot is busy.
+func tryAcquireCacheSlot(lockDir string, slots int) (*CacheLock, error) {
+	for i := 0; i < slots; i++ {
+		file, err := os.OpenFile(filepath.Join(lockDir, fmt.Sprintf("slot-%d.lock", i)), os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open cache lock file: %v", err)
+		}
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return &CacheLock{file: file}, nil
+		}
+		file.Close()
+		if err != syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("unable to lock cache slot: %v", err)
+		}
+	}
+	return nil, nil
+}
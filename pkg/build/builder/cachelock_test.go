@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTryAcquireCacheSlot(t *testing.T) {
+	lockDir, err := ioutil.TempDir("", "cachelock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(lockDir)
+
+	first, err := tryAcquireCacheSlot(lockDir, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == nil {
+		t.Fatal("expected to acquire the free slot")
+	}
+
+	// The single slot is held, so a second pass must come back empty.
+	second, err := tryAcquireCacheSlot(lockDir, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second != nil {
+		second.Release()
+		t.Fatal("expected the slot to be busy")
+	}
+
+	// With a second slot available, the next build can proceed.
+	second, err = tryAcquireCacheSlot(lockDir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second == nil {
+		t.Fatal("expected to acquire the second slot")
+	}
+	second.Release()
+
+	first.Release()
+	reacquired, err := tryAcquireCacheSlot(lockDir, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reacquired == nil {
+		t.Fatal("expected to reacquire the released slot")
+	}
+	reacquired.Release()
+}
@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// InputChecksumEnvVar is the name of the environment variable that declares
+// the expected SHA-256 digest, in hex, of the binary build input stream.
+// When set, the uploaded archive is verified while it streams in and the
+// build fails on a mismatch, protecting against truncated or corrupted
+// uploads. Because the vendored build API cannot carry the digest, clients
+// supply it through the build environment.
+const InputChecksumEnvVar = "BUILD_INPUT_SHA256"
+
+// StatusReasonInputChecksumMismatch is the reason associated with builds
+// rejected because the uploaded binary input did not match the declared
+// checksum.
+const StatusReasonInputChecksumMismatch buildapiv1.StatusReason = "BinaryInputChecksumMismatch"
+
+// inputChecksumFromEnvironment returns the declared SHA-256 digest of the
+// binary build input, normalized to lower case, or an empty string if no
+// checksum was declared.
+func inputChecksumFromEnvironment() (string, error) {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(InputChecksumEnvVar)))
+	value = strings.TrimPrefix(value, "sha256:")
+	if len(value) == 0 {
+		return "", nil
+	}
+	if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("invalid value %q for %s: not a hex SHA-256 digest", value, InputChecksumEnvVar)
+	}
+	return value, nil
+}
+
+// inputChecksumMismatchError describes a binary build input whose digest
+// did not match the declared checksum.
+type inputChecksumMismatchError struct {
+	expected string
+	actual   string
+}
+
+func (e *inputChecksumMismatchError) Error() string {
+	return fmt.Sprintf("the binary build input has digest sha256:%s, but sha256:%s was declared", e.actual, e.expected)
+}
+
+// IsInputChecksumMismatch reports whether the error indicates that the
+// binary build input failed checksum verification.
+func IsInputChecksumMismatch(err error) bool {
+	_, ok := err.(*inputChecksumMismatchError)
+	return ok
+}
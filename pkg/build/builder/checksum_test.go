@@ -0,0 +1,86 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestInputChecksumFromEnvironment(t *testing.T) {
+	valid := hex.EncodeToString(bytes.Repeat([]byte{0xab}, 32))
+	testCases := []struct {
+		value     string
+		expected  string
+		expectErr bool
+	}{
+		{value: "", expected: ""},
+		{value: valid, expected: valid},
+		{value: "sha256:" + valid, expected: valid},
+		{value: "SHA256:" + valid, expected: valid},
+		{value: "abcd", expectErr: true},
+		{value: "not hex at all, but 64 chars long to get past a length check..", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(InputChecksumEnvVar, tc.value)
+		checksum, err := inputChecksumFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && checksum != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, checksum)
+		}
+	}
+	os.Unsetenv(InputChecksumEnvVar)
+}
+
+func TestIsInputChecksumMismatch(t *testing.T) {
+	if !IsInputChecksumMismatch(&inputChecksumMismatchError{expected: "a", actual: "b"}) {
+		t.Errorf("expected a mismatch error to be recognized")
+	}
+	if IsInputChecksumMismatch(errors.New("other failure")) {
+		t.Errorf("expected an unrelated error not to be recognized")
+	}
+}
+
+func TestExtractInputBinaryVerifiesChecksum(t *testing.T) {
+	content := []byte("binary build input\n")
+	digest := sha256.Sum256(content)
+	source := &buildapiv1.BinaryBuildSource{AsFile: "input.bin"}
+
+	dir, err := ioutil.TempDir("", "checksum-input")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv(InputChecksumEnvVar, hex.EncodeToString(digest[:]))
+	defer os.Unsetenv(InputChecksumEnvVar)
+	if err := ExtractInputBinary(bytes.NewBuffer(content), source, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	received, err := ioutil.ReadFile(filepath.Join(dir, "input.bin"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("expected %q, got %q", content, received)
+	}
+
+	mismatchDir, err := ioutil.TempDir("", "checksum-input")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(mismatchDir)
+	err = ExtractInputBinary(bytes.NewBufferString("tampered content"), source, mismatchDir)
+	if !IsInputChecksumMismatch(err) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+}
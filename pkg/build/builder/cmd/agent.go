@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// BuildAgentListenEnvVar is the name of the environment variable that sets
+// the address the build agent listens on.
+const BuildAgentListenEnvVar = "BUILD_AGENT_LISTEN"
+
+// defaultBuildAgentListen only accepts local connections; exposing the agent
+// to a network is an explicit decision left to the operator.
+const defaultBuildAgentListen = "127.0.0.1:8331"
+
+// maxAgentBuildBytes caps the size of a submitted Build object. Even builds
+// with large inline Dockerfiles stay well under this.
+const maxAgentBuildBytes = 16 << 20
+
+// buildAgent serves builds submitted over HTTP. It runs one build at a time,
+// reusing the environment-driven build pipeline by publishing each submitted
+// Build object through the BUILD environment variable.
+type buildAgent struct {
+	out io.Writer
+
+	mutex     sync.Mutex
+	running   bool
+	lastBuild *buildapiv1.Build
+	lastError string
+}
+
+// agentStatus is the response body of the agent's status endpoint.
+type agentStatus struct {
+	Running   bool              `json:"running"`
+	LastBuild *buildapiv1.Build `json:"lastBuild,omitempty"`
+	LastError string            `json:"lastError,omitempty"`
+}
+
+// RunBuildAgent runs the builder as a long-lived agent: Build objects are
+// submitted over HTTP instead of the one-shot BUILD environment variable,
+// executed with the regular Docker or S2I pipeline, and their logs streamed
+// back in the response. This allows dedicated build machines outside the
+// cluster to execute builds on behalf of it.
+func RunBuildAgent(out io.Writer) error {
+	listen := os.Getenv(BuildAgentListenEnvVar)
+	if len(listen) == 0 {
+		listen = defaultBuildAgentListen
+	}
+	agent := &buildAgent{out: out}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", agent.serveHealth)
+	mux.HandleFunc("/status", agent.serveStatus)
+	mux.HandleFunc("/build", agent.serveBuild)
+	fmt.Fprintf(out, "Build agent listening on %s\n", listen)
+	return http.ListenAndServe(listen, mux)
+}
+
+func (a *buildAgent) serveHealth(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+func (a *buildAgent) serveStatus(w http.ResponseWriter, r *http.Request) {
+	a.mutex.Lock()
+	status := agentStatus{Running: a.running, LastBuild: a.lastBuild, LastError: a.lastError}
+	a.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// serveBuild accepts a Build object, runs it, and streams the build log as
+// the response body. The response status code reflects only whether the
+// build was accepted; the build outcome is reported in the final log line
+// and through the status endpoint.
+func (a *buildAgent) serveBuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAgentBuildBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot read the build definition: %v", err), http.StatusBadRequest)
+		return
+	}
+	build, err := decodeBuild(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot parse the build definition: %v", err), http.StatusBadRequest)
+		return
+	}
+	var b builder
+	switch {
+	case build.Spec.Strategy.DockerStrategy != nil:
+		b = dockerBuilder{}
+	case build.Spec.Strategy.SourceStrategy != nil:
+		b = s2iBuilder{}
+	default:
+		http.Error(w, "only Docker and Source strategy builds are supported", http.StatusBadRequest)
+		return
+	}
+
+	a.mutex.Lock()
+	if a.running {
+		a.mutex.Unlock()
+		http.Error(w, "a build is already running", http.StatusConflict)
+		return
+	}
+	a.running = true
+	a.lastBuild = build
+	a.lastError = ""
+	a.mutex.Unlock()
+
+	glog.V(0).Infof("Running build %s/%s for a remote client", build.Namespace, build.Name)
+	// The build pipeline is driven by the environment, so the submitted
+	// object replaces the one-shot definition for the duration of the build.
+	os.Setenv("BUILD", string(body))
+	os.Unsetenv("BUILD_FILE")
+
+	stream := &flushWriter{out: w}
+	err = runBuild(io.MultiWriter(a.out, stream), b)
+
+	a.mutex.Lock()
+	a.running = false
+	if err != nil {
+		a.lastError = err.Error()
+	}
+	a.mutex.Unlock()
+
+	if err != nil {
+		fmt.Fprintf(stream, "build error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(stream, "Build %s/%s completed\n", build.Namespace, build.Name)
+}
+
+// flushWriter streams writes to an HTTP response as they happen, so remote
+// clients see build progress instead of one buffered blob at the end.
+type flushWriter struct {
+	out http.ResponseWriter
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	if flusher, ok := w.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
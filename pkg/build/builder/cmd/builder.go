@@ -1,32 +1,41 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
 
 	istorage "github.com/containers/image/storage"
 	"github.com/containers/image/types"
 	"github.com/containers/storage"
-	realglog "github.com/golang/glog"
 
 	s2iapi "github.com/openshift/source-to-image/pkg/api"
 	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
 
 	"github.com/openshift/library-go/pkg/git"
-	"github.com/openshift/library-go/pkg/serviceability"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 
 	buildscheme "github.com/openshift/client-go/build/clientset/versioned/scheme"
 	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+	corev1 "k8s.io/api/core/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 
 	buildapiv1 "github.com/openshift/api/build/v1"
 	bld "github.com/openshift/builder/pkg/build/builder"
 	"github.com/openshift/builder/pkg/build/builder/cmd/scmauth"
+	"github.com/openshift/builder/pkg/build/builder/logger"
 	"github.com/openshift/builder/pkg/build/builder/timing"
 	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 	utilglog "github.com/openshift/builder/pkg/build/builder/util/glog"
@@ -59,24 +68,104 @@ type builderConfig struct {
 	store           storage.Store
 }
 
+// loadBuildDefinition returns the serialized Build object this pod is meant
+// to run. It prefers a mounted file named by BUILD_FILE, because very large
+// Build objects - big inline Dockerfiles, many environment variables - can
+// exceed what the environment can carry, and falls back to the traditional
+// BUILD environment variable.
+func loadBuildDefinition() (string, error) {
+	if buildFile := os.Getenv("BUILD_FILE"); len(buildFile) != 0 {
+		content, err := ioutil.ReadFile(buildFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the build definition from %s: %v", buildFile, err)
+		}
+		glog.V(2).Infof("Reading the build definition from %s", buildFile)
+		return string(content), nil
+	}
+	return os.Getenv("BUILD"), nil
+}
+
+// decodeBuild parses a serialized Build object. JSON and YAML encodings are
+// both accepted, and Build objects from other build.openshift.io API
+// versions are decoded as v1 on a best-effort basis, easing standalone use
+// of the builder binaries outside a matching OpenShift release.
+func decodeBuild(data []byte) (*buildapiv1.Build, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] != '{' {
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse build string: %v", err)
+		}
+		data = converted
+	}
+
+	build := &buildapiv1.Build{}
+	obj, _, err := buildJSONCodec.Decode(data, nil, build)
+	if err != nil {
+		converted, convErr := convertBuildToV1(data)
+		if convErr != nil {
+			return nil, fmt.Errorf("unable to parse build string: %v", err)
+		}
+		data = converted
+		obj, _, err = buildJSONCodec.Decode(data, nil, build)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse build string: %v", err)
+		}
+	}
+	build, ok := obj.(*buildapiv1.Build)
+	if !ok {
+		return nil, fmt.Errorf("build string %s is not a build: %#v", string(data), obj)
+	}
+	return build, nil
+}
+
+// convertBuildToV1 rewrites a Build object from another build.openshift.io
+// API version so the v1 codec accepts it. Fields the v1 type does not know
+// are dropped, which is acceptable for the standalone and testing uses this
+// serves.
+func convertBuildToV1(data []byte) ([]byte, error) {
+	var typeMeta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.Kind != "Build" || !strings.HasPrefix(typeMeta.APIVersion, buildapiv1.GroupName+"/") {
+		return nil, fmt.Errorf("no conversion for %s, kind %s", typeMeta.APIVersion, typeMeta.Kind)
+	}
+	glog.V(0).Infof("warning: Interpreting a Build object of version %s as %s; unrecognized fields are ignored", typeMeta.APIVersion, buildapiv1.SchemeGroupVersion)
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	document["apiVersion"] = buildapiv1.SchemeGroupVersion.String()
+	return json.Marshal(document)
+}
+
 func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderConfig, error) {
 	cfg := &builderConfig{}
 	var err error
 
 	cfg.out = out
 
-	buildStr := os.Getenv("BUILD")
+	// The structured configuration must be projected onto the environment
+	// before anything reads from it.
+	if err := applyBuilderOptions(); err != nil {
+		return nil, err
+	}
 
-	cfg.build = &buildapiv1.Build{}
+	if err := bld.CheckImageEncryptionSupport(); err != nil {
+		return nil, err
+	}
 
-	obj, _, err := buildJSONCodec.Decode([]byte(buildStr), nil, cfg.build)
+	buildStr, err := loadBuildDefinition()
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse build string: %v", err)
+		return nil, err
 	}
-	ok := false
-	cfg.build, ok = obj.(*buildapiv1.Build)
-	if !ok {
-		return nil, fmt.Errorf("build string %s is not a build: %#v", buildStr, obj)
+
+	cfg.build, err = decodeBuild([]byte(buildStr))
+	if err != nil {
+		return nil, err
 	}
 	if glog.Is(4) {
 		redactedBuild := builderutil.SafeForLoggingBuild(cfg.build)
@@ -88,10 +177,46 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 		}
 	}
 
+	logger.ApplyBuildLogLevel(cfg.build)
+
+	if err := bld.LoadFeatureFlags(cfg.build); err != nil {
+		return nil, err
+	}
+
+	bld.EnforceFIPSMode(cfg.build)
+
+	if err := bld.ResolveValueFromEnv(cfg.build); err != nil {
+		return nil, err
+	}
+
+	if err := bld.ConfigureBuildWorkDir(); err != nil {
+		return nil, err
+	}
+
+	if err := bld.ConfigureBuildDNS(); err != nil {
+		return nil, err
+	}
+
+	if err := bld.StartEgressProxy(); err != nil {
+		return nil, err
+	}
+
+	if err := bld.StartHealthEndpoint(); err != nil {
+		glog.V(0).Infof("warning: Unable to start health endpoint: %v", err)
+	}
+
+	if err := bld.StartLogStreamService(); err != nil {
+		glog.V(0).Infof("warning: Unable to start the log stream service: %v", err)
+	}
+
 	// sourceSecretsDir (SOURCE_SECRET_PATH)
 	cfg.sourceSecretDir = os.Getenv("SOURCE_SECRET_PATH")
 
 	if needsDocker {
+		// Registry access must honor the same proxy configuration that is
+		// passed to git, so that pulls and pushes work in proxied clusters.
+		bld.SetupRegistryProxy(cfg.build)
+
 		if _, ok := os.LookupEnv("DOCKER_HOST"); ok {
 			// dockerClient and dockerEndpoint (DOCKER_HOST)
 			// usually not set, defaults to docker socket
@@ -116,8 +241,15 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 					systemContext.SignaturePolicyPath = signaturePolicyPath
 				}
 			}
+			if err := bld.ApplyPlatformChoice(&systemContext, cfg.build); err != nil {
+				return nil, err
+			}
 
 			storeOptions := storage.DefaultStoreOptions
+			if graphRoot, runRoot := bld.StorageRootOverride(); len(graphRoot) != 0 {
+				storeOptions.GraphRoot = graphRoot
+				storeOptions.RunRoot = runRoot
+			}
 			if driver, ok := os.LookupEnv("BUILD_STORAGE_DRIVER"); ok {
 				storeOptions.GraphDriverName = driver
 			}
@@ -126,6 +258,15 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 					storage.ReloadConfigurationFile(storageConfPath, &storeOptions)
 				}
 			}
+			if stores := bld.AdditionalImageStores(); len(stores) > 0 {
+				driver := storeOptions.GraphDriverName
+				if len(driver) == 0 {
+					driver = "overlay"
+				}
+				for _, store := range stores {
+					storeOptions.GraphDriverOptions = append(storeOptions.GraphDriverOptions, fmt.Sprintf("%s.imagestore=%s", driver, store))
+				}
+			}
 
 			store, err := storage.GetStore(storeOptions)
 			cfg.store = store
@@ -139,7 +280,7 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 			}
 			istorage.Transport.SetStore(store)
 
-			dockerClient, err := bld.GetDaemonlessClient(systemContext, store, os.Getenv("BUILD_ISOLATION"))
+			dockerClient, err := bld.GetBuildBackendClient(os.Getenv(bld.BuildBackendEnvVar), systemContext, store, bld.IsolationSpec(cfg.build))
 			if err != nil {
 				return nil, fmt.Errorf("no daemonless store: %v", err)
 			}
@@ -148,10 +289,32 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 		}
 	}
 
-	// buildsClient (KUBERNETES_SERVICE_HOST, KUBERNETES_SERVICE_PORT)
-	clientConfig, err := restclient.InClusterConfig()
-	if err != nil {
-		return nil, fmt.Errorf("cannot connect to the server: %v", err)
+	// buildsClient (KUBECONFIG or KUBERNETES_SERVICE_HOST, KUBERNETES_SERVICE_PORT)
+	standalone := bld.StandaloneFromEnvironment()
+	var clientConfig *restclient.Config
+	if !standalone {
+		if kubeconfig := os.Getenv("KUBECONFIG"); len(kubeconfig) != 0 {
+			clientConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load kubeconfig %s: %v", kubeconfig, err)
+			}
+		} else if len(os.Getenv("KUBERNETES_SERVICE_HOST")) == 0 {
+			glog.V(0).Infof("warning: No in-cluster configuration found, running in standalone mode")
+			standalone = true
+		} else {
+			clientConfig, err = restclient.InClusterConfig()
+			if err != nil {
+				return nil, fmt.Errorf("cannot connect to the server: %v", err)
+			}
+		}
+	}
+	if standalone {
+		standaloneClient, err := bld.NewStandaloneBuildsClient(cfg.build)
+		if err != nil {
+			return nil, err
+		}
+		cfg.buildsClient = standaloneClient
+		return cfg, nil
 	}
 	buildsClient, err := buildclientv1.NewForConfig(clientConfig)
 	if err != nil {
@@ -159,6 +322,18 @@ func newBuilderConfigFromEnvironment(out io.Writer, needsDocker bool) (*builderC
 	}
 	cfg.buildsClient = buildsClient.Builds(cfg.build.Namespace)
 
+	// Post Events for build milestones to the build's namespace, so they
+	// show up in `oc describe build`. The build can proceed without them.
+	eventsClient, err := corev1client.NewForConfig(clientConfig)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to create an event client, build events will not be posted: %v", err)
+	} else {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: eventsClient.Events(cfg.build.Namespace)})
+		recorder := broadcaster.NewRecorder(buildscheme.Scheme, corev1.EventSource{Component: "builder"})
+		bld.SetBuildEventRecorder(recorder, cfg.build)
+	}
+
 	return cfg, nil
 }
 
@@ -218,12 +393,18 @@ func (c *builderConfig) clone() error {
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(secretTmpDir)
+	builderutil.TrackSecretTemp(secretTmpDir)
+	defer builderutil.CleanupSecretTemps()
 
 	gitClient := git.NewRepositoryWithEnv(gitEnv)
 
+	if err := bld.RunBuildHooks(bld.HookStagePreClone, c.build); err != nil {
+		return err
+	}
+
+	bld.RecordBuildProgress(buildapiv1.StageFetchInputs)
 	buildDir := bld.InputContentPath
-	sourceInfo, err := bld.GitClone(ctx, gitClient, c.build.Spec.Source.Git, c.build.Spec.Revision, buildDir)
+	sourceInfo, err := bld.FetchSource(ctx, gitClient, c.build, buildDir)
 	if err != nil {
 		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
 		c.build.Status.Reason = buildapiv1.StatusReasonFetchSourceFailed
@@ -233,13 +414,30 @@ func (c *builderConfig) clone() error {
 
 	if sourceInfo != nil {
 		sourceRev = bld.GetSourceRevision(c.build, sourceInfo)
+		bld.RecordResolvedRevision(c.build, buildDir, sourceInfo)
+	}
+
+	if err := bld.WritePromotionHints(c.build, sourceInfo); err != nil {
+		glog.V(0).Infof("warning: Ignoring promotion hints: %v", err)
+	}
+
+	if err := bld.VerifySourceRevision(c.build, sourceInfo); err != nil {
+		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		c.build.Status.Reason = bld.StatusReasonSourceRevisionMismatch
+		c.build.Status.Message = builderutil.StatusMessageSourceRevisionMismatch
+		return err
 	}
 
 	err = bld.ExtractInputBinary(os.Stdin, c.build.Spec.Source.Binary, buildDir)
 	if err != nil {
 		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
-		c.build.Status.Reason = buildapiv1.StatusReasonFetchSourceFailed
-		c.build.Status.Message = builderutil.StatusMessageFetchSourceFailed
+		if bld.IsInputChecksumMismatch(err) {
+			c.build.Status.Reason = bld.StatusReasonInputChecksumMismatch
+			c.build.Status.Message = builderutil.StatusMessageInputChecksumMismatch
+		} else {
+			c.build.Status.Reason = buildapiv1.StatusReasonFetchSourceFailed
+			c.build.Status.Message = builderutil.StatusMessageFetchSourceFailed
+		}
 		return err
 	}
 
@@ -253,7 +451,28 @@ func (c *builderConfig) clone() error {
 		}
 	}
 
-	return nil
+	if err := c.enforceContextDirPolicy(); err != nil {
+		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		c.build.Status.Reason = buildapiv1.StatusReasonInvalidContextDirectory
+		c.build.Status.Message = builderutil.StatusMessageContextDirNotAllowed
+		return err
+	}
+
+	if err := bld.EnforceSecretScan(buildDir); err != nil {
+		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		c.build.Status.Reason = bld.StatusReasonSecretDetectedInSource
+		c.build.Status.Message = builderutil.StatusMessageSecretDetectedInSource
+		return err
+	}
+
+	return bld.RunBuildHooks(bld.HookStagePostClone, c.build)
+}
+
+// enforceContextDirPolicy rejects the build if a cluster-supplied policy file
+// restricts the context directories this namespace may build from the source
+// repository.
+func (c *builderConfig) enforceContextDirPolicy() error {
+	return bld.EnforceContextDirPolicy(os.Getenv(bld.ContextDirPolicyPathEnvVar), c.build)
 }
 
 func (c *builderConfig) extractImageContent() error {
@@ -268,21 +487,64 @@ func (c *builderConfig) extractImageContent() error {
 }
 
 // execute is responsible for running a build
-func (c *builderConfig) execute(b builder) error {
+func (c *builderConfig) execute(b builder) (err error) {
+	// The summary reflects the final status, so it is written after every
+	// other exit path has run.
+	defer func() { bld.WriteBuildSummary(c.build, err) }()
+
+	// Status updates that could not be delivered while the API server was
+	// unreachable are retried before the builder exits.
+	defer bld.FlushBuildStatusUpdates(c.build, c.buildsClient)
+
 	cgLimits, err := bld.GetCGroupLimits()
 	if err != nil {
 		return fmt.Errorf("failed to retrieve cgroup limits: %v", err)
 	}
 	glog.V(4).Infof("Running build with cgroup limits: %#v", *cgLimits)
 
+	if err := bld.ValidateOutputImageReference(c.build); err != nil {
+		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		c.build.Status.Reason = bld.StatusReasonInvalidOutputReference
+		c.build.Status.Message = builderutil.StatusMessageInvalidOutputReference
+		bld.HandleBuildStatusUpdate(c.build, c.buildsClient, nil)
+		return err
+	}
+
+	cacheLock, err := bld.AcquireCacheLock(c.build, c.buildsClient)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache volume: %v", err)
+	}
+	defer cacheLock.Release()
+
+	if err := bld.RunBuildHooks(bld.HookStagePreBuild, c.build); err != nil {
+		return err
+	}
+
+	bld.ReportCommitStatus(c.build, bld.CommitStatusPending)
+
 	if err := b.Build(c.dockerClient, c.dockerEndpoint, c.buildsClient, c.build, cgLimits); err != nil {
+		bld.ReportCommitStatus(c.build, bld.CommitStatusFailure)
 		return fmt.Errorf("build error: %v", err)
 	}
 
+	if err := bld.CheckEgressPolicy(); err != nil {
+		c.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		c.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+		bld.HandleBuildStatusUpdate(c.build, c.buildsClient, nil)
+		bld.ReportCommitStatus(c.build, bld.CommitStatusFailure)
+		return err
+	}
+
 	if c.build.Spec.Output.To == nil || len(c.build.Spec.Output.To.Name) == 0 {
 		fmt.Fprintf(c.out, "Build complete, no image push requested\n")
 	}
 
+	if err := bld.EmitProvenance(c.build); err != nil {
+		glog.V(0).Infof("warning: Unable to emit the build provenance: %v", err)
+	}
+
+	bld.ReportCommitStatus(c.build, bld.CommitStatusSuccess)
+
 	return nil
 }
 
@@ -300,6 +562,20 @@ func (s2iBuilder) Build(dockerClient bld.DockerClient, sock string, buildsClient
 	return bld.NewS2IBuilder(dockerClient, sock, buildsClient, build, cgLimits).Build()
 }
 
+type customBuilder struct{}
+
+// Build starts a Custom strategy build.
+func (customBuilder) Build(dockerClient bld.DockerClient, sock string, buildsClient buildclientv1.BuildInterface, build *buildapiv1.Build, cgLimits *s2iapi.CGroupLimits) error {
+	return bld.NewCustomBuilder(dockerClient, buildsClient, build, cgLimits).Build()
+}
+
+type scriptBuilder struct{}
+
+// Build starts a script build.
+func (scriptBuilder) Build(dockerClient bld.DockerClient, sock string, buildsClient buildclientv1.BuildInterface, build *buildapiv1.Build, cgLimits *s2iapi.CGroupLimits) error {
+	return bld.NewScriptBuilder(dockerClient, buildsClient, build, cgLimits).Build()
+}
+
 func runBuild(out io.Writer, builder builder) error {
 	cfg, err := newBuilderConfigFromEnvironment(out, true)
 	if err != nil {
@@ -308,55 +584,32 @@ func runBuild(out io.Writer, builder builder) error {
 	if cfg.cleanup != nil {
 		defer cfg.cleanup()
 	}
+	// Custom strategy and script builds are dispatched by the build
+	// definition rather than by a dedicated command, so either builder
+	// binary handles them.
+	if cfg.build.Spec.Strategy.CustomStrategy != nil {
+		builder = customBuilder{}
+	} else if bld.HasScriptCommands() {
+		builder = scriptBuilder{}
+	}
 	return cfg.execute(builder)
 }
 
 // RunDockerBuild creates a docker builder and runs its build
 func RunDockerBuild(out io.Writer) error {
-	realglog.V(5)
-	serviceability.InitLogrus("DEBUG")
-	/*
-		switch {
-		case glog.Is(4):
-			serviceability.InitLogrus("DEBUG")
-		case glog.Is(2):
-			serviceability.InitLogrus("INFO")
-		case glog.Is(0):
-			serviceability.InitLogrus("WARN")
-		}
-	*/
+	logger.Init()
 	return runBuild(out, dockerBuilder{})
 }
 
 // RunS2IBuild creates a S2I builder and runs its build
 func RunS2IBuild(out io.Writer) error {
-	serviceability.InitLogrus("DEBUG")
-	/*
-		switch {
-		case glog.Is(4):
-			serviceability.InitLogrus("DEBUG")
-		case glog.Is(2):
-			serviceability.InitLogrus("INFO")
-		case glog.Is(0):
-			serviceability.InitLogrus("WARN")
-		}
-	*/
+	logger.Init()
 	return runBuild(out, s2iBuilder{})
 }
 
 // RunGitClone performs a git clone using the build defined in the environment
 func RunGitClone(out io.Writer) error {
-	serviceability.InitLogrus("DEBUG")
-	/*
-		switch {
-		case glog.Is(4):
-			serviceability.InitLogrus("DEBUG")
-		case glog.Is(2):
-			serviceability.InitLogrus("INFO")
-		case glog.Is(0):
-			serviceability.InitLogrus("WARN")
-		}
-	*/
+	logger.Init()
 
 	cfg, err := newBuilderConfigFromEnvironment(out, false)
 	if err != nil {
@@ -376,17 +629,7 @@ func RunGitClone(out io.Writer) error {
 // and also adds some env and label values to the dockerfile based on
 // the build information.
 func RunManageDockerfile(out io.Writer) error {
-	serviceability.InitLogrus("DEBUG")
-	/*
-		switch {
-		case glog.Is(4):
-			serviceability.InitLogrus("DEBUG")
-		case glog.Is(2):
-			serviceability.InitLogrus("INFO")
-		case glog.Is(0):
-			serviceability.InitLogrus("WARN")
-		}
-	*/
+	logger.Init()
 
 	cfg, err := newBuilderConfigFromEnvironment(out, false)
 	if err != nil {
@@ -401,17 +644,7 @@ func RunManageDockerfile(out io.Writer) error {
 // RunExtractImageContent extracts files from existing images
 // into the build working directory.
 func RunExtractImageContent(out io.Writer) error {
-	serviceability.InitLogrus("DEBUG")
-	/*
-		switch {
-		case glog.Is(4):
-			serviceability.InitLogrus("DEBUG")
-		case glog.Is(2):
-			serviceability.InitLogrus("INFO")
-		case glog.Is(0):
-			serviceability.InitLogrus("WARN")
-		}
-	*/
+	logger.Init()
 	cfg, err := newBuilderConfigFromEnvironment(out, true)
 	if err != nil {
 		return err
@@ -49,12 +49,21 @@ func (h *Helper) GetDockerAuth(imageName, authType string) (docker.AuthConfigura
 	var err error
 
 	if pathForAuthType := os.Getenv(authType); len(pathForAuthType) > 0 {
-		searchPaths = []string{pathForAuthType}
-	} else {
-		searchPaths = getExtraSearchPaths()
+		// The variable may name several mounted secrets, separated like PATH;
+		// earlier entries take precedence.
+		searchPaths = filepath.SplitList(pathForAuthType)
 	}
+	// Node-level credentials are only consulted after any mounted secrets.
+	searchPaths = append(searchPaths, getExtraSearchPaths()...)
 	glog.V(3).Infof("Getting docker auth in paths : %v", searchPaths)
-	cfg, err = GetDockerConfig(searchPaths)
+
+	// Per-registry credential helpers take precedence over static auth
+	// entries, matching docker's behavior.
+	if auth, ok := getCredentialHelperAuth(searchPaths, imageName, false); ok {
+		return auth, true
+	}
+
+	cfg, err = GetMergedDockerConfig(searchPaths)
 	if err != nil {
 		glogreal.Errorf("Reading docker config from %v failed: %v", searchPaths, err)
 		return docker.AuthConfiguration{}, false
@@ -64,6 +73,10 @@ func (h *Helper) GetDockerAuth(imageName, authType string) (docker.AuthConfigura
 	keyring.Add(cfg)
 	authConfs, found := keyring.Lookup(imageName)
 	if !found || len(authConfs) == 0 {
+		// A default credential store covers registries with no static entry.
+		if auth, ok := getCredentialHelperAuth(searchPaths, imageName, true); ok {
+			return auth, true
+		}
 		return docker.AuthConfiguration{}, false
 	}
 	glog.V(3).Infof("Using %s user for Docker authentication for image %s", authConfs[0].Username, imageName)
@@ -113,6 +126,39 @@ func GetDockerConfig(path []string) (cfg credentialprovider.DockerConfig, err er
 	return cfg, err
 }
 
+// GetMergedDockerConfig merges the docker configs found in the given paths
+// into a single keyring. Earlier paths take precedence per registry; the
+// credential source selected for each registry is logged at V(3). An error is
+// only returned when none of the paths yields a config.
+func GetMergedDockerConfig(paths []string) (credentialprovider.DockerConfig, error) {
+	merged := credentialprovider.DockerConfig{}
+	var lastErr error
+	found := false
+	for _, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		cfg, err := GetDockerConfig([]string{path})
+		if err != nil {
+			glog.V(3).Infof("No docker config in %s: %v", path, err)
+			lastErr = err
+			continue
+		}
+		found = true
+		for registry, entry := range cfg {
+			if _, ok := merged[registry]; ok {
+				continue
+			}
+			merged[registry] = entry
+			glog.V(3).Infof("Using credentials from %s for registry %q", path, registry)
+		}
+	}
+	if !found && lastErr != nil {
+		return nil, lastErr
+	}
+	return merged, nil
+}
+
 // ReadDockerConfigJsonFileGeneratedFromSecret return DockerConfig by reading specific file named .dockerconfigjson
 // generated by secret from given paths.
 func ReadDockerConfigJsonFileGeneratedFromSecret(path []string) (cfg credentialprovider.DockerConfig, err error) {
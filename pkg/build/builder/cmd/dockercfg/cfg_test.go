@@ -50,3 +50,45 @@ func TestGetDockerAuth(t *testing.T) {
 		return
 	}
 }
+
+func TestGetMergedDockerConfig(t *testing.T) {
+	tmpDirPath, err := ioutil.TempDir("", "test_merge_")
+	if err != nil {
+		t.Fatalf("Creating tmp dir fail: %v", err)
+	}
+	defer os.RemoveAll(tmpDirPath)
+
+	configs := map[string]string{
+		"primary":   "{ \"auths\": { \"shared.tld\":{\"auth\":\"Zm9vOmJhcgo=\"}, \"primary.tld\":{\"auth\":\"Zm9vOmJhcgo=\"}}}",
+		"secondary": "{ \"auths\": { \"shared.tld\":{\"auth\":\"b3RoZXI6Y3JlZHMK\"}, \"secondary.tld\":{\"auth\":\"Zm9vOmJhcgo=\"}}}",
+	}
+	var paths []string
+	for _, name := range []string{"primary", "secondary"} {
+		dir := filepath.Join(tmpDirPath, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Creating dir fail: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(configs[name]), 0644); err != nil {
+			t.Fatalf("Writing config fail: %v", err)
+		}
+		paths = append(paths, dir)
+	}
+
+	cfg, err := GetMergedDockerConfig(paths)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, registry := range []string{"shared.tld", "primary.tld", "secondary.tld"} {
+		if _, ok := cfg[registry]; !ok {
+			t.Errorf("registry %s missing from merged config", registry)
+		}
+	}
+	// Earlier paths win for registries both configs define.
+	if cfg["shared.tld"].Username != "foo" {
+		t.Errorf("unexpected user %q for shared.tld", cfg["shared.tld"].Username)
+	}
+
+	if _, err := GetMergedDockerConfig([]string{filepath.Join(tmpDirPath, "missing")}); err == nil {
+		t.Error("expected an error when no path yields a config")
+	}
+}
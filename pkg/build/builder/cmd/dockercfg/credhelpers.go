@@ -0,0 +1,107 @@
+package dockercfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// credHelperConfig is the subset of a docker config file referencing
+// credential helpers: credHelpers maps a registry to a helper name, and
+// credsStore names a default helper for registries without a static entry.
+type credHelperConfig struct {
+	CredsStore  string            `json:"credsStore,omitempty"`
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+}
+
+// readCredHelperConfig reads credential helper references from the docker
+// config mounted at path, returning nil when there are none.
+func readCredHelperConfig(path string) *credHelperConfig {
+	cfgPath := GetDockercfgFile(path)
+	if len(cfgPath) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return nil
+	}
+	cfg := &credHelperConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil
+	}
+	if len(cfg.CredsStore) == 0 && len(cfg.CredHelpers) == 0 {
+		return nil
+	}
+	return cfg
+}
+
+// runCredentialHelper executes a docker credential helper (e.g.
+// docker-credential-ecr-login shipped in the builder image) and parses the
+// credentials it returns for the registry.
+func runCredentialHelper(helper, registry string) (docker.AuthConfiguration, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	out, err := cmd.Output()
+	if err != nil {
+		return docker.AuthConfiguration{}, fmt.Errorf("credential helper %s: %v", helper, err)
+	}
+	response := struct {
+		Username string
+		Secret   string
+	}{}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return docker.AuthConfiguration{}, fmt.Errorf("credential helper %s: unexpected output: %v", helper, err)
+	}
+	return docker.AuthConfiguration{
+		Username:      response.Username,
+		Password:      response.Secret,
+		ServerAddress: registry,
+	}, nil
+}
+
+// registryHostname extracts the registry host from an image name, defaulting
+// to the Docker Hub for bare names.
+func registryHostname(imageName string) string {
+	parts := strings.SplitN(imageName, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+	return "index.docker.io"
+}
+
+// getCredentialHelperAuth executes a credential helper covering the image's
+// registry, if the docker configs in searchPaths reference one. The default
+// credsStore is only consulted when includeDefaultStore is set, so callers
+// can give per-registry helpers and static entries their documented
+// precedence.
+func getCredentialHelperAuth(searchPaths []string, imageName string, includeDefaultStore bool) (docker.AuthConfiguration, bool) {
+	registry := registryHostname(imageName)
+	for _, path := range searchPaths {
+		if len(path) == 0 {
+			continue
+		}
+		cfg := readCredHelperConfig(path)
+		if cfg == nil {
+			continue
+		}
+		helper := cfg.CredHelpers[registry]
+		if len(helper) == 0 && includeDefaultStore {
+			helper = cfg.CredsStore
+		}
+		if len(helper) == 0 {
+			continue
+		}
+		auth, err := runCredentialHelper(helper, registry)
+		if err != nil {
+			glog.V(0).Infof("warning: %v", err)
+			continue
+		}
+		glog.V(3).Infof("Using credential helper %s for registry %q", helper, registry)
+		return auth, true
+	}
+	return docker.AuthConfiguration{}, false
+}
@@ -0,0 +1,68 @@
+package dockercfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryHostname(t *testing.T) {
+	tests := map[string]string{
+		"centos:7":                                  "index.docker.io",
+		"library/centos:7":                          "index.docker.io",
+		"quay.io/openshift/builder:latest":          "quay.io",
+		"localhost/test":                            "localhost",
+		"registry:5000/ns/image":                    "registry:5000",
+		"12345.dkr.ecr.us-east-1.amazonaws.com/app": "12345.dkr.ecr.us-east-1.amazonaws.com",
+	}
+	for imageName, expected := range tests {
+		if got := registryHostname(imageName); got != expected {
+			t.Errorf("registryHostname(%q) = %q; want %q", imageName, got, expected)
+		}
+	}
+}
+
+func TestGetCredentialHelperAuth(t *testing.T) {
+	tmpDirPath, err := ioutil.TempDir("", "test_credhelper_")
+	if err != nil {
+		t.Fatalf("Creating tmp dir fail: %v", err)
+	}
+	defer os.RemoveAll(tmpDirPath)
+
+	// A fake helper binary on PATH producing fixed credentials.
+	helperPath := filepath.Join(tmpDirPath, "docker-credential-test")
+	helper := "#!/bin/sh\necho '{\"Username\":\"token-user\",\"Secret\":\"token-secret\"}'\n"
+	if err := ioutil.WriteFile(helperPath, []byte(helper), 0755); err != nil {
+		t.Fatalf("Writing helper fail: %v", err)
+	}
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", tmpDirPath+string(os.PathListSeparator)+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	cfgDir := filepath.Join(tmpDirPath, "cfg")
+	if err := os.MkdirAll(cfgDir, 0755); err != nil {
+		t.Fatalf("Creating dir fail: %v", err)
+	}
+	content := "{ \"credHelpers\": { \"helper.tld\": \"test\" }, \"credsStore\": \"test\" }"
+	if err := ioutil.WriteFile(filepath.Join(cfgDir, "config.json"), []byte(content), 0644); err != nil {
+		t.Fatalf("Writing config fail: %v", err)
+	}
+
+	auth, ok := getCredentialHelperAuth([]string{cfgDir}, "helper.tld/ns/image:latest", false)
+	if !ok {
+		t.Fatal("expected the per-registry helper to provide credentials")
+	}
+	if auth.Username != "token-user" || auth.Password != "token-secret" {
+		t.Errorf("unexpected credentials %q/%q", auth.Username, auth.Password)
+	}
+
+	// Registries not named in credHelpers only reach the default store when
+	// requested.
+	if _, ok := getCredentialHelperAuth([]string{cfgDir}, "other.tld/image", false); ok {
+		t.Error("did not expect the default store to be consulted")
+	}
+	if _, ok := getCredentialHelperAuth([]string{cfgDir}, "other.tld/image", true); !ok {
+		t.Error("expected the default store to provide credentials")
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ghodss/yaml"
+
+	bld "github.com/openshift/builder/pkg/build/builder"
+)
+
+// BuilderOptionsEnvVar is the name of the environment variable carrying the
+// structured builder configuration, as JSON or YAML. It gathers the
+// settings that were historically spread over many individual BUILD_*
+// variables; those remain supported as deprecated fallbacks for any field
+// the structured configuration leaves empty.
+const BuilderOptionsEnvVar = "BUILD_OPTIONS"
+
+// BuilderOptionsFileEnvVar names a file to read the structured builder
+// configuration from, for configurations too large or too sensitive for the
+// environment.
+const BuilderOptionsFileEnvVar = "BUILD_OPTIONS_FILE"
+
+// builderOptions is the structured builder configuration. Every field maps
+// onto one of the environment variables the builder features are driven by;
+// applying the configuration amounts to setting those variables, so the
+// feature code keeps a single source of truth.
+type builderOptions struct {
+	Storage             storageOptions    `json:"storage,omitempty"`
+	Registries          registriesOptions `json:"registries,omitempty"`
+	Isolation           string            `json:"isolation,omitempty"`
+	SignaturePolicyPath string            `json:"signaturePolicyPath,omitempty"`
+	Proxy               proxyOptions      `json:"proxy,omitempty"`
+	Cache               cacheOptions      `json:"cache,omitempty"`
+}
+
+// storageOptions configures the containers/storage store backing the build.
+type storageOptions struct {
+	Driver   string `json:"driver,omitempty"`
+	ConfPath string `json:"confPath,omitempty"`
+	WorkDir  string `json:"workDir,omitempty"`
+}
+
+// registriesOptions points at the registry configuration files.
+type registriesOptions struct {
+	ConfPath string `json:"confPath,omitempty"`
+	DirPath  string `json:"dirPath,omitempty"`
+}
+
+// proxyOptions configures the proxies used for source and registry access.
+type proxyOptions struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+}
+
+// cacheOptions configures the build caches.
+type cacheOptions struct {
+	InstructionCache       *bool  `json:"instructionCache,omitempty"`
+	ImportRepository       string `json:"importRepository,omitempty"`
+	ExportRepository       string `json:"exportRepository,omitempty"`
+	ContextCachePath       string `json:"contextCachePath,omitempty"`
+	S2IDependencyCachePath string `json:"s2iDependencyCachePath,omitempty"`
+}
+
+// applyBuilderOptions reads the structured builder configuration, if any,
+// and projects it onto the environment variables the rest of the builder
+// reads. A variable that is already set is overridden: the structured
+// configuration is the authoritative source, the individual variables the
+// deprecated fallback.
+func applyBuilderOptions() error {
+	data, err := loadBuilderOptions()
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	options := builderOptions{}
+	if err := yaml.Unmarshal(data, &options); err != nil {
+		return fmt.Errorf("unable to parse the builder configuration: %v", err)
+	}
+
+	setFromOption("BUILD_STORAGE_DRIVER", options.Storage.Driver)
+	setFromOption("BUILD_STORAGE_CONF_PATH", options.Storage.ConfPath)
+	setFromOption(bld.BuildWorkDirEnvVar, options.Storage.WorkDir)
+	setFromOption("BUILD_REGISTRIES_CONF_PATH", options.Registries.ConfPath)
+	setFromOption("BUILD_REGISTRIES_DIR_PATH", options.Registries.DirPath)
+	setFromOption(bld.IsolationEnvVar, options.Isolation)
+	setFromOption("BUILD_SIGNATURE_POLICY_PATH", options.SignaturePolicyPath)
+	setFromOption("HTTP_PROXY", options.Proxy.HTTPProxy)
+	setFromOption("HTTPS_PROXY", options.Proxy.HTTPSProxy)
+	setFromOption("NO_PROXY", options.Proxy.NoProxy)
+	if options.Cache.InstructionCache != nil {
+		setFromOption(bld.InstructionCacheEnvVar, fmt.Sprintf("%t", *options.Cache.InstructionCache))
+	}
+	setFromOption(bld.CacheFromEnvVar, options.Cache.ImportRepository)
+	setFromOption(bld.CacheToEnvVar, options.Cache.ExportRepository)
+	setFromOption(bld.ContextCacheEnvVar, options.Cache.ContextCachePath)
+	setFromOption(bld.S2ICachePathEnvVar, options.Cache.S2IDependencyCachePath)
+	return nil
+}
+
+// loadBuilderOptions returns the serialized builder configuration, from the
+// configured file or directly from the environment.
+func loadBuilderOptions() ([]byte, error) {
+	if path := os.Getenv(BuilderOptionsFileEnvVar); len(path) != 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the builder configuration from %s: %v", path, err)
+		}
+		return data, nil
+	}
+	return []byte(os.Getenv(BuilderOptionsEnvVar)), nil
+}
+
+// setFromOption applies one configuration field to its environment
+// variable, warning when it overrides a deprecated per-variable setting.
+func setFromOption(envVar string, value string) {
+	if len(value) == 0 {
+		return
+	}
+	if previous, ok := os.LookupEnv(envVar); ok && previous != value {
+		glog.V(0).Infof("warning: The builder configuration overrides the deprecated %s setting", envVar)
+	}
+	os.Setenv(envVar, value)
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 
 	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 )
 
 const (
@@ -31,6 +33,7 @@ func (s CACert) Setup(baseDir string, context SCMAuthContext) error {
 		return err
 	}
 	defer gitconfig.Close()
+	builderutil.TrackSecretTemp(gitconfig.Name())
 	content := fmt.Sprintf(CACertConfig, filepath.Join(baseDir, CACertName))
 	glog.V(5).Infof("Adding CACert Auth to %s:\n%s\n", gitconfig.Name(), content)
 	gitconfig.WriteString(content)
@@ -0,0 +1,86 @@
+package scmauth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+const (
+	ExtraHeaderName = "extraheader"
+	// BearerTokenSecret holds a bare token; it is sent as an Authorization
+	// bearer header, the form GitHub App and Azure DevOps tokens expect.
+	BearerTokenSecret = "bearerToken"
+	// ExtraHeaderSecret holds a complete header line ("Name: value") for
+	// providers whose token does not travel as a bearer header.
+	ExtraHeaderSecret = "extraHeader"
+	ExtraHeaderConfig = `# extra header auth git config
+[http]
+   extraHeader = %s
+`
+)
+
+// ExtraHeader implements the SCMAuth interface for tokens that git must send
+// as an HTTP header via http.extraHeader, rather than as credentials in the
+// URL.
+type ExtraHeader struct {
+	SourceURL s2igit.URL
+}
+
+// Setup creates a gitconfig fragment that adds the header to every http
+// request git makes.
+func (h ExtraHeader) Setup(baseDir string, context SCMAuthContext) error {
+	// Only apply to https and http URLs
+	if !(h.SourceURL.Type == s2igit.URLTypeURL &&
+		(h.SourceURL.URL.Scheme == "http" || h.SourceURL.URL.Scheme == "https") &&
+		h.SourceURL.URL.Opaque == "") {
+		return nil
+	}
+
+	header, err := readSecret(baseDir, ExtraHeaderSecret)
+	if err != nil {
+		return err
+	}
+	if len(header) == 0 {
+		token, err := readSecret(baseDir, BearerTokenSecret)
+		if err != nil {
+			return err
+		}
+		if len(token) == 0 {
+			return nil
+		}
+		header = "Authorization: Bearer " + token
+	}
+	if !strings.Contains(header, ":") {
+		return fmt.Errorf("the %s secret must contain a complete header line of the form \"Name: value\"", ExtraHeaderSecret)
+	}
+
+	gitconfig, err := ioutil.TempFile("", "extraheadercfg.")
+	if err != nil {
+		return err
+	}
+	defer gitconfig.Close()
+	builderutil.TrackSecretTemp(gitconfig.Name())
+
+	// The header value is a credential; only the fact that one is set is
+	// logged.
+	glog.V(5).Infof("Adding an http.extraHeader credential to %s", gitconfig.Name())
+	fmt.Fprintf(gitconfig, ExtraHeaderConfig, header)
+
+	return ensureGitConfigIncludes(gitconfig.Name(), context)
+}
+
+// Name returns the name of this auth method.
+func (_ ExtraHeader) Name() string {
+	return ExtraHeaderName
+}
+
+// Handles returns true if the secret provides a bearer token or a literal
+// extra header.
+func (_ ExtraHeader) Handles(name string) bool {
+	return name == BearerTokenSecret || name == ExtraHeaderSecret
+}
@@ -0,0 +1,93 @@
+package scmauth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func TestExtraHeaderHandles(t *testing.T) {
+	extraHeader := &ExtraHeader{}
+	if !extraHeader.Handles("bearerToken") {
+		t.Errorf("should handle bearerToken")
+	}
+	if !extraHeader.Handles("extraHeader") {
+		t.Errorf("should handle extraHeader")
+	}
+	if extraHeader.Handles("username") {
+		t.Errorf("should not handle username")
+	}
+}
+
+func TestExtraHeaderSetupBearerToken(t *testing.T) {
+	context := NewDefaultSCMContext()
+	extraHeader := &ExtraHeader{
+		SourceURL: *git.MustParse("https://my.host/git/repo"),
+	}
+	secretDir := secretDir(t, "bearerToken")
+	defer os.RemoveAll(secretDir)
+
+	err := extraHeader.Setup(secretDir, context)
+	gitConfig, _ := context.Get("GIT_CONFIG")
+	defer cleanupConfig(gitConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validateConfig(t, gitConfig, "extraHeader = Authorization: Bearer test")
+}
+
+func TestExtraHeaderSetupLiteralHeader(t *testing.T) {
+	context := NewDefaultSCMContext()
+	extraHeader := &ExtraHeader{
+		SourceURL: *git.MustParse("https://my.host/git/repo"),
+	}
+	secretDir := secretDir(t)
+	defer os.RemoveAll(secretDir)
+	header := "Authorization: Basic dGVzdDp0ZXN0"
+	if err := ioutil.WriteFile(filepath.Join(secretDir, "extraHeader"), []byte(header), 0600); err != nil {
+		t.Fatalf("error creating test file: %v", err)
+	}
+
+	err := extraHeader.Setup(secretDir, context)
+	gitConfig, _ := context.Get("GIT_CONFIG")
+	defer cleanupConfig(gitConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validateConfig(t, gitConfig, "extraHeader = "+header)
+}
+
+func TestExtraHeaderSetupInvalidHeader(t *testing.T) {
+	context := NewDefaultSCMContext()
+	extraHeader := &ExtraHeader{
+		SourceURL: *git.MustParse("https://my.host/git/repo"),
+	}
+	// secretDir writes "test" into the file, which is not a header line.
+	secretDir := secretDir(t, "extraHeader")
+	defer os.RemoveAll(secretDir)
+
+	if err := extraHeader.Setup(secretDir, context); err == nil {
+		t.Fatalf("expected an error for a value that is not a header line")
+	}
+}
+
+func TestExtraHeaderSetupNonHTTP(t *testing.T) {
+	context := NewDefaultSCMContext()
+	extraHeader := &ExtraHeader{
+		SourceURL: *git.MustParse("git@my.host:git/repo.git"),
+	}
+	secretDir := secretDir(t, "bearerToken")
+	defer os.RemoveAll(secretDir)
+
+	err := extraHeader.Setup(secretDir, context)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, gitConfigPresent := context.Get("GIT_CONFIG")
+	if gitConfigPresent {
+		t.Fatalf("git config not expected")
+	}
+}
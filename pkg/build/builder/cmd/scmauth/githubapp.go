@@ -0,0 +1,250 @@
+package scmauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+const (
+	GitHubAppName = "githubapp"
+	// GitHubAppPrivateKeySecret holds the PEM-encoded private key of a
+	// GitHub App. Together with the IDs below, the builder mints
+	// short-lived installation tokens instead of carrying a long-lived
+	// personal access token in the cluster.
+	GitHubAppPrivateKeySecret     = "githubAppPrivateKey"
+	GitHubAppIDSecret             = "githubAppId"
+	GitHubAppInstallationIDSecret = "githubAppInstallationId"
+	// GitHubAppAPIURLSecret overrides the API endpoint, for GitHub
+	// Enterprise Server installations.
+	GitHubAppAPIURLSecret = "githubAppApiUrl"
+
+	defaultGitHubAPIURL = "https://api.github.com"
+	// githubAppJWTLifetime is the validity of the app JWT used to mint
+	// tokens; GitHub caps it at ten minutes.
+	githubAppJWTLifetime = 9 * time.Minute
+	// githubAppRefreshMargin is how long before an installation token
+	// expires that a fresh one is minted, so long clone or submodule
+	// operations never present a stale token.
+	githubAppRefreshMargin = 10 * time.Minute
+)
+
+// GitHubApp implements the SCMAuth interface by exchanging a GitHub App
+// private key for a short-lived installation token before the clone.
+type GitHubApp struct {
+	SourceURL s2igit.URL
+}
+
+// githubAppTokenMinter mints installation tokens for one App installation.
+type githubAppTokenMinter struct {
+	appID          string
+	installationID string
+	apiURL         string
+	privateKey     *rsa.PrivateKey
+}
+
+// Setup mints an installation token, exposes it to git as a basic auth
+// header via http.extraHeader, and keeps refreshing it in the background for
+// operations that outlive the token.
+func (g GitHubApp) Setup(baseDir string, context SCMAuthContext) error {
+	// Only apply to https and http URLs
+	if !(g.SourceURL.Type == s2igit.URLTypeURL &&
+		(g.SourceURL.URL.Scheme == "http" || g.SourceURL.URL.Scheme == "https") &&
+		g.SourceURL.URL.Opaque == "") {
+		return nil
+	}
+
+	minter, err := newGitHubAppTokenMinter(baseDir)
+	if err != nil {
+		return err
+	}
+	token, expiresAt, err := minter.mintToken(http.DefaultClient)
+	if err != nil {
+		return fmt.Errorf("unable to mint a GitHub App installation token: %v", err)
+	}
+
+	gitconfig, err := ioutil.TempFile("", "githubappcfg.")
+	if err != nil {
+		return err
+	}
+	builderutil.TrackSecretTemp(gitconfig.Name())
+	fmt.Fprintf(gitconfig, ExtraHeaderConfig, githubAppAuthHeader(token))
+	if err := gitconfig.Close(); err != nil {
+		return err
+	}
+	glog.V(5).Infof("Minted a GitHub App installation token valid until %s", expiresAt.Format(time.RFC3339))
+
+	go minter.refreshInto(gitconfig.Name(), expiresAt)
+
+	return ensureGitConfigIncludes(gitconfig.Name(), context)
+}
+
+// newGitHubAppTokenMinter reads the App credentials from the secret.
+func newGitHubAppTokenMinter(baseDir string) (*githubAppTokenMinter, error) {
+	appID, err := readSecret(baseDir, GitHubAppIDSecret)
+	if err != nil {
+		return nil, err
+	}
+	installationID, err := readSecret(baseDir, GitHubAppInstallationIDSecret)
+	if err != nil {
+		return nil, err
+	}
+	if len(appID) == 0 || len(installationID) == 0 {
+		return nil, fmt.Errorf("a GitHub App secret must provide both %s and %s", GitHubAppIDSecret, GitHubAppInstallationIDSecret)
+	}
+	apiURL, err := readSecret(baseDir, GitHubAppAPIURLSecret)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiURL) == 0 {
+		apiURL = defaultGitHubAPIURL
+	}
+	keyPEM, err := ioutil.ReadFile(filepath.Join(baseDir, GitHubAppPrivateKeySecret))
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := parseGitHubAppKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &githubAppTokenMinter{
+		appID:          appID,
+		installationID: installationID,
+		apiURL:         strings.TrimSuffix(apiURL, "/"),
+		privateKey:     privateKey,
+	}, nil
+}
+
+// parseGitHubAppKey accepts the PKCS#1 keys GitHub issues, and PKCS#8 ones
+// for keys that were converted along the way.
+func parseGitHubAppKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("the %s secret contains no PEM block", GitHubAppPrivateKeySecret)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the %s secret: %v", GitHubAppPrivateKeySecret, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the %s secret holds %T, not an RSA key", GitHubAppPrivateKeySecret, key)
+	}
+	return rsaKey, nil
+}
+
+// appJWT builds the RS256-signed JWT that authenticates the App itself.
+func (m *githubAppTokenMinter) appJWT(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		// A minute of backdating tolerates clock skew against GitHub.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": m.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// mintToken exchanges an app JWT for an installation token.
+func (m *githubAppTokenMinter) mintToken(client *http.Client) (string, time.Time, error) {
+	jwt, err := m.appJWT(time.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	url := fmt.Sprintf("%s/app/installations/%s/access_tokens", m.apiURL, m.installationID)
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	request.Header.Set("Authorization", "Bearer "+jwt)
+	request.Header.Set("Accept", "application/vnd.github+json")
+	response, err := client.Do(request)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(response.Body)
+		return "", time.Time{}, fmt.Errorf("the token endpoint returned status %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	var minted struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&minted); err != nil {
+		return "", time.Time{}, err
+	}
+	if len(minted.Token) == 0 {
+		return "", time.Time{}, fmt.Errorf("the token endpoint returned no token")
+	}
+	return minted.Token, minted.ExpiresAt, nil
+}
+
+// refreshInto re-mints the token shortly before it expires and rewrites the
+// gitconfig fragment in place, so an already-running git picks the new token
+// up on its next request.
+func (m *githubAppTokenMinter) refreshInto(path string, expiresAt time.Time) {
+	for {
+		wait := time.Until(expiresAt.Add(-githubAppRefreshMargin))
+		if wait < time.Minute {
+			wait = time.Minute
+		}
+		time.Sleep(wait)
+		token, nextExpiry, err := m.mintToken(http.DefaultClient)
+		if err != nil {
+			glog.V(0).Infof("warning: Unable to refresh the GitHub App installation token: %v", err)
+			expiresAt = time.Now()
+			continue
+		}
+		content := fmt.Sprintf(ExtraHeaderConfig, githubAppAuthHeader(token))
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			glog.V(0).Infof("warning: Unable to store the refreshed GitHub App installation token: %v", err)
+		} else {
+			glog.V(5).Infof("Refreshed the GitHub App installation token; valid until %s", nextExpiry.Format(time.RFC3339))
+		}
+		expiresAt = nextExpiry
+	}
+}
+
+// githubAppAuthHeader renders the header git sends; installation tokens
+// authenticate as the x-access-token basic auth user.
+func githubAppAuthHeader(token string) string {
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token))
+}
+
+// Name returns the name of this auth method.
+func (_ GitHubApp) Name() string {
+	return GitHubAppName
+}
+
+// Handles returns true if the secret provides a GitHub App private key.
+func (_ GitHubApp) Handles(name string) bool {
+	return name == GitHubAppPrivateKeySecret
+}
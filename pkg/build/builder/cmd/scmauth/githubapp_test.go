@@ -0,0 +1,140 @@
+package scmauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func githubAppSecretDir(t *testing.T, key *rsa.PrivateKey, apiURL string) string {
+	t.Helper()
+	dir := secretDir(t)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	files := map[string]string{
+		GitHubAppPrivateKeySecret:     string(keyPEM),
+		GitHubAppIDSecret:             "12345",
+		GitHubAppInstallationIDSecret: "67890",
+		GitHubAppAPIURLSecret:         apiURL,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("error creating test file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestGitHubAppHandles(t *testing.T) {
+	githubApp := &GitHubApp{}
+	if !githubApp.Handles(GitHubAppPrivateKeySecret) {
+		t.Errorf("should handle %s", GitHubAppPrivateKeySecret)
+	}
+	if githubApp.Handles("username") {
+		t.Errorf("should not handle username")
+	}
+}
+
+func TestGitHubAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minter := &githubAppTokenMinter{appID: "12345", privateKey: key}
+	jwt, err := minter.appJWT(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected three JWT segments, got %d", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hashed[:], signature); err != nil {
+		t.Errorf("the JWT signature does not verify: %v", err)
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["iss"] != "12345" {
+		t.Errorf("expected issuer 12345, got %v", claims["iss"])
+	}
+}
+
+func TestGitHubAppSetup(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/installations/67890/access_tokens" {
+			t.Errorf("unexpected request path %s", r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			t.Errorf("expected a bearer app JWT, got %q", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_testtoken","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	dir := githubAppSecretDir(t, key, server.URL)
+	defer os.RemoveAll(dir)
+
+	context := NewDefaultSCMContext()
+	githubApp := &GitHubApp{
+		SourceURL: *git.MustParse("https://my.host/git/repo"),
+	}
+	err = githubApp.Setup(dir, context)
+	gitConfig, _ := context.Get("GIT_CONFIG")
+	defer cleanupConfig(gitConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	validateConfig(t, gitConfig, "extraHeader = "+githubAppAuthHeader("ghs_testtoken"))
+}
+
+func TestGitHubAppSetupMissingIDs(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := secretDir(t)
+	defer os.RemoveAll(dir)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, GitHubAppPrivateKeySecret), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	context := NewDefaultSCMContext()
+	githubApp := &GitHubApp{
+		SourceURL: *git.MustParse("https://my.host/git/repo"),
+	}
+	if err := githubApp.Setup(dir, context); err == nil {
+		t.Fatalf("expected an error when the app and installation IDs are missing")
+	}
+}
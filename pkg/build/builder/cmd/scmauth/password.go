@@ -6,10 +6,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
 
 	builder "github.com/openshift/builder/pkg/build/builder"
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 )
 
 const (
@@ -64,25 +67,39 @@ func (u UsernamePassword) Setup(baseDir string, context SCMAuthContext) error {
 		}
 	}
 
+	// Credentials for submodules hosted on other servers.
+	hostCredentials, err := readHostCredentials(baseDir)
+	if err != nil {
+		return err
+	}
+
 	// Write git config if needed
-	if gitconfigURL != nil {
+	if gitconfigURL != nil || len(hostCredentials) > 0 {
+		var credentials []string
+		if gitconfigURL != nil {
+			credentials = append(credentials, gitconfigURL.String())
+		}
+		credentials = append(credentials, hostCredentials...)
+
 		gitcredentials, err := ioutil.TempFile("", "gitcredentials.")
 		if err != nil {
 			return err
 		}
 		defer gitcredentials.Close()
+		builderutil.TrackSecretTemp(gitcredentials.Name())
 		gitconfig, err := ioutil.TempFile("", "gitcredentialscfg.")
 		if err != nil {
 			return err
 		}
 		defer gitconfig.Close()
+		builderutil.TrackSecretTemp(gitconfig.Name())
 
 		configContent := fmt.Sprintf(UserPassGitConfig, gitcredentials.Name())
 
 		glog.V(5).Infof("Adding username/password credentials to git config:\n%s\n", configContent)
 
 		fmt.Fprintf(gitconfig, "%s", configContent)
-		fmt.Fprintf(gitcredentials, "%s", gitconfigURL.String())
+		fmt.Fprintf(gitcredentials, "%s", strings.Join(credentials, "\n"))
 
 		return ensureGitConfigIncludes(gitconfig.Name(), context)
 	}
@@ -90,6 +107,66 @@ func (u UsernamePassword) Setup(baseDir string, context SCMAuthContext) error {
 	return nil
 }
 
+// readHostCredentials collects credentials for additional hosts from secret
+// keys of the form <host>.username, <host>.password, and <host>.token. The
+// entries end up in the same git credential store as the primary credentials,
+// so submodules hosted on other servers authenticate with their own
+// credentials during `git submodule update`.
+func readHostCredentials(baseDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	hosts := map[string]struct{}{}
+	for _, entry := range entries {
+		name := entry.Name()
+		for _, secret := range []string{UsernameSecret, PasswordSecret, TokenSecret} {
+			suffix := "." + secret
+			if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+				hosts[strings.TrimSuffix(name, suffix)] = struct{}{}
+			}
+		}
+	}
+	var hostNames []string
+	for host := range hosts {
+		hostNames = append(hostNames, host)
+	}
+	sort.Strings(hostNames)
+
+	var credentials []string
+	for _, host := range hostNames {
+		username, err := readSecret(baseDir, host+"."+UsernameSecret)
+		if err != nil {
+			return nil, err
+		}
+		password, err := readSecret(baseDir, host+"."+PasswordSecret)
+		if err != nil {
+			return nil, err
+		}
+		token, err := readSecret(baseDir, host+"."+TokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			password = token
+		}
+		if password == "" {
+			glog.V(5).Infof("Ignoring credentials for host %s: no password or token", host)
+			continue
+		}
+		if username == "" {
+			username = DefaultUsername
+		}
+		credentialURL := url.URL{Scheme: "https", Host: host, User: url.UserPassword(username, password)}
+		glog.V(5).Infof("Adding submodule credentials for host %s", host)
+		credentials = append(credentials, credentialURL.String())
+	}
+	return credentials, nil
+}
+
 func doSetup(sourceURL url.URL, usernameSecret, passwordSecret, tokenSecret string) (*url.URL, *url.URL, error) {
 	// Extract auth from the source URL
 	urlUsername := ""
@@ -140,12 +217,19 @@ func (_ UsernamePassword) Name() string {
 	return UsernamePasswordName
 }
 
-// Handles returns true if a username, password or token secret is present
+// Handles returns true if a username, password or token secret is present,
+// either as a bare key or suffixing a host name for per-host credentials.
 func (_ UsernamePassword) Handles(name string) bool {
 	switch name {
 	case UsernameSecret, PasswordSecret, TokenSecret:
 		return true
 	}
+	for _, secret := range []string{UsernameSecret, PasswordSecret, TokenSecret} {
+		suffix := "." + secret
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return true
+		}
+	}
 	return false
 }
 
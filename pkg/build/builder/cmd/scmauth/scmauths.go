@@ -15,6 +15,8 @@ func GitAuths(sourceURL *s2igit.URL) SCMAuths {
 	auths := SCMAuths{
 		&SSHPrivateKey{},
 		&UsernamePassword{SourceURL: *sourceURL},
+		&GitHubApp{SourceURL: *sourceURL},
+		&ExtraHeader{SourceURL: *sourceURL},
 		&CACert{SourceURL: *sourceURL},
 		&GitConfig{},
 	}
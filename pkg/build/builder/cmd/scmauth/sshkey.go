@@ -3,6 +3,8 @@ package scmauth
 import (
 	"io/ioutil"
 	"path/filepath"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 )
 
 const SSHPrivateKeyMethodName = "ssh-privatekey"
@@ -18,6 +20,7 @@ func (_ SSHPrivateKey) Setup(baseDir string, context SCMAuthContext) error {
 		return err
 	}
 	defer script.Close()
+	builderutil.TrackSecretTemp(script.Name())
 	if err := script.Chmod(0711); err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	builder "github.com/openshift/builder/pkg/build/builder"
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 	utilglog "github.com/openshift/builder/pkg/build/builder/util/glog"
 )
 
@@ -18,6 +19,7 @@ func createGitConfig(includePath string, context SCMAuthContext) error {
 	if err != nil {
 		return err
 	}
+	builderutil.TrackSecretTemp(tempDir)
 	gitconfig := filepath.Join(tempDir, ".gitconfig")
 	content := fmt.Sprintf("[include]\npath = %s\n", includePath)
 	if err := ioutil.WriteFile(gitconfig, []byte(content), 0600); err != nil {
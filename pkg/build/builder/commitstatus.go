@@ -0,0 +1,235 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// CommitStatusAnnotation enables reporting build progress as commit statuses
+// on the SCM. The value names the provider API: "github", "gitlab", or
+// "gitea". BuildConfig annotations propagate to Builds, so the setting is
+// made once per BuildConfig.
+const CommitStatusAnnotation = "build.openshift.io/report-commit-status"
+
+// CommitStatusURLAnnotation overrides the provider API base URL, for
+// self-hosted GitHub Enterprise, GitLab, or Gitea installations. When unset,
+// the API base is derived from the source host.
+const CommitStatusURLAnnotation = "build.openshift.io/commit-status-url"
+
+// CommitStatusContextAnnotation overrides the status context name shown next
+// to the commit.
+const CommitStatusContextAnnotation = "build.openshift.io/commit-status-context"
+
+// Commit status states, in provider-neutral terms.
+const (
+	CommitStatusPending = "pending"
+	CommitStatusSuccess = "success"
+	CommitStatusFailure = "failure"
+)
+
+const defaultCommitStatusContext = "openshift/build"
+
+// commitStatusReporter posts commit statuses for one build.
+type commitStatusReporter struct {
+	provider      string
+	apiBase       string
+	token         string
+	statusContext string
+	owner         string
+	repo          string
+	commit        string
+	client        *http.Client
+}
+
+// newCommitStatusReporter assembles a reporter from the build annotations,
+// the source secret, and the resolved revision. It returns nil without error
+// when reporting is not enabled.
+func newCommitStatusReporter(build *buildapiv1.Build) (*commitStatusReporter, error) {
+	provider := build.Annotations[CommitStatusAnnotation]
+	if len(provider) == 0 {
+		return nil, nil
+	}
+	switch provider {
+	case "github", "gitlab", "gitea":
+	default:
+		return nil, fmt.Errorf("unknown commit status provider %q, expected github, gitlab, or gitea", provider)
+	}
+	if build.Spec.Source.Git == nil {
+		return nil, fmt.Errorf("the build has no git source to report statuses to")
+	}
+	sourceURL, err := url.Parse(build.Spec.Source.Git.URI)
+	if err != nil || len(sourceURL.Host) == 0 {
+		return nil, fmt.Errorf("unable to derive the repository from source URI %q", build.Spec.Source.Git.URI)
+	}
+	pathParts := strings.Split(strings.Trim(strings.TrimSuffix(sourceURL.Path, ".git"), "/"), "/")
+	if len(pathParts) < 2 {
+		return nil, fmt.Errorf("source URI %q does not name an owner and repository", build.Spec.Source.Git.URI)
+	}
+	owner := strings.Join(pathParts[:len(pathParts)-1], "/")
+	repo := pathParts[len(pathParts)-1]
+
+	commit := resolvedCommitFor(build)
+	if len(commit) == 0 {
+		return nil, fmt.Errorf("no commit is resolved for the build yet")
+	}
+
+	token, err := commitStatusToken()
+	if err != nil {
+		return nil, err
+	}
+	if len(token) == 0 {
+		return nil, fmt.Errorf("the source secret provides no token or password for commit statuses")
+	}
+
+	apiBase := build.Annotations[CommitStatusURLAnnotation]
+	if len(apiBase) == 0 {
+		apiBase = defaultCommitStatusAPIBase(provider, sourceURL.Host)
+	}
+	statusContext := build.Annotations[CommitStatusContextAnnotation]
+	if len(statusContext) == 0 {
+		statusContext = defaultCommitStatusContext
+	}
+	return &commitStatusReporter{
+		provider:      provider,
+		apiBase:       strings.TrimSuffix(apiBase, "/"),
+		token:         token,
+		statusContext: statusContext,
+		owner:         owner,
+		repo:          repo,
+		commit:        commit,
+		client:        http.DefaultClient,
+	}, nil
+}
+
+// defaultCommitStatusAPIBase derives the API endpoint from the source host.
+func defaultCommitStatusAPIBase(provider, host string) string {
+	if provider == "github" && host == "github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + host
+}
+
+// resolvedCommitFor returns the commit the build runs against: the requested
+// revision, or the one the clone resolved.
+func resolvedCommitFor(build *buildapiv1.Build) string {
+	if build.Spec.Revision != nil && build.Spec.Revision.Git != nil && len(build.Spec.Revision.Git.Commit) != 0 {
+		return build.Spec.Revision.Git.Commit
+	}
+	if annotation, ok := build.Annotations[ResolvedRevisionAnnotation]; ok {
+		resolved := resolvedRevision{}
+		if err := json.Unmarshal([]byte(annotation), &resolved); err == nil {
+			return resolved.Commit
+		}
+	}
+	return ""
+}
+
+// commitStatusToken reads the API token from the mounted source secret,
+// accepting the same token and password keys the clone credentials use.
+func commitStatusToken() (string, error) {
+	secretDir := os.Getenv("SOURCE_SECRET_PATH")
+	if len(secretDir) == 0 {
+		return "", fmt.Errorf("no source secret is mounted (SOURCE_SECRET_PATH is unset)")
+	}
+	for _, key := range []string{"token", "password"} {
+		lines, err := ReadLines(filepath.Join(secretDir, key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if len(lines) > 0 && len(lines[0]) != 0 {
+			return lines[0], nil
+		}
+	}
+	return "", nil
+}
+
+// report posts one status. The request body and endpoint differ per
+// provider; the state names do too.
+func (r *commitStatusReporter) report(state, description string) error {
+	var endpoint string
+	var body []byte
+	var err error
+	headers := map[string]string{"Content-Type": "application/json"}
+	switch r.provider {
+	case "github", "gitea":
+		prefix := ""
+		if r.provider == "gitea" {
+			prefix = "/api/v1"
+		}
+		endpoint = fmt.Sprintf("%s%s/repos/%s/%s/statuses/%s", r.apiBase, prefix, r.owner, r.repo, r.commit)
+		body, err = json.Marshal(map[string]string{
+			"state":       state,
+			"context":     r.statusContext,
+			"description": description,
+		})
+		headers["Authorization"] = "token " + r.token
+	case "gitlab":
+		gitlabState := state
+		switch state {
+		case CommitStatusPending:
+			gitlabState = "running"
+		case CommitStatusFailure:
+			gitlabState = "failed"
+		}
+		project := url.PathEscape(r.owner + "/" + r.repo)
+		endpoint = fmt.Sprintf("%s/api/v4/projects/%s/statuses/%s", r.apiBase, project, r.commit)
+		body, err = json.Marshal(map[string]string{
+			"state":       gitlabState,
+			"context":     r.statusContext,
+			"description": description,
+		})
+		headers["PRIVATE-TOKEN"] = r.token
+	}
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		responseBody, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("the status endpoint returned %s: %s", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
+
+// ReportCommitStatus posts the state of the build as a commit status on the
+// SCM, when enabled. Reporting is best-effort: the build outcome must not
+// depend on the SCM being reachable.
+func ReportCommitStatus(build *buildapiv1.Build, state string) {
+	reporter, err := newCommitStatusReporter(build)
+	if err != nil {
+		glog.V(0).Infof("warning: Not reporting the commit status: %v", err)
+		return
+	}
+	if reporter == nil {
+		return
+	}
+	description := fmt.Sprintf("Build %s/%s", build.Namespace, build.Name)
+	if err := reporter.report(state, description); err != nil {
+		glog.V(0).Infof("warning: Unable to report the %s commit status: %v", state, err)
+		return
+	}
+	glog.V(2).Infof("Reported the %s commit status for %s", state, reporter.commit)
+}
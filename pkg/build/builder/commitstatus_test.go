@@ -0,0 +1,130 @@
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func commitStatusBuild(provider, apiBase string) *buildapiv1.Build {
+	build := &buildapiv1.Build{}
+	build.Namespace = "ns"
+	build.Name = "build-1"
+	build.Annotations = map[string]string{
+		CommitStatusAnnotation: provider,
+	}
+	if len(apiBase) != 0 {
+		build.Annotations[CommitStatusURLAnnotation] = apiBase
+	}
+	build.Spec.Source.Git = &buildapiv1.GitBuildSource{URI: "https://my.host/owner/repo.git"}
+	build.Spec.Revision = &buildapiv1.SourceRevision{
+		Git: &buildapiv1.GitSourceRevision{Commit: "0123456789abcdef"},
+	}
+	return build
+}
+
+func setupCommitStatusSecret(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("SOURCE_SECRET_PATH", dir)
+	t.Cleanup(func() { os.Unsetenv("SOURCE_SECRET_PATH") })
+}
+
+func TestNewCommitStatusReporter(t *testing.T) {
+	setupCommitStatusSecret(t)
+
+	build := commitStatusBuild("github", "")
+	reporter, err := newCommitStatusReporter(build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reporter.owner != "owner" || reporter.repo != "repo" || reporter.commit != "0123456789abcdef" {
+		t.Errorf("unexpected reporter %+v", reporter)
+	}
+	if reporter.apiBase != "https://my.host" {
+		t.Errorf("expected the source host as API base, got %q", reporter.apiBase)
+	}
+	if reporter.token != "secret-token" {
+		t.Errorf("expected the token from the source secret, got %q", reporter.token)
+	}
+
+	disabled, err := newCommitStatusReporter(&buildapiv1.Build{})
+	if err != nil || disabled != nil {
+		t.Errorf("expected reporting to be disabled without the annotation, got %v, %v", disabled, err)
+	}
+
+	if _, err := newCommitStatusReporter(commitStatusBuild("subversion", "")); err == nil {
+		t.Errorf("expected an unknown provider to be rejected")
+	}
+}
+
+func TestCommitStatusReportGitHub(t *testing.T) {
+	setupCommitStatusSecret(t)
+	var gotPath, gotAuth string
+	var gotBody map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	build := commitStatusBuild("github", server.URL)
+	reporter, err := newCommitStatusReporter(build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.report(CommitStatusSuccess, "Build ns/build-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/repos/owner/repo/statuses/0123456789abcdef" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotAuth != "token secret-token" {
+		t.Errorf("unexpected authorization %q", gotAuth)
+	}
+	if gotBody["state"] != "success" || gotBody["context"] != defaultCommitStatusContext {
+		t.Errorf("unexpected body %v", gotBody)
+	}
+}
+
+func TestCommitStatusReportGitLab(t *testing.T) {
+	setupCommitStatusSecret(t)
+	var gotPath, gotToken string
+	var gotBody map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotToken = r.Header.Get("PRIVATE-TOKEN")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	build := commitStatusBuild("gitlab", server.URL)
+	reporter, err := newCommitStatusReporter(build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.report(CommitStatusPending, "Build ns/build-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/api/v4/projects/owner%2Frepo/statuses/0123456789abcdef" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotToken != "secret-token" {
+		t.Errorf("unexpected token %q", gotToken)
+	}
+	if gotBody["state"] != "running" {
+		t.Errorf("expected the pending state to map to running, got %v", gotBody)
+	}
+}
@@ -27,7 +27,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/openshift/imagebuilder"
-	imagereference "github.com/openshift/library-go/pkg/image/reference"
 	s2igit "github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/util"
 
@@ -36,6 +35,7 @@ import (
 	builderutil "github.com/openshift/builder/pkg/build/builder/util"
 	"github.com/openshift/builder/pkg/build/builder/util/dockerfile"
 	utilglog "github.com/openshift/builder/pkg/build/builder/util/glog"
+	"github.com/openshift/builder/pkg/imagereference"
 	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
 	"github.com/openshift/library-go/pkg/git"
 )
@@ -55,8 +55,6 @@ const (
 	// SecretBuildSourceBaseMountPath is the path that the controller will have
 	// mounted secret input content within the build pod
 	secretBuildSourceBaseMountPath = "/var/run/secrets/openshift.io/build"
-	// BuildWorkDirMount is the working directory within the build pod, mounted as a volume.
-	buildWorkDirMount = "/tmp/build"
 )
 
 var (
@@ -64,6 +62,10 @@ var (
 	// client facing libraries should not be using glog
 	glog = utilglog.ToFile(os.Stderr, 2)
 
+	// buildWorkDirMount is the working directory within the build pod, mounted
+	// as a volume. ConfigureBuildWorkDir may relocate it before the build starts.
+	buildWorkDirMount = "/tmp/build"
+
 	// InputContentPath is the path at which the build inputs will be available
 	// to all the build containers.
 	InputContentPath = filepath.Join(buildWorkDirMount, "inputs")
@@ -313,6 +315,13 @@ func GetSourceRevision(build *buildapiv1.Build, sourceInfo *git.SourceInfo) *bui
 // HandleBuildStatusUpdate handles updating the build status
 // retries occur on update conflict and unreachable api server
 func HandleBuildStatusUpdate(build *buildapiv1.Build, client buildclientv1.BuildInterface, sourceRev *buildapiv1.SourceRevision) {
+	// Rapid successive updates are coalesced; the build object accumulates
+	// state, so the next update that goes through carries everything. An
+	// undelivered earlier update forces this one through.
+	if !currentStatusUpdateBuffer.hasPending() && !currentStatusUpdateLimiter.allow(build, sourceRev) {
+		return
+	}
+
 	var latestBuild *buildapiv1.Build
 	var err error
 
@@ -336,6 +345,14 @@ func HandleBuildStatusUpdate(build *buildapiv1.Build, client buildclientv1.Build
 			latestBuild.Spec.Revision = sourceRev
 			latestBuild.ResourceVersion = ""
 		}
+		for _, annotation := range []string{ResourceUsageAnnotation, RevisionVerifiedAnnotation, PushResultsAnnotation, FeatureFlagsAnnotation, BaseImageDigestsAnnotation} {
+			if value, ok := build.Annotations[annotation]; ok {
+				if latestBuild.Annotations == nil {
+					latestBuild.Annotations = map[string]string{}
+				}
+				latestBuild.Annotations[annotation] = value
+			}
+		}
 		latestBuild.Status.Phase = build.Status.Phase
 		latestBuild.Status.Reason = build.Status.Reason
 		latestBuild.Status.Message = build.Status.Message
@@ -358,7 +375,10 @@ func HandleBuildStatusUpdate(build *buildapiv1.Build, client buildclientv1.Build
 	})
 
 	if err != nil {
-		glog.Infof("error: Unable to update build status: %v", err)
+		currentStatusUpdateBuffer.markFailed()
+		glog.Infof("error: Unable to update build status: %v (delivery will be retried with the next update)", err)
+	} else {
+		currentStatusUpdateBuffer.markSent()
 	}
 }
 
@@ -408,6 +428,7 @@ func buildLabels(build *buildapiv1.Build, sourceInfo *git.SourceInfo) []dockerfi
 		labels[builderutil.DefaultDockerLabelNamespace+"build.commit.ref"] = build.Spec.Source.Git.Ref
 	}
 	addBuildLabels(labels, build)
+	addPromotionHintLabels(labels, build, sourceInfo)
 
 	kv := make([]dockerfile.KeyValue, 0, len(labels)+len(build.Spec.Output.ImageLabels))
 	keys := make([]string, 0, len(labels))
@@ -419,7 +440,7 @@ func buildLabels(build *buildapiv1.Build, sourceInfo *git.SourceInfo) []dockerfi
 		kv = append(kv, dockerfile.KeyValue{Key: k, Value: labels[k]})
 	}
 	// override autogenerated labels with user provided labels
-	for _, lbl := range build.Spec.Output.ImageLabels {
+	for _, lbl := range userImageLabels(build, sourceInfo) {
 		kv = append(kv, dockerfile.KeyValue{Key: lbl.Name, Value: lbl.Value})
 	}
 	return kv
@@ -465,11 +486,8 @@ func addBuildParameters(dir string, build *buildapiv1.Build, sourceInfo *git.Sou
 	// Update base image if build strategy specifies the From field.
 	if build.Spec.Strategy.DockerStrategy != nil && build.Spec.Strategy.DockerStrategy.From != nil && build.Spec.Strategy.DockerStrategy.From.Kind == "DockerImage" {
 		// Reduce the name to a minimal canonical form for the daemon
-		name := build.Spec.Strategy.DockerStrategy.From.Name
-		if ref, err := imagereference.Parse(name); err == nil {
-			name = ref.DaemonMinimal().Exact()
-		}
-		err := replaceLastFrom(node, name, "")
+		name := imagereference.Normalize(build.Spec.Strategy.DockerStrategy.From.Name)
+		err := replaceStageFrom(node, name, strings.TrimSpace(os.Getenv(OverrideFromStageEnvVar)))
 		if err != nil {
 			return err
 		}
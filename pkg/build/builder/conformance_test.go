@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+
+	"github.com/openshift/imagebuilder"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// instructionSequence returns the ordered WORKDIR/USER instructions per stage,
+// which must be preserved exactly by the builder's Dockerfile rewriting for
+// the built image to match docker build semantics.
+func instructionSequence(node *parser.Node) map[string][]string {
+	sequence := map[string][]string{}
+	stages := imagebuilder.NewStages(node, imagebuilder.NewBuilder(nil))
+	for _, stage := range stages {
+		for _, child := range stage.Node.Children {
+			switch child.Value {
+			case dockercmd.Workdir, dockercmd.User:
+				if child.Next != nil {
+					sequence[stage.Name] = append(sequence[stage.Name], child.Value+" "+child.Next.Value)
+				}
+			}
+		}
+	}
+	return sequence
+}
+
+// TestConformanceWorkdirUserPreserved runs each Dockerfile in the conformance
+// corpus through the builder's mutation pipeline and verifies that the
+// per-stage WORKDIR and USER instructions come out unchanged and in order.
+func TestConformanceWorkdirUserPreserved(t *testing.T) {
+	corpus, err := filepath.Glob("testdata/conformance/Dockerfile.*")
+	if err != nil {
+		t.Fatalf("unable to list conformance corpus: %v", err)
+	}
+	if len(corpus) == 0 {
+		t.Fatal("conformance corpus is empty")
+	}
+
+	for _, dockerfilePath := range corpus {
+		in, err := ioutil.ReadFile(dockerfilePath)
+		if err != nil {
+			t.Fatalf("%s: %v", dockerfilePath, err)
+		}
+		original, err := imagebuilder.ParseDockerfile(strings.NewReader(string(in)))
+		if err != nil {
+			t.Fatalf("%s: %v", dockerfilePath, err)
+		}
+
+		dir, err := ioutil.TempDir("", "conformance")
+		if err != nil {
+			t.Fatalf("%s: %v", dockerfilePath, err)
+		}
+		defer os.RemoveAll(dir)
+		if err := ioutil.WriteFile(filepath.Join(dir, defaultDockerfilePath), in, 0644); err != nil {
+			t.Fatalf("%s: %v", dockerfilePath, err)
+		}
+
+		build := &buildapiv1.Build{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "conformance-build",
+				Namespace: "default",
+			},
+			Spec: buildapiv1.BuildSpec{
+				CommonSpec: buildapiv1.CommonSpec{
+					Strategy: buildapiv1.BuildStrategy{
+						DockerStrategy: &buildapiv1.DockerBuildStrategy{},
+					},
+				},
+			},
+		}
+		if err := addBuildParameters(dir, build, nil); err != nil {
+			t.Errorf("%s: addBuildParameters: %v", dockerfilePath, err)
+			continue
+		}
+
+		out, err := ioutil.ReadFile(filepath.Join(dir, defaultDockerfilePath))
+		if err != nil {
+			t.Fatalf("%s: %v", dockerfilePath, err)
+		}
+		mutated, err := imagebuilder.ParseDockerfile(strings.NewReader(string(out)))
+		if err != nil {
+			t.Errorf("%s: mutated Dockerfile does not parse: %v", dockerfilePath, err)
+			continue
+		}
+
+		want := instructionSequence(original)
+		got := instructionSequence(mutated)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: WORKDIR/USER sequence changed by the builder:\noriginal: %v\nmutated:  %v", dockerfilePath, want, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// ContextDirPolicyPathEnvVar names the environment variable pointing at a
+// cluster-supplied context directory policy file, typically mounted from a
+// ConfigMap by the build controller.
+const ContextDirPolicyPathEnvVar = "BUILD_CONTEXT_DIR_POLICY_PATH"
+
+// contextDirPolicy restricts which context directories namespaces may build
+// from a given repository. Shared monorepos can use this to keep sensitive
+// directories from being copied into arbitrary teams' images.
+type contextDirPolicy struct {
+	Rules []contextDirPolicyRule `json:"rules"`
+}
+
+// contextDirPolicyRule is a single entry in a contextDirPolicy. A rule applies
+// when both the repository and namespace selectors match; an empty selector
+// matches everything. AllowedContextDirs holds glob patterns matched against
+// the cleaned context directory.
+type contextDirPolicyRule struct {
+	Repository         string   `json:"repository,omitempty"`
+	Namespaces         []string `json:"namespaces,omitempty"`
+	AllowedContextDirs []string `json:"allowedContextDirs"`
+}
+
+// readContextDirPolicy loads the policy file at policyPath. A missing file
+// means no policy is configured and returns nil without error.
+func readContextDirPolicy(policyPath string) (*contextDirPolicy, error) {
+	if len(policyPath) == 0 {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(policyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading context directory policy %s: %v", policyPath, err)
+	}
+	policy := &contextDirPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("error parsing context directory policy %s: %v", policyPath, err)
+	}
+	return policy, nil
+}
+
+func (r contextDirPolicyRule) matches(repository, namespace string) bool {
+	if len(r.Repository) > 0 && r.Repository != repository {
+		return false
+	}
+	if len(r.Namespaces) > 0 {
+		found := false
+		for _, ns := range r.Namespaces {
+			if ns == namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// EnforceContextDirPolicy loads the policy file at policyPath (if any) and
+// verifies the build's context directory against it.
+func EnforceContextDirPolicy(policyPath string, build *buildapiv1.Build) error {
+	policy, err := readContextDirPolicy(policyPath)
+	if err != nil {
+		return err
+	}
+	return checkContextDirPolicy(policy, build)
+}
+
+// checkContextDirPolicy verifies that the build's context directory is allowed
+// by policy. Builds with no applicable rules are allowed; once any rule
+// applies, the context directory must match one of its patterns.
+func checkContextDirPolicy(policy *contextDirPolicy, build *buildapiv1.Build) error {
+	if policy == nil || build.Spec.Source.Git == nil {
+		return nil
+	}
+	contextDir := path.Clean("/" + build.Spec.Source.ContextDir)
+	contextDir = strings.TrimPrefix(contextDir, "/")
+	restricted := false
+	for _, rule := range policy.Rules {
+		if !rule.matches(build.Spec.Source.Git.URI, build.Namespace) {
+			continue
+		}
+		restricted = true
+		for _, pattern := range rule.AllowedContextDirs {
+			if ok, err := path.Match(pattern, contextDir); err == nil && ok {
+				return nil
+			}
+		}
+	}
+	if restricted {
+		return fmt.Errorf("context directory %q is not allowed for repository %s in namespace %s", build.Spec.Source.ContextDir, build.Spec.Source.Git.URI, build.Namespace)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func policyTestBuild(namespace, uri, contextDir string) *buildapiv1.Build {
+	return &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-build",
+			Namespace: namespace,
+		},
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Source: buildapiv1.BuildSource{
+					Git: &buildapiv1.GitBuildSource{
+						URI: uri,
+					},
+					ContextDir: contextDir,
+				},
+			},
+		},
+	}
+}
+
+func TestCheckContextDirPolicy(t *testing.T) {
+	policy := &contextDirPolicy{
+		Rules: []contextDirPolicyRule{
+			{
+				Repository:         "https://example.com/monorepo.git",
+				Namespaces:         []string{"team-a"},
+				AllowedContextDirs: []string{"services/team-a/*"},
+			},
+			{
+				Repository:         "https://example.com/monorepo.git",
+				AllowedContextDirs: []string{"shared/*"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		namespace  string
+		uri        string
+		contextDir string
+		expectErr  bool
+	}{
+		{
+			name:       "allowed by namespace rule",
+			namespace:  "team-a",
+			uri:        "https://example.com/monorepo.git",
+			contextDir: "services/team-a/api",
+		},
+		{
+			name:       "allowed by shared rule",
+			namespace:  "team-b",
+			uri:        "https://example.com/monorepo.git",
+			contextDir: "shared/libs",
+		},
+		{
+			name:       "denied directory",
+			namespace:  "team-b",
+			uri:        "https://example.com/monorepo.git",
+			contextDir: "services/team-a/api",
+			expectErr:  true,
+		},
+		{
+			name:       "denied by path cleaning",
+			namespace:  "team-b",
+			uri:        "https://example.com/monorepo.git",
+			contextDir: "shared/../services/team-a/api",
+			expectErr:  true,
+		},
+		{
+			name:       "unrestricted repository",
+			namespace:  "team-b",
+			uri:        "https://example.com/other.git",
+			contextDir: "anything",
+		},
+	}
+	for _, tc := range testCases {
+		err := checkContextDirPolicy(policy, policyTestBuild(tc.namespace, tc.uri, tc.contextDir))
+		if tc.expectErr && err == nil {
+			t.Errorf("%s: expected error, got none", tc.name)
+		}
+		if !tc.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestCheckContextDirPolicyNoPolicy(t *testing.T) {
+	if err := checkContextDirPolicy(nil, policyTestBuild("team-a", "https://example.com/monorepo.git", "anything")); err != nil {
+		t.Errorf("unexpected error with no policy: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	docker "github.com/fsouza/go-dockerclient"
+	s2iapi "github.com/openshift/source-to-image/pkg/api"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
+	"github.com/openshift/builder/pkg/build/builder/timing"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+
+	"github.com/openshift/builder/pkg/imagereference"
+)
+
+// CustomBuilder runs a Custom strategy build: the user-specified builder
+// image is executed with the serialized Build object and the usual contract
+// environment injected, and is expected to produce and push the output
+// image itself. Unlike the historical implementation, the image runs in the
+// daemonless environment; requests to expose a Docker socket are refused.
+type CustomBuilder struct {
+	dockerClient DockerClient
+	build        *buildapiv1.Build
+	client       buildclientv1.BuildInterface
+	cgLimits     *s2iapi.CGroupLimits
+}
+
+// NewCustomBuilder creates a new instance of CustomBuilder.
+func NewCustomBuilder(dockerClient DockerClient, buildsClient buildclientv1.BuildInterface, build *buildapiv1.Build, cgLimits *s2iapi.CGroupLimits) *CustomBuilder {
+	return &CustomBuilder{
+		dockerClient: dockerClient,
+		build:        build,
+		client:       buildsClient,
+		cgLimits:     cgLimits,
+	}
+}
+
+// Build executes the custom build.
+func (c *CustomBuilder) Build() error {
+	ctx := timing.NewContext(context.Background())
+	defer func() {
+		c.build.Status.Stages = timing.AppendStageAndStepInfo(c.build.Status.Stages, timing.GetStages(ctx))
+		HandleBuildStatusUpdate(c.build, c.client, nil)
+	}()
+
+	strategy := c.build.Spec.Strategy.CustomStrategy
+	if strategy == nil {
+		return fmt.Errorf("the build is not a Custom strategy build")
+	}
+	if strategy.ExposeDockerSocket {
+		// There is no Docker daemon in the daemonless environment, and
+		// granting one would amount to a privileged escape hatch.
+		return fmt.Errorf("exposing the Docker socket to custom builds is not supported")
+	}
+	image := strategy.From.Name
+	if len(image) == 0 {
+		return fmt.Errorf("the custom build strategy does not name a builder image")
+	}
+
+	if _, err := c.dockerClient.InspectImage(image); err != nil || strategy.ForcePull {
+		pullAuthConfig, _ := dockercfg.NewHelper().GetDockerAuth(image, dockercfg.PullAuthType)
+		glog.V(0).Infof("Pulling custom builder image %s ...", image)
+		RecordBuildProgress(buildapiv1.StagePullImages)
+		startTime := metav1.Now()
+		err := PullImage(c.dockerClient, image, pullAuthConfig)
+		timing.RecordNewStep(ctx, buildapiv1.StagePullImages, buildapiv1.StepPullBaseImage, startTime, metav1.Now())
+		if err != nil {
+			return fmt.Errorf("failed to pull image %s: %v", image, err)
+		}
+	}
+
+	environment, err := customBuildEnvironment(c.build)
+	if err != nil {
+		return err
+	}
+	binds, err := customBuildBinds(strategy)
+	if err != nil {
+		return err
+	}
+
+	RecordBuildProgress(buildapiv1.StageBuild)
+	glog.V(0).Infof("Running custom builder image %s ...", image)
+	createOptions := docker.CreateContainerOptions{
+		Name: containerName("custom", c.build.Name, c.build.Namespace, "custom-build"),
+		Config: &docker.Config{
+			Image: image,
+			Env:   environment,
+		},
+		HostConfig: &docker.HostConfig{
+			Binds: binds,
+		},
+	}
+	if c.cgLimits != nil {
+		createOptions.HostConfig.Memory = c.cgLimits.MemoryLimitBytes
+		createOptions.HostConfig.MemorySwap = c.cgLimits.MemorySwap
+		createOptions.HostConfig.CgroupParent = c.cgLimits.Parent
+	}
+	attachOptions := docker.AttachToContainerOptions{
+		OutputStream: os.Stdout,
+		ErrorStream:  os.Stderr,
+		Stream:       true,
+		Stdout:       true,
+		Stderr:       true,
+	}
+	startTime := metav1.Now()
+	if dc, ok := c.dockerClient.(*docker.Client); ok {
+		err = dockerRun(dc, createOptions, attachOptions)
+	} else if dc, ok := c.dockerClient.(*DaemonlessClient); ok {
+		err = daemonlessRun(context.Background(), dc.Store, dc.Isolation, createOptions, attachOptions)
+	} else {
+		err = ClientTypeUnknown
+	}
+	timing.RecordNewStep(ctx, buildapiv1.StageBuild, buildapiv1.StepDockerBuild, startTime, metav1.Now())
+	if err != nil {
+		return fmt.Errorf("the custom builder image failed: %v", err)
+	}
+	return nil
+}
+
+// customBuildEnvironment assembles the environment contract of custom
+// builds: the serialized Build object, the source and output coordinates,
+// the location of the push credentials, and the strategy's own variables.
+func customBuildEnvironment(build *buildapiv1.Build) ([]string, error) {
+	serializable := build.DeepCopy()
+	serializable.TypeMeta = metav1.TypeMeta{Kind: "Build", APIVersion: buildapiv1.SchemeGroupVersion.String()}
+	serialized, err := json.Marshal(serializable)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize the build: %v", err)
+	}
+	environment := []string{"BUILD=" + string(serialized)}
+	if gitSource := build.Spec.Source.Git; gitSource != nil {
+		environment = append(environment, "SOURCE_REPOSITORY="+gitSource.URI, "SOURCE_URI="+gitSource.URI)
+		if len(gitSource.Ref) != 0 {
+			environment = append(environment, "SOURCE_REF="+gitSource.Ref)
+		}
+	}
+	if len(build.Spec.Source.ContextDir) != 0 {
+		environment = append(environment, "SOURCE_CONTEXT_DIR="+build.Spec.Source.ContextDir)
+	}
+	if build.Spec.Output.To != nil && len(build.Spec.Output.To.Name) != 0 {
+		outputImage := build.Spec.Output.To.Name
+		environment = append(environment, "OUTPUT_IMAGE="+outputImage)
+		if ref, err := imagereference.Resolve(outputImage); err == nil {
+			environment = append(environment, "OUTPUT_REGISTRY="+ref.Registry)
+		}
+	}
+	if pushCfg := os.Getenv(dockercfg.PushAuthType); len(pushCfg) != 0 {
+		environment = append(environment, "PUSH_DOCKERCFG_PATH="+pushCfg)
+	}
+	for _, env := range build.Spec.Strategy.CustomStrategy.Env {
+		environment = append(environment, env.Name+"="+env.Value)
+	}
+	return environment, nil
+}
+
+// customBuildBinds mounts the strategy's secrets and the push credentials
+// into the custom builder container, read-only.
+func customBuildBinds(strategy *buildapiv1.CustomBuildStrategy) ([]string, error) {
+	var binds []string
+	for _, secret := range strategy.Secrets {
+		if !filepath.IsAbs(secret.MountPath) {
+			return nil, fmt.Errorf("the mount path of secret %q must be absolute", secret.SecretSource.Name)
+		}
+		source := filepath.Join(secretBuildSourceBaseMountPath, secret.SecretSource.Name)
+		if _, err := os.Stat(source); err != nil {
+			return nil, fmt.Errorf("secret %q is not mounted in the build pod: %v", secret.SecretSource.Name, err)
+		}
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", source, secret.MountPath))
+	}
+	if pushCfg := os.Getenv(dockercfg.PushAuthType); len(pushCfg) != 0 {
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", pushCfg, pushCfg))
+	}
+	return binds, nil
+}
@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
+)
+
+func TestCustomBuildEnvironment(t *testing.T) {
+	os.Setenv(dockercfg.PushAuthType, "/var/run/secrets/push")
+	defer os.Unsetenv(dockercfg.PushAuthType)
+
+	build := &buildapiv1.Build{}
+	build.Name = "custom-build"
+	build.Spec.Source.Git = &buildapiv1.GitBuildSource{URI: "https://example.com/repo.git", Ref: "main"}
+	build.Spec.Source.ContextDir = "app"
+	build.Spec.Output.To = &corev1.ObjectReference{Kind: "DockerImage", Name: "registry.example.com/ns/output:latest"}
+	build.Spec.Strategy.CustomStrategy = &buildapiv1.CustomBuildStrategy{
+		From: corev1.ObjectReference{Kind: "DockerImage", Name: "example/custom-builder:latest"},
+		Env:  []corev1.EnvVar{{Name: "EXTRA", Value: "value"}},
+	}
+
+	environment, err := customBuildEnvironment(build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"SOURCE_REPOSITORY=https://example.com/repo.git",
+		"SOURCE_URI=https://example.com/repo.git",
+		"SOURCE_REF=main",
+		"SOURCE_CONTEXT_DIR=app",
+		"OUTPUT_IMAGE=registry.example.com/ns/output:latest",
+		"OUTPUT_REGISTRY=registry.example.com",
+		"PUSH_DOCKERCFG_PATH=/var/run/secrets/push",
+		"EXTRA=value",
+	}
+	for _, e := range expected {
+		found := false
+		for _, entry := range environment {
+			if entry == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected environment to contain %q, got %v", e, environment)
+		}
+	}
+	if !strings.HasPrefix(environment[0], "BUILD={") {
+		t.Errorf("expected the first entry to carry the serialized build, got %q", environment[0])
+	}
+	if !strings.Contains(environment[0], `"kind":"Build"`) {
+		t.Errorf("expected the serialized build to declare its kind, got %q", environment[0])
+	}
+}
+
+func TestCustomBuildBindsRejectsRelativeMountPath(t *testing.T) {
+	strategy := &buildapiv1.CustomBuildStrategy{
+		Secrets: []buildapiv1.SecretSpec{
+			{SecretSource: corev1.LocalObjectReference{Name: "creds"}, MountPath: "relative/path"},
+		},
+	}
+	if _, err := customBuildBinds(strategy); err == nil {
+		t.Errorf("expected a relative mount path to be rejected")
+	}
+}
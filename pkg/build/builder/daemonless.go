@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,19 +15,23 @@ import (
 	"github.com/containers/buildah"
 	"github.com/containers/buildah/imagebuildah"
 	"github.com/containers/buildah/util"
+	cp "github.com/containers/image/copy"
 	"github.com/containers/image/pkg/docker/config"
 	"github.com/containers/image/pkg/sysregistriesv2"
+	"github.com/containers/image/signature"
+	is "github.com/containers/image/storage"
 	"github.com/containers/image/transports/alltransports"
 	"github.com/containers/image/types"
 	"github.com/containers/storage"
 	"github.com/containers/storage/pkg/archive"
 	"github.com/containers/storage/pkg/idtools"
 	docker "github.com/fsouza/go-dockerclient"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
 
 	buildapiv1 "github.com/openshift/api/build/v1"
-	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/openshift/builder/pkg/imagereference"
 )
 
 func pullDaemonlessImage(sc types.SystemContext, store storage.Store, imageName string, authConfig docker.AuthConfiguration) error {
@@ -35,8 +40,9 @@ func pullDaemonlessImage(sc types.SystemContext, store storage.Store, imageName
 	if imageName == "" {
 		return fmt.Errorf("unable to pull using empty image name")
 	}
+	logSignaturePolicyDecision(imageName)
 
-	_, err := alltransports.ParseImageName("docker://" + imageName)
+	srcRef, err := alltransports.ParseImageName("docker://" + imageName)
 	if err != nil {
 		return fmt.Errorf("error parsing image name to pull %s: %v", "docker://"+imageName, err)
 	}
@@ -52,13 +58,9 @@ func pullDaemonlessImage(sc types.SystemContext, store storage.Store, imageName
 		return fmt.Errorf("error reading system registries configuration: %v", err)
 	}
 
-	ref, err := reference.Parse(imageName)
+	ref, err := imagereference.Resolve(imageName)
 	if err != nil {
-		return fmt.Errorf("error parsing image name %s: %v", ref, err)
-	}
-	if ref.Registry == "" {
-		glog.V(2).Infof("defaulting registry to docker.io for image %s", imageName)
-		ref.Registry = "docker.io"
+		return err
 	}
 
 	if authConfig.Username != "" && authConfig.Password != "" {
@@ -80,16 +82,53 @@ func pullDaemonlessImage(sc types.SystemContext, store storage.Store, imageName
 		glog.V(2).Infof("Registry for %q is not present in the registries configuration, assuming it is secure.", imageName)
 	}
 
-	options := buildah.PullOptions{
-		ReportWriter:  os.Stderr,
-		Store:         store,
-		SystemContext: &systemContext,
+	watcher, err := newStallWatcher(context.Background(), os.Stderr, "pull of "+imageName)
+	if err != nil {
+		return err
 	}
-	_, err = buildah.Pull(context.TODO(), "docker://"+imageName, options)
-	return err
+	defer watcher.Stop()
+
+	// The copy is driven directly through containers/image rather than
+	// buildah.Pull, so the progress channel is available for periodic
+	// progress reports in the build log.
+	destRef, err := is.Transport.ParseStoreReference(store, imageName)
+	if err != nil {
+		return fmt.Errorf("error parsing image name %q: %v", imageName, err)
+	}
+	policy, err := signature.DefaultPolicy(&systemContext)
+	if err != nil {
+		return fmt.Errorf("error obtaining default signature policy: %v", err)
+	}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return fmt.Errorf("error creating new signature policy context: %v", err)
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			glog.V(2).Infof("Error destroying signature policy context: %v", err)
+		}
+	}()
+
+	copyOptions := &cp.Options{
+		ReportWriter:   watcher.Writer(),
+		SourceCtx:      &systemContext,
+		DestinationCtx: &systemContext,
+	}
+	progressInterval, err := pullProgressIntervalFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if progressInterval > 0 {
+		monitor := newPullProgressMonitor(imageName, os.Stderr, progressInterval)
+		defer monitor.Stop()
+		copyOptions.Progress = monitor.Channel()
+		copyOptions.ProgressInterval = copyProgressInterval
+	}
+	err = cp.Image(watcher.Context(), policyContext, destRef, srcRef, copyOptions)
+	return watcher.Err(err)
 }
 
-func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation buildah.Isolation, dir string, optimization buildapiv1.ImageOptimizationPolicy, opts *docker.BuildImageOptions) error {
+func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation buildah.Isolation, dir string, optimization buildapiv1.ImageOptimizationPolicy, annotations []string, opts *docker.BuildImageOptions) error {
 	glog.V(2).Infof("Building...")
 
 	args := make(map[string]string)
@@ -108,7 +147,9 @@ func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation
 	case buildapiv1.ImageOptimizationSkipLayers, buildapiv1.ImageOptimizationSkipLayersAndWarn:
 		// layers: false
 	case buildapiv1.ImageOptimizationNone:
-		// layers = true // TODO: enable
+		// Committing every step as its own image enables the content-
+		// addressed instruction cache across builds on the same store.
+		layers = instructionCacheFromEnvironment()
 	default:
 		return fmt.Errorf("internal error: image optimization policy %q not fully implemented", string(optimization))
 	}
@@ -129,7 +170,59 @@ func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation
 		}
 	}
 
+	buildVolumes, err := buildVolumesFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	extraHosts, err := extraHostsFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	networkMode, err := networkModeFromEnvironment()
+	if err != nil {
+		return err
+	}
+	namespaceOptions, networkPolicy := networkNamespaceOptions(networkMode)
+
+	shmSize, err := shmSizeFromEnvironment()
+	if err != nil {
+		return err
+	}
+	ulimits, err := ulimitsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if err := warnUnsupportedPidsLimit(); err != nil {
+		return err
+	}
+
 	var transientMounts []imagebuildah.Mount
+	// Build volumes are transient mounts, so their content is visible to the
+	// build steps but never committed into image layers.
+	for _, volume := range buildVolumes {
+		transientMounts = append(transientMounts, imagebuildah.Mount{
+			Source:      volume.SourcePath,
+			Destination: volume.DestinationPath,
+			Type:        "bind",
+			Options:     []string{"ro", "nodev", "nosuid"},
+		})
+	}
+	cacheVolumes, err := s2iDependencyCacheMounts()
+	if err != nil {
+		return err
+	}
+	// Dependency caches must be writable so the package managers can add to
+	// them.
+	for _, volume := range cacheVolumes {
+		transientMounts = append(transientMounts, imagebuildah.Mount{
+			Source:      volume.SourcePath,
+			Destination: volume.DestinationPath,
+			Type:        "bind",
+			Options:     []string{"rw", "nodev", "nosuid"},
+		})
+	}
 	if st, err := os.Stat("/run/secrets"); err == nil && st.IsDir() {
 		// Add a non-recursive bind of /run/secrets, to pass along
 		// anything that the runtime mounted from the node into our
@@ -143,25 +236,39 @@ func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation
 		})
 	}
 
+	output := opts.OutputStream
+	var cacheStats *cacheStatsWriter
+	if layers {
+		cacheStats = newCacheStatsWriter(output)
+		output = cacheStats
+		defer cacheStats.report()
+	}
+
 	options := imagebuildah.BuildOptions{
 		ContextDirectory: dir,
 		PullPolicy:       pullPolicy,
 		Isolation:        isolation,
 		TransientMounts:  transientMounts,
 		Args:             args,
+		Annotations:      annotations,
 		Output:           opts.Name,
-		Out:              opts.OutputStream,
-		Err:              opts.OutputStream,
-		ReportWriter:     opts.OutputStream,
+		Out:              output,
+		Err:              output,
+		ReportWriter:     output,
 		OutputFormat:     buildah.Dockerv2ImageManifest,
 		SystemContext:    &systemContext,
-		NamespaceOptions: buildah.NamespaceOptions{
-			{Name: string(specs.NetworkNamespace), Host: true},
-		},
+		NamespaceOptions: namespaceOptions,
+		ConfigureNetwork: networkPolicy,
 		CommonBuildOpts: &buildah.CommonBuildOptions{
+			AddHost:      extraHosts,
 			Memory:       opts.Memory,
 			MemorySwap:   opts.Memswap,
 			CgroupParent: opts.CgroupParent,
+			CPUShares:    uint64(opts.CPUShares),
+			CPUQuota:     opts.CPUQuota,
+			CPUPeriod:    uint64(opts.CPUPeriod),
+			ShmSize:      shmSize,
+			Ulimit:       ulimits,
 		},
 		Layers:                  layers,
 		NoCache:                 opts.NoCache,
@@ -172,6 +279,138 @@ func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation
 	return imagebuildah.BuildDockerfiles(opts.Context, store, options, opts.Dockerfile)
 }
 
+// networkNamespaceOptions maps a validated network mode to buildah namespace
+// settings. "none" creates a fresh network namespace with no interfaces
+// configured; every other mode shares the build pod's namespace, which is the
+// host namespace from buildah's point of view.
+func networkNamespaceOptions(mode string) (buildah.NamespaceOptions, buildah.NetworkConfigurationPolicy) {
+	if mode == networkModeNone {
+		return buildah.NamespaceOptions{
+			{Name: string(specs.NetworkNamespace)},
+		}, buildah.NetworkDisabled
+	}
+	return buildah.NamespaceOptions{
+		{Name: string(specs.NetworkNamespace), Host: true},
+	}, buildah.NetworkDefault
+}
+
+// overrideDaemonlessImageConfig rewrites the configuration of a committed
+// local image and commits the result back under the same name. The commit
+// adds an empty layer on top, but does not change any of the image content.
+func overrideDaemonlessImageConfig(sc types.SystemContext, store storage.Store, imageName string, overrides *imageConfigOverrides) error {
+	systemContext := sc
+	builder, err := buildah.NewBuilder(context.TODO(), store, buildah.BuilderOptions{
+		FromImage:     imageName,
+		SystemContext: &systemContext,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := builder.Delete(); err != nil {
+			glog.V(0).Infof("Error deleting container %q(%s): %v", builder.Container, builder.ContainerID, err)
+		}
+	}()
+
+	if len(overrides.Entrypoint) > 0 {
+		builder.SetEntrypoint(overrides.Entrypoint)
+	}
+	if len(overrides.Cmd) > 0 {
+		builder.SetCmd(overrides.Cmd)
+	}
+	if len(overrides.User) > 0 {
+		builder.SetUser(overrides.User)
+	}
+	if len(overrides.WorkingDir) > 0 {
+		builder.SetWorkDir(overrides.WorkingDir)
+	}
+	if len(overrides.ExposedPorts) > 0 {
+		builder.ClearPorts()
+		for _, port := range overrides.ExposedPorts {
+			builder.SetPort(port)
+		}
+	}
+	for _, env := range overrides.Env {
+		spec := strings.SplitN(env, "=", 2)
+		builder.SetEnv(spec[0], spec[1])
+	}
+
+	dest, err := is.Transport.ParseStoreReference(store, imageName)
+	if err != nil {
+		return err
+	}
+	_, err = builder.Commit(context.TODO(), dest, buildah.CommitOptions{
+		PreferredManifestType: buildah.Dockerv2ImageManifest,
+		SystemContext:         &systemContext,
+	})
+	return err
+}
+
+// exportDaemonlessImage writes a local image as an oci-archive to path, or to
+// stdout when path is "-". The archive transport needs a seekable file, so
+// the stdout case stages the archive in a temporary file first.
+func exportDaemonlessImage(sc types.SystemContext, store storage.Store, imageName, path string) error {
+	glog.V(2).Infof("Exporting image %q from local storage to %q.", imageName, path)
+
+	systemContext := sc
+
+	streamToStdout := path == "-"
+	if streamToStdout {
+		tempFile, err := ioutil.TempFile("", "oci-archive")
+		if err != nil {
+			return err
+		}
+		tempFile.Close()
+		defer os.Remove(tempFile.Name())
+		path = tempFile.Name()
+	}
+
+	dest, err := alltransports.ParseImageName("oci-archive:" + path)
+	if err != nil {
+		return fmt.Errorf("error parsing export destination %s: %v", "oci-archive:"+path, err)
+	}
+	options := buildah.PushOptions{
+		ReportWriter:  os.Stderr,
+		Store:         store,
+		SystemContext: &systemContext,
+	}
+	if err := buildah.Push(context.Background(), imageName, dest, options); err != nil {
+		return fmt.Errorf("error exporting image %s: %v", imageName, err)
+	}
+
+	if streamToStdout {
+		archive, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		if _, err := io.Copy(os.Stdout, archive); err != nil {
+			return fmt.Errorf("error streaming exported image to stdout: %v", err)
+		}
+	}
+	return nil
+}
+
+// daemonlessImageHistory returns the history of a local image, as recorded in
+// its OCI configuration.
+func daemonlessImageHistory(sc types.SystemContext, store storage.Store, imageName string) ([]ociv1.History, error) {
+	systemContext := sc
+	ref, err := is.Transport.ParseStoreReference(store, imageName)
+	if err != nil {
+		return nil, err
+	}
+	img, err := ref.NewImage(context.TODO(), &systemContext)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	config, err := img.OCIConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return config.History, nil
+}
+
 func tagDaemonlessImage(sc types.SystemContext, store storage.Store, buildTag, pushTag string) error {
 	glog.V(2).Infof("Tagging local image %q with name %q.", buildTag, pushTag)
 
@@ -228,6 +467,45 @@ func removeDaemonlessImage(sc types.SystemContext, store storage.Store, buildTag
 	return nil
 }
 
+// BuildCompressionEnvVar overrides the automatically selected layer
+// compression for pushes. Supported values are "gzip" and "uncompressed";
+// anything the vendored image stack cannot produce falls back to gzip with a
+// warning rather than failing the push.
+const BuildCompressionEnvVar = "BUILD_COMPRESSION"
+
+// uncompressedPushSizeLimit is the image size below which pushing to a
+// cluster-local (insecure) registry skips compression: on a fast link the
+// gzip CPU time costs more than the bandwidth it saves.
+const uncompressedPushSizeLimit = int64(64 * 1024 * 1024)
+
+// selectPushCompression picks the compression applied to layers while
+// pushing, based on what we know about the destination registry and the image
+// size, honoring an explicit BUILD_COMPRESSION override. The negotiated
+// choice is logged so users can tell what was sent.
+func selectPushCompression(sc types.SystemContext, store storage.Store, imageName string, insecureRegistry bool) archive.Compression {
+	switch strings.ToLower(os.Getenv(BuildCompressionEnvVar)) {
+	case "gzip":
+		glog.V(0).Infof("Using gzip layer compression as configured")
+		return archive.Gzip
+	case "uncompressed", "none":
+		glog.V(0).Infof("Pushing uncompressed layers as configured")
+		return archive.Uncompressed
+	case "":
+	default:
+		glog.V(0).Infof("warning: unsupported %s value %q, falling back to gzip", BuildCompressionEnvVar, os.Getenv(BuildCompressionEnvVar))
+		return archive.Gzip
+	}
+
+	if insecureRegistry {
+		if image, err := inspectDaemonlessImage(sc, store, imageName); err == nil && image.Size < uncompressedPushSizeLimit {
+			glog.V(0).Infof("Pushing %d uncompressed bytes to cluster-local registry", image.Size)
+			return archive.Uncompressed
+		}
+	}
+	glog.V(2).Infof("Using gzip layer compression for push")
+	return archive.Gzip
+}
+
 func pushDaemonlessImage(sc types.SystemContext, store storage.Store, imageName string, authConfig docker.AuthConfiguration) error {
 	glog.V(2).Infof("Pushing image %q from local storage.", imageName)
 
@@ -260,11 +538,13 @@ func pushDaemonlessImage(sc types.SystemContext, store storage.Store, imageName
 	if err != nil {
 		return fmt.Errorf("error reading system registries configuration: %v", err)
 	}
+	insecureRegistry := false
 	if registry := sysregistriesv2.FindRegistry(imageName, registries); registry != nil {
 		if registry.Insecure {
 			glog.V(2).Infof("Registry %q is marked as insecure in the registries configuration.", registry.URL)
 			systemContext.DockerInsecureSkipTLSVerify = true
 			systemContext.OCIInsecureSkipTLSVerify = true
+			insecureRegistry = true
 		} else {
 			glog.V(2).Infof("Registry %q is marked as secure in the registries configuration.", registry.URL)
 		}
@@ -272,13 +552,20 @@ func pushDaemonlessImage(sc types.SystemContext, store storage.Store, imageName
 		glog.V(2).Infof("Registry for %q is not present in the registries configuration, assuming it is secure.", imageName)
 	}
 
+	watcher, err := newStallWatcher(context.Background(), os.Stdout, "push of "+imageName)
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
 	options := buildah.PushOptions{
-		ReportWriter:  os.Stdout,
+		Compression:   selectPushCompression(sc, store, imageName, insecureRegistry),
+		ReportWriter:  watcher.Writer(),
 		Store:         store,
 		SystemContext: &systemContext,
 	}
 
-	return buildah.Push(context.TODO(), imageName, dest, options)
+	return watcher.Err(buildah.Push(watcher.Context(), imageName, dest, options))
 }
 
 func inspectDaemonlessImage(sc types.SystemContext, store storage.Store, name string) (*docker.Image, error) {
@@ -344,6 +631,22 @@ func inspectDaemonlessImage(sc types.SystemContext, store storage.Store, name st
 		created = *oconfig.Created
 	}
 
+	repoDigests := []string{}
+	if img.Digest != "" {
+		seen := map[string]struct{}{}
+		for _, imgName := range img.Names {
+			if strings.Contains(imgName, "@") {
+				continue
+			}
+			repository, _ := docker.ParseRepositoryTag(imgName)
+			if _, ok := seen[repository]; ok {
+				continue
+			}
+			seen[repository] = struct{}{}
+			repoDigests = append(repoDigests, repository+"@"+img.Digest.String())
+		}
+	}
+
 	return &docker.Image{
 		ID:              img.ID,
 		RepoTags:        []string{},
@@ -358,7 +661,7 @@ func inspectDaemonlessImage(sc types.SystemContext, store storage.Store, name st
 		Architecture:    oconfig.Architecture,
 		Size:            size,
 		VirtualSize:     size,
-		RepoDigests:     []string{},
+		RepoDigests:     repoDigests,
 		RootFS:          rootfs,
 		OS:              oconfig.OS,
 	}, nil
@@ -374,13 +677,32 @@ func daemonlessRun(ctx context.Context, store storage.Store, isolation buildah.I
 		return fmt.Errorf("error calling daemonlessRun: expected a HostConfig")
 	}
 
+	extraHosts, err := extraHostsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	shmSize, err := shmSizeFromEnvironment()
+	if err != nil {
+		return err
+	}
+	ulimits, err := ulimitsFromEnvironment()
+	if err != nil {
+		return err
+	}
+
 	builderOptions := buildah.BuilderOptions{
 		Container: createOpts.Name,
 		FromImage: createOpts.Config.Image,
 		CommonBuildOpts: &buildah.CommonBuildOptions{
+			AddHost:      append(append([]string{}, createOpts.HostConfig.ExtraHosts...), extraHosts...),
 			Memory:       createOpts.HostConfig.Memory,
 			MemorySwap:   createOpts.HostConfig.MemorySwap,
 			CgroupParent: createOpts.HostConfig.CgroupParent,
+			CPUShares:    uint64(createOpts.HostConfig.CPUShares),
+			CPUQuota:     createOpts.HostConfig.CPUQuota,
+			CPUPeriod:    uint64(createOpts.HostConfig.CPUPeriod),
+			ShmSize:      shmSize,
+			Ulimit:       ulimits,
 		},
 	}
 
@@ -398,12 +720,20 @@ func daemonlessRun(ctx context.Context, store storage.Store, isolation buildah.I
 	if len(entrypoint) == 0 {
 		entrypoint = builder.Entrypoint()
 	}
+	networkMode, err := networkModeFromEnvironment()
+	if err != nil {
+		return err
+	}
+	namespaceOptions, networkPolicy := networkNamespaceOptions(networkMode)
+
 	runOptions := buildah.RunOptions{
-		Isolation:  isolation,
-		Entrypoint: entrypoint,
-		Cmd:        createOpts.Config.Cmd,
-		Stdout:     attachOpts.OutputStream,
-		Stderr:     attachOpts.ErrorStream,
+		Isolation:        isolation,
+		Entrypoint:       entrypoint,
+		Cmd:              createOpts.Config.Cmd,
+		Stdout:           attachOpts.OutputStream,
+		Stderr:           attachOpts.ErrorStream,
+		NamespaceOptions: namespaceOptions,
+		ConfigureNetwork: networkPolicy,
 	}
 
 	return builder.Run(append(entrypoint, createOpts.Config.Cmd...), runOptions)
@@ -482,7 +812,7 @@ func GetDaemonlessClient(systemContext types.SystemContext, store storage.Store,
 }
 
 func (d *DaemonlessClient) BuildImage(opts docker.BuildImageOptions) error {
-	return buildDaemonlessImage(d.SystemContext, d.Store, d.Isolation, opts.ContextDir, buildapiv1.ImageOptimizationNone, &opts)
+	return buildDaemonlessImage(d.SystemContext, d.Store, d.Isolation, opts.ContextDir, buildapiv1.ImageOptimizationNone, nil, &opts)
 }
 
 func (d *DaemonlessClient) PushImage(opts docker.PushImageOptions, auth docker.AuthConfiguration) error {
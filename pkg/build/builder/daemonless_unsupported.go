@@ -8,6 +8,7 @@ import (
 	"github.com/containers/image/types"
 	"github.com/containers/storage"
 	docker "github.com/fsouza/go-dockerclient"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	buildapiv1 "github.com/openshift/api/build/v1"
 	"github.com/pkg/errors"
 )
@@ -51,9 +52,18 @@ func (d *DaemonlessClient) TagImage(name string, opts docker.TagImageOptions) er
 func daemonlessRun(ctx context.Context, store storage.Store, isolation Isolation, createOpts docker.CreateContainerOptions, attachOpts docker.AttachToContainerOptions) error {
 	return errors.New("running containers not supported on this platform")
 }
-func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation Isolation, dir string, optimization buildapiv1.ImageOptimizationPolicy, opts *docker.BuildImageOptions) error {
+func buildDaemonlessImage(sc types.SystemContext, store storage.Store, isolation Isolation, dir string, optimization buildapiv1.ImageOptimizationPolicy, annotations []string, opts *docker.BuildImageOptions) error {
 	return errors.New("running building images not supported on this platform")
 }
+func overrideDaemonlessImageConfig(sc types.SystemContext, store storage.Store, imageName string, overrides *imageConfigOverrides) error {
+	return errors.New("overriding image configuration not supported on this platform")
+}
+func daemonlessImageHistory(sc types.SystemContext, store storage.Store, imageName string) ([]ociv1.History, error) {
+	return nil, errors.New("inspecting image history not supported on this platform")
+}
+func exportDaemonlessImage(sc types.SystemContext, store storage.Store, imageName, path string) error {
+	return errors.New("exporting images not supported on this platform")
+}
 
 // GetDaemonlessClient returns an error.
 func GetDaemonlessClient(systemContext types.SystemContext, store storage.Store, isolationSpec string) (client DockerClient, err error) {
@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Magic numbers identifying compressed binary build input streams that
+// bsdtar cannot be relied on to decompress itself. gzip and zip inputs are
+// left to bsdtar, which has always handled them.
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// decompressionCommand sniffs the buffered stream and returns the command
+// to decompress it to stdout, or nil if the stream needs no external
+// decompression. Nothing is consumed from the stream.
+func decompressionCommand(in *bufio.Reader) []string {
+	prefix, err := in.Peek(len(xzMagic))
+	if err != nil && len(prefix) < len(zstdMagic) {
+		return nil
+	}
+	switch {
+	case bytes.HasPrefix(prefix, zstdMagic):
+		return []string{"zstd", "-d", "-c"}
+	case bytes.HasPrefix(prefix, xzMagic):
+		return []string{"xz", "-d", "-c"}
+	}
+	return nil
+}
+
+// decompressArchive filters the stream through the given decompression
+// command and extracts the resulting archive into dir.
+func decompressArchive(in io.Reader, dir string, command []string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = in
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to run %s to decompress the binary build input: %v", command[0], err)
+	}
+	extractErr := extractArchive(stdout, dir)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("unable to decompress the binary build input with %s: %v", command[0], err)
+	}
+	return extractErr
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecompressionCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{
+			name:     "zstd",
+			input:    []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x00},
+			expected: "zstd",
+		},
+		{
+			name:     "xz",
+			input:    []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00},
+			expected: "xz",
+		},
+		{
+			name:  "gzip is left to bsdtar",
+			input: []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00},
+		},
+		{
+			name:  "plain tar",
+			input: bytes.Repeat([]byte{0x00}, 512),
+		},
+		{
+			name:  "short stream",
+			input: []byte{0x28},
+		},
+		{
+			name:  "empty stream",
+			input: nil,
+		},
+	}
+	for _, tc := range testCases {
+		in := bufio.NewReader(bytes.NewBuffer(tc.input))
+		command := decompressionCommand(in)
+		actual := ""
+		if command != nil {
+			actual = command[0]
+		}
+		if actual != tc.expected {
+			t.Errorf("[%s] expected command %q, got %q", tc.name, tc.expected, actual)
+			continue
+		}
+		// Detection must not consume the stream.
+		rest, err := ioutil.ReadAll(in)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", tc.name, err)
+		}
+		if !bytes.Equal(rest, tc.input) {
+			t.Errorf("[%s] detection consumed input, %d of %d bytes remain", tc.name, len(rest), len(tc.input))
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package builder
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContextCacheEnvVar is the name of the environment variable that names a
+// directory, typically a persistent volume shared by a build config's builds,
+// where the most recently uploaded binary build context is cached. The cache
+// is what differential uploads are reconstructed against.
+const ContextCacheEnvVar = "BUILD_CONTEXT_CACHE_PATH"
+
+// deltaContextMagic introduces a differential binary build context on the
+// input stream. Clients that uploaded a full context previously can send
+// only the changed files, together with a manifest naming the unchanged
+// files to take from the cache.
+const deltaContextMagic = "OSBDELTA1\n"
+
+// contextCacheDirName is the subdirectory of the context cache that holds
+// the file tree of the previous context.
+const contextCacheDirName = "context"
+
+// contextCacheIDFile is the file in the context cache that records the
+// identifier of the cached context.
+const contextCacheIDFile = "id"
+
+// contextDeltaFile names one file of the previous context that the new
+// context reuses unchanged.
+type contextDeltaFile struct {
+	// Path is the file's location, relative to the context root.
+	Path string `json:"path"`
+	// SHA256 is the hex digest of the file's content, verified against the
+	// cached copy before it is reused.
+	SHA256 string `json:"sha256"`
+}
+
+// contextDeltaManifest describes a differential context upload. It is sent
+// as a single line of JSON following the magic header, and is followed by an
+// archive of the new and changed files.
+type contextDeltaManifest struct {
+	// BaseID identifies the cached context the delta was computed against.
+	BaseID string `json:"baseId"`
+	// ID identifies the context that reconstruction produces; it is recorded
+	// in the cache for the next build to reference.
+	ID string `json:"id"`
+	// Reused lists the files to take unmodified from the cached context.
+	Reused []contextDeltaFile `json:"reused"`
+}
+
+// isDeltaContext reports whether the buffered stream begins with a
+// differential context upload, without consuming any of it.
+func isDeltaContext(in *bufio.Reader) bool {
+	prefix, err := in.Peek(len(deltaContextMagic))
+	if err != nil {
+		return false
+	}
+	return string(prefix) == deltaContextMagic
+}
+
+// readDeltaManifest consumes the magic header and the manifest line from the
+// stream, leaving the reader positioned at the start of the archive of
+// changed files.
+func readDeltaManifest(in *bufio.Reader) (*contextDeltaManifest, error) {
+	if _, err := in.Discard(len(deltaContextMagic)); err != nil {
+		return nil, err
+	}
+	line, err := in.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the delta context manifest: %v", err)
+	}
+	manifest := &contextDeltaManifest{}
+	if err := json.Unmarshal(line, manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse the delta context manifest: %v", err)
+	}
+	if len(manifest.ID) == 0 {
+		return nil, fmt.Errorf("the delta context manifest does not identify the context it produces")
+	}
+	return manifest, nil
+}
+
+// extractDeltaContext reconstructs a full build context in dir from a
+// differential upload, copying unchanged files out of the context cache and
+// extracting the changed files from the remainder of the stream. On success
+// the cache is refreshed to hold the reconstructed context.
+func extractDeltaContext(in *bufio.Reader, dir string) error {
+	cacheDir := strings.TrimSpace(os.Getenv(ContextCacheEnvVar))
+	if len(cacheDir) == 0 {
+		return fmt.Errorf("a differential context was received, but no context cache is configured; set %s or retry with a full upload", ContextCacheEnvVar)
+	}
+
+	manifest, err := readDeltaManifest(in)
+	if err != nil {
+		return err
+	}
+
+	cachedID, err := readCachedContextID(cacheDir)
+	if err != nil {
+		return err
+	}
+	if cachedID != manifest.BaseID {
+		return fmt.Errorf("the differential context was computed against context %q, but the cache holds %q; retry with a full upload", manifest.BaseID, cachedID)
+	}
+
+	glog.V(0).Infof("Receiving differential source from STDIN, reusing %d files from context %s ...", len(manifest.Reused), manifest.BaseID)
+
+	for _, reused := range manifest.Reused {
+		if err := restoreCachedFile(filepath.Join(cacheDir, contextCacheDirName), dir, reused); err != nil {
+			return err
+		}
+	}
+
+	if err := extractArchive(in, dir); err != nil {
+		return err
+	}
+
+	if err := refreshContextCache(cacheDir, dir, manifest.ID); err != nil {
+		// The build context is complete; a stale cache only degrades the
+		// next upload, so it does not fail this build.
+		glog.V(0).Infof("warning: Unable to refresh the build context cache: %v", err)
+	}
+	return nil
+}
+
+// contextRelativePath validates a path from a delta manifest and resolves it
+// below root, rejecting anything that would escape it.
+func contextRelativePath(root, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("the delta context manifest names an absolute path %q", path)
+	}
+	cleaned := filepath.Clean(path)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("the delta context manifest names a path %q outside the context", path)
+	}
+	return filepath.Join(root, cleaned), nil
+}
+
+// restoreCachedFile copies one unchanged file from the cached context into
+// the reconstructed context, verifying its digest.
+func restoreCachedFile(cacheDir, dir string, reused contextDeltaFile) error {
+	src, err := contextRelativePath(cacheDir, reused.Path)
+	if err != nil {
+		return err
+	}
+	dst, err := contextRelativePath(dir, reused.Path)
+	if err != nil {
+		return err
+	}
+
+	digest, mode, err := fileDigest(src)
+	if err != nil {
+		return fmt.Errorf("the cached context does not contain %q; retry with a full upload: %v", reused.Path, err)
+	}
+	if digest != reused.SHA256 {
+		return fmt.Errorf("the cached copy of %q does not match the manifest; retry with a full upload", reused.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return copyFileContent(src, dst, mode)
+}
+
+// fileDigest returns the hex SHA-256 digest and mode of a regular file.
+func fileDigest(path string) (string, os.FileMode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%q is not a regular file", path)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), info.Mode(), nil
+}
+
+// copyFileContent copies a regular file, preserving its mode.
+func copyFileContent(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readCachedContextID returns the identifier of the cached context, or an
+// empty string if the cache is empty.
+func readCachedContextID(cacheDir string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(cacheDir, contextCacheIDFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read the context cache: %v", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// refreshContextCache replaces the cached context with a copy of the
+// reconstructed one and records its identifier.
+func refreshContextCache(cacheDir, dir, id string) error {
+	contextDir := filepath.Join(cacheDir, contextCacheDirName)
+	if err := os.RemoveAll(contextDir); err != nil {
+		return err
+	}
+	if err := copyContextTree(dir, contextDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cacheDir, contextCacheIDFile), []byte(id+"\n"), 0644)
+}
+
+// copyContextTree copies the regular files and directories of a context
+// tree. Anything else (sockets, devices) has no business in a build context
+// and is skipped.
+func copyContextTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFileContent(path, target, info.Mode())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return nil
+		}
+	})
+}
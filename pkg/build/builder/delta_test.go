@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsDeltaContext(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: deltaContextMagic + `{"id":"abc"}` + "\n", expected: true},
+		{input: "not an archive at all", expected: false},
+		{input: "", expected: false},
+	}
+	for _, tc := range testCases {
+		in := bufio.NewReader(bytes.NewBufferString(tc.input))
+		if actual := isDeltaContext(in); actual != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.input, tc.expected, actual)
+		}
+		// Detection must not consume the stream.
+		rest, err := ioutil.ReadAll(in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+		}
+		if string(rest) != tc.input {
+			t.Errorf("%q: detection consumed input, %q remains", tc.input, string(rest))
+		}
+	}
+}
+
+func TestReadDeltaManifest(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		expectErr bool
+	}{
+		{
+			name:  "valid",
+			input: deltaContextMagic + `{"baseId":"one","id":"two","reused":[{"path":"main.go","sha256":"abc"}]}` + "\n",
+		},
+		{
+			name:      "missing id",
+			input:     deltaContextMagic + `{"baseId":"one"}` + "\n",
+			expectErr: true,
+		},
+		{
+			name:      "not json",
+			input:     deltaContextMagic + "garbage\n",
+			expectErr: true,
+		},
+		{
+			name:      "truncated",
+			input:     deltaContextMagic + `{"id":"two"`,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		manifest, err := readDeltaManifest(bufio.NewReader(bytes.NewBufferString(tc.input)))
+		if tc.expectErr != (err != nil) {
+			t.Errorf("[%s] expected error %v, got %v", tc.name, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && manifest.ID != "two" {
+			t.Errorf("[%s] expected id %q, got %q", tc.name, "two", manifest.ID)
+		}
+	}
+}
+
+func TestContextRelativePath(t *testing.T) {
+	testCases := []struct {
+		path      string
+		expected  string
+		expectErr bool
+	}{
+		{path: "main.go", expected: "/root/main.go"},
+		{path: "pkg/util/util.go", expected: "/root/pkg/util/util.go"},
+		{path: "pkg/../main.go", expected: "/root/main.go"},
+		{path: "../escape", expectErr: true},
+		{path: "pkg/../../escape", expectErr: true},
+		{path: "/etc/passwd", expectErr: true},
+	}
+	for _, tc := range testCases {
+		resolved, err := contextRelativePath("/root", tc.path)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.path, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && resolved != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.path, tc.expected, resolved)
+		}
+	}
+}
+
+func TestRestoreCachedFile(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "delta-cache")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	dir, err := ioutil.TempDir("", "delta-context")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("cached content\n")
+	if err := os.MkdirAll(filepath.Join(cacheDir, "pkg"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, "pkg", "kept.go"), content, 0640); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	digest, _, err := fileDigest(filepath.Join(cacheDir, "pkg", "kept.go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := restoreCachedFile(cacheDir, dir, contextDeltaFile{Path: "pkg/kept.go", SHA256: digest}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored, err := ioutil.ReadFile(filepath.Join(dir, "pkg", "kept.go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(restored, content) {
+		t.Errorf("expected %q, got %q", content, restored)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "pkg", "kept.go")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if info.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640, got %v", info.Mode().Perm())
+	}
+
+	if err := restoreCachedFile(cacheDir, dir, contextDeltaFile{Path: "pkg/kept.go", SHA256: "0000"}); err == nil {
+		t.Errorf("expected a digest mismatch to fail")
+	}
+	if err := restoreCachedFile(cacheDir, dir, contextDeltaFile{Path: "missing.go", SHA256: digest}); err == nil {
+		t.Errorf("expected a missing cached file to fail")
+	}
+}
+
+func TestRefreshContextCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "delta-cache")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	dir, err := ioutil.TempDir("", "delta-context")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "pkg", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := refreshContextCache(cacheDir, dir, "context-2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	id, err := readCachedContextID(cacheDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "context-2" {
+		t.Errorf("expected cached id %q, got %q", "context-2", id)
+	}
+	cached, err := ioutil.ReadFile(filepath.Join(cacheDir, contextCacheDirName, "pkg", "main.go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(cached) != "package main\n" {
+		t.Errorf("unexpected cached content %q", string(cached))
+	}
+}
+
+func TestReadCachedContextIDEmptyCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "delta-cache")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	id, err := readCachedContextID(cacheDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "" {
+		t.Errorf("expected an empty id for an empty cache, got %q", id)
+	}
+}
@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// S2ICachePathEnvVar is the name of the environment variable pointing at a
+// volume that persists language dependency caches between S2I builds of the
+// same BuildConfig. When set, per-ecosystem cache directories are created
+// under it, mounted writable into the assemble container, and the matching
+// package manager environment variables are injected so Maven, npm, pip,
+// and the Go module proxy reuse dependencies downloaded by earlier builds.
+const S2ICachePathEnvVar = "BUILD_S2I_CACHE_PATH"
+
+// s2iCacheMountBase is where the cache directories appear inside the
+// assemble container.
+const s2iCacheMountBase = "/var/cache/s2i"
+
+// s2iCacheEcosystem describes one language ecosystem whose dependency cache
+// the builder knows how to persist.
+type s2iCacheEcosystem struct {
+	// name is the cache subdirectory on the cache volume.
+	name string
+	// environment points the ecosystem's package manager at the mounted
+	// cache.
+	environment string
+}
+
+var s2iCacheEcosystems = []s2iCacheEcosystem{
+	{name: "m2", environment: "MAVEN_OPTS=-Dmaven.repo.local=" + s2iCacheMountBase + "/m2"},
+	{name: "npm", environment: "npm_config_cache=" + s2iCacheMountBase + "/npm"},
+	{name: "pip", environment: "PIP_CACHE_DIR=" + s2iCacheMountBase + "/pip"},
+	{name: "gomod", environment: "GOMODCACHE=" + s2iCacheMountBase + "/gomod"},
+}
+
+// s2iDependencyCachePath returns the configured cache volume path.
+func s2iDependencyCachePath() string {
+	return strings.TrimSpace(os.Getenv(S2ICachePathEnvVar))
+}
+
+// s2iDependencyCacheMounts prepares the per-ecosystem cache directories on
+// the cache volume and returns the mounts exposing them to the assemble
+// container. It returns nothing when no cache volume is configured.
+func s2iDependencyCacheMounts() ([]buildVolume, error) {
+	path := s2iDependencyCachePath()
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("%s must be an absolute path, got %q", S2ICachePathEnvVar, path)
+	}
+	var volumes []buildVolume
+	for _, ecosystem := range s2iCacheEcosystems {
+		source := filepath.Join(path, ecosystem.name)
+		// The assemble user is typically unprivileged and random, so the
+		// cache directories must be writable by everyone.
+		if err := os.MkdirAll(source, 0777); err != nil {
+			return nil, fmt.Errorf("unable to create the dependency cache directory %s: %v", source, err)
+		}
+		volumes = append(volumes, buildVolume{
+			Name:            "s2i-cache-" + ecosystem.name,
+			SourcePath:      source,
+			DestinationPath: filepath.Join(s2iCacheMountBase, ecosystem.name),
+		})
+	}
+	return volumes, nil
+}
+
+// s2iDependencyCacheEnvironment returns the environment entries pointing
+// package managers at the mounted caches, when a cache volume is
+// configured.
+func s2iDependencyCacheEnvironment() []string {
+	if len(s2iDependencyCachePath()) == 0 {
+		return nil
+	}
+	var environment []string
+	for _, ecosystem := range s2iCacheEcosystems {
+		environment = append(environment, ecosystem.environment)
+	}
+	return environment
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestS2IDependencyCacheMounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depcache-test")
+	if err != nil {
+		t.Fatalf("failed to create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(S2ICachePathEnvVar, dir)
+	defer os.Unsetenv(S2ICachePathEnvVar)
+
+	volumes, err := s2iDependencyCacheMounts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(volumes) != len(s2iCacheEcosystems) {
+		t.Fatalf("expected %d volumes, got %d", len(s2iCacheEcosystems), len(volumes))
+	}
+	for i, ecosystem := range s2iCacheEcosystems {
+		expectedSource := filepath.Join(dir, ecosystem.name)
+		if volumes[i].SourcePath != expectedSource {
+			t.Errorf("%s: expected source %q, got %q", ecosystem.name, expectedSource, volumes[i].SourcePath)
+		}
+		if info, err := os.Stat(expectedSource); err != nil || !info.IsDir() {
+			t.Errorf("%s: expected the cache directory to be created: %v", ecosystem.name, err)
+		}
+		if volumes[i].DestinationPath != filepath.Join(s2iCacheMountBase, ecosystem.name) {
+			t.Errorf("%s: unexpected destination %q", ecosystem.name, volumes[i].DestinationPath)
+		}
+	}
+
+	os.Setenv(S2ICachePathEnvVar, "relative/path")
+	if _, err := s2iDependencyCacheMounts(); err == nil {
+		t.Errorf("expected a relative cache path to be rejected")
+	}
+}
+
+func TestS2IDependencyCacheEnvironment(t *testing.T) {
+	os.Unsetenv(S2ICachePathEnvVar)
+	if environment := s2iDependencyCacheEnvironment(); len(environment) != 0 {
+		t.Errorf("expected no environment without a cache volume, got %v", environment)
+	}
+
+	os.Setenv(S2ICachePathEnvVar, "/cache")
+	defer os.Unsetenv(S2ICachePathEnvVar)
+	environment := s2iDependencyCacheEnvironment()
+	if len(environment) != len(s2iCacheEcosystems) {
+		t.Fatalf("expected %d entries, got %d", len(s2iCacheEcosystems), len(environment))
+	}
+	for _, entry := range environment {
+		if !strings.Contains(entry, s2iCacheMountBase) {
+			t.Errorf("expected %q to point into %s", entry, s2iCacheMountBase)
+		}
+	}
+}
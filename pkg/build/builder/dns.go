@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+// ExtraHostsEnvVar names the environment variable carrying a comma-separated
+// list of "hostname:ip" entries to add to the /etc/hosts of containers that
+// run during the build, so RUN instructions and the assemble script can
+// resolve hostnames that are not in cluster DNS.
+const ExtraHostsEnvVar = "BUILD_EXTRA_HOSTS"
+
+// DNSServersEnvVar names the environment variable carrying a comma-separated
+// list of DNS server addresses to consult before the cluster's resolvers.
+const DNSServersEnvVar = "BUILD_DNS_SERVERS"
+
+// resolvConfPath is the builder's own resolver configuration. Build
+// containers run in the host network namespace and receive a copy of this
+// file, so updating it here reaches every build step.
+const resolvConfPath = "/etc/resolv.conf"
+
+// extraHostsFromEnvironment reads the additional host entries from the
+// environment. An unset variable means none were requested.
+func extraHostsFromEnvironment() ([]string, error) {
+	value := os.Getenv(ExtraHostsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var hosts []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || net.ParseIP(parts[1]) == nil {
+			return nil, fmt.Errorf("unable to parse %s entry %q: expected hostname:ip", ExtraHostsEnvVar, entry)
+		}
+		hosts = append(hosts, entry)
+	}
+	return hosts, nil
+}
+
+// dnsServersFromEnvironment reads the additional DNS server addresses from
+// the environment. An unset variable means none were requested.
+func dnsServersFromEnvironment() ([]string, error) {
+	value := os.Getenv(DNSServersEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var servers []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("unable to parse %s entry %q: expected an IP address", DNSServersEnvVar, entry)
+		}
+		servers = append(servers, entry)
+	}
+	return servers, nil
+}
+
+// ConfigureBuildDNS applies the DNS servers requested for this build by
+// prepending them to the builder's resolver configuration, so they are
+// consulted first both by the build steps and by the builder's own clones
+// and pushes. Without any configured servers it is a no-op.
+func ConfigureBuildDNS() error {
+	servers, err := dnsServersFromEnvironment()
+	if err != nil {
+		return err
+	}
+	return prependNameservers(resolvConfPath, servers)
+}
+
+// prependNameservers adds nameserver entries for the given servers to the
+// top of the named resolver configuration, keeping the existing entries as
+// fallbacks. A file that already starts with the entries is left alone, so
+// repeated calls are safe.
+func prependNameservers(path string, servers []string) error {
+	if len(servers) == 0 {
+		return nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	var lines []string
+	for _, server := range servers {
+		lines = append(lines, "nameserver "+server)
+	}
+	header := strings.Join(lines, "\n") + "\n"
+	if strings.HasPrefix(string(content), header) {
+		return nil
+	}
+	if err := ioutil.WriteFile(path, append([]byte(header), content...), 0644); err != nil {
+		return fmt.Errorf("unable to update %s: %v", path, err)
+	}
+	return nil
+}
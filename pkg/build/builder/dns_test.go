@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtraHostsFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []string
+		expectErr bool
+	}{
+		{value: "", expected: nil},
+		{value: "db.internal:10.0.0.5", expected: []string{"db.internal:10.0.0.5"}},
+		{value: "db.internal:10.0.0.5, cache.internal:fd00::5", expected: []string{"db.internal:10.0.0.5", "cache.internal:fd00::5"}},
+		{value: "db.internal", expectErr: true},
+		{value: "db.internal:not-an-ip", expectErr: true},
+		{value: ":10.0.0.5", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(ExtraHostsEnvVar, tc.value)
+		hosts, err := extraHostsFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && !reflect.DeepEqual(hosts, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, hosts)
+		}
+	}
+	os.Unsetenv(ExtraHostsEnvVar)
+}
+
+func TestDNSServersFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []string
+		expectErr bool
+	}{
+		{value: "", expected: nil},
+		{value: "10.0.0.53", expected: []string{"10.0.0.53"}},
+		{value: "10.0.0.53, fd00::53", expected: []string{"10.0.0.53", "fd00::53"}},
+		{value: "dns.internal", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(DNSServersEnvVar, tc.value)
+		servers, err := dnsServersFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && !reflect.DeepEqual(servers, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, servers)
+		}
+	}
+	os.Unsetenv(DNSServersEnvVar)
+}
+
+func TestPrependNameservers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dns-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(path, []byte("nameserver 172.30.0.10\nsearch cluster.local\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	servers := []string{"10.0.0.53", "fd00::53"}
+	for i := 0; i < 2; i++ {
+		if err := prependNameservers(path, servers); err != nil {
+			t.Fatalf("pass %d: unexpected error: %v", i, err)
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "nameserver 10.0.0.53\nnameserver fd00::53\nnameserver 172.30.0.10\nsearch cluster.local\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
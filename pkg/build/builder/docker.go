@@ -6,7 +6,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	dockercmd "github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
@@ -58,6 +60,7 @@ func (d *DockerBuilder) Build() error {
 	ctx := timing.NewContext(context.Background())
 	defer func() {
 		d.build.Status.Stages = timing.AppendStageAndStepInfo(d.build.Status.Stages, timing.GetStages(ctx))
+		RecordResourceUsage(d.build)
 		HandleBuildStatusUpdate(d.build, d.client, nil)
 	}()
 
@@ -90,6 +93,29 @@ func (d *DockerBuilder) Build() error {
 	if len(imageNames) == 0 {
 		return fmt.Errorf("no FROM image in Dockerfile")
 	}
+	prePulled := false
+	if prePullEnabled() {
+		prePullNames := append([]string{}, imageNames...)
+		// Image sources are substituted into the Dockerfile later, so they
+		// are pulled during the build unless fetched here.
+		for _, imageSource := range d.build.Spec.Source.Images {
+			if imageSource.From.Kind == "DockerImage" && len(imageSource.From.Name) != 0 {
+				prePullNames = append(prePullNames, imageSource.From.Name)
+			}
+		}
+		RecordBuildProgress(buildapiv1.StagePullImages)
+		if err := prePullImages(ctx, d.dockerClient, prePullNames, d.build.Spec.Strategy.DockerStrategy.ForcePull, d.pullImage); err != nil {
+			RecordBuildEvent(eventTypeWarning, BuildEventReasonPullFailed, "%v", err)
+			d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			d.build.Status.Reason = buildapiv1.StatusReasonPullBuilderImageFailed
+			d.build.Status.Message = builderutil.StatusMessagePullBuilderImageFailed
+			classifyPullFailure(d.build, strings.Join(prePullNames, ", "), err)
+			classifyRateLimitedPull(d.build, strings.Join(prePullNames, ", "), err)
+			HandleBuildStatusUpdate(d.build, d.client, nil)
+			return err
+		}
+		prePulled = true
+	}
 	for _, imageName := range imageNames {
 		if imageName == "scratch" {
 			glog.V(4).Infof("\nSkipping image \"scratch\"")
@@ -104,21 +130,25 @@ func (d *DockerBuilder) Build() error {
 			imageExists = false
 		}
 		// if forcePull or the image does not exist on the node we should pull the image first
-		if d.build.Spec.Strategy.DockerStrategy.ForcePull || !imageExists {
+		if (d.build.Spec.Strategy.DockerStrategy.ForcePull && !prePulled) || !imageExists {
 			pullAuthConfig, _ := dockercfg.NewHelper().GetDockerAuth(
 				imageName,
 				dockercfg.PullAuthType,
 			)
 			glog.V(0).Infof("\nPulling image %s ...", imageName)
+			RecordBuildProgress(buildapiv1.StagePullImages)
 			startTime := metav1.Now()
 			err = d.pullImage(imageName, pullAuthConfig)
 
 			timing.RecordNewStep(ctx, buildapiv1.StagePullImages, buildapiv1.StepPullBaseImage, startTime, metav1.Now())
 
 			if err != nil {
+				RecordBuildEvent(eventTypeWarning, BuildEventReasonPullFailed, "Failed to pull image %s: %v", imageName, err)
 				d.build.Status.Phase = buildapiv1.BuildPhaseFailed
 				d.build.Status.Reason = buildapiv1.StatusReasonPullBuilderImageFailed
 				d.build.Status.Message = builderutil.StatusMessagePullBuilderImageFailed
+				classifyPullFailure(d.build, imageName, err)
+				classifyRateLimitedPull(d.build, imageName, err)
 				HandleBuildStatusUpdate(d.build, d.client, nil)
 				return fmt.Errorf("failed to pull image: %v", err)
 			}
@@ -126,9 +156,65 @@ func (d *DockerBuilder) Build() error {
 		}
 	}
 
+	recordPullThroughCacheMetrics(d.build)
+
+	if err := VerifySigstoreSignatures(ctx, d.build, imageNames); err != nil {
+		d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		d.build.Status.Reason = StatusReasonBaseImageSignatureUnverified
+		d.build.Status.Message = builderutil.StatusMessageBaseImageSignatureUnverified
+		HandleBuildStatusUpdate(d.build, d.client, nil)
+		return err
+	}
+
+	if pinBaseImageDigests() {
+		pinned := map[string]string{}
+		for _, imageName := range imageNames {
+			if imageName == "scratch" {
+				continue
+			}
+			digested, err := resolveImageDigest(d.dockerClient, imageName)
+			if err != nil {
+				glog.V(0).Infof("warning: Unable to resolve a digest for image %s: %v", imageName, err)
+				continue
+			}
+			pinned[imageName] = digested
+		}
+		if err := pinDockerfileBaseImages(dockerfilePath, pinned); err != nil {
+			return err
+		}
+		recordBaseImageDigests(d.build, pinned)
+	}
+
+	if onBuildDisallowed() {
+		if err := checkOnBuildAllowed(d.dockerClient, dockerfilePath, imageNames); err != nil {
+			d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			d.build.Status.Reason = StatusReasonOnBuildForbidden
+			d.build.Status.Message = builderutil.StatusMessageOnBuildForbidden
+			HandleBuildStatusUpdate(d.build, d.client, nil)
+			return err
+		}
+	}
+
+	if push {
+		pullPreviousImage(d.build, pushTag, d.pullImage)
+	}
+
+	helperServices, err := helperServicesFromEnvironment()
+	if err != nil {
+		return err
+	}
+	helperIDs, err := startHelperServices(d.dockerClient, d.build, helperServices)
+	if err != nil {
+		return err
+	}
+
+	RecordBuildProgress(buildapiv1.StageBuild)
 	startTime := metav1.Now()
 	err = d.dockerBuild(ctx, buildDir, buildTag)
 
+	// Helper services must be gone before the built image is used further.
+	stopHelperServices(d.dockerClient, helperIDs)
+
 	timing.RecordNewStep(ctx, buildapiv1.StageBuild, buildapiv1.StepDockerBuild, startTime, metav1.Now())
 
 	if err != nil {
@@ -139,6 +225,46 @@ func (d *DockerBuilder) Build() error {
 		return err
 	}
 
+	artifactPaths, err := artifactPathsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if len(artifactPaths) > 0 {
+		cName := containerName("docker", d.build.Name, d.build.Namespace, "extract-artifacts")
+		if err := extractBuildArtifacts(ctx, d.dockerClient, buildTag, cName, artifactPaths); err != nil {
+			return err
+		}
+	}
+
+	if err := applyImageConfigOverrides(d.dockerClient, buildTag); err != nil {
+		d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		d.build.Status.Reason = buildapiv1.StatusReasonDockerBuildFailed
+		d.build.Status.Message = builderutil.StatusMessageDockerBuildFailed
+		HandleBuildStatusUpdate(d.build, d.client, nil)
+		return err
+	}
+
+	if err := RunBuildHooks(HookStagePostCommit, d.build); err != nil {
+		return err
+	}
+
+	if path := exportImagePath(); len(path) != 0 {
+		glog.V(0).Infof("Exporting image as an oci-archive instead of pushing ...")
+		RecordBuildProgress(buildapiv1.StagePushImage)
+		if err := exportImage(d.dockerClient, buildTag, path); err != nil {
+			d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			d.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+			d.build.Status.Message = builderutil.StatusMessageGenericBuildFailed
+			HandleBuildStatusUpdate(d.build, d.client, nil)
+			return err
+		}
+		if err := removeImage(d.dockerClient, buildTag); err != nil {
+			glog.V(0).Infof("warning: Failed to remove temporary build tag %v: %v", buildTag, err)
+		}
+		glog.V(0).Infof("Export successful")
+		return nil
+	}
+
 	if push {
 		if err := tagImage(d.dockerClient, buildTag, pushTag); err != nil {
 			return err
@@ -150,6 +276,9 @@ func (d *DockerBuilder) Build() error {
 	}
 
 	if push && pushTag != "" {
+		if err := RunBuildHooks(HookStagePrePush, d.build); err != nil {
+			return err
+		}
 		// Get the Docker push authentication
 		pushAuthConfig, authPresent := dockercfg.NewHelper().GetDockerAuth(
 			pushTag,
@@ -159,17 +288,29 @@ func (d *DockerBuilder) Build() error {
 			glog.V(4).Infof("Authenticating Docker push with user %q", pushAuthConfig.Username)
 		}
 		glog.V(0).Infof("\nPushing image %s ...", pushTag)
+		RecordBuildProgress(buildapiv1.StagePushImage)
 		startTime = metav1.Now()
 		digest, err := d.pushImage(pushTag, pushAuthConfig)
 
 		timing.RecordNewStep(ctx, buildapiv1.StagePushImage, buildapiv1.StepPushDockerImage, startTime, metav1.Now())
 
 		if err != nil {
+			recordPushResults(d.build, []PushDestinationResult{{Destination: pushTag, Error: err.Error()}})
+			d.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			d.build.Status.Reason, d.build.Status.Message = pushFailureStatus(err)
+			HandleBuildStatusUpdate(d.build, d.client, nil)
+			return reportPushFailure(err, authPresent, pushAuthConfig)
+		}
+
+		results := []PushDestinationResult{{Destination: pushTag, Digest: digest, Success: true}}
+		additionalResults, pushErr := pushAdditionalDestinations(d.dockerClient, pushTag, d.pushImage)
+		recordPushResults(d.build, append(results, additionalResults...))
+		if pushErr != nil {
 			d.build.Status.Phase = buildapiv1.BuildPhaseFailed
 			d.build.Status.Reason = buildapiv1.StatusReasonPushImageToRegistryFailed
 			d.build.Status.Message = builderutil.StatusMessagePushImageToRegistryFailed
 			HandleBuildStatusUpdate(d.build, d.client, nil)
-			return reportPushFailure(err, authPresent, pushAuthConfig)
+			return pushErr
 		}
 
 		if len(digest) > 0 {
@@ -179,32 +320,20 @@ func (d *DockerBuilder) Build() error {
 			HandleBuildStatusUpdate(d.build, d.client, nil)
 		}
 		glog.V(0).Infof("Push successful")
+		RecordBuildEvent(eventTypeNormal, BuildEventReasonPushCompleted, "Successfully pushed %s", pushTag)
+		if err := RunBuildHooks(HookStagePostPush, d.build); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func (d *DockerBuilder) pullImage(name string, authConfig docker.AuthConfiguration) error {
-	repository, tag := docker.ParseRepositoryTag(name)
-	options := docker.PullImageOptions{
-		Repository: repository,
-		Tag:        tag,
-	}
-
-	if options.Tag == "" && strings.Contains(name, "@") {
-		options.Repository = name
-	}
-
-	return d.dockerClient.PullImage(options, authConfig)
+	return pullThroughCache(d.dockerClient, name, authConfig)
 }
 
 func (d *DockerBuilder) pushImage(name string, authConfig docker.AuthConfiguration) (string, error) {
-	repository, tag := docker.ParseRepositoryTag(name)
-	options := docker.PushImageOptions{
-		Name: repository,
-		Tag:  tag,
-	}
-	err := d.dockerClient.PushImage(options, authConfig)
-	return "", err
+	return "", PushImage(d.dockerClient, name, authConfig)
 }
 
 // copyConfigMaps copies all files from the directory where the configMap is
@@ -307,6 +436,18 @@ func (d *DockerBuilder) dockerBuild(ctx context.Context, dir string, tag string)
 		forcePull = d.build.Spec.Strategy.DockerStrategy.ForcePull
 	}
 
+	if reproducibleBuild() {
+		timestamp := reproducibleBuildTimestamp(d.build)
+		glog.V(0).Infof("Reproducible build: normalizing source timestamps to %s", timestamp.Format(time.RFC3339))
+		if err := normalizeContextTimestamps(dir, timestamp); err != nil {
+			return err
+		}
+		buildArgs = append(buildArgs, docker.BuildArg{Name: sourceDateEpochArg, Value: strconv.FormatInt(timestamp.Unix(), 10)})
+		// Cached layers would carry the timestamps of the build that
+		// created them.
+		noCache = true
+	}
+
 	var auth *docker.AuthConfigurations
 	var err error
 	path := os.Getenv(dockercfg.PullAuthType)
@@ -324,12 +465,19 @@ func (d *DockerBuilder) dockerBuild(ctx context.Context, dir string, tag string)
 		return err
 	}
 
+	maxLogBytes, maxLogLines, err := buildLogBudgetFromEnvironment()
+	if err != nil {
+		return err
+	}
+	buildLog := newBuildLogWriter(os.Stdout, maxLogBytes, maxLogLines)
+	defer buildLog.Flush()
+
 	opts := docker.BuildImageOptions{
 		Context:             ctx,
 		Name:                tag,
 		RmTmpContainer:      true,
 		ForceRmTmpContainer: true,
-		OutputStream:        os.Stdout,
+		OutputStream:        buildLog,
 		Dockerfile:          dockerfilePath,
 		NoCache:             noCache,
 		Pull:                forcePull,
@@ -340,6 +488,13 @@ func (d *DockerBuilder) dockerBuild(ctx context.Context, dir string, tag string)
 		return err
 	}
 	opts.NetworkMode = network
+	if mode, err := networkModeFromEnvironment(); err != nil {
+		return err
+	} else if mode == networkModeNone || mode == networkModeHost {
+		// The daemon understands these modes directly; "pod" keeps the
+		// network configuration detected above.
+		opts.NetworkMode = mode
+	}
 	if len(resolvConfHostPath) != 0 {
 		cmd := exec.Command("chcon", "system_u:object_r:svirt_sandbox_file_t:s0", "/etc/resolv.conf")
 		err := cmd.Run()
@@ -356,6 +511,9 @@ func (d *DockerBuilder) dockerBuild(ctx context.Context, dir string, tag string)
 		opts.Memory = d.cgLimits.MemoryLimitBytes
 		opts.Memswap = d.cgLimits.MemorySwap
 		opts.CgroupParent = d.cgLimits.Parent
+		opts.CPUShares = d.cgLimits.CPUShares
+		opts.CPUQuota = d.cgLimits.CPUQuota
+		opts.CPUPeriod = d.cgLimits.CPUPeriod
 	}
 
 	if auth != nil {
@@ -369,8 +527,55 @@ func (d *DockerBuilder) dockerBuild(ctx context.Context, dir string, tag string)
 		}
 	}
 
+	if concurrency, err := stageConcurrencyFromEnvironment(); err != nil {
+		return err
+	} else if concurrency > 1 {
+		if err := d.prebuildDockerfileStages(dir, dockerfilePath, concurrency, opts); err != nil {
+			return err
+		}
+	}
+
 	if dc, ok := d.dockerClient.(*DaemonlessClient); ok {
-		return buildDaemonlessImage(dc.SystemContext, dc.Store, dc.Isolation, dir, imageOptimizationPolicy, &opts)
+		if repository := cacheFromRepository(); len(repository) != 0 {
+			ImportBuildCache(ctx, dc.SystemContext, dc.Store, repository)
+		}
+		if err := buildDaemonlessImage(dc.SystemContext, dc.Store, dc.Isolation, dir, imageOptimizationPolicy, sourceProvenanceAnnotations(d.build), &opts); err != nil {
+			return err
+		}
+		if repository := cacheToRepository(); len(repository) != 0 {
+			ExportBuildCache(dc.SystemContext, dc.Store, repository)
+		}
+		// Verify the layer provenance the build recorded; a mismatch does
+		// not invalidate the image, so it only produces a warning.
+		if history, err := daemonlessImageHistory(dc.SystemContext, dc.Store, tag); err != nil {
+			glog.V(0).Infof("warning: Unable to read the history of image %s: %v", tag, err)
+		} else if err := verifyImageHistory(history, filepath.Join(dir, dockerfilePath)); err != nil {
+			glog.V(0).Infof("warning: Image history does not match the Dockerfile: %v", err)
+		}
+		return nil
+	}
+	if volumes, err := buildVolumesFromEnvironment(); err != nil {
+		return err
+	} else if len(volumes) > 0 {
+		// A daemon-side build cannot bind-mount pod paths into RUN steps.
+		return fmt.Errorf("build volumes are not supported when building through a container engine daemon")
+	}
+	if hosts, err := extraHostsFromEnvironment(); err != nil {
+		return err
+	} else if len(hosts) > 0 {
+		// The daemon's build API offers no way to add host entries to RUN steps.
+		return fmt.Errorf("extra host entries are not supported when building through a container engine daemon")
+	}
+	if shmSize, err := shmSizeFromEnvironment(); err != nil {
+		return err
+	} else if len(shmSize) > 0 {
+		// The daemon's build API offers no way to size /dev/shm for RUN steps.
+		return fmt.Errorf("a /dev/shm size is not supported when building through a container engine daemon")
+	}
+	if ulimits, err := dockerUlimitsFromEnvironment(); err != nil {
+		return err
+	} else if len(ulimits) > 0 {
+		opts.Ulimits = ulimits
 	}
 	return dockerBuildImage(d.dockerClient, dir, d.tar, &opts)
 }
@@ -423,6 +628,67 @@ func replaceLastFrom(node *parser.Node, image string, alias string) error {
 	return nil
 }
 
+// OverrideFromStageEnvVar names the stage whose FROM instruction the base
+// image override from the build strategy replaces. Without it the override
+// applies to the final stage, resolved through any chain of FROM <stage>
+// references down to the underlying base image, so references between stages
+// are left intact.
+const OverrideFromStageEnvVar = "BUILD_OVERRIDE_FROM_STAGE"
+
+// replaceStageFrom changes the FROM instruction of the named stage (or of
+// the final stage when stageName is empty) to point to the given image. A
+// selected stage that builds FROM an earlier stage keeps that reference;
+// the replacement follows the chain back and rewrites the FROM that names
+// an actual image.
+func replaceStageFrom(node *parser.Node, image string, stageName string) error {
+	if node == nil {
+		return nil
+	}
+	type stageFrom struct {
+		from  *parser.Node
+		alias string
+	}
+	var stages []stageFrom
+	for _, child := range node.Children {
+		if child != nil && child.Value == dockercmd.From && child.Next != nil {
+			stages = append(stages, stageFrom{from: child, alias: stageAlias(child)})
+		}
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+	selected := len(stages) - 1
+	if len(stageName) != 0 {
+		selected = -1
+		for i := range stages {
+			if len(stages[i].alias) != 0 && strings.EqualFold(stages[i].alias, stageName) {
+				selected = i
+				break
+			}
+		}
+		if selected < 0 {
+			return fmt.Errorf("the stage %q requested for the base image override was not found in the Dockerfile", stageName)
+		}
+	}
+	// Follow FROM <stage> references back to the FROM naming an image.
+	for {
+		base := stages[selected].from.Next.Value
+		previous := -1
+		for i := 0; i < selected; i++ {
+			if len(stages[i].alias) != 0 && strings.EqualFold(stages[i].alias, base) {
+				previous = i
+			}
+		}
+		if previous < 0 {
+			break
+		}
+		selected = previous
+	}
+	glog.Infof("Replaced Dockerfile FROM image %s", stages[selected].from.Next.Value)
+	stages[selected].from.Next.Value = image
+	return nil
+}
+
 // getLastFrom gets the image name of the last FROM instruction
 // in the dockerfile
 func getLastFrom(node *parser.Node) (string, string) {
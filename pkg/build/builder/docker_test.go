@@ -145,6 +145,85 @@ RUN echo "hello world"
 	}
 }
 
+func TestReplaceStageFrom(t *testing.T) {
+	tests := []struct {
+		original  string
+		image     string
+		stage     string
+		want      string
+		expectErr bool
+	}{
+		{
+			original: `FROM busybox
+RUN echo "hello world"
+`,
+			image: "centos",
+			want: `FROM centos
+RUN echo "hello world"
+`,
+		},
+		{
+			// The final stage references an earlier one; the override
+			// lands on its underlying base image.
+			original: `FROM busybox AS builder
+RUN make
+FROM builder
+RUN make install
+`,
+			image: "centos",
+			want: `FROM centos AS builder
+RUN make
+FROM builder
+RUN make install
+`,
+		},
+		{
+			original: `FROM busybox AS builder
+RUN make
+FROM scratch
+COPY --from=builder /bin/app /app
+`,
+			image: "centos",
+			stage: "builder",
+			want: `FROM centos AS builder
+RUN make
+FROM scratch
+COPY --from=builder /bin/app /app
+`,
+		},
+		{
+			original: `FROM busybox AS builder
+`,
+			image:     "centos",
+			stage:     "missing",
+			expectErr: true,
+		},
+	}
+	for i, test := range tests {
+		got, err := dockerfile.Parse(strings.NewReader(test.original))
+		if err != nil {
+			t.Errorf("test[%d]: %v", i, err)
+			continue
+		}
+		err = replaceStageFrom(got, test.image, test.stage)
+		if test.expectErr != (err != nil) {
+			t.Errorf("test[%d]: expected error %v, got %v", i, test.expectErr, err)
+			continue
+		}
+		if test.expectErr {
+			continue
+		}
+		want, err := dockerfile.Parse(strings.NewReader(test.want))
+		if err != nil {
+			t.Errorf("test[%d]: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(dockerfile.Write(got), dockerfile.Write(want)) {
+			t.Errorf("test[%d]: replaceStageFrom(node, %q, %q) produced:\n%s\nwant:\n%s", i, test.image, test.stage, dockerfile.Write(got), dockerfile.Write(want))
+		}
+	}
+}
+
 func TestAppendPostCommit(t *testing.T) {
 	type want struct {
 		Err bool
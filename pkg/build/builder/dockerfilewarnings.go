@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	docker "github.com/fsouza/go-dockerclient"
+
+	"github.com/openshift/imagebuilder"
+)
+
+// BuildEventReasonDockerfileWarning marks events reporting deprecated or
+// risky Dockerfile constructs found before the build starts.
+const BuildEventReasonDockerfileWarning = "BuildDockerfileWarning"
+
+// secretEnvNamePattern matches environment variable names that commonly
+// carry credentials.
+var secretEnvNamePattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIAL)`)
+
+// dockerfileWarning is one finding from the Dockerfile analysis.
+type dockerfileWarning struct {
+	Line    int
+	Message string
+}
+
+// warnDeprecatedDockerfile analyzes the Dockerfile the build is about to run
+// and reports deprecated instructions and risky practices to the build log
+// and, when an event recorder is configured, as warning events. Findings
+// never fail the build; a Dockerfile that does not parse is left for the
+// build itself to reject with its usual error.
+func warnDeprecatedDockerfile(dockerfilePath string) {
+	node, err := imagebuilder.ParseFile(dockerfilePath)
+	if err != nil {
+		glog.V(2).Infof("Skipping Dockerfile analysis: %v", err)
+		return
+	}
+	for _, warning := range analyzeDockerfile(node) {
+		glog.V(0).Infof("warning: Dockerfile line %d: %s", warning.Line, warning.Message)
+		RecordBuildEvent(eventTypeWarning, BuildEventReasonDockerfileWarning, "Dockerfile line %d: %s", warning.Line, warning.Message)
+	}
+}
+
+// analyzeDockerfile scans the parsed Dockerfile for MAINTAINER usage, ADD of
+// remote URLs, unpinned base images, and credential-looking ENV names.
+func analyzeDockerfile(node *parser.Node) []dockerfileWarning {
+	var warnings []dockerfileWarning
+	stageNames := map[string]struct{}{}
+	for _, child := range node.Children {
+		switch child.Value {
+		case dockercmd.Maintainer:
+			warnings = append(warnings, dockerfileWarning{
+				Line:    child.StartLine,
+				Message: "MAINTAINER is deprecated, use a LABEL maintainer=... instead",
+			})
+		case dockercmd.Add:
+			for arg := child.Next; arg != nil; arg = arg.Next {
+				if strings.HasPrefix(arg.Value, "http://") || strings.HasPrefix(arg.Value, "https://") {
+					warnings = append(warnings, dockerfileWarning{
+						Line:    child.StartLine,
+						Message: fmt.Sprintf("ADD of the remote URL %s is never cached and not verified; prefer COPY of a pre-fetched file", arg.Value),
+					})
+				}
+			}
+		case dockercmd.From:
+			if child.Next == nil {
+				continue
+			}
+			image := child.Next.Value
+			// Record the stage alias, and skip FROMs referencing an
+			// earlier stage rather than an image.
+			if alias := child.Next.Next; alias != nil && strings.EqualFold(alias.Value, "as") && alias.Next != nil {
+				stageNames[strings.ToLower(alias.Next.Value)] = struct{}{}
+			}
+			if _, isStage := stageNames[strings.ToLower(image)]; isStage || image == "scratch" {
+				continue
+			}
+			if strings.Contains(image, "@") {
+				continue
+			}
+			if _, tag := docker.ParseRepositoryTag(image); len(tag) == 0 || tag == "latest" {
+				warnings = append(warnings, dockerfileWarning{
+					Line:    child.StartLine,
+					Message: fmt.Sprintf("FROM %s does not pin the base image; builds can silently change when the tag moves, pin a specific tag or digest", image),
+				})
+			}
+		case dockercmd.Env:
+			// ENV arguments alternate between names and values.
+			name := true
+			for arg := child.Next; arg != nil; arg = arg.Next {
+				if name && secretEnvNamePattern.MatchString(arg.Value) {
+					warnings = append(warnings, dockerfileWarning{
+						Line:    child.StartLine,
+						Message: fmt.Sprintf("ENV %s looks like a credential; values set with ENV are committed into the image for anyone who can pull it", arg.Value),
+					})
+				}
+				name = !name
+			}
+		}
+	}
+	return warnings
+}
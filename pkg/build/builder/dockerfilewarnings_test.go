@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/openshift/imagebuilder"
+)
+
+func TestAnalyzeDockerfile(t *testing.T) {
+	testCases := []struct {
+		name       string
+		dockerfile string
+		expected   []string
+	}{
+		{
+			name:       "clean",
+			dockerfile: "FROM registry.example.com/base:v1.2\nLABEL maintainer=dev@example.com\nCOPY app /app\n",
+		},
+		{
+			name:       "maintainer",
+			dockerfile: "FROM base:v1\nMAINTAINER dev@example.com\n",
+			expected:   []string{"MAINTAINER is deprecated"},
+		},
+		{
+			name:       "remote ADD",
+			dockerfile: "FROM base:v1\nADD https://example.com/pkg.tar.gz /tmp/\n",
+			expected:   []string{"ADD of the remote URL https://example.com/pkg.tar.gz"},
+		},
+		{
+			name:       "latest tag",
+			dockerfile: "FROM base:latest\n",
+			expected:   []string{"FROM base:latest does not pin the base image"},
+		},
+		{
+			name:       "missing tag",
+			dockerfile: "FROM base\n",
+			expected:   []string{"FROM base does not pin the base image"},
+		},
+		{
+			name:       "digest is pinned",
+			dockerfile: "FROM base@sha256:0000000000000000000000000000000000000000000000000000000000000000\n",
+		},
+		{
+			name:       "scratch is ignored",
+			dockerfile: "FROM scratch\nCOPY app /app\n",
+		},
+		{
+			name:       "stage references are ignored",
+			dockerfile: "FROM base:v1 AS builder\nFROM builder\n",
+		},
+		{
+			name:       "secret env",
+			dockerfile: "FROM base:v1\nENV DB_PASSWORD=hunter2 APP_NAME=app\n",
+			expected:   []string{"ENV DB_PASSWORD looks like a credential"},
+		},
+	}
+	for _, tc := range testCases {
+		node, err := imagebuilder.ParseDockerfile(bytes.NewBufferString(tc.dockerfile))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		warnings := analyzeDockerfile(node)
+		if len(warnings) != len(tc.expected) {
+			t.Errorf("%s: expected %d warnings, got %v", tc.name, len(tc.expected), warnings)
+			continue
+		}
+		for i, expected := range tc.expected {
+			if !strings.Contains(warnings[i].Message, expected) {
+				t.Errorf("%s: expected warning %d to contain %q, got %q", tc.name, i, expected, warnings[i].Message)
+			}
+			if warnings[i].Line <= 0 {
+				t.Errorf("%s: expected a line number, got %d", tc.name, warnings[i].Line)
+			}
+		}
+	}
+}
@@ -45,6 +45,7 @@ var (
 		"unexpected end of JSON input",
 		"i/o timeout",
 		"TLS handshake timeout",
+		"stalled transfer",
 	}
 )
 
@@ -70,6 +71,12 @@ func RetryImageAction(client DockerClient, opts interface{}, authConfig docker.A
 	pullOpt := docker.PullImageOptions{}
 	pushOpt := docker.PushImageOptions{}
 
+	alternateAuths, alternateErr := alternatePullCredentials()
+	if alternateErr != nil {
+		glog.V(0).Infof("warning: Ignoring alternate pull credentials: %v", alternateErr)
+	}
+	rotation := 0
+
 	for retries := 0; retries <= DefaultPushOrPullRetryCount; retries++ {
 		if reflect.TypeOf(opts) == reflect.TypeOf(pullOpt) {
 			actionName = "Pull"
@@ -85,19 +92,34 @@ func RetryImageAction(client DockerClient, opts interface{}, authConfig docker.A
 			return nil
 		}
 
-		errMsg := fmt.Sprintf("%s", err)
-		for _, errorString := range RetriableErrors {
-			if strings.Contains(errMsg, errorString) {
-				retriableError = true
-				break
+		delay := DefaultPushOrPullRetryDelay
+		if isRateLimitError(err) {
+			// Rate limits pass on their own; honor the server's Retry-After
+			// and, for pulls, rotate to another account's credentials since
+			// the limits are commonly per account.
+			retriableError = true
+			delay = retryAfterDelay(err, DefaultPushOrPullRetryDelay)
+			if actionName == "Pull" && len(alternateAuths) > 0 {
+				authConfig = alternateAuths[rotation%len(alternateAuths)]
+				rotation++
+				glog.V(0).Infof("Rotating to alternate pull credentials for user %q", authConfig.Username)
+			}
+		} else {
+			errMsg := fmt.Sprintf("%s", err)
+			for _, errorString := range RetriableErrors {
+				if strings.Contains(errMsg, errorString) {
+					retriableError = true
+					break
+				}
 			}
 		}
 		if !retriableError {
 			return err
 		}
 
-		glog.V(0).Infof("Warning: %s failed, retrying in %s ...", actionName, DefaultPushOrPullRetryDelay)
-		time.Sleep(DefaultPushOrPullRetryDelay)
+		glog.V(0).Infof("Warning: %s failed, retrying in %s ...", actionName, delay)
+		RecordBuildEvent(eventTypeWarning, BuildEventReasonRetried, "%s of image failed, retrying: %v", actionName, err)
+		time.Sleep(delay)
 	}
 
 	return fmt.Errorf("After retrying %d times, %s image still failed due to error: %v", DefaultPushOrPullRetryCount, actionName, err)
@@ -16,10 +16,11 @@ import (
 )
 
 type FakeDocker struct {
-	pushImageFunc   func(opts docker.PushImageOptions, auth docker.AuthConfiguration) error
-	pullImageFunc   func(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
-	buildImageFunc  func(opts docker.BuildImageOptions) error
-	removeImageFunc func(name string) error
+	pushImageFunc    func(opts docker.PushImageOptions, auth docker.AuthConfiguration) error
+	pullImageFunc    func(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	buildImageFunc   func(opts docker.BuildImageOptions) error
+	removeImageFunc  func(name string) error
+	inspectImageFunc func(name string) (*docker.Image, error)
 
 	buildImageCalled  bool
 	pushImageCalled   bool
@@ -103,6 +104,9 @@ func (d *FakeDocker) RemoveContainer(opts docker.RemoveContainerOptions) error {
 	return nil
 }
 func (d *FakeDocker) InspectImage(name string) (*docker.Image, error) {
+	if d.inspectImageFunc != nil {
+		return d.inspectImageFunc(name)
+	}
 	return &docker.Image{}, nil
 }
 func (d *FakeDocker) StartContainer(id string, hostConfig *docker.HostConfig) error {
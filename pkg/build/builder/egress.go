@@ -0,0 +1,258 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EgressAllowlistEnvVar names the environment variable carrying a
+// comma-separated list of hosts the build may contact: typically the git
+// host, the registries involved, and any outbound proxy. Entries match a
+// hostname exactly, or a whole subtree with a leading "*." wildcard. When
+// set, the builder routes build traffic through a local proxy that logs
+// contact with any other host. The policy is not applied when unset.
+const EgressAllowlistEnvVar = "BUILD_EGRESS_ALLOWLIST"
+
+// EgressEnforceEnvVar upgrades the egress policy from reporting to
+// enforcement when set to "true": connections to hosts outside the allowlist
+// are refused, and a build that attempted any fails even if its steps
+// tolerated the refusal.
+const EgressEnforceEnvVar = "BUILD_EGRESS_ENFORCE"
+
+// egressProxy is a local forward proxy applying the build's egress policy.
+// It handles CONNECT tunnels for TLS traffic and forwards plain HTTP
+// requests, chaining to the cluster's outbound proxy when one was already
+// configured.
+type egressProxy struct {
+	allowlist []string
+	enforce   bool
+	upstream  *url.URL
+
+	mutex      sync.Mutex
+	violations map[string]struct{}
+}
+
+// currentEgressProxy is the proxy started for this build, if any.
+var currentEgressProxy *egressProxy
+
+// StartEgressProxy starts the egress policy proxy when an allowlist is
+// configured, and points HTTP_PROXY and HTTPS_PROXY at it so git, registry
+// clients and build steps route their traffic through the policy. Any proxy
+// that was configured before remains in use as the upstream of the local one.
+func StartEgressProxy() error {
+	value := os.Getenv(EgressAllowlistEnvVar)
+	if len(value) == 0 {
+		return nil
+	}
+	proxy := &egressProxy{
+		enforce:    strings.EqualFold(os.Getenv(EgressEnforceEnvVar), "true"),
+		violations: make(map[string]struct{}),
+	}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if len(entry) == 0 {
+			continue
+		}
+		proxy.allowlist = append(proxy.allowlist, entry)
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		if upstream := os.Getenv(name); len(upstream) > 0 {
+			upstreamURL, err := ParseProxyURL(upstream)
+			if err != nil {
+				return fmt.Errorf("unable to parse the configured proxy %q: %v", upstream, err)
+			}
+			proxy.upstream = upstreamURL
+			break
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("unable to start the egress proxy: %v", err)
+	}
+	go func() {
+		if err := http.Serve(listener, proxy); err != nil {
+			glog.V(0).Infof("warning: Egress proxy terminated: %v", err)
+		}
+	}()
+
+	address := fmt.Sprintf("http://%s", listener.Addr().String())
+	setProxyEnv("HTTP_PROXY", address)
+	setProxyEnv("HTTPS_PROXY", address)
+	currentEgressProxy = proxy
+	glog.V(0).Infof("Applying the build egress policy through a local proxy at %s", address)
+	return nil
+}
+
+// CheckEgressPolicy fails an enforced egress policy when the build attempted
+// to contact hosts outside the allowlist, even if the build steps tolerated
+// the refused connections. Without an enforced policy it only reports.
+func CheckEgressPolicy() error {
+	if currentEgressProxy == nil {
+		return nil
+	}
+	violations := currentEgressProxy.recordedViolations()
+	if len(violations) == 0 {
+		return nil
+	}
+	if !currentEgressProxy.enforce {
+		glog.V(0).Infof("warning: The build contacted hosts outside the egress allowlist: %s", strings.Join(violations, ", "))
+		return nil
+	}
+	return fmt.Errorf("the build attempted to contact hosts outside the egress allowlist: %s", strings.Join(violations, ", "))
+}
+
+func (p *egressProxy) recordedViolations() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	violations := make([]string, 0, len(p.violations))
+	for host := range p.violations {
+		violations = append(violations, host)
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+func (p *egressProxy) recordViolation(host string) {
+	p.mutex.Lock()
+	_, known := p.violations[host]
+	p.violations[host] = struct{}{}
+	p.mutex.Unlock()
+	if known {
+		return
+	}
+	if p.enforce {
+		glog.V(0).Infof("Blocking build egress to %q: not in the egress allowlist", host)
+	} else {
+		glog.V(0).Infof("warning: Build egress to %q is outside the egress allowlist", host)
+	}
+}
+
+// allows matches the connection target against the allowlist. Loopback
+// targets are always allowed, so the build can reach local helper services.
+func (p *egressProxy) allows(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, pattern := range p.allowlist {
+		if pattern == host {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.allows(r.Host) {
+		p.recordViolation(r.Host)
+		if p.enforce {
+			http.Error(w, fmt.Sprintf("egress to %s is not allowed by the build egress policy", r.Host), http.StatusForbidden)
+			return
+		}
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// tunnel serves a CONNECT request by opening a TCP connection to the target
+// (through the upstream proxy when one is configured) and copying bytes in
+// both directions until either side closes.
+func (p *egressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	serverConn, err := p.dial(r.Host)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to reach %s: %v", r.Host, err), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		serverConn.Close()
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		serverConn.Close()
+		return
+	}
+	fmt.Fprintf(clientConn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	go func() {
+		io.Copy(serverConn, clientConn)
+		serverConn.Close()
+	}()
+	io.Copy(clientConn, serverConn)
+	clientConn.Close()
+}
+
+// dial opens a TCP connection to the given host:port, asking the upstream
+// proxy for a tunnel when one is configured.
+func (p *egressProxy) dial(hostport string) (net.Conn, error) {
+	if p.upstream == nil {
+		return net.Dial("tcp", hostport)
+	}
+	upstreamHost := p.upstream.Host
+	if !strings.Contains(upstreamHost, ":") {
+		upstreamHost += ":80"
+	}
+	conn, err := net.Dial("tcp", upstreamHost)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", hostport, hostport)
+	response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused the connection: %s", response.Status)
+	}
+	return conn, nil
+}
+
+// forward relays a plain HTTP request, through the upstream proxy when one
+// is configured.
+func (p *egressProxy) forward(w http.ResponseWriter, r *http.Request) {
+	transport := &http.Transport{}
+	if p.upstream != nil {
+		transport.Proxy = http.ProxyURL(p.upstream)
+	}
+	outbound := r.Clone(r.Context())
+	outbound.RequestURI = ""
+	response, err := transport.RoundTrip(outbound)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to reach %s: %v", r.Host, err), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for name, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEgressProxyAllows(t *testing.T) {
+	proxy := &egressProxy{allowlist: []string{"github.com", "*.example.com"}}
+	testCases := []struct {
+		host    string
+		allowed bool
+	}{
+		{host: "github.com:443", allowed: true},
+		{host: "GitHub.com:22", allowed: true},
+		{host: "api.github.com:443", allowed: false},
+		{host: "registry.example.com:443", allowed: true},
+		{host: "deep.registry.example.com:443", allowed: true},
+		{host: "example.com:443", allowed: false},
+		{host: "localhost:8080", allowed: true},
+		{host: "127.0.0.1:8080", allowed: true},
+		{host: "evil.test:443", allowed: false},
+	}
+	for _, tc := range testCases {
+		if allowed := proxy.allows(tc.host); allowed != tc.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tc.host, tc.allowed, allowed)
+		}
+	}
+}
+
+func TestEgressProxyForward(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer backend.Close()
+	proxy := &egressProxy{
+		// The backend listens on a loopback address, which is always
+		// allowed, so allow-list decisions are tested against names.
+		allowlist:  []string{"allowed.test"},
+		enforce:    true,
+		violations: make(map[string]struct{}),
+	}
+	frontend := httptest.NewServer(proxy)
+	defer frontend.Close()
+	frontendURL, err := url.Parse(frontend.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(frontendURL)}}
+
+	response, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil || string(body) != "hello" {
+		t.Errorf("expected the proxied response body, got %q (%v)", string(body), err)
+	}
+
+	// A request addressed to a name outside the allowlist is refused and
+	// recorded, without the backend being contacted.
+	blocked, err := client.Get("http://blocked.test/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	blocked.Body.Close()
+	if blocked.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, blocked.StatusCode)
+	}
+	if expected := []string{"blocked.test"}; !reflect.DeepEqual(proxy.recordedViolations(), expected) {
+		t.Errorf("expected violations %v, got %v", expected, proxy.recordedViolations())
+	}
+}
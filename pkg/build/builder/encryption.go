@@ -0,0 +1,37 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+)
+
+// DecryptionKeysPathEnvVar points at a directory of private keys, typically
+// mounted from a secret, for decrypting encrypted base image layers.
+//
+// NOTE: decryption is not implemented yet. The vendored containers/image
+// predates OCI image encryption (containers/ocicrypt), so the builder cannot
+// decrypt layers; the variable is reserved and refused below so an operator
+// who sets it gets an immediate, explicit error instead of an opaque pull
+// failure partway into the build.
+const DecryptionKeysPathEnvVar = "BUILD_DECRYPTION_KEYS_PATH"
+
+// EncryptionRecipientsEnvVar lists the recipients (public keys) the output
+// image layers should be encrypted for before the push.
+//
+// NOTE: like decryption above, this is reserved but not implemented; it is
+// refused rather than ignored, because silently pushing unencrypted layers
+// when encryption was requested would be far worse than failing the build.
+const EncryptionRecipientsEnvVar = "BUILD_ENCRYPTION_RECIPIENTS"
+
+// CheckImageEncryptionSupport fails the build early when image encryption or
+// decryption is requested, since the vendored image libraries cannot honor
+// the request yet.
+func CheckImageEncryptionSupport() error {
+	if value := os.Getenv(DecryptionKeysPathEnvVar); len(value) != 0 {
+		return fmt.Errorf("%s is set, but pulling encrypted images is not supported by this builder yet", DecryptionKeysPathEnvVar)
+	}
+	if value := os.Getenv(EncryptionRecipientsEnvVar); len(value) != 0 {
+		return fmt.Errorf("%s is set, but encrypting output images is not supported by this builder yet", EncryptionRecipientsEnvVar)
+	}
+	return nil
+}
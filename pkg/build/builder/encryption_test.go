@@ -0,0 +1,28 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckImageEncryptionSupport(t *testing.T) {
+	testCases := []struct {
+		keysPath    string
+		recipients  string
+		expectError bool
+	}{
+		{keysPath: "", recipients: "", expectError: false},
+		{keysPath: "/var/run/secrets/decryption-keys", recipients: "", expectError: true},
+		{keysPath: "", recipients: "jwe:/etc/keys/recipient.pub", expectError: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(DecryptionKeysPathEnvVar, tc.keysPath)
+		os.Setenv(EncryptionRecipientsEnvVar, tc.recipients)
+		err := CheckImageEncryptionSupport()
+		if (err != nil) != tc.expectError {
+			t.Errorf("%q/%q: expected error %v, got %v", tc.keysPath, tc.recipients, tc.expectError, err)
+		}
+	}
+	os.Unsetenv(DecryptionKeysPathEnvVar)
+	os.Unsetenv(EncryptionRecipientsEnvVar)
+}
@@ -0,0 +1,112 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// EnvValueFromPathEnvVar names a directory holding projections of the Secrets
+// and ConfigMaps referenced by `valueFrom` entries in the build strategy
+// environment. The build pod mounts each referenced object under
+// <dir>/secrets/<name>/<key> or <dir>/configmaps/<name>/<key>, and the
+// builder resolves the references from those files before constructing the
+// S2I configuration or Docker build arguments.
+const EnvValueFromPathEnvVar = "BUILD_ENV_VALUE_FROM_PATH"
+
+const (
+	secretProjectionDir    = "secrets"
+	configMapProjectionDir = "configmaps"
+)
+
+// ResolveValueFromEnv replaces `valueFrom` references in the build strategy
+// environment with the projected Secret or ConfigMap content, so downstream
+// consumers only ever see plain values. Secret-sourced variables are
+// registered for log redaction.
+func ResolveValueFromEnv(build *buildapiv1.Build) error {
+	root := os.Getenv(EnvValueFromPathEnvVar)
+	if build.Spec.Strategy.SourceStrategy != nil {
+		if err := resolveValueFromEnvVars(root, build.Spec.Strategy.SourceStrategy.Env); err != nil {
+			return err
+		}
+	}
+	if build.Spec.Strategy.DockerStrategy != nil {
+		if err := resolveValueFromEnvVars(root, build.Spec.Strategy.DockerStrategy.Env); err != nil {
+			return err
+		}
+	}
+	if build.Spec.Strategy.CustomStrategy != nil {
+		if err := resolveValueFromEnvVars(root, build.Spec.Strategy.CustomStrategy.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveValueFromEnvVars(root string, env []corev1.EnvVar) error {
+	for i := range env {
+		if env[i].ValueFrom == nil {
+			continue
+		}
+		if len(root) == 0 {
+			return fmt.Errorf("environment variable %q uses valueFrom, but %s is not set", env[i].Name, EnvValueFromPathEnvVar)
+		}
+		switch {
+		case env[i].ValueFrom.SecretKeyRef != nil:
+			ref := env[i].ValueFrom.SecretKeyRef
+			value, found, err := readProjectedKey(root, secretProjectionDir, ref.Name, ref.Key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				if ref.Optional != nil && *ref.Optional {
+					glog.V(2).Infof("Skipping optional environment variable %s: secret %s has no key %s", env[i].Name, ref.Name, ref.Key)
+					continue
+				}
+				return fmt.Errorf("environment variable %q references key %s of secret %s, which is not available to the build", env[i].Name, ref.Key, ref.Name)
+			}
+			builderutil.MarkEnvVarSecret(env[i].Name)
+			env[i].Value = value
+			env[i].ValueFrom = nil
+		case env[i].ValueFrom.ConfigMapKeyRef != nil:
+			ref := env[i].ValueFrom.ConfigMapKeyRef
+			value, found, err := readProjectedKey(root, configMapProjectionDir, ref.Name, ref.Key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				if ref.Optional != nil && *ref.Optional {
+					glog.V(2).Infof("Skipping optional environment variable %s: config map %s has no key %s", env[i].Name, ref.Name, ref.Key)
+					continue
+				}
+				return fmt.Errorf("environment variable %q references key %s of config map %s, which is not available to the build", env[i].Name, ref.Key, ref.Name)
+			}
+			env[i].Value = value
+			env[i].ValueFrom = nil
+		default:
+			return fmt.Errorf("environment variable %q uses a valueFrom source that is not supported in builds", env[i].Name)
+		}
+	}
+	return nil
+}
+
+// readProjectedKey reads a single key of a projected Secret or ConfigMap.
+// A missing file is not an error: the caller decides based on whether the
+// reference is optional.
+func readProjectedKey(root, kind, name, key string) (string, bool, error) {
+	path := filepath.Join(root, kind, name, key)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("reading projected %s: %v", path, err)
+	}
+	return string(content), true, nil
+}
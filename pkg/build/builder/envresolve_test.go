@@ -0,0 +1,142 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+func TestResolveValueFromEnv(t *testing.T) {
+	root, err := ioutil.TempDir("", "envresolve")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"secrets/db-credentials/password": "hunter2",
+		"configmaps/build-settings/mode":  "release",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	os.Setenv(EnvValueFromPathEnvVar, root)
+	defer os.Unsetenv(EnvValueFromPathEnvVar)
+
+	optional := true
+	build := &buildapiv1.Build{}
+	build.Spec.Strategy.DockerStrategy = &buildapiv1.DockerBuildStrategy{
+		Env: []corev1.EnvVar{
+			{Name: "PLAIN", Value: "value"},
+			{
+				Name: "DB_PASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "db-credentials"},
+						Key:                  "password",
+					},
+				},
+			},
+			{
+				Name: "MODE",
+				ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "build-settings"},
+						Key:                  "mode",
+					},
+				},
+			},
+			{
+				Name: "MISSING_OPTIONAL",
+				ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "build-settings"},
+						Key:                  "absent",
+						Optional:             &optional,
+					},
+				},
+			},
+		},
+	}
+
+	if err := ResolveValueFromEnv(build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env := build.Spec.Strategy.DockerStrategy.Env
+	expected := map[string]string{
+		"PLAIN":            "value",
+		"DB_PASSWORD":      "hunter2",
+		"MODE":             "release",
+		"MISSING_OPTIONAL": "",
+	}
+	for _, e := range env {
+		if e.Value != expected[e.Name] {
+			t.Errorf("%s: expected value %q, got %q", e.Name, expected[e.Name], e.Value)
+		}
+		if e.Name != "MISSING_OPTIONAL" && e.ValueFrom != nil {
+			t.Errorf("%s: expected valueFrom to be cleared", e.Name)
+		}
+	}
+
+	// The secret-sourced value must not survive log redaction.
+	for _, e := range builderutil.SafeForLoggingEnvVar(env) {
+		if e.Name == "DB_PASSWORD" && e.Value == "hunter2" {
+			t.Errorf("expected DB_PASSWORD to be redacted, got %q", e.Value)
+		}
+	}
+}
+
+func TestResolveValueFromEnvErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "envresolve")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	newBuild := func(env corev1.EnvVar) *buildapiv1.Build {
+		build := &buildapiv1.Build{}
+		build.Spec.Strategy.SourceStrategy = &buildapiv1.SourceBuildStrategy{Env: []corev1.EnvVar{env}}
+		return build
+	}
+	missingSecret := corev1.EnvVar{
+		Name: "TOKEN",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "absent"},
+				Key:                  "token",
+			},
+		},
+	}
+	unsupported := corev1.EnvVar{
+		Name: "POD_NAME",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		},
+	}
+
+	os.Unsetenv(EnvValueFromPathEnvVar)
+	if err := ResolveValueFromEnv(newBuild(missingSecret)); err == nil || !strings.Contains(err.Error(), EnvValueFromPathEnvVar) {
+		t.Errorf("expected an error about %s, got %v", EnvValueFromPathEnvVar, err)
+	}
+
+	os.Setenv(EnvValueFromPathEnvVar, root)
+	defer os.Unsetenv(EnvValueFromPathEnvVar)
+	if err := ResolveValueFromEnv(newBuild(missingSecret)); err == nil || !strings.Contains(err.Error(), "not available to the build") {
+		t.Errorf("expected a missing reference error, got %v", err)
+	}
+	if err := ResolveValueFromEnv(newBuild(unsupported)); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected an unsupported source error, got %v", err)
+	}
+}
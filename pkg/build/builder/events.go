@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"fmt"
+	"sync"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events posted for build milestones. `oc describe build`
+// lists them next to the build, so problems are visible without digging
+// through the build log.
+const (
+	// BuildEventReasonStageStarted marks the transition into a build stage.
+	BuildEventReasonStageStarted = "BuildStageStarted"
+	// BuildEventReasonRetried records a retried pull, push or tag operation.
+	BuildEventReasonRetried = "BuildOperationRetried"
+	// BuildEventReasonPullFailed records a failure to pull an image the
+	// build needs.
+	BuildEventReasonPullFailed = "BuildPullFailed"
+	// BuildEventReasonPushCompleted records a successful push of the output
+	// image.
+	BuildEventReasonPushCompleted = "BuildPushCompleted"
+)
+
+var buildEventSink = struct {
+	mutex    sync.Mutex
+	recorder record.EventRecorder
+	build    *buildapiv1.Build
+}{}
+
+// SetBuildEventRecorder wires an event recorder and the build the posted
+// events refer to. Until it is called, RecordBuildEvent is a no-op, so code
+// paths that run outside a build pod (tests, git clone helpers) need no
+// special casing.
+func SetBuildEventRecorder(recorder record.EventRecorder, build *buildapiv1.Build) {
+	buildEventSink.mutex.Lock()
+	defer buildEventSink.mutex.Unlock()
+	buildEventSink.recorder = recorder
+	buildEventSink.build = build
+}
+
+// RecordBuildEvent posts an Event tied to the current build. eventType is
+// one of the corev1.EventType* constants. Warnings are additionally
+// collected for the build summary.
+func RecordBuildEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if eventType == eventTypeWarning {
+		recordSummaryWarning(fmt.Sprintf(messageFmt, args...))
+	}
+	buildEventSink.mutex.Lock()
+	recorder := buildEventSink.recorder
+	build := buildEventSink.build
+	buildEventSink.mutex.Unlock()
+	if recorder == nil || build == nil {
+		return
+	}
+	recorder.Eventf(build, eventType, reason, messageFmt, args...)
+}
+
+// eventTypeNormal and eventTypeWarning avoid importing corev1 at every call
+// site just for the event type constants.
+const (
+	eventTypeNormal  = corev1.EventTypeNormal
+	eventTypeWarning = corev1.EventTypeWarning
+)
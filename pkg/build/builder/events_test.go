@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecordBuildEvent(t *testing.T) {
+	// Without a recorder, posting events is a silent no-op.
+	SetBuildEventRecorder(nil, nil)
+	RecordBuildEvent(eventTypeNormal, BuildEventReasonStageStarted, "no sink")
+
+	recorder := record.NewFakeRecorder(10)
+	build := &buildapiv1.Build{}
+	build.Name = "test-build"
+	build.Namespace = "test-namespace"
+	SetBuildEventRecorder(recorder, build)
+	defer SetBuildEventRecorder(nil, nil)
+
+	RecordBuildEvent(eventTypeWarning, BuildEventReasonPullFailed, "Failed to pull image %s: %s", "busybox", "no route to host")
+	select {
+	case event := <-recorder.Events:
+		for _, expected := range []string{"Warning", BuildEventReasonPullFailed, "busybox"} {
+			if !strings.Contains(event, expected) {
+				t.Errorf("expected event to contain %q, got %q", expected, event)
+			}
+		}
+	default:
+		t.Fatalf("expected an event to be recorded")
+	}
+}
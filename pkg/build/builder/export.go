@@ -0,0 +1,30 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExportImagePathEnvVar switches the builder from pushing the output image to
+// exporting it as an oci-archive. The value is the destination path, which
+// may be a regular file or a named pipe read by another process (e.g. a
+// scanning sidecar); the special value "-" streams the archive to stdout.
+// The push stage is skipped entirely when this is set, so the build needs no
+// push credentials.
+const ExportImagePathEnvVar = "BUILD_EXPORT_IMAGE_PATH"
+
+func exportImagePath() string {
+	return os.Getenv(ExportImagePathEnvVar)
+}
+
+// exportImage writes the built image as an oci-archive to the configured
+// destination instead of pushing it.
+func exportImage(dockerClient DockerClient, imageName, path string) error {
+	dc, ok := dockerClient.(*DaemonlessClient)
+	if !ok {
+		// A daemon keeps the image on its own side of the socket; there is
+		// no local storage to export from.
+		return fmt.Errorf("image export is not supported when building through a container engine daemon")
+	}
+	return exportDaemonlessImage(dc.SystemContext, dc.Store, imageName, path)
+}
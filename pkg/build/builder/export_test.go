@@ -0,0 +1,26 @@
+package builder
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExportImageRequiresDaemonlessClient(t *testing.T) {
+	err := exportImage(&FakeDocker{}, "registry.example.com/image:tag", "/tmp/out.tar")
+	if err == nil || !strings.Contains(err.Error(), "not supported when building through a container engine daemon") {
+		t.Errorf("expected a daemon unsupported error, got %v", err)
+	}
+}
+
+func TestExportImagePath(t *testing.T) {
+	defer os.Unsetenv(ExportImagePathEnvVar)
+	os.Unsetenv(ExportImagePathEnvVar)
+	if path := exportImagePath(); path != "" {
+		t.Errorf("expected no export path, got %q", path)
+	}
+	os.Setenv(ExportImagePathEnvVar, "-")
+	if path := exportImagePath(); path != "-" {
+		t.Errorf("expected the stdout marker, got %q", path)
+	}
+}
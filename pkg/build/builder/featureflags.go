@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// FeatureFlagsPathEnvVar names the environment variable pointing at a
+// cluster-supplied feature flag file (typically a mounted ConfigMap). Each
+// line is "<flag>=<true|false>"; blank lines and lines starting with # are
+// ignored. A missing file means no experimental subsystems are enabled.
+const FeatureFlagsPathEnvVar = "BUILD_FEATURE_FLAGS_PATH"
+
+// FeatureFlagsAnnotation records the enabled feature flags on the build, so
+// the provenance of an image shows which experimental behaviors were active
+// when it was built.
+const FeatureFlagsAnnotation = "build.openshift.io/feature-flags"
+
+// Feature flags recognized by this builder. Unknown flags in the file are
+// tolerated (and logged), so a single ConfigMap can serve a cluster with
+// mixed builder versions.
+const (
+	// FeatureCacheMounts enables cache volume mounts in build steps.
+	FeatureCacheMounts = "cache-mounts"
+	// FeatureHermeticMode enables network-isolated builds.
+	FeatureHermeticMode = "hermetic-mode"
+	// FeatureZstdCompression enables zstd layer compression.
+	FeatureZstdCompression = "zstd-compression"
+)
+
+var knownFeatureFlags = map[string]struct{}{
+	FeatureCacheMounts:     {},
+	FeatureHermeticMode:    {},
+	FeatureZstdCompression: {},
+}
+
+// activeFeatureFlags is the flag set loaded by LoadFeatureFlags.
+var activeFeatureFlags = map[string]bool{}
+
+// featureEnabled reports whether the named experimental subsystem is enabled
+// for this cluster.
+func featureEnabled(name string) bool {
+	return activeFeatureFlags[name]
+}
+
+// readFeatureFlags parses a feature flag file.
+func readFeatureFlags(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	flags := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected <flag>=<true|false>", path, line)
+		}
+		name := strings.TrimSpace(text[:i])
+		enabled, err := strconv.ParseBool(strings.TrimSpace(text[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if _, known := knownFeatureFlags[name]; !known {
+			glog.V(0).Infof("warning: Ignoring unknown feature flag %q", name)
+			continue
+		}
+		flags[name] = enabled
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// LoadFeatureFlags reads the cluster feature flag file named by
+// FeatureFlagsPathEnvVar, logs the active set, and stamps the enabled flags
+// into the build's annotations.
+func LoadFeatureFlags(build *buildapiv1.Build) error {
+	path := os.Getenv(FeatureFlagsPathEnvVar)
+	if len(path) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	flags, err := readFeatureFlags(path)
+	if err != nil {
+		return fmt.Errorf("unable to read feature flags: %v", err)
+	}
+	activeFeatureFlags = flags
+
+	enabled := make([]string, 0, len(flags))
+	for name, on := range flags {
+		if on {
+			enabled = append(enabled, name)
+		}
+	}
+	sort.Strings(enabled)
+	if len(enabled) == 0 {
+		glog.V(2).Infof("No feature flags enabled")
+		return nil
+	}
+	glog.V(0).Infof("Enabled feature flags: %s", strings.Join(enabled, ", "))
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[FeatureFlagsAnnotation] = strings.Join(enabled, ",")
+	return nil
+}
@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadFeatureFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "featureflags")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name      string
+		content   string
+		expected  map[string]bool
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			content:  "",
+			expected: map[string]bool{},
+		},
+		{
+			name:    "flags with comments",
+			content: "# cluster feature flags\nzstd-compression=true\n\nhermetic-mode=false\n",
+			expected: map[string]bool{
+				FeatureZstdCompression: true,
+				FeatureHermeticMode:    false,
+			},
+		},
+		{
+			name:     "unknown flag ignored",
+			content:  "warp-drive=true\ncache-mounts=true\n",
+			expected: map[string]bool{FeatureCacheMounts: true},
+		},
+		{
+			name:      "missing separator",
+			content:   "cache-mounts\n",
+			expectErr: true,
+		},
+		{
+			name:      "bad boolean",
+			content:   "cache-mounts=maybe\n",
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			flags, err := readFeatureFlags(path)
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(flags, tc.expected) {
+				t.Errorf("got %v; want %v", flags, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// FIPSEnforceEnvVar forces FIPS enforcement even on nodes that do not report
+// FIPS mode, for clusters that require approved cryptography everywhere
+// regardless of kernel configuration.
+const FIPSEnforceEnvVar = "BUILD_FIPS_ENFORCE"
+
+// FIPSModeAnnotation records whether the build ran in a FIPS-enforcing
+// environment, so compliance audits can tell from the Build alone.
+const FIPSModeAnnotation = "build.openshift.io/fips-mode"
+
+// fipsEnabledFile is where the kernel reports FIPS mode. A variable so tests
+// can point it elsewhere.
+var fipsEnabledFile = "/proc/sys/crypto/fips_enabled"
+
+// fipsModeEnabled reports whether the node kernel runs in FIPS mode.
+func fipsModeEnabled() bool {
+	data, err := ioutil.ReadFile(fipsEnabledFile)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) == "1"
+}
+
+// fipsEnforced reports whether the build must restrict itself to approved
+// cryptography: either the node is in FIPS mode, or enforcement was
+// requested explicitly.
+func fipsEnforced() bool {
+	if fipsModeEnabled() {
+		return true
+	}
+	value := os.Getenv(FIPSEnforceEnvVar)
+	if len(value) == 0 {
+		return false
+	}
+	enforced, err := strconv.ParseBool(value)
+	if err != nil {
+		glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, FIPSEnforceEnvVar)
+		return false
+	}
+	return enforced
+}
+
+// EnforceFIPSMode states the FIPS posture of the build environment at
+// startup, records it on the build, and when enforcement applies, pins the
+// tools the builder shells out to so they cannot negotiate non-approved
+// cryptography.
+func EnforceFIPSMode(build *buildapiv1.Build) {
+	enforced := fipsEnforced()
+	mode := "disabled"
+	if enforced {
+		mode = "enabled"
+	}
+	glog.V(0).Infof("FIPS mode is %s for this build", mode)
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[FIPSModeAnnotation] = mode
+	if !enforced {
+		return
+	}
+	// git honors GIT_SSL_VERSION as a floor: refuse TLS versions whose
+	// cipher suites are not FIPS-approved instead of silently falling back.
+	if len(os.Getenv("GIT_SSL_VERSION")) == 0 {
+		os.Setenv("GIT_SSL_VERSION", "tlsv1.2")
+	}
+	// OpenSSL-based tools in the image switch to their approved-only
+	// providers when this is set, even if the kernel flag is invisible
+	// inside the container.
+	os.Setenv("OPENSSL_FORCE_FIPS_MODE", "1")
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func writeFIPSFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fips_enabled")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFIPSEnforced(t *testing.T) {
+	testCases := []struct {
+		fipsFile string
+		envValue string
+		expected bool
+	}{
+		{fipsFile: "0\n", envValue: "", expected: false},
+		{fipsFile: "1\n", envValue: "", expected: true},
+		{fipsFile: "0\n", envValue: "true", expected: true},
+		{fipsFile: "1\n", envValue: "false", expected: true},
+		{fipsFile: "0\n", envValue: "not-a-bool", expected: false},
+	}
+	defer func(previous string) { fipsEnabledFile = previous }(fipsEnabledFile)
+	for _, tc := range testCases {
+		fipsEnabledFile = writeFIPSFile(t, tc.fipsFile)
+		os.Setenv(FIPSEnforceEnvVar, tc.envValue)
+		if actual := fipsEnforced(); actual != tc.expected {
+			t.Errorf("file %q env %q: expected %v, got %v", tc.fipsFile, tc.envValue, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(FIPSEnforceEnvVar)
+}
+
+func TestEnforceFIPSModeAnnotation(t *testing.T) {
+	defer func(previous string) { fipsEnabledFile = previous }(fipsEnabledFile)
+
+	fipsEnabledFile = writeFIPSFile(t, "1\n")
+	build := &buildapiv1.Build{}
+	EnforceFIPSMode(build)
+	if build.Annotations[FIPSModeAnnotation] != "enabled" {
+		t.Errorf("expected the enabled annotation, got %q", build.Annotations[FIPSModeAnnotation])
+	}
+
+	fipsEnabledFile = writeFIPSFile(t, "0\n")
+	build = &buildapiv1.Build{}
+	EnforceFIPSMode(build)
+	if build.Annotations[FIPSModeAnnotation] != "disabled" {
+		t.Errorf("expected the disabled annotation, got %q", build.Annotations[FIPSModeAnnotation])
+	}
+}
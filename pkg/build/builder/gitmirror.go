@@ -0,0 +1,48 @@
+package builder
+
+import (
+	"os"
+)
+
+// GitBundlePathEnvVar points at a pre-fetched git bundle file. When set, the
+// clone reads from the bundle instead of contacting the remote at all; the
+// checked-out revision is whatever the bundle carries for the requested ref.
+const GitBundlePathEnvVar = "BUILD_GIT_BUNDLE_PATH"
+
+// GitMirrorPathEnvVar points at a local mirror repository, for example on a
+// shared PVC. The clone still negotiates refs with the remote, but borrows
+// objects from the mirror via --reference, so hot repositories transfer
+// almost nothing. --dissociate keeps the resulting clone self-contained, so
+// the build does not depend on the mirror staying mounted.
+const GitMirrorPathEnvVar = "BUILD_GIT_MIRROR_PATH"
+
+// gitBundlePath returns the configured bundle file, or empty when none is
+// usable. A configured but missing bundle is only warned about: the remote
+// is still reachable, so the clone can proceed without the optimization.
+func gitBundlePath() string {
+	path := os.Getenv(GitBundlePathEnvVar)
+	if len(path) == 0 {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		glog.V(0).Infof("warning: Ignoring %s=%s: not a readable bundle file", GitBundlePathEnvVar, path)
+		return ""
+	}
+	return path
+}
+
+// gitMirrorOptions returns the clone options that borrow objects from the
+// configured local mirror, or nothing when no usable mirror is configured.
+func gitMirrorOptions() []string {
+	path := os.Getenv(GitMirrorPathEnvVar)
+	if len(path) == 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		glog.V(0).Infof("warning: Ignoring %s=%s: not a directory", GitMirrorPathEnvVar, path)
+		return nil
+	}
+	return []string{"--reference", path, "--dissociate"}
+}
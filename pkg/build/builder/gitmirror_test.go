@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitBundlePath(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "source.bundle")
+	if err := ioutil.WriteFile(bundle, []byte("bundle"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: ""},
+		{value: bundle, expected: bundle},
+		{value: filepath.Join(dir, "missing.bundle"), expected: ""},
+		{value: dir, expected: ""},
+	}
+	for _, tc := range testCases {
+		os.Setenv(GitBundlePathEnvVar, tc.value)
+		if actual := gitBundlePath(); actual != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(GitBundlePathEnvVar)
+}
+
+func TestGitMirrorOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		{value: "", expected: nil},
+		{value: dir, expected: []string{"--reference", dir, "--dissociate"}},
+		{value: filepath.Join(dir, "missing"), expected: nil},
+	}
+	for _, tc := range testCases {
+		os.Setenv(GitMirrorPathEnvVar, tc.value)
+		if actual := gitMirrorOptions(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(GitMirrorPathEnvVar)
+}
@@ -0,0 +1,158 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/timing"
+)
+
+// GitCloneRetriesEnvVar sets how many times a failed clone is retried when
+// the failure looks transient. The default applies when unset.
+const GitCloneRetriesEnvVar = "BUILD_GIT_CLONE_RETRIES"
+
+// GitCloneFallbackURLEnvVar names an alternate URL for the same repository -
+// a mirror, or the same host over another protocol - tried after the primary
+// URL fails, including failures that are not transient.
+const GitCloneFallbackURLEnvVar = "BUILD_GIT_CLONE_FALLBACK_URL"
+
+const defaultGitCloneRetries = 2
+
+// gitCloneRetryDelay is the base backoff between attempts; it grows linearly
+// with the attempt number. A variable so tests do not sleep.
+var gitCloneRetryDelay = 5 * time.Second
+
+// transientGitErrorFragments identify failures worth retrying: the request
+// never reached the server, or the server dropped it mid-transfer. Anything
+// else - bad credentials, missing repository - will fail the same way again.
+var transientGitErrorFragments = []string{
+	"timed out",
+	"timeout",
+	"connection refused",
+	"connection reset",
+	"could not resolve",
+	"Could not resolve",
+	"early EOF",
+	"The remote end hung up unexpectedly",
+	"RPC failed",
+	"Service Unavailable",
+	"temporarily unavailable",
+	"TLS connection",
+	"gnutls_handshake",
+}
+
+func gitCloneRetries() (int, error) {
+	value := os.Getenv(GitCloneRetriesEnvVar)
+	if len(value) == 0 {
+		return defaultGitCloneRetries, nil
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		return 0, fmt.Errorf("invalid value %q for %s, expected a non-negative integer", value, GitCloneRetriesEnvVar)
+	}
+	return retries, nil
+}
+
+// isTransientGitError reports whether a clone failure is worth retrying.
+func isTransientGitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	for _, fragment := range transientGitErrorFragments {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneAttempt is the timing of one clone attempt, kept so the attempts can
+// be published as build steps when more than one was needed.
+type cloneAttempt struct {
+	step      buildapiv1.StepName
+	startTime metav1.Time
+	endTime   metav1.Time
+}
+
+// cloneWithRetries clones uri into dir, retrying transient failures with
+// backoff and falling over to the configured alternate URL once the primary
+// is exhausted. When more than one attempt was needed, each attempt is
+// recorded as its own step in the build stages.
+func cloneWithRetries(ctx context.Context, gitClient GitClient, dir, uri string, options []string) error {
+	retries, err := gitCloneRetries()
+	if err != nil {
+		return err
+	}
+	uris := []string{uri}
+	if fallback := os.Getenv(GitCloneFallbackURLEnvVar); len(fallback) != 0 && fallback != uri {
+		uris = append(uris, fallback)
+	}
+
+	var attempts []cloneAttempt
+	var lastErr error
+	attempt := 0
+	for _, cloneURI := range uris {
+		for try := 0; try <= retries; try++ {
+			attempt++
+			// A failed clone can leave a partial checkout behind; each
+			// attempt needs the directory empty again.
+			if attempt > 1 {
+				if err := os.RemoveAll(dir); err != nil {
+					return err
+				}
+				if err := os.MkdirAll(dir, 0777); err != nil {
+					return err
+				}
+			}
+			startTime := metav1.Now()
+			err := gitClient.CloneWithOptions(dir, cloneURI, options...)
+			attempts = append(attempts, cloneAttempt{
+				step:      buildapiv1.StepName(fmt.Sprintf("FetchGitSourceAttempt%d", attempt)),
+				startTime: startTime,
+				endTime:   metav1.Now(),
+			})
+			if err == nil {
+				recordCloneAttempts(ctx, attempts)
+				return nil
+			}
+			lastErr = err
+			if !isTransientGitError(err) {
+				glog.V(0).Infof("warning: Cloning %s failed: %v", cloneURI, err)
+				break
+			}
+			if try < retries {
+				delay := time.Duration(try+1) * gitCloneRetryDelay
+				glog.V(0).Infof("warning: Cloning %s failed with a transient error, retrying in %s: %v", cloneURI, delay, err)
+				RecordBuildEvent(eventTypeWarning, BuildEventReasonRetried, "Clone of %s failed, retrying: %v", cloneURI, err)
+				time.Sleep(delay)
+			} else {
+				glog.V(0).Infof("warning: Cloning %s failed after %d attempts: %v", cloneURI, try+1, err)
+			}
+		}
+		if cloneURI != uris[len(uris)-1] {
+			glog.V(0).Infof("Falling back to the alternate clone URL %s", uris[len(uris)-1])
+		}
+	}
+	recordCloneAttempts(ctx, attempts)
+	return lastErr
+}
+
+// recordCloneAttempts publishes per-attempt steps, but only when the clone
+// actually needed more than one attempt; the common single-attempt case is
+// already covered by the regular fetch source step.
+func recordCloneAttempts(ctx context.Context, attempts []cloneAttempt) {
+	if len(attempts) < 2 {
+		return
+	}
+	for _, attempt := range attempts {
+		timing.RecordNewStep(ctx, buildapiv1.StageFetchInputs, attempt.step, attempt.startTime, attempt.endTime)
+	}
+}
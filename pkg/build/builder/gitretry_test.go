@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/git"
+
+	"github.com/openshift/builder/pkg/build/builder/timing"
+)
+
+// fakeGitClient fails CloneWithOptions with the scripted errors, in order,
+// and records the URL of each attempt.
+type fakeGitClient struct {
+	cloneErrors []error
+	cloneURLs   []string
+}
+
+func (f *fakeGitClient) CloneWithOptions(dir string, url string, args ...string) error {
+	f.cloneURLs = append(f.cloneURLs, url)
+	if len(f.cloneErrors) == 0 {
+		return nil
+	}
+	err := f.cloneErrors[0]
+	f.cloneErrors = f.cloneErrors[1:]
+	return err
+}
+
+func (f *fakeGitClient) Fetch(dir string, url string, ref string) error { return nil }
+func (f *fakeGitClient) Checkout(dir string, ref string) error          { return nil }
+func (f *fakeGitClient) PotentialPRRetryAsFetch(dir string, url string, ref string, err error) error {
+	return err
+}
+func (f *fakeGitClient) SubmoduleUpdate(dir string, init, recursive bool) error { return nil }
+func (f *fakeGitClient) TimedListRemote(timeout time.Duration, url string, args ...string) (string, string, error) {
+	return "", "", nil
+}
+func (f *fakeGitClient) GetInfo(location string) (*git.SourceInfo, []error) { return nil, nil }
+
+func TestIsTransientGitError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: fmt.Errorf("fatal: unable to access 'https://host/repo/': Connection timed out"), expected: true},
+		{err: fmt.Errorf("ssh: connect to host my.host: connection refused"), expected: true},
+		{err: fmt.Errorf("error: RPC failed; curl 56 GnuTLS recv error"), expected: true},
+		{err: fmt.Errorf("remote: Repository not found."), expected: false},
+		{err: fmt.Errorf("fatal: Authentication failed"), expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := isTransientGitError(tc.err); actual != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.expected, actual)
+		}
+	}
+}
+
+func TestCloneWithRetriesTransient(t *testing.T) {
+	defer func(previous time.Duration) { gitCloneRetryDelay = previous }(gitCloneRetryDelay)
+	gitCloneRetryDelay = 0
+
+	client := &fakeGitClient{cloneErrors: []error{fmt.Errorf("early EOF")}}
+	ctx := timing.NewContext(context.Background())
+	if err := cloneWithRetries(ctx, client, t.TempDir(), "https://my.host/repo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.cloneURLs) != 2 {
+		t.Errorf("expected 2 attempts, got %v", client.cloneURLs)
+	}
+	stages := timing.GetStages(ctx)
+	if len(stages) != 1 || len(stages[0].Steps) != 2 {
+		t.Errorf("expected one stage with 2 attempt steps, got %v", stages)
+	}
+}
+
+func TestCloneWithRetriesNonTransient(t *testing.T) {
+	defer func(previous time.Duration) { gitCloneRetryDelay = previous }(gitCloneRetryDelay)
+	gitCloneRetryDelay = 0
+
+	client := &fakeGitClient{cloneErrors: []error{fmt.Errorf("remote: Repository not found.")}}
+	ctx := timing.NewContext(context.Background())
+	if err := cloneWithRetries(ctx, client, t.TempDir(), "https://my.host/repo", nil); err == nil {
+		t.Fatalf("expected the clone to fail")
+	}
+	if len(client.cloneURLs) != 1 {
+		t.Errorf("expected a single attempt, got %v", client.cloneURLs)
+	}
+}
+
+func TestCloneWithRetriesFallbackURL(t *testing.T) {
+	defer func(previous time.Duration) { gitCloneRetryDelay = previous }(gitCloneRetryDelay)
+	gitCloneRetryDelay = 0
+	os.Setenv(GitCloneFallbackURLEnvVar, "git://mirror.host/repo")
+	defer os.Unsetenv(GitCloneFallbackURLEnvVar)
+
+	client := &fakeGitClient{cloneErrors: []error{fmt.Errorf("fatal: Authentication failed")}}
+	ctx := timing.NewContext(context.Background())
+	if err := cloneWithRetries(ctx, client, t.TempDir(), "https://my.host/repo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"https://my.host/repo", "git://mirror.host/repo"}
+	if len(client.cloneURLs) != 2 || client.cloneURLs[0] != expected[0] || client.cloneURLs[1] != expected[1] {
+		t.Errorf("expected attempts %v, got %v", expected, client.cloneURLs)
+	}
+}
@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// HealthPortEnvVar configures a TCP port for a small HTTP endpoint reporting
+// the current build stage and the time of the last observed progress. A
+// liveness probe can compare the progress timestamp against a deadline to
+// detect hung builds (e.g. a wedged git clone or a stalled registry
+// connection) and restart the pod. The endpoint is not started when unset.
+const HealthPortEnvVar = "BUILD_HEALTH_PORT"
+
+// buildProgress tracks the most recent observable progress of the build.
+type buildProgress struct {
+	mutex        sync.Mutex
+	stage        buildapiv1.StageName
+	lastProgress time.Time
+}
+
+var currentProgress = &buildProgress{}
+
+// RecordBuildProgress notes that the build reached (or is still making
+// progress in) the given stage, for reporting via the health endpoint.
+func RecordBuildProgress(stage buildapiv1.StageName) {
+	currentProgress.mutex.Lock()
+	previousStage := currentProgress.stage
+	currentProgress.stage = stage
+	currentProgress.lastProgress = time.Now()
+	currentProgress.mutex.Unlock()
+	if stage != previousStage {
+		RecordBuildEvent(eventTypeNormal, BuildEventReasonStageStarted, "Build stage %s started", stage)
+	}
+}
+
+func (p *buildProgress) snapshot() (buildapiv1.StageName, time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stage, p.lastProgress
+}
+
+// healthStatus is the JSON document served by the health endpoint.
+type healthStatus struct {
+	Stage        string `json:"stage,omitempty"`
+	LastProgress string `json:"lastProgress,omitempty"`
+}
+
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	stage, lastProgress := currentProgress.snapshot()
+	status := healthStatus{Stage: string(stage)}
+	if !lastProgress.IsZero() {
+		status.LastProgress = lastProgress.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		glog.V(4).Infof("Error writing health status: %v", err)
+	}
+}
+
+// StartHealthEndpoint serves the health status on the port configured by
+// BUILD_HEALTH_PORT, in the background for the rest of the process lifetime.
+// It does nothing when the variable is not set.
+func StartHealthEndpoint() error {
+	value := os.Getenv(HealthPortEnvVar)
+	if len(value) == 0 {
+		return nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid %s value %q", HealthPortEnvVar, value)
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", serveHealth)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			glog.V(0).Infof("warning: Health endpoint terminated: %v", err)
+		}
+	}()
+	glog.V(2).Infof("Serving build health status on port %d", port)
+	return nil
+}
@@ -0,0 +1,31 @@
+package builder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestServeHealth(t *testing.T) {
+	RecordBuildProgress(buildapiv1.StageBuild)
+
+	recorder := httptest.NewRecorder()
+	serveHealth(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", recorder.Code)
+	}
+
+	status := healthStatus{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Stage != string(buildapiv1.StageBuild) {
+		t.Errorf("unexpected stage %q", status.Stage)
+	}
+	if len(status.LastProgress) == 0 {
+		t.Error("lastProgress is missing")
+	}
+}
@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// HelperServicesEnvVar names the environment variable carrying a JSON list of
+// helper services (for example a test database) to run alongside the build,
+// reachable from RUN and assemble steps, and torn down before the image is
+// committed.
+const HelperServicesEnvVar = "BUILD_HELPER_SERVICES"
+
+// helperService declares one service container to run for the duration of the
+// build steps.
+type helperService struct {
+	Name    string   `json:"name"`
+	Image   string   `json:"image"`
+	Command []string `json:"command,omitempty"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// helperServicesFromEnvironment reads the helper service declarations from
+// the environment. An unset variable means none were requested.
+func helperServicesFromEnvironment() ([]helperService, error) {
+	value := os.Getenv(HelperServicesEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var services []helperService
+	if err := json.Unmarshal([]byte(value), &services); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", HelperServicesEnvVar, err)
+	}
+	for _, service := range services {
+		if len(service.Name) == 0 || len(service.Image) == 0 {
+			return nil, fmt.Errorf("helper services must declare a name and an image")
+		}
+	}
+	return services, nil
+}
+
+// startHelperServices launches the declared helper services in the build's
+// network namespace and returns the container IDs for teardown. Helper
+// services require a container engine capable of running detached containers;
+// the daemonless path reports a clear error instead of failing obscurely
+// mid-build.
+func startHelperServices(client DockerClient, build *buildapiv1.Build, services []helperService) ([]string, error) {
+	if len(services) == 0 {
+		return nil, nil
+	}
+	dc, ok := client.(*docker.Client)
+	if !ok {
+		return nil, fmt.Errorf("helper services are not supported without a container engine daemon")
+	}
+
+	network, _, err := getContainerNetworkConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var containerIDs []string
+	for _, service := range services {
+		name := containerName("helper", build.Name, build.Namespace, service.Name)
+		glog.V(0).Infof("Starting helper service %q from image %s ...", service.Name, service.Image)
+		container, err := dc.CreateContainer(docker.CreateContainerOptions{
+			Name: name,
+			Config: &docker.Config{
+				Image: service.Image,
+				Cmd:   service.Command,
+				Env:   service.Env,
+			},
+			HostConfig: &docker.HostConfig{
+				NetworkMode: network,
+			},
+		})
+		if err != nil {
+			stopHelperServices(client, containerIDs)
+			return nil, fmt.Errorf("error creating helper service %q: %v", service.Name, err)
+		}
+		containerIDs = append(containerIDs, container.ID)
+		if err := dc.StartContainer(container.ID, nil); err != nil {
+			stopHelperServices(client, containerIDs)
+			return nil, fmt.Errorf("error starting helper service %q: %v", service.Name, err)
+		}
+	}
+	return containerIDs, nil
+}
+
+// stopHelperServices tears down helper service containers before the built
+// image is committed, so their state never leaks into image layers.
+func stopHelperServices(client DockerClient, containerIDs []string) {
+	for _, id := range containerIDs {
+		if err := client.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true}); err != nil {
+			glog.V(0).Infof("warning: Failed to remove helper service container %q: %v", id, err)
+		}
+	}
+}
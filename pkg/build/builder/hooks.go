@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// BuildHooksPathEnvVar is the name of the environment variable that names a
+// directory, typically a mounted ConfigMap or hostPath, holding executables
+// to run at build lifecycle stages. Hooks let cluster administrators inject
+// policy around builds without forking the builder image.
+const BuildHooksPathEnvVar = "BUILD_HOOKS_PATH"
+
+// The lifecycle stages at which build hooks run.
+const (
+	// HookStagePreClone runs before any source is fetched.
+	HookStagePreClone = "pre-clone"
+	// HookStagePostClone runs after all build inputs have been assembled.
+	HookStagePostClone = "post-clone"
+	// HookStagePreBuild runs before the strategy-specific build starts.
+	HookStagePreBuild = "pre-build"
+	// HookStagePostCommit runs after the output image has been committed,
+	// before it is tagged for pushing.
+	HookStagePostCommit = "post-commit"
+	// HookStagePrePush runs before the output image is pushed.
+	HookStagePrePush = "pre-push"
+	// HookStagePostPush runs after the output image has been pushed.
+	HookStagePostPush = "post-push"
+)
+
+// buildHookInput is the JSON document piped to each hook's standard input.
+type buildHookInput struct {
+	// Stage is the lifecycle stage the hook is running at.
+	Stage string `json:"stage"`
+	// Build is the build being processed.
+	Build *buildapiv1.Build `json:"build"`
+}
+
+// discoverBuildHooks returns the executables in the hook directory, sorted
+// by name so that administrators control the execution order.
+func discoverBuildHooks(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []string
+	for _, entry := range entries {
+		// Mounted ConfigMaps expose their keys as symlinks, so follow them.
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		hooks = append(hooks, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(hooks)
+	return hooks, nil
+}
+
+// RunBuildHooks executes every hook configured for the given lifecycle
+// stage, feeding each the build and stage context as JSON on stdin. A hook
+// exiting with a non-zero status fails the build.
+func RunBuildHooks(stage string, build *buildapiv1.Build) error {
+	dir := os.Getenv(BuildHooksPathEnvVar)
+	if len(dir) == 0 {
+		return nil
+	}
+	hooks, err := discoverBuildHooks(dir)
+	if err != nil {
+		return fmt.Errorf("unable to discover build hooks: %v", err)
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(buildHookInput{Stage: stage, Build: build})
+	if err != nil {
+		return fmt.Errorf("unable to serialize the build for hooks: %v", err)
+	}
+
+	for _, hook := range hooks {
+		glog.V(2).Infof("Running build hook %s at stage %s", filepath.Base(hook), stage)
+		cmd := exec.Command(hook)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "BUILD_HOOK_STAGE="+stage)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("the build hook %s failed at stage %s: %v", filepath.Base(hook), stage, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiscoverBuildHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "20-second"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "10-first"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a hook"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hooks, err := discoverBuildHooks(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "10-first"), filepath.Join(dir, "20-second")}
+	if len(hooks) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, hooks)
+	}
+	for i := range expected {
+		if hooks[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, hooks)
+			break
+		}
+	}
+}
+
+func TestRunBuildHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	outFile := filepath.Join(dir, "out")
+
+	hook := "#!/bin/sh\ncat > " + outFile + "\necho \"stage=$BUILD_HOOK_STAGE\" >> " + outFile + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "10-record"), []byte(hook), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	build := &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "hooked-build", Namespace: "default"},
+	}
+
+	os.Setenv(BuildHooksPathEnvVar, dir)
+	defer os.Unsetenv(BuildHooksPathEnvVar)
+	if err := RunBuildHooks(HookStagePreBuild, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorded, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{`"stage":"pre-build"`, `"name":"hooked-build"`, "stage=pre-build"} {
+		if !strings.Contains(string(recorded), expected) {
+			t.Errorf("expected hook input to contain %q, got %q", expected, string(recorded))
+		}
+	}
+
+	failing := "#!/bin/sh\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "20-fail"), []byte(failing), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := RunBuildHooks(HookStagePreBuild, build); err == nil {
+		t.Errorf("expected a failing hook to fail the stage")
+	}
+}
@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ImageConfigOverridesEnvVar holds a JSON document describing mutations to
+// apply to the output image configuration after the image is committed and
+// before it is pushed, so simple adjustments (entrypoint, cmd, user, working
+// directory, exposed ports, environment) do not require a wrapper Dockerfile.
+const ImageConfigOverridesEnvVar = "BUILD_IMAGE_CONFIG_OVERRIDES"
+
+// imageConfigOverrides is the parsed form of BUILD_IMAGE_CONFIG_OVERRIDES.
+// Only fields which are set are applied; Env entries are "NAME=value".
+type imageConfigOverrides struct {
+	Entrypoint   []string `json:"entrypoint,omitempty"`
+	Cmd          []string `json:"cmd,omitempty"`
+	User         string   `json:"user,omitempty"`
+	WorkingDir   string   `json:"workingDir,omitempty"`
+	ExposedPorts []string `json:"exposedPorts,omitempty"`
+	Env          []string `json:"env,omitempty"`
+}
+
+// imageConfigOverridesFromEnvironment parses BUILD_IMAGE_CONFIG_OVERRIDES,
+// returning nil when it is not set.
+func imageConfigOverridesFromEnvironment() (*imageConfigOverrides, error) {
+	value := os.Getenv(ImageConfigOverridesEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	overrides := &imageConfigOverrides{}
+	if err := json.Unmarshal([]byte(value), overrides); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", ImageConfigOverridesEnvVar, err)
+	}
+	for _, env := range overrides.Env {
+		if !strings.Contains(env, "=") {
+			return nil, fmt.Errorf("%s: environment entry %q is not in NAME=value form", ImageConfigOverridesEnvVar, env)
+		}
+	}
+	return overrides, nil
+}
+
+// applyImageConfigOverrides rewrites the configuration of the committed image
+// according to BUILD_IMAGE_CONFIG_OVERRIDES. It is a no-op when no overrides
+// are configured. A daemon cannot rewrite an image config in place, so this
+// is only supported for daemonless builds.
+func applyImageConfigOverrides(dockerClient DockerClient, imageName string) error {
+	overrides, err := imageConfigOverridesFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if overrides == nil {
+		return nil
+	}
+	dc, ok := dockerClient.(*DaemonlessClient)
+	if !ok {
+		return fmt.Errorf("image config overrides are not supported when building through a container engine daemon")
+	}
+	glog.V(0).Infof("Applying image config overrides to %s", imageName)
+	return overrideDaemonlessImageConfig(dc.SystemContext, dc.Store, imageName, overrides)
+}
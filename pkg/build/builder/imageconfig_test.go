@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestImageConfigOverridesFromEnvironment(t *testing.T) {
+	defer os.Unsetenv(ImageConfigOverridesEnvVar)
+
+	os.Unsetenv(ImageConfigOverridesEnvVar)
+	overrides, err := imageConfigOverridesFromEnvironment()
+	if err != nil || overrides != nil {
+		t.Errorf("expected no overrides with the variable unset, got %v, %v", overrides, err)
+	}
+
+	os.Setenv(ImageConfigOverridesEnvVar, `{"entrypoint":["/bin/run"],"cmd":["--verbose"],"user":"1001","workingDir":"/app","exposedPorts":["8080/tcp"],"env":["MODE=production"]}`)
+	overrides, err = imageConfigOverridesFromEnvironment()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides.Entrypoint) != 1 || overrides.Entrypoint[0] != "/bin/run" {
+		t.Errorf("unexpected entrypoint %v", overrides.Entrypoint)
+	}
+	if overrides.User != "1001" || overrides.WorkingDir != "/app" {
+		t.Errorf("unexpected overrides %+v", overrides)
+	}
+
+	os.Setenv(ImageConfigOverridesEnvVar, `{"env":["MISSING_SEPARATOR"]}`)
+	if _, err := imageConfigOverridesFromEnvironment(); err == nil {
+		t.Error("expected an error for a malformed environment entry")
+	}
+
+	os.Setenv(ImageConfigOverridesEnvVar, `{not json`)
+	if _, err := imageConfigOverridesFromEnvironment(); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,37 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// AdditionalImageStoresEnvVar holds a colon-separated list of read-only
+// additional image store paths (containers/storage "additionalimagestores").
+// A node can pre-populate such a store with common base images and share it
+// across all build pods, so the images are not pulled over and over again.
+const AdditionalImageStoresEnvVar = "BUILD_ADDITIONAL_IMAGE_STORES"
+
+// AdditionalImageStores returns the configured additional image store paths.
+// Paths that do not exist are skipped with a warning: a missing store only
+// costs the node the layer sharing, while configuring it into
+// containers/storage would fail every build on the node.
+func AdditionalImageStores() []string {
+	var stores []string
+	for _, path := range filepath.SplitList(os.Getenv(AdditionalImageStoresEnvVar)) {
+		if len(path) == 0 {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			glog.V(0).Infof("warning: Ignoring additional image store %s: %v", path, err)
+			continue
+		}
+		if !st.IsDir() {
+			glog.V(0).Infof("warning: Ignoring additional image store %s: not a directory", path)
+			continue
+		}
+		glog.V(0).Infof("Using additional image store %s", path)
+		stores = append(stores, path)
+	}
+	return stores
+}
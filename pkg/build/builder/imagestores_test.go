@@ -0,0 +1,36 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdditionalImageStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagestores")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "not-a-directory")
+	if err := ioutil.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer os.Unsetenv(AdditionalImageStoresEnvVar)
+
+	os.Unsetenv(AdditionalImageStoresEnvVar)
+	if stores := AdditionalImageStores(); len(stores) != 0 {
+		t.Errorf("expected no stores, got %v", stores)
+	}
+
+	// Missing paths and non-directories are skipped, usable paths kept.
+	value := strings.Join([]string{dir, filepath.Join(dir, "missing"), file, ""}, string(os.PathListSeparator))
+	os.Setenv(AdditionalImageStoresEnvVar, value)
+	stores := AdditionalImageStores()
+	if len(stores) != 1 || stores[0] != dir {
+		t.Errorf("expected [%s], got %v", dir, stores)
+	}
+}
@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InstructionCacheEnvVar is the name of the environment variable that
+// enables the content-addressed instruction cache for daemonless Docker
+// strategy builds. Each Dockerfile step is committed as an image in
+// containers/storage, keyed by the base image, the instruction history, and
+// the copied context files; a rebuild reuses the committed image when
+// nothing relevant changed, skipping the step without needing a Docker
+// daemon cache. Builds requesting the squashed-layers optimization are not
+// affected.
+const InstructionCacheEnvVar = "BUILD_INSTRUCTION_CACHE"
+
+// instructionCacheFromEnvironment reports whether the instruction cache is
+// enabled. An unparsable value counts as disabled, with a warning.
+func instructionCacheFromEnvironment() bool {
+	value := strings.TrimSpace(os.Getenv(InstructionCacheEnvVar))
+	if len(value) == 0 {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, InstructionCacheEnvVar)
+		return false
+	}
+	return enabled
+}
+
+// cacheStatsWriter counts instruction cache hits and misses by watching the
+// build output for step and cache markers, so the build log can report how
+// effective the cache was.
+type cacheStatsWriter struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	partial []byte
+	steps   int64
+	hits    int64
+}
+
+func newCacheStatsWriter(out io.Writer) *cacheStatsWriter {
+	return &cacheStatsWriter{out: out}
+}
+
+func (w *cacheStatsWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	w.partial = append(w.partial, p...)
+	for {
+		newline := bytes.IndexByte(w.partial, '\n')
+		if newline < 0 {
+			break
+		}
+		line := string(w.partial[:newline])
+		w.partial = w.partial[newline+1:]
+		switch {
+		case strings.HasPrefix(line, "STEP "):
+			w.steps++
+		case strings.HasPrefix(line, "--> Using cache"):
+			w.hits++
+		}
+	}
+	w.mutex.Unlock()
+	return w.out.Write(p)
+}
+
+// report logs the cache effectiveness observed during the build.
+func (w *cacheStatsWriter) report() {
+	w.mutex.Lock()
+	steps, hits := w.steps, w.hits
+	w.mutex.Unlock()
+	if steps == 0 {
+		return
+	}
+	glog.V(0).Infof("Instruction cache: %d of %d steps were cache hits", hits, steps)
+}
@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestInstructionCacheFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "", expected: false},
+		{value: "true", expected: true},
+		{value: "0", expected: false},
+		{value: "always", expected: false},
+	}
+	for _, tc := range testCases {
+		os.Setenv(InstructionCacheEnvVar, tc.value)
+		if actual := instructionCacheFromEnvironment(); actual != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(InstructionCacheEnvVar)
+}
+
+func TestCacheStatsWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newCacheStatsWriter(out)
+	w.Write([]byte("STEP 1: FROM busybox\nSTEP 2: RUN true\n--> Using cache 1234\nSTEP 3: COPY"))
+	w.Write([]byte(" . /src\nsome build output\n"))
+	if w.steps != 3 {
+		t.Errorf("expected 3 steps, got %d", w.steps)
+	}
+	if w.hits != 1 {
+		t.Errorf("expected 1 cache hit, got %d", w.hits)
+	}
+	expected := "STEP 1: FROM busybox\nSTEP 2: RUN true\n--> Using cache 1234\nSTEP 3: COPY . /src\nsome build output\n"
+	if out.String() != expected {
+		t.Errorf("expected the output to pass through unchanged, got %q", out.String())
+	}
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// IsolationEnvVar selects the isolation mode ("chroot", "oci" or "rootless")
+// RUN instructions execute under. It is read from the build strategy
+// environment, so individual Builds and BuildConfig defaults can choose a
+// mode, and falls back to the variable in the builder's own environment.
+const IsolationEnvVar = "BUILD_ISOLATION"
+
+// maxUserNamespacesPath limits how many user namespaces unprivileged users
+// may create; zero means rootless isolation cannot work on this node.
+const maxUserNamespacesPath = "/proc/sys/user/max_user_namespaces"
+
+// IsolationSpec determines the isolation mode for a build. A mode set in the
+// build strategy environment wins over the builder's own environment. Modes
+// the node cannot support are replaced by the default with a warning, so the
+// build proceeds rather than failing deep inside the runtime with an obscure
+// error.
+func IsolationSpec(build *buildapiv1.Build) string {
+	spec := os.Getenv(IsolationEnvVar)
+	if build != nil {
+		var strategyEnv []corev1.EnvVar
+		if build.Spec.Strategy.DockerStrategy != nil {
+			strategyEnv = build.Spec.Strategy.DockerStrategy.Env
+		} else if build.Spec.Strategy.SourceStrategy != nil {
+			strategyEnv = build.Spec.Strategy.SourceStrategy.Env
+		}
+		for _, env := range strategyEnv {
+			if env.Name == IsolationEnvVar {
+				spec = env.Value
+			}
+		}
+	}
+	return validateIsolationSpec(spec)
+}
+
+// validateIsolationSpec checks the requested isolation mode against node
+// capabilities. Unknown modes pass through unchanged; GetDaemonlessClient
+// rejects them with a clear error.
+func validateIsolationSpec(spec string) string {
+	switch strings.ToLower(spec) {
+	case "oci":
+		if _, err := exec.LookPath("runc"); err != nil {
+			glog.V(0).Infof("warning: Requested oci isolation, but no runc binary is available; using the default isolation instead: %v", err)
+			return ""
+		}
+	case "rootless":
+		if _, err := exec.LookPath("runc"); err != nil {
+			glog.V(0).Infof("warning: Requested rootless isolation, but no runc binary is available; using the default isolation instead: %v", err)
+			return ""
+		}
+		if !unprivilegedUserNamespacesEnabled() {
+			glog.V(0).Infof("warning: Requested rootless isolation, but this node does not allow unprivileged user namespaces; using the default isolation instead")
+			return ""
+		}
+	}
+	return spec
+}
+
+// unprivilegedUserNamespacesEnabled reports whether the node allows creating
+// user namespaces without privileges. Nodes without the sysctl are assumed to
+// allow them.
+func unprivilegedUserNamespacesEnabled() bool {
+	content, err := ioutil.ReadFile(maxUserNamespacesPath)
+	if err != nil {
+		return true
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return true
+	}
+	return max > 0
+}
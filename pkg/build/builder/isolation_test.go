@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestIsolationSpec(t *testing.T) {
+	// "chroot" needs no node capabilities, so these cases exercise the
+	// precedence rules without depending on the test environment.
+	defer os.Unsetenv(IsolationEnvVar)
+
+	os.Unsetenv(IsolationEnvVar)
+	if spec := IsolationSpec(&buildapiv1.Build{}); spec != "" {
+		t.Errorf("expected empty isolation spec, got %q", spec)
+	}
+
+	os.Setenv(IsolationEnvVar, "chroot")
+	if spec := IsolationSpec(&buildapiv1.Build{}); spec != "chroot" {
+		t.Errorf("expected isolation from the process environment, got %q", spec)
+	}
+
+	build := &buildapiv1.Build{}
+	build.Spec.Strategy.DockerStrategy = &buildapiv1.DockerBuildStrategy{
+		Env: []corev1.EnvVar{{Name: IsolationEnvVar, Value: "chroot"}},
+	}
+	os.Unsetenv(IsolationEnvVar)
+	if spec := IsolationSpec(build); spec != "chroot" {
+		t.Errorf("expected isolation from the strategy environment, got %q", spec)
+	}
+}
+
+func TestValidateIsolationSpecFallback(t *testing.T) {
+	// An empty PATH guarantees no runc binary is found.
+	emptyDir, err := ioutil.TempDir("", "isolation")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(emptyDir)
+	originalPath := os.Getenv("PATH")
+	os.Setenv("PATH", emptyDir)
+	defer os.Setenv("PATH", originalPath)
+
+	for _, spec := range []string{"oci", "rootless"} {
+		if result := validateIsolationSpec(spec); result != "" {
+			t.Errorf("%s: expected fallback to the default isolation, got %q", spec, result)
+		}
+	}
+	if result := validateIsolationSpec("chroot"); result != "chroot" {
+		t.Errorf("chroot: expected no fallback, got %q", result)
+	}
+	// Unknown modes are passed through for GetDaemonlessClient to reject.
+	if result := validateIsolationSpec("bogus"); result != "bogus" {
+		t.Errorf("bogus: expected pass-through, got %q", result)
+	}
+}
@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"regexp"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// labelNameRegexp matches label names following OCI conventions: lowercase
+// reverse-DNS components of alphanumerics separated by '.', '-', or '_'.
+var labelNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// reservedLabelNamespaces are prefixes that user-provided labels may not
+// claim, because the builder and the platform stamp their own metadata there.
+var reservedLabelNamespaces = []string{
+	builderutil.DefaultDockerLabelNamespace,
+	"io.k8s.",
+}
+
+// userImageLabels returns the user-provided labels from the build spec with
+// substitutions expanded over build and source metadata (the same template
+// data as promotion hints, plus {{.Timestamp}}). Labels with invalid names,
+// names in reserved namespaces, or bad templates are dropped with a warning
+// rather than failing the build.
+func userImageLabels(build *buildapiv1.Build, sourceInfo *git.SourceInfo) []buildapiv1.ImageLabel {
+	var labels []buildapiv1.ImageLabel
+	data := buildTemplateDataFor(build, sourceInfo)
+	for _, lbl := range build.Spec.Output.ImageLabels {
+		if !labelNameRegexp.MatchString(lbl.Name) {
+			glog.V(0).Infof("warning: Ignoring image label %q: name does not follow OCI label conventions", lbl.Name)
+			continue
+		}
+		if ns := reservedLabelNamespace(lbl.Name); len(ns) > 0 {
+			glog.V(0).Infof("warning: Ignoring image label %q: the %s namespace is reserved", lbl.Name, ns)
+			continue
+		}
+		value := lbl.Value
+		if strings.Contains(value, "{{") {
+			expanded, err := expandBuildTemplate("label "+lbl.Name, value, data)
+			if err != nil {
+				glog.V(0).Infof("warning: Ignoring image label %q: %v", lbl.Name, err)
+				continue
+			}
+			value = expanded
+		}
+		labels = append(labels, buildapiv1.ImageLabel{Name: lbl.Name, Value: value})
+	}
+	return labels
+}
+
+// reservedLabelNamespace returns the reserved namespace a label name falls
+// into, or an empty string.
+func reservedLabelNamespace(name string) string {
+	for _, ns := range reservedLabelNamespaces {
+		if strings.HasPrefix(name, ns) {
+			return ns
+		}
+	}
+	return ""
+}
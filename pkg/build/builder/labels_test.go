@@ -0,0 +1,45 @@
+package builder
+
+import (
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUserImageLabels(t *testing.T) {
+	build := &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-build", Namespace: "testns"},
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Output: buildapiv1.BuildOutput{
+					ImageLabels: []buildapiv1.ImageLabel{
+						{Name: "com.example.release", Value: "stable"},
+						{Name: "com.example.commit", Value: "{{.Commit}}"},
+						{Name: "com.example.built-by", Value: "{{.BuildNamespace}}/{{.BuildName}}"},
+						{Name: "Invalid Name", Value: "dropped"},
+						{Name: "io.openshift.build.name", Value: "dropped"},
+						{Name: "com.example.broken", Value: "{{.Commit"},
+					},
+				},
+			},
+		},
+	}
+	sourceInfo := &git.SourceInfo{CommitID: "0123abcd"}
+
+	labels := userImageLabels(build, sourceInfo)
+	expected := map[string]string{
+		"com.example.release":  "stable",
+		"com.example.commit":   "0123abcd",
+		"com.example.built-by": "testns/test-build",
+	}
+	if len(labels) != len(expected) {
+		t.Fatalf("got %d labels (%v); want %d", len(labels), labels, len(expected))
+	}
+	for _, lbl := range labels {
+		if expected[lbl.Name] != lbl.Value {
+			t.Errorf("label %s: got %q; want %q", lbl.Name, lbl.Value, expected[lbl.Name])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/openshift/imagebuilder"
+)
+
+// verifyImageHistory checks that the history of a built image records the
+// Dockerfile instructions that produced it. The imagebuildah executor stores
+// the instruction behind each commit as the layer's created_by entry; this
+// walks the history newest to oldest and matches it against the Dockerfile,
+// so tools like `podman history` show accurate provenance for the layers the
+// build committed. History entries older than the first match belong to the
+// base image and are not checked.
+func verifyImageHistory(history []ociv1.History, dockerfilePath string) error {
+	node, err := imagebuilder.ParseFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	instructions := node.Children
+	if len(instructions) == 0 || len(history) == 0 {
+		return nil
+	}
+
+	matched := 0
+	i := len(history) - 1
+	for j := len(instructions) - 1; j >= 0 && i >= 0; j-- {
+		// The executor records RUN instructions as "/bin/sh -c <command>"
+		// and everything else as "/bin/sh -c #(nop) <instruction>".
+		expected := instructions[j].Original
+		if instructions[j].Value == "run" {
+			expected = strings.TrimSpace(expected[len("run"):])
+		}
+		if !strings.Contains(history[i].CreatedBy, expected) {
+			break
+		}
+		matched++
+		i--
+	}
+	if matched == 0 {
+		last := instructions[len(instructions)-1]
+		return fmt.Errorf("image history %q does not record Dockerfile instruction %q", history[len(history)-1].CreatedBy, last.Original)
+	}
+	glog.V(2).Infof("Image history records created_by entries for %d of %d Dockerfile instructions", matched, len(instructions))
+	return nil
+}
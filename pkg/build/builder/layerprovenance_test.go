@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestVerifyImageHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layerprovenance")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	dockerfile := `FROM busybox
+RUN touch /tmp/built
+ENV FOO=bar
+CMD ["/bin/sh"]
+`
+	if err := ioutil.WriteFile(dockerfilePath, []byte(dockerfile), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	emptyLayer := true
+	fullHistory := []ociv1.History{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:base in /"},
+		{CreatedBy: "/bin/sh -c #(nop) FROM busybox", EmptyLayer: emptyLayer},
+		{CreatedBy: "/bin/sh -c touch /tmp/built"},
+		{CreatedBy: "/bin/sh -c #(nop) ENV FOO=bar", EmptyLayer: emptyLayer},
+		{CreatedBy: `/bin/sh -c #(nop) CMD ["/bin/sh"]`, EmptyLayer: emptyLayer},
+	}
+	if err := verifyImageHistory(fullHistory, dockerfilePath); err != nil {
+		t.Errorf("full history: unexpected error: %v", err)
+	}
+
+	// Without per-instruction layers only the final commit carries the last
+	// instruction; that still counts as recorded provenance.
+	squashedHistory := []ociv1.History{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:base in /"},
+		{CreatedBy: `/bin/sh -c #(nop) CMD ["/bin/sh"]`},
+	}
+	if err := verifyImageHistory(squashedHistory, dockerfilePath); err != nil {
+		t.Errorf("squashed history: unexpected error: %v", err)
+	}
+
+	mismatchedHistory := []ociv1.History{
+		{CreatedBy: "/bin/sh -c #(nop) ENTRYPOINT [\"/other\"]"},
+	}
+	if err := verifyImageHistory(mismatchedHistory, dockerfilePath); err == nil {
+		t.Errorf("mismatched history: expected an error")
+	}
+
+	if err := verifyImageHistory(nil, dockerfilePath); err != nil {
+		t.Errorf("empty history: unexpected error: %v", err)
+	}
+}
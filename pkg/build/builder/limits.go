@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// ShmSizeEnvVar sets the size of the /dev/shm mount in containers that run
+// assemble and RUN steps, as a size with an optional unit (e.g. "1g"). The
+// runtime default of 64 MB is too small for JVM and browser-test workloads.
+const ShmSizeEnvVar = "BUILD_SHM_SIZE"
+
+// UlimitsEnvVar carries a comma-separated list of resource limits for the
+// containers that run assemble and RUN steps, each in the form
+// name=soft[:hard] (e.g. "nofile=10240:10240,nproc=4096").
+const UlimitsEnvVar = "BUILD_ULIMITS"
+
+// PidsLimitEnvVar requests a cap on the number of processes in build
+// containers. The current build runtime cannot apply it; the value is
+// validated and reported so a configured cap does not go silently missing.
+const PidsLimitEnvVar = "BUILD_PIDS_LIMIT"
+
+// shmSizeFromEnvironment reads and validates the /dev/shm size. An unset
+// variable keeps the runtime default.
+func shmSizeFromEnvironment() (string, error) {
+	value := os.Getenv(ShmSizeEnvVar)
+	if len(value) == 0 {
+		return "", nil
+	}
+	if _, err := units.RAMInBytes(value); err != nil {
+		return "", fmt.Errorf("unable to parse %s: %v", ShmSizeEnvVar, err)
+	}
+	return value, nil
+}
+
+// ulimitsFromEnvironment reads and validates the resource limit list. An
+// unset variable keeps the runtime defaults.
+func ulimitsFromEnvironment() ([]string, error) {
+	value := os.Getenv(UlimitsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var ulimits []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if _, err := units.ParseUlimit(entry); err != nil {
+			return nil, fmt.Errorf("unable to parse %s entry %q: %v", UlimitsEnvVar, entry, err)
+		}
+		ulimits = append(ulimits, entry)
+	}
+	return ulimits, nil
+}
+
+// dockerUlimitsFromEnvironment converts the configured resource limits to
+// the form the container engine daemon's build API accepts.
+func dockerUlimitsFromEnvironment() ([]docker.ULimit, error) {
+	ulimits, err := ulimitsFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	var converted []docker.ULimit
+	for _, entry := range ulimits {
+		ulimit, err := units.ParseUlimit(entry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s entry %q: %v", UlimitsEnvVar, entry, err)
+		}
+		converted = append(converted, docker.ULimit{Name: ulimit.Name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+	}
+	return converted, nil
+}
+
+// warnUnsupportedPidsLimit validates a configured pids limit and reports
+// that this runtime cannot apply it, rather than dropping it silently.
+func warnUnsupportedPidsLimit() error {
+	value := os.Getenv(PidsLimitEnvVar)
+	if len(value) == 0 {
+		return nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", PidsLimitEnvVar, err)
+	}
+	glog.V(0).Infof("warning: A pids limit of %s was requested, but this build runtime cannot apply it", value)
+	return nil
+}
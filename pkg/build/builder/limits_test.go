@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestShmSizeFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  string
+		expectErr bool
+	}{
+		{value: "", expected: ""},
+		{value: "1g", expected: "1g"},
+		{value: "262144000", expected: "262144000"},
+		{value: "huge", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(ShmSizeEnvVar, tc.value)
+		size, err := shmSizeFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && size != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, size)
+		}
+	}
+	os.Unsetenv(ShmSizeEnvVar)
+}
+
+func TestUlimitsFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []string
+		expectErr bool
+	}{
+		{value: "", expected: nil},
+		{value: "nofile=10240:10240", expected: []string{"nofile=10240:10240"}},
+		{value: "nofile=10240, nproc=4096", expected: []string{"nofile=10240", "nproc=4096"}},
+		{value: "nofile", expectErr: true},
+		{value: "nofile=many", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(UlimitsEnvVar, tc.value)
+		ulimits, err := ulimitsFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && !reflect.DeepEqual(ulimits, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, ulimits)
+		}
+	}
+	os.Unsetenv(UlimitsEnvVar)
+}
+
+func TestDockerUlimitsFromEnvironment(t *testing.T) {
+	os.Setenv(UlimitsEnvVar, "nofile=10240:20480")
+	ulimits, err := dockerUlimitsFromEnvironment()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []docker.ULimit{{Name: "nofile", Soft: 10240, Hard: 20480}}
+	if !reflect.DeepEqual(ulimits, expected) {
+		t.Errorf("expected %v, got %v", expected, ulimits)
+	}
+	os.Unsetenv(UlimitsEnvVar)
+}
@@ -0,0 +1,265 @@
+// Package logger provides the single logging facade for the builder. It maps
+// one configured level onto both glog verbosity, which the per-package glog
+// shims consult, and the logrus level used by vendored libraries, replacing
+// the previous per-command juggling of the two. It also offers a small
+// structured logger with stage and field annotations and a configurable
+// output format.
+package logger
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/serviceability"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// LogLevelEnvVar configures the logging level for the whole builder: one of
+// error, warn, info, debug, or a numeric glog verbosity (0-10). When unset
+// the builder logs at debug, matching its historical behavior.
+const LogLevelEnvVar = "BUILD_LOG_LEVEL"
+
+// LogFormatEnvVar configures the output format of the structured logger:
+// "text" (the default) or "json".
+const LogFormatEnvVar = "BUILD_LOG_FORMAT"
+
+// BuildLogLevelEnvVar is the conventional OpenShift build environment
+// variable: a numeric verbosity set on the BuildConfig and carried in the
+// build strategy environment.
+const BuildLogLevelEnvVar = "BUILD_LOGLEVEL"
+
+// Level is a severity of the structured logger. Levels order from most to
+// least severe, so a configured level admits it and everything above it.
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+// levelSpec ties together the three representations of one level: its name,
+// the glog verbosity historically associated with it, and the logrus level
+// name understood by serviceability.InitLogrus.
+type levelSpec struct {
+	name      string
+	level     Level
+	verbosity int
+	logrus    string
+}
+
+var levelSpecs = []levelSpec{
+	{"error", LevelError, 0, "ERROR"},
+	{"warn", LevelWarn, 0, "WARN"},
+	{"info", LevelInfo, 2, "INFO"},
+	{"debug", LevelDebug, 4, "DEBUG"},
+}
+
+var state = struct {
+	sync.Mutex
+	out   io.Writer
+	level Level
+	json  bool
+}{
+	out:   os.Stderr,
+	level: LevelDebug,
+}
+
+// parseLevel resolves a configured level, either a name or a numeric glog
+// verbosity, into a level spec.
+func parseLevel(value string) (levelSpec, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for _, spec := range levelSpecs {
+		if spec.name == name {
+			return spec, nil
+		}
+	}
+	if verbosity, err := strconv.Atoi(name); err == nil && verbosity >= 0 && verbosity <= 10 {
+		// Pick the most verbose named level the verbosity admits, and
+		// keep the exact verbosity for glog.
+		chosen := levelSpecs[0]
+		for _, spec := range levelSpecs {
+			if spec.verbosity <= verbosity {
+				chosen = spec
+			}
+		}
+		chosen.verbosity = verbosity
+		return chosen, nil
+	}
+	return levelSpec{}, fmt.Errorf("unrecognized log level %q: expected error, warn, info, debug, or a verbosity between 0 and 10", value)
+}
+
+// Init configures logging for the process from the environment: the glog
+// verbosity flag, the logrus level, and the structured logger's level and
+// format. Invalid values are reported and leave the defaults in place.
+func Init() {
+	spec := levelSpec{name: "debug", level: LevelDebug, verbosity: 4, logrus: "DEBUG"}
+	if value := os.Getenv(LogLevelEnvVar); len(value) != 0 {
+		parsed, err := parseLevel(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s: %v\n", LogLevelEnvVar, err)
+		} else {
+			spec = parsed
+			if err := flag.Set("v", strconv.Itoa(spec.verbosity)); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: unable to set the glog verbosity: %v\n", err)
+			}
+		}
+	}
+	serviceability.InitLogrus(spec.logrus)
+	state.Lock()
+	defer state.Unlock()
+	state.level = spec.level
+	switch format := strings.ToLower(os.Getenv(LogFormatEnvVar)); format {
+	case "", "text":
+		state.json = false
+	case "json":
+		state.json = true
+	default:
+		fmt.Fprintf(os.Stderr, "warning: ignoring unrecognized %s=%q: expected text or json\n", LogFormatEnvVar, format)
+	}
+}
+
+// ApplyBuildLogLevel reconfigures logging from the BUILD_LOGLEVEL set on the
+// Build, looking at the strategy environment first and the process
+// environment second. It covers glog, logrus, and the s2i logger, which
+// reads the same glog verbosity flag. An explicitly configured
+// BUILD_LOG_LEVEL wins over the Build's loglevel, and without either the
+// defaults from Init stay in place.
+func ApplyBuildLogLevel(build *buildapiv1.Build) {
+	if len(os.Getenv(LogLevelEnvVar)) != 0 {
+		return
+	}
+	value := os.Getenv(BuildLogLevelEnvVar)
+	if build != nil {
+		var strategyEnv []corev1.EnvVar
+		switch {
+		case build.Spec.Strategy.DockerStrategy != nil:
+			strategyEnv = build.Spec.Strategy.DockerStrategy.Env
+		case build.Spec.Strategy.SourceStrategy != nil:
+			strategyEnv = build.Spec.Strategy.SourceStrategy.Env
+		case build.Spec.Strategy.CustomStrategy != nil:
+			strategyEnv = build.Spec.Strategy.CustomStrategy.Env
+		}
+		for _, env := range strategyEnv {
+			if env.Name == BuildLogLevelEnvVar {
+				value = env.Value
+			}
+		}
+	}
+	if len(value) == 0 {
+		return
+	}
+	spec, err := parseLevel(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring %s: %v\n", BuildLogLevelEnvVar, err)
+		return
+	}
+	if err := flag.Set("v", strconv.Itoa(spec.verbosity)); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to set the glog verbosity: %v\n", err)
+	}
+	serviceability.InitLogrus(spec.logrus)
+	state.Lock()
+	defer state.Unlock()
+	state.level = spec.level
+}
+
+// SetOutput redirects the structured logger, for tests.
+func SetOutput(w io.Writer) {
+	state.Lock()
+	defer state.Unlock()
+	state.out = w
+}
+
+// field is one structured annotation. Fields are kept as a slice so text
+// output preserves the order they were added in.
+type field struct {
+	key   string
+	value string
+}
+
+// Logger is a structured logger carrying a fixed set of field annotations.
+// The zero value is usable and has no fields.
+type Logger struct {
+	fields []field
+}
+
+// New returns a structured logger with no fields.
+func New() Logger {
+	return Logger{}
+}
+
+// WithField returns a logger that additionally annotates every message with
+// the given field.
+func (l Logger) WithField(key string, value interface{}) Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return Logger{fields: append(fields, field{key: key, value: fmt.Sprintf("%v", value)})}
+}
+
+// WithStage returns a logger annotating every message with the build stage.
+func (l Logger) WithStage(stage buildapiv1.StageName) Logger {
+	return l.WithField("stage", string(stage))
+}
+
+// Errorf logs at the error level.
+func (l Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, "error", format, args...)
+}
+
+// Warnf logs at the warn level.
+func (l Logger) Warnf(format string, args ...interface{}) {
+	l.logf(LevelWarn, "warn", format, args...)
+}
+
+// Infof logs at the info level.
+func (l Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, "info", format, args...)
+}
+
+// Debugf logs at the debug level.
+func (l Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, "debug", format, args...)
+}
+
+func (l Logger) logf(level Level, levelName, format string, args ...interface{}) {
+	state.Lock()
+	defer state.Unlock()
+	if level > state.level {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	if state.json {
+		entry := map[string]string{
+			"level": levelName,
+			"msg":   message,
+		}
+		for _, f := range l.fields {
+			entry[f.key] = f.value
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			// A map of strings cannot fail to serialize; guard anyway.
+			fmt.Fprintf(state.out, "%s\n", message)
+			return
+		}
+		fmt.Fprintf(state.out, "%s\n", line)
+		return
+	}
+	var annotations []string
+	for _, f := range l.fields {
+		annotations = append(annotations, fmt.Sprintf("%s=%s", f.key, f.value))
+	}
+	if len(annotations) != 0 {
+		message = fmt.Sprintf("%s [%s]", message, strings.Join(annotations, " "))
+	}
+	fmt.Fprintln(state.out, message)
+}
@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name              string
+		value             string
+		expectedLevel     Level
+		expectedVerbosity int
+		expectError       bool
+	}{
+		{
+			name:              "named level",
+			value:             "info",
+			expectedLevel:     LevelInfo,
+			expectedVerbosity: 2,
+		},
+		{
+			name:              "named level is case insensitive",
+			value:             "DEBUG",
+			expectedLevel:     LevelDebug,
+			expectedVerbosity: 4,
+		},
+		{
+			name:              "numeric zero maps to warn",
+			value:             "0",
+			expectedLevel:     LevelWarn,
+			expectedVerbosity: 0,
+		},
+		{
+			name:              "numeric verbosity is preserved",
+			value:             "7",
+			expectedLevel:     LevelDebug,
+			expectedVerbosity: 7,
+		},
+		{
+			name:        "out of range",
+			value:       "11",
+			expectError: true,
+		},
+		{
+			name:        "unrecognized name",
+			value:       "loud",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := parseLevel(test.value)
+			if test.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if spec.level != test.expectedLevel {
+				t.Errorf("level = %v; expected %v", spec.level, test.expectedLevel)
+			}
+			if spec.verbosity != test.expectedVerbosity {
+				t.Errorf("verbosity = %d; expected %d", spec.verbosity, test.expectedVerbosity)
+			}
+		})
+	}
+}
+
+func TestLoggerTextOutput(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+	defer SetOutput(os.Stderr)
+
+	log := New().WithStage("PullImages").WithField("image", "busybox")
+	log.Infof("Pulling image %s", "busybox")
+	expected := "Pulling image busybox [stage=PullImages image=busybox]\n"
+	if buffer.String() != expected {
+		t.Errorf("output = %q; expected %q", buffer.String(), expected)
+	}
+}
+
+func TestLoggerJSONOutput(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+	defer SetOutput(os.Stderr)
+	os.Setenv(LogFormatEnvVar, "json")
+	defer os.Unsetenv(LogFormatEnvVar)
+	Init()
+	defer func() {
+		os.Unsetenv(LogFormatEnvVar)
+		Init()
+	}()
+
+	New().WithField("image", "busybox").Warnf("pull failed")
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buffer.String(), err)
+	}
+	expected := map[string]string{
+		"level": "warn",
+		"msg":   "pull failed",
+		"image": "busybox",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("entry[%q] = %q; expected %q", key, entry[key], value)
+		}
+	}
+}
+
+func TestLoggerLevelFiltering(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+	defer SetOutput(os.Stderr)
+	os.Setenv(LogLevelEnvVar, "warn")
+	defer os.Unsetenv(LogLevelEnvVar)
+	Init()
+	defer func() {
+		os.Unsetenv(LogLevelEnvVar)
+		Init()
+	}()
+
+	log := New()
+	log.Debugf("not shown")
+	log.Infof("not shown either")
+	log.Errorf("shown")
+	if buffer.String() != "shown\n" {
+		t.Errorf("output = %q; expected only the error line", buffer.String())
+	}
+}
+
+func buildWithLogLevel(value string) *buildapiv1.Build {
+	return &buildapiv1.Build{
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Strategy: buildapiv1.BuildStrategy{
+					SourceStrategy: &buildapiv1.SourceBuildStrategy{
+						Env: []corev1.EnvVar{
+							{Name: BuildLogLevelEnvVar, Value: value},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyBuildLogLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		build         *buildapiv1.Build
+		processValue  string
+		explicitLevel string
+		expected      Level
+	}{
+		{
+			name:     "strategy environment",
+			build:    buildWithLogLevel("0"),
+			expected: LevelWarn,
+		},
+		{
+			name:         "process environment fallback",
+			build:        &buildapiv1.Build{},
+			processValue: "2",
+			expected:     LevelInfo,
+		},
+		{
+			name:         "strategy environment wins over process environment",
+			build:        buildWithLogLevel("5"),
+			processValue: "0",
+			expected:     LevelDebug,
+		},
+		{
+			name:          "explicit BUILD_LOG_LEVEL wins",
+			build:         buildWithLogLevel("0"),
+			explicitLevel: "info",
+			expected:      LevelInfo,
+		},
+		{
+			name:     "invalid value leaves the default",
+			build:    buildWithLogLevel("chatty"),
+			expected: LevelDebug,
+		},
+		{
+			name:     "unset leaves the default",
+			build:    &buildapiv1.Build{},
+			expected: LevelDebug,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Unsetenv(LogLevelEnvVar)
+			os.Unsetenv(BuildLogLevelEnvVar)
+			defer func() {
+				os.Unsetenv(LogLevelEnvVar)
+				os.Unsetenv(BuildLogLevelEnvVar)
+				Init()
+			}()
+			if len(test.processValue) != 0 {
+				os.Setenv(BuildLogLevelEnvVar, test.processValue)
+			}
+			if len(test.explicitLevel) != 0 {
+				os.Setenv(LogLevelEnvVar, test.explicitLevel)
+			}
+			Init()
+			ApplyBuildLogLevel(test.build)
+			state.Lock()
+			level := state.level
+			state.Unlock()
+			if level != test.expected {
+				t.Errorf("level = %v; expected %v", level, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+)
+
+// BuildLogMaxBytesEnvVar is the name of the environment variable that caps
+// the amount of container output a build streams to its log, expressed with
+// an optional unit suffix ("5m"). When the budget is exceeded, the middle of
+// the log is dropped and a truncation marker is emitted, so the beginning
+// and the end of the output - usually the interesting parts - survive.
+const BuildLogMaxBytesEnvVar = "BUILD_LOG_MAX_BYTES"
+
+// BuildLogMaxLinesEnvVar is the name of the environment variable that caps
+// the number of container output lines a build streams to its log.
+const BuildLogMaxLinesEnvVar = "BUILD_LOG_MAX_LINES"
+
+// buildLogBudgetFromEnvironment returns the configured log budget. Zero
+// values mean the corresponding dimension is not limited.
+func buildLogBudgetFromEnvironment() (maxBytes, maxLines int64, err error) {
+	if value := strings.TrimSpace(os.Getenv(BuildLogMaxBytesEnvVar)); len(value) != 0 {
+		maxBytes, err = units.RAMInBytes(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid value %q for %s: %v", value, BuildLogMaxBytesEnvVar, err)
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(BuildLogMaxLinesEnvVar)); len(value) != 0 {
+		maxLines, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid value %q for %s: %v", value, BuildLogMaxLinesEnvVar, err)
+		}
+	}
+	return maxBytes, maxLines, nil
+}
+
+// buildLogWriter enforces a log budget on container output. The first half
+// of the budget streams through unmodified; once it is spent the writer
+// switches to retaining only a tail, which Flush emits after a truncation
+// marker. Writes never fail the producing build step.
+type buildLogWriter struct {
+	out      io.Writer
+	maxBytes int64
+	maxLines int64
+
+	headBytes int64
+	headLines int64
+	tailBytes int64
+	tailLines int64
+
+	truncating bool
+	swallowed  int64
+	tail       []byte
+}
+
+// newBuildLogWriter wraps out with the given log budget. A zero budget in
+// both dimensions leaves the output unlimited.
+func newBuildLogWriter(out io.Writer, maxBytes, maxLines int64) *buildLogWriter {
+	return &buildLogWriter{
+		out:      out,
+		maxBytes: maxBytes,
+		maxLines: maxLines,
+		// The head gets half the budget and the tail a quarter, leaving
+		// room for the truncation marker and rounding.
+		headBytes: maxBytes / 2,
+		headLines: maxLines / 2,
+		tailBytes: maxBytes / 4,
+		tailLines: maxLines / 4,
+	}
+}
+
+func (w *buildLogWriter) Write(p []byte) (int, error) {
+	// The log stream service receives the output before any truncation, so
+	// external consumers see the complete log.
+	PublishBuildLog(p)
+	if w.maxBytes == 0 && w.maxLines == 0 {
+		return w.out.Write(p)
+	}
+	if !w.truncating {
+		passed := w.headroom(p)
+		if passed > 0 {
+			if _, err := w.out.Write(p[:passed]); err != nil {
+				return passed, err
+			}
+			w.headBytes -= int64(passed)
+			w.headLines -= int64(bytes.Count(p[:passed], []byte("\n")))
+		}
+		if passed == len(p) {
+			return len(p), nil
+		}
+		w.truncating = true
+		p = p[passed:]
+	}
+	w.swallowed += int64(len(p))
+	w.tail = append(w.tail, p...)
+	w.trimTail()
+	return len(p), nil
+}
+
+// headroom returns how much of p still fits into the head of the budget.
+func (w *buildLogWriter) headroom(p []byte) int {
+	passed := len(p)
+	if w.maxBytes > 0 && int64(passed) > w.headBytes {
+		passed = int(w.headBytes)
+	}
+	if w.maxLines > 0 {
+		if w.headLines <= 0 {
+			return 0
+		}
+		lines := int64(0)
+		for i := 0; i < passed; i++ {
+			if p[i] == '\n' {
+				lines++
+				if lines == w.headLines {
+					passed = i + 1
+					break
+				}
+			}
+		}
+	}
+	return passed
+}
+
+// trimTail drops whole lines from the front of the retained tail until it
+// fits its share of the budget.
+func (w *buildLogWriter) trimTail() {
+	for (w.maxBytes > 0 && int64(len(w.tail)) > w.tailBytes) ||
+		(w.maxLines > 0 && int64(bytes.Count(w.tail, []byte("\n"))) > w.tailLines) {
+		newline := bytes.IndexByte(w.tail, '\n')
+		if newline < 0 {
+			w.tail = nil
+			return
+		}
+		w.tail = w.tail[newline+1:]
+	}
+}
+
+// Flush emits the truncation marker and the retained tail if the budget was
+// exceeded. It must run once the producing build step has finished.
+func (w *buildLogWriter) Flush() {
+	if !w.truncating {
+		return
+	}
+	omitted := w.swallowed - int64(len(w.tail))
+	fmt.Fprintf(w.out, "\n[build log truncated: %s omitted to stay within the configured budget]\n", units.BytesSize(float64(omitted)))
+	if len(w.tail) > 0 {
+		w.out.Write(w.tail)
+	}
+	w.truncating = false
+	w.swallowed = 0
+	w.tail = nil
+}
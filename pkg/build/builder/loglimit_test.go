@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildLogBudgetFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		bytesValue string
+		linesValue string
+		maxBytes   int64
+		maxLines   int64
+		expectErr  bool
+	}{
+		{bytesValue: "", linesValue: ""},
+		{bytesValue: "5m", linesValue: "", maxBytes: 5 * 1024 * 1024},
+		{bytesValue: "", linesValue: "1000", maxLines: 1000},
+		{bytesValue: "1024", linesValue: "10", maxBytes: 1024, maxLines: 10},
+		{bytesValue: "much", linesValue: "", expectErr: true},
+		{bytesValue: "", linesValue: "few", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(BuildLogMaxBytesEnvVar, tc.bytesValue)
+		os.Setenv(BuildLogMaxLinesEnvVar, tc.linesValue)
+		maxBytes, maxLines, err := buildLogBudgetFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q/%q: expected error %v, got %v", tc.bytesValue, tc.linesValue, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && (maxBytes != tc.maxBytes || maxLines != tc.maxLines) {
+			t.Errorf("%q/%q: expected (%d, %d), got (%d, %d)", tc.bytesValue, tc.linesValue, tc.maxBytes, tc.maxLines, maxBytes, maxLines)
+		}
+	}
+	os.Unsetenv(BuildLogMaxBytesEnvVar)
+	os.Unsetenv(BuildLogMaxLinesEnvVar)
+}
+
+func TestBuildLogWriterUnlimited(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newBuildLogWriter(out, 0, 0)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	w.Flush()
+	if got := strings.Count(out.String(), "\n"); got != 100 {
+		t.Errorf("expected all 100 lines, got %d", got)
+	}
+	if strings.Contains(out.String(), "truncated") {
+		t.Errorf("expected no truncation marker, got %q", out.String())
+	}
+}
+
+func TestBuildLogWriterLineBudget(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newBuildLogWriter(out, 0, 20)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	w.Flush()
+
+	log := out.String()
+	if !strings.Contains(log, "line 0\n") || !strings.Contains(log, "line 9\n") {
+		t.Errorf("expected the head of the log to be preserved, got %q", log)
+	}
+	if !strings.Contains(log, "line 99\n") {
+		t.Errorf("expected the tail of the log to be preserved, got %q", log)
+	}
+	if strings.Contains(log, "line 50\n") {
+		t.Errorf("expected the middle of the log to be dropped, got %q", log)
+	}
+	if !strings.Contains(log, "truncated") {
+		t.Errorf("expected a truncation marker, got %q", log)
+	}
+}
+
+func TestBuildLogWriterByteBudget(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newBuildLogWriter(out, 400, 0)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %02d\n", i)
+	}
+	w.Flush()
+
+	log := out.String()
+	if !strings.Contains(log, "line 00\n") {
+		t.Errorf("expected the head of the log to be preserved, got %q", log)
+	}
+	if !strings.Contains(log, "line 99\n") {
+		t.Errorf("expected the tail of the log to be preserved, got %q", log)
+	}
+	if !strings.Contains(log, "truncated") {
+		t.Errorf("expected a truncation marker, got %q", log)
+	}
+	if len(log) > 600 {
+		t.Errorf("expected the log to stay near the 400 byte budget, got %d bytes", len(log))
+	}
+}
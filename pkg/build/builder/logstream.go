@@ -0,0 +1,161 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogStreamSocketEnvVar configures a unix socket path on which the builder
+// serves the build log as a stream of structured records. Sidecars such as
+// log forwarders can consume stage-annotated log lines from it instead of
+// scraping the container stdout. The service is not started when unset.
+const LogStreamSocketEnvVar = "BUILD_LOG_STREAM_SOCKET"
+
+// maxLogStreamRecords bounds the log history retained for late-connecting
+// consumers. Older records are dropped; offsets keep increasing so a
+// consumer can detect the gap.
+const maxLogStreamRecords = 10000
+
+// logStreamRecord is one log line as served over the log stream socket.
+type logStreamRecord struct {
+	Offset int64  `json:"offset"`
+	Time   string `json:"time"`
+	Stage  string `json:"stage,omitempty"`
+	Line   string `json:"line"`
+}
+
+// logStream retains recent build log lines and wakes connected consumers
+// when new ones arrive.
+type logStream struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	records []logStreamRecord
+	next    int64
+	partial []byte
+}
+
+// currentLogStream is nil unless the log stream service was configured.
+var currentLogStream *logStream
+
+// PublishBuildLog feeds build output to the log stream service, if one is
+// running. Incomplete lines are buffered until their newline arrives.
+func PublishBuildLog(p []byte) {
+	if currentLogStream == nil {
+		return
+	}
+	currentLogStream.publish(p)
+}
+
+func (s *logStream) publish(p []byte) {
+	stage, _ := currentProgress.snapshot()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		newline := bytes.IndexByte(s.partial, '\n')
+		if newline < 0 {
+			break
+		}
+		s.records = append(s.records, logStreamRecord{
+			Offset: s.next,
+			Time:   now,
+			Stage:  string(stage),
+			Line:   string(s.partial[:newline]),
+		})
+		s.next++
+		s.partial = s.partial[newline+1:]
+	}
+	if len(s.records) > maxLogStreamRecords {
+		s.records = s.records[len(s.records)-maxLogStreamRecords:]
+	}
+	s.cond.Broadcast()
+}
+
+// copyFrom returns the retained records at or past the given offset, waiting
+// for new ones when the consumer has caught up.
+func (s *logStream) copyFrom(offset int64) []logStreamRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.next <= offset {
+		s.cond.Wait()
+	}
+	oldest := s.records[0].Offset
+	if offset < oldest {
+		offset = oldest
+	}
+	return append([]logStreamRecord(nil), s.records[offset-oldest:]...)
+}
+
+// serveLogStream streams records to one consumer. The consumer sends the
+// offset it wants to resume from as the first line (an empty line starts
+// from the oldest retained record); each record is then delivered as one
+// JSON line.
+func (s *logStream) serveLogStream(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	request, err := reader.ReadString('\n')
+	if err != nil {
+		glog.V(4).Infof("Error reading log stream request: %v", err)
+		return
+	}
+	offset := int64(0)
+	if trimmed := strings.TrimSpace(request); len(trimmed) != 0 {
+		offset, err = strconv.ParseInt(trimmed, 10, 64)
+		if err != nil || offset < 0 {
+			glog.V(4).Infof("Ignoring invalid log stream offset %q", trimmed)
+			offset = 0
+		}
+	}
+	encoder := json.NewEncoder(conn)
+	for {
+		records := s.copyFrom(offset)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				glog.V(4).Infof("Log stream consumer disconnected: %v", err)
+				return
+			}
+		}
+		offset = records[len(records)-1].Offset + 1
+	}
+}
+
+// StartLogStreamService serves structured build log records on the unix
+// socket configured by BUILD_LOG_STREAM_SOCKET, in the background for the
+// rest of the process lifetime. It does nothing when the variable is not
+// set.
+func StartLogStreamService() error {
+	path := os.Getenv(LogStreamSocketEnvVar)
+	if len(path) == 0 {
+		return nil
+	}
+	// A socket left behind by a previous builder would block the listen.
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	stream := &logStream{}
+	stream.cond = sync.NewCond(&stream.mutex)
+	currentLogStream = stream
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				glog.V(0).Infof("warning: Log stream service terminated: %v", err)
+				return
+			}
+			go stream.serveLogStream(conn)
+		}
+	}()
+	glog.V(2).Infof("Serving the build log stream on %s", path)
+	return nil
+}
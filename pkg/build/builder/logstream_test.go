@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTestLogStream() *logStream {
+	stream := &logStream{}
+	stream.cond = sync.NewCond(&stream.mutex)
+	return stream
+}
+
+func TestLogStreamPublish(t *testing.T) {
+	stream := newTestLogStream()
+	stream.publish([]byte("first line\nsecond "))
+	stream.publish([]byte("line\n"))
+	stream.publish([]byte("incomplete"))
+
+	if len(stream.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(stream.records))
+	}
+	if stream.records[0].Line != "first line" || stream.records[0].Offset != 0 {
+		t.Errorf("unexpected first record: %#v", stream.records[0])
+	}
+	if stream.records[1].Line != "second line" || stream.records[1].Offset != 1 {
+		t.Errorf("unexpected second record: %#v", stream.records[1])
+	}
+	if string(stream.partial) != "incomplete" {
+		t.Errorf("expected the partial line to be buffered, got %q", string(stream.partial))
+	}
+}
+
+func TestLogStreamCopyFrom(t *testing.T) {
+	stream := newTestLogStream()
+	for i := 0; i < maxLogStreamRecords+10; i++ {
+		stream.publish([]byte(fmt.Sprintf("line %d\n", i)))
+	}
+	if len(stream.records) != maxLogStreamRecords {
+		t.Fatalf("expected the history to be bounded at %d records, got %d", maxLogStreamRecords, len(stream.records))
+	}
+	// An offset that has already been dropped resumes at the oldest record.
+	records := stream.copyFrom(0)
+	if records[0].Offset != 10 {
+		t.Errorf("expected the oldest retained offset to be 10, got %d", records[0].Offset)
+	}
+	records = stream.copyFrom(stream.next - 1)
+	if len(records) != 1 || records[0].Line != fmt.Sprintf("line %d", maxLogStreamRecords+9) {
+		t.Errorf("unexpected records from the tail: %#v", records)
+	}
+}
+
+func TestLogStreamService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstream-test")
+	if err != nil {
+		t.Fatalf("failed to create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "logs.sock")
+	os.Setenv(LogStreamSocketEnvVar, socket)
+	defer os.Unsetenv(LogStreamSocketEnvVar)
+	defer func() { currentLogStream = nil }()
+
+	if err := StartLogStreamService(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	PublishBuildLog([]byte("hello\nworld\n"))
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "1\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	record := logStreamRecord{}
+	if err := json.Unmarshal(line, &record); err != nil {
+		t.Fatalf("failed to parse record %q: %v", string(line), err)
+	}
+	if record.Offset != 1 || record.Line != "world" {
+		t.Errorf("unexpected record: %#v", record)
+	}
+}
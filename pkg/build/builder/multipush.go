@@ -0,0 +1,186 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
+	"github.com/openshift/builder/pkg/imagereference"
+)
+
+const (
+	// AdditionalPushDestinationsEnvVar names the environment variable
+	// carrying a JSON list of image references to push the build output to
+	// in addition to the build's output reference.
+	AdditionalPushDestinationsEnvVar = "BUILD_ADDITIONAL_PUSH_DESTINATIONS"
+
+	// PushPartialSuccessPolicyEnvVar controls whether a build with multiple
+	// push destinations fails when only some of the pushes succeed. "fail"
+	// (the default) fails the build; "tolerate" keeps it successful as long
+	// as the primary destination was pushed.
+	PushPartialSuccessPolicyEnvVar = "BUILD_PUSH_PARTIAL_SUCCESS_POLICY"
+
+	// PushResultsAnnotation records the per-destination push outcomes as
+	// JSON on the build, so consumers can see which destinations received
+	// the image and under which digest.
+	PushResultsAnnotation = "build.openshift.io/push-results"
+)
+
+// PushDestinationResult is the recorded outcome of pushing to one
+// destination.
+type PushDestinationResult struct {
+	Destination string `json:"destination"`
+	Digest      string `json:"digest,omitempty"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// additionalPushDestinations reads the extra push destinations from the
+// environment. An unset variable means only the build output reference is
+// pushed.
+func additionalPushDestinations() ([]string, error) {
+	value := os.Getenv(AdditionalPushDestinationsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var destinations []string
+	if err := json.Unmarshal([]byte(value), &destinations); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", AdditionalPushDestinationsEnvVar, err)
+	}
+	for _, destination := range destinations {
+		if err := imagereference.Validate(destination); err != nil {
+			return nil, fmt.Errorf("invalid push destination %q: %v", destination, err)
+		}
+	}
+	return destinations, nil
+}
+
+// partialPushTolerated reports whether the cluster allows a build to succeed
+// when some (but not all) push destinations fail.
+func partialPushTolerated() (bool, error) {
+	switch value := os.Getenv(PushPartialSuccessPolicyEnvVar); {
+	case len(value) == 0, strings.EqualFold(value, "fail"):
+		return false, nil
+	case strings.EqualFold(value, "tolerate"):
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid value %q for %s: must be \"fail\" or \"tolerate\"", os.Getenv(PushPartialSuccessPolicyEnvVar), PushPartialSuccessPolicyEnvVar)
+	}
+}
+
+// MultiPusher pushes an already-built image to a set of destinations with
+// all-or-nothing semantics: every destination is attempted even after a
+// failure, and the returned error aggregates the failures per destination.
+type MultiPusher interface {
+	// Push tags sourceTag as each destination and pushes it there. It
+	// returns the per-destination results, and a *MultiPushError when any
+	// destination failed.
+	Push(sourceTag string, destinations []string) ([]PushDestinationResult, error)
+}
+
+// MultiPushError aggregates the failed destinations of a multi-registry
+// push.
+type MultiPushError struct {
+	// Failed holds the result of each destination that was not pushed.
+	Failed []PushDestinationResult
+	// Attempted is how many destinations were attempted in total.
+	Attempted int
+}
+
+func (e *MultiPushError) Error() string {
+	descriptions := make([]string, 0, len(e.Failed))
+	for _, result := range e.Failed {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s", result.Destination, result.Error))
+	}
+	return fmt.Sprintf("%d of %d push destinations failed: %s", len(e.Failed), e.Attempted, strings.Join(descriptions, "; "))
+}
+
+// multiPusher is the MultiPusher working against the build's image store.
+type multiPusher struct {
+	dockerClient DockerClient
+	pushFn       func(name string, authConfig docker.AuthConfiguration) (string, error)
+}
+
+// NewMultiPusher returns a MultiPusher that tags with the given client and
+// pushes with the given function, each destination with its own push
+// credentials.
+func NewMultiPusher(dockerClient DockerClient, pushFn func(name string, authConfig docker.AuthConfiguration) (string, error)) MultiPusher {
+	return multiPusher{dockerClient: dockerClient, pushFn: pushFn}
+}
+
+func (p multiPusher) Push(sourceTag string, destinations []string) ([]PushDestinationResult, error) {
+	var results []PushDestinationResult
+	var failed []PushDestinationResult
+	for i, destination := range destinations {
+		glog.V(0).Infof("\nPushing image %s (destination %d of %d) ...", destination, i+1, len(destinations))
+		result := PushDestinationResult{Destination: destination}
+		if err := tagImage(p.dockerClient, sourceTag, destination); err != nil {
+			result.Error = err.Error()
+		} else {
+			authConfig, _ := dockercfg.NewHelper().GetDockerAuth(destination, dockercfg.PushAuthType)
+			digest, err := p.pushFn(destination, authConfig)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Success = true
+				result.Digest = digest
+				glog.V(0).Infof("Push to %s successful", destination)
+			}
+		}
+		if !result.Success {
+			failed = append(failed, result)
+			glog.V(0).Infof("warning: Push to %s failed: %s", destination, result.Error)
+		}
+		results = append(results, result)
+	}
+	if len(failed) > 0 {
+		return results, &MultiPushError{Failed: failed, Attempted: len(destinations)}
+	}
+	return results, nil
+}
+
+// pushAdditionalDestinations tags and pushes the already-pushed image to each
+// configured extra destination, reporting progress per destination. It
+// returns the per-destination results, and an error unless every destination
+// succeeded or partial success is tolerated.
+func pushAdditionalDestinations(dockerClient DockerClient, sourceTag string, pushFn func(name string, authConfig docker.AuthConfiguration) (string, error)) ([]PushDestinationResult, error) {
+	destinations, err := additionalPushDestinations()
+	if err != nil {
+		return nil, err
+	}
+	if len(destinations) == 0 {
+		return nil, nil
+	}
+	tolerated, err := partialPushTolerated()
+	if err != nil {
+		return nil, err
+	}
+
+	results, pushErr := NewMultiPusher(dockerClient, pushFn).Push(sourceTag, destinations)
+	if pushErr != nil && !tolerated {
+		return results, pushErr
+	}
+	return results, nil
+}
+
+// recordPushResults stamps the per-destination outcomes on the build. A
+// marshalling failure only loses the annotation, not the build.
+func recordPushResults(build *buildapiv1.Build, results []PushDestinationResult) {
+	if len(results) == 0 {
+		return
+	}
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to record push results: %v", err)
+		return
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[PushResultsAnnotation] = string(resultsJSON)
+}
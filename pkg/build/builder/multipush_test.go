@@ -0,0 +1,120 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func TestMultiPusherAggregatesFailures(t *testing.T) {
+	pushFn := func(name string, authConfig docker.AuthConfiguration) (string, error) {
+		if name == "registry-b.example.com/ns/app:latest" {
+			return "", errors.New("connection refused")
+		}
+		return "sha256:0000000000000000000000000000000000000000000000000000000000000000", nil
+	}
+	destinations := []string{
+		"registry-a.example.com/ns/app:latest",
+		"registry-b.example.com/ns/app:latest",
+		"registry-c.example.com/ns/app:latest",
+	}
+	results, err := NewMultiPusher(&FakeDocker{}, pushFn).Push("source:tag", destinations)
+	if len(results) != 3 {
+		t.Fatalf("expected every destination to be attempted, got %v", results)
+	}
+	pushErr, ok := err.(*MultiPushError)
+	if !ok {
+		t.Fatalf("expected a *MultiPushError, got %v", err)
+	}
+	if pushErr.Attempted != 3 || len(pushErr.Failed) != 1 {
+		t.Errorf("unexpected aggregation %+v", pushErr)
+	}
+	message := pushErr.Error()
+	if message != "1 of 3 push destinations failed: registry-b.example.com/ns/app:latest: connection refused" {
+		t.Errorf("unexpected error message %q", message)
+	}
+}
+
+func TestPushAdditionalDestinations(t *testing.T) {
+	testCases := []struct {
+		name         string
+		destinations string
+		policy       string
+		pushErrs     map[string]error
+		expectErr    bool
+		expectedOK   int
+	}{
+		{
+			name: "no destinations",
+		},
+		{
+			name:         "all succeed",
+			destinations: `["registry-a.example.com/ns/app:latest","registry-b.example.com/ns/app:latest"]`,
+			expectedOK:   2,
+		},
+		{
+			name:         "partial failure fails by default",
+			destinations: `["registry-a.example.com/ns/app:latest","registry-b.example.com/ns/app:latest"]`,
+			pushErrs:     map[string]error{"registry-b.example.com/ns/app:latest": errors.New("connection refused")},
+			expectErr:    true,
+			expectedOK:   1,
+		},
+		{
+			name:         "partial failure tolerated",
+			destinations: `["registry-a.example.com/ns/app:latest","registry-b.example.com/ns/app:latest"]`,
+			policy:       "tolerate",
+			pushErrs:     map[string]error{"registry-b.example.com/ns/app:latest": errors.New("connection refused")},
+			expectedOK:   1,
+		},
+		{
+			name:         "invalid destination",
+			destinations: `["UPPERCASE not a reference"]`,
+			expectErr:    true,
+		},
+		{
+			name:         "invalid policy",
+			destinations: `["registry-a.example.com/ns/app:latest"]`,
+			policy:       "sometimes",
+			expectErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.destinations) > 0 {
+				os.Setenv(AdditionalPushDestinationsEnvVar, tc.destinations)
+				defer os.Unsetenv(AdditionalPushDestinationsEnvVar)
+			}
+			if len(tc.policy) > 0 {
+				os.Setenv(PushPartialSuccessPolicyEnvVar, tc.policy)
+				defer os.Unsetenv(PushPartialSuccessPolicyEnvVar)
+			}
+			pushFn := func(name string, authConfig docker.AuthConfiguration) (string, error) {
+				if err := tc.pushErrs[name]; err != nil {
+					return "", err
+				}
+				return "sha256:0000000000000000000000000000000000000000000000000000000000000000", nil
+			}
+			results, err := pushAdditionalDestinations(&FakeDocker{}, "source:tag", pushFn)
+			if tc.expectErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			succeeded := 0
+			for _, result := range results {
+				if result.Success {
+					succeeded++
+					if len(result.Digest) == 0 {
+						t.Errorf("successful push to %s recorded no digest", result.Destination)
+					}
+				}
+			}
+			if succeeded != tc.expectedOK {
+				t.Errorf("got %d successful destinations; want %d", succeeded, tc.expectedOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NetworkModeEnvVar selects the network namespace mode for containers that
+// run during the build. Supported modes are "pod" (the default; build steps
+// share the build pod's network), "host" (treated as "pod", because the
+// builder itself already runs inside the pod's namespace), "none" (a fresh,
+// unconfigured network namespace, for hermetic builds that must only use
+// pre-fetched dependencies) and "slirp4netns" (user-mode networking, when the
+// build runtime supports it).
+const NetworkModeEnvVar = "BUILD_NETWORK_MODE"
+
+const (
+	networkModePod   = "pod"
+	networkModeHost  = "host"
+	networkModeNone  = "none"
+	networkModeSlirp = "slirp4netns"
+)
+
+// OfflineBuildEnvVar switches the build to offline mode when set to "true":
+// only the builder's own pull and push phases use the network, while RUN
+// instructions and the assemble script execute with networking disabled.
+// Builds then cannot download dependencies on the fly, which keeps them
+// reproducible from the pre-fetched inputs alone.
+const OfflineBuildEnvVar = "BUILD_OFFLINE"
+
+// offlineBuildEnabled reports whether offline mode was requested.
+func offlineBuildEnabled() bool {
+	return strings.EqualFold(os.Getenv(OfflineBuildEnvVar), "true")
+}
+
+// networkModeFromEnvironment reads and validates the requested network mode.
+// An unset variable selects the pod network. Modes this runtime cannot
+// provide fall back to the pod network with a warning rather than quietly
+// leaving the build without connectivity.
+func networkModeFromEnvironment() (string, error) {
+	mode := strings.ToLower(os.Getenv(NetworkModeEnvVar))
+	if offlineBuildEnabled() {
+		if len(mode) != 0 && mode != networkModeNone {
+			return "", fmt.Errorf("%s=true requires the %s network mode, but %s requests %q", OfflineBuildEnvVar, networkModeNone, NetworkModeEnvVar, mode)
+		}
+		return networkModeNone, nil
+	}
+	switch mode {
+	case "", networkModePod, networkModeHost, networkModeNone:
+		return mode, nil
+	case networkModeSlirp:
+		glog.V(0).Infof("warning: The %s network mode is not supported by this build runtime; using the pod network instead", networkModeSlirp)
+		return networkModePod, nil
+	default:
+		return "", fmt.Errorf("unrecognized %s setting %q, supported modes: %s, %s, %s, %s", NetworkModeEnvVar, mode, networkModeHost, networkModeNone, networkModePod, networkModeSlirp)
+	}
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNetworkModeFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  string
+		expectErr bool
+	}{
+		{value: "", expected: ""},
+		{value: "pod", expected: networkModePod},
+		{value: "Host", expected: networkModeHost},
+		{value: "none", expected: networkModeNone},
+		{value: "slirp4netns", expected: networkModePod},
+		{value: "bridge", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(NetworkModeEnvVar, tc.value)
+		mode, err := networkModeFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && mode != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, mode)
+		}
+	}
+	os.Unsetenv(NetworkModeEnvVar)
+}
+
+func TestNetworkModeFromEnvironmentOffline(t *testing.T) {
+	testCases := []struct {
+		mode      string
+		expected  string
+		expectErr bool
+	}{
+		{mode: "", expected: networkModeNone},
+		{mode: "none", expected: networkModeNone},
+		{mode: "pod", expectErr: true},
+		{mode: "host", expectErr: true},
+	}
+	os.Setenv(OfflineBuildEnvVar, "true")
+	for _, tc := range testCases {
+		os.Setenv(NetworkModeEnvVar, tc.mode)
+		mode, err := networkModeFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.mode, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && mode != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.mode, tc.expected, mode)
+		}
+	}
+	os.Unsetenv(OfflineBuildEnvVar)
+	os.Unsetenv(NetworkModeEnvVar)
+}
@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+
+	"github.com/openshift/imagebuilder"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// DisallowOnBuildEnvVar, when set to true, makes Docker-strategy builds fail
+// if the Dockerfile declares ONBUILD instructions or any base image carries
+// ONBUILD triggers, for clusters that consider triggers from untrusted base
+// images a security risk.
+const DisallowOnBuildEnvVar = "BUILD_DISALLOW_ONBUILD"
+
+// StatusReasonOnBuildForbidden is the reason associated with builds rejected
+// because of ONBUILD use while the cluster forbids it.
+const StatusReasonOnBuildForbidden buildapiv1.StatusReason = "OnBuildForbidden"
+
+// onBuildDisallowed reports whether the cluster forbids ONBUILD use.
+func onBuildDisallowed() bool {
+	return strings.EqualFold(os.Getenv(DisallowOnBuildEnvVar), "true")
+}
+
+// checkOnBuildAllowed returns an error naming the offending instruction or
+// image when the Dockerfile declares ONBUILD instructions or one of the
+// referenced base images carries ONBUILD triggers.
+func checkOnBuildAllowed(dockerClient DockerClient, dockerfilePath string, imageNames []string) error {
+	node, err := imagebuilder.ParseFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	for _, child := range node.Children {
+		if child.Value == dockercmd.Onbuild {
+			return fmt.Errorf("the Dockerfile declares an ONBUILD instruction, which is not allowed in this cluster")
+		}
+	}
+	for _, imageName := range imageNames {
+		if imageName == "scratch" {
+			continue
+		}
+		image, err := dockerClient.InspectImage(imageName)
+		if err != nil || image == nil || image.Config == nil {
+			// A missing image will fail the build later with a better error.
+			continue
+		}
+		if len(image.Config.OnBuild) > 0 {
+			return fmt.Errorf("base image %s carries ONBUILD triggers (%s), which are not allowed in this cluster", imageName, strings.Join(image.Config.OnBuild, "; "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/openshift/imagebuilder"
+)
+
+type onBuildFakeDocker struct {
+	FakeDocker
+	onBuild map[string][]string
+}
+
+func (d *onBuildFakeDocker) InspectImage(name string) (*docker.Image, error) {
+	return &docker.Image{
+		Config: &docker.Config{OnBuild: d.onBuild[name]},
+	}, nil
+}
+
+func writeTestDockerfile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "onbuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerfilePath := filepath.Join(dir, defaultDockerfilePath)
+	if err := ioutil.WriteFile(dockerfilePath, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dockerfilePath
+}
+
+func TestCheckOnBuildAllowed(t *testing.T) {
+	client := &onBuildFakeDocker{
+		onBuild: map[string][]string{
+			"triggers:latest": {"RUN echo hello"},
+		},
+	}
+
+	dockerfilePath := writeTestDockerfile(t, "FROM centos:7\nRUN true\n")
+	defer os.RemoveAll(filepath.Dir(dockerfilePath))
+	if err := checkOnBuildAllowed(client, dockerfilePath, []string{"centos:7", "scratch"}); err != nil {
+		t.Errorf("unexpected error for trigger-free build: %v", err)
+	}
+
+	if err := checkOnBuildAllowed(client, dockerfilePath, []string{"triggers:latest"}); err == nil {
+		t.Error("expected error for base image with ONBUILD triggers, got none")
+	}
+
+	onBuildDockerfilePath := writeTestDockerfile(t, "FROM centos:7\nONBUILD RUN echo hello\n")
+	defer os.RemoveAll(filepath.Dir(onBuildDockerfilePath))
+	if err := checkOnBuildAllowed(client, onBuildDockerfilePath, []string{"centos:7"}); err == nil {
+		t.Error("expected error for Dockerfile with ONBUILD instruction, got none")
+	}
+}
+
+// The daemonless path executes Dockerfiles through the vendored imagebuilder
+// engine, so the tests below pin its ONBUILD semantics to what the Docker
+// daemon does: triggers recorded in the base image run first, in order,
+// exactly once, and ONBUILD instructions in the child Dockerfile are recorded
+// in the resulting config without being executed.
+
+func TestDaemonlessOnBuildTriggerOrder(t *testing.T) {
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader("FROM triggers:latest\nRUN child-step\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	base := &docker.Image{
+		Config: &docker.Config{
+			OnBuild: []string{"RUN trigger-one", "COPY rootfs /rootfs"},
+		},
+	}
+	b := imagebuilder.NewBuilder(nil)
+	if err := b.FromImage(base, node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var steps []string
+	for _, child := range node.Children {
+		steps = append(steps, child.Original)
+	}
+	expected := []string{"RUN trigger-one", "COPY rootfs /rootfs", "RUN child-step"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("expected steps %v, got %v", expected, steps)
+	}
+	if len(b.Config().OnBuild) != 0 {
+		t.Errorf("expected executed triggers to be cleared from the config, got %v", b.Config().OnBuild)
+	}
+}
+
+func TestDaemonlessOnBuildForbiddenTriggers(t *testing.T) {
+	testCases := []string{
+		"ONBUILD RUN echo chained",
+		"FROM centos:7",
+		"MAINTAINER nobody",
+	}
+	for _, trigger := range testCases {
+		node, err := imagebuilder.ParseDockerfile(strings.NewReader("FROM triggers:latest\nRUN true\n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		base := &docker.Image{Config: &docker.Config{OnBuild: []string{trigger}}}
+		if err := imagebuilder.NewBuilder(nil).FromImage(base, node); err == nil {
+			t.Errorf("expected trigger %q to be rejected, got no error", trigger)
+		}
+	}
+}
+
+func TestDaemonlessOnBuildRecordsTriggers(t *testing.T) {
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader("FROM scratch\nONBUILD RUN echo deferred\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b := imagebuilder.NewBuilder(nil)
+	if err := b.FromImage(&docker.Image{Config: &docker.Config{}}, node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, child := range node.Children {
+		step := b.Step()
+		if err := step.Resolve(child); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := b.Run(step, imagebuilder.NoopExecutor, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	expected := []string{"RUN echo deferred"}
+	if !reflect.DeepEqual(b.Config().OnBuild, expected) {
+		t.Errorf("expected recorded triggers %v, got %v", expected, b.Config().OnBuild)
+	}
+}
@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"fmt"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/builder/pkg/imagereference"
+)
+
+// StatusReasonInvalidOutputReference is the reason associated with builds
+// rejected up front because their output image reference cannot be parsed.
+const StatusReasonInvalidOutputReference buildapiv1.StatusReason = "InvalidOutputImageReference"
+
+// ValidateOutputImageReference parses the build's output image reference
+// before any work starts, so a bad tag or digest fails the build immediately
+// instead of at push time after minutes of building. Valid references are
+// rewritten to their normalized form, so spelled-out docker.io/library
+// references and their implicit shorthand are treated identically by the
+// rest of the build.
+func ValidateOutputImageReference(build *buildapiv1.Build) error {
+	output := build.Spec.Output.To
+	if output == nil || len(output.Name) == 0 {
+		return nil
+	}
+	if err := imagereference.Validate(output.Name); err != nil {
+		return fmt.Errorf("invalid output image reference: %v", err)
+	}
+	if normalized := imagereference.Normalize(output.Name); normalized != output.Name {
+		glog.V(2).Infof("Normalized output image reference %q to %q", output.Name, normalized)
+		output.Name = normalized
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateOutputImageReference(t *testing.T) {
+	buildWithOutput := func(name string) *buildapiv1.Build {
+		return &buildapiv1.Build{
+			Spec: buildapiv1.BuildSpec{
+				CommonSpec: buildapiv1.CommonSpec{
+					Output: buildapiv1.BuildOutput{
+						To: &corev1.ObjectReference{Kind: "DockerImage", Name: name},
+					},
+				},
+			},
+		}
+	}
+
+	// No output means nothing to validate.
+	if err := ValidateOutputImageReference(&buildapiv1.Build{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	build := buildWithOutput("docker.io/library/centos:7")
+	if err := ValidateOutputImageReference(build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if build.Spec.Output.To.Name != "docker.io/centos:7" {
+		t.Errorf("unexpected normalized reference %q", build.Spec.Output.To.Name)
+	}
+
+	build = buildWithOutput("registry.example.com/ns/app@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	if err := ValidateOutputImageReference(build); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, invalid := range []string{"registry.example.com/ns/app:bad tag", "registry.example.com/ns/app@sha256:short"} {
+		if err := ValidateOutputImageReference(buildWithOutput(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
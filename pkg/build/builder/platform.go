@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containers/image/types"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// BuildPlatformEnvVar selects which platform to resolve manifest lists (OCI
+// image indexes) to, as "os/arch" or "os/arch/variant". By default image
+// references resolve to the host platform; with emulation available on the
+// node, overriding the platform allows e.g. cross-building ARM images on an
+// x86 cluster. It is read from the build strategy environment first, then
+// from the builder's own environment.
+const BuildPlatformEnvVar = "BUILD_PLATFORM"
+
+// ApplyPlatformChoice applies a configured platform override to the system
+// context used for image resolution.
+func ApplyPlatformChoice(sc *types.SystemContext, build *buildapiv1.Build) error {
+	spec := os.Getenv(BuildPlatformEnvVar)
+	if build != nil {
+		var strategyEnv []corev1.EnvVar
+		if build.Spec.Strategy.DockerStrategy != nil {
+			strategyEnv = build.Spec.Strategy.DockerStrategy.Env
+		} else if build.Spec.Strategy.SourceStrategy != nil {
+			strategyEnv = build.Spec.Strategy.SourceStrategy.Env
+		}
+		for _, env := range strategyEnv {
+			if env.Name == BuildPlatformEnvVar {
+				spec = env.Value
+			}
+		}
+	}
+	if len(spec) == 0 {
+		return nil
+	}
+
+	parts := strings.Split(spec, "/")
+	if len(parts) < 2 || len(parts) > 3 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return fmt.Errorf("invalid %s value %q, expected os/arch or os/arch/variant", BuildPlatformEnvVar, spec)
+	}
+	if len(parts) == 3 {
+		// The vendored containers/image has no variant choice; os/arch
+		// selection alone is usually unambiguous.
+		glog.V(0).Infof("warning: Ignoring platform variant %q of %s, selecting by os/arch only", parts[2], spec)
+	}
+	glog.V(0).Infof("Resolving manifest lists for platform %s/%s", parts[0], parts[1])
+	sc.OSChoice = parts[0]
+	sc.ArchitectureChoice = parts[1]
+	return nil
+}
@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"os"
+	"testing"
+
+	"github.com/containers/image/types"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApplyPlatformChoice(t *testing.T) {
+	defer os.Unsetenv(BuildPlatformEnvVar)
+
+	os.Unsetenv(BuildPlatformEnvVar)
+	sc := types.SystemContext{}
+	if err := ApplyPlatformChoice(&sc, &buildapiv1.Build{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.OSChoice != "" || sc.ArchitectureChoice != "" {
+		t.Errorf("expected no platform choice, got %s/%s", sc.OSChoice, sc.ArchitectureChoice)
+	}
+
+	os.Setenv(BuildPlatformEnvVar, "linux/arm64")
+	sc = types.SystemContext{}
+	if err := ApplyPlatformChoice(&sc, &buildapiv1.Build{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.OSChoice != "linux" || sc.ArchitectureChoice != "arm64" {
+		t.Errorf("expected linux/arm64, got %s/%s", sc.OSChoice, sc.ArchitectureChoice)
+	}
+
+	// The strategy environment wins over the process environment, and a
+	// variant is tolerated.
+	build := &buildapiv1.Build{}
+	build.Spec.Strategy.DockerStrategy = &buildapiv1.DockerBuildStrategy{
+		Env: []corev1.EnvVar{{Name: BuildPlatformEnvVar, Value: "linux/arm/v7"}},
+	}
+	sc = types.SystemContext{}
+	if err := ApplyPlatformChoice(&sc, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.OSChoice != "linux" || sc.ArchitectureChoice != "arm" {
+		t.Errorf("expected linux/arm, got %s/%s", sc.OSChoice, sc.ArchitectureChoice)
+	}
+
+	for _, invalid := range []string{"linux", "/amd64", "linux/", "a/b/c/d"} {
+		os.Setenv(BuildPlatformEnvVar, invalid)
+		sc = types.SystemContext{}
+		if err := ApplyPlatformChoice(&sc, &buildapiv1.Build{}); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
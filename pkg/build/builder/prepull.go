@@ -0,0 +1,112 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
+	"github.com/openshift/builder/pkg/build/builder/timing"
+)
+
+// PrePullEnvVar enables fetching every image a build references (FROM lines,
+// the builder image, image sources) in parallel before the build starts,
+// instead of pulling them lazily and serially mid-build.
+const PrePullEnvVar = "BUILD_PRE_PULL"
+
+// PrePullConcurrencyEnvVar limits how many images the pre-pull phase fetches
+// at the same time.
+const PrePullConcurrencyEnvVar = "BUILD_PRE_PULL_CONCURRENCY"
+
+const defaultPrePullConcurrency = 4
+
+func prePullEnabled() bool {
+	return strings.EqualFold(os.Getenv(PrePullEnvVar), "true")
+}
+
+func prePullConcurrencyFromEnvironment() (int, error) {
+	value := os.Getenv(PrePullConcurrencyEnvVar)
+	if len(value) == 0 {
+		return defaultPrePullConcurrency, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", PrePullConcurrencyEnvVar, value)
+	}
+	return concurrency, nil
+}
+
+// prePullImages fetches the given images in parallel, bounded by the
+// configured concurrency, and records a pull timing step per image. Images
+// already present are skipped unless forcePull is set. The first pull error
+// is returned after all pulls finish.
+func prePullImages(ctx context.Context, dockerClient DockerClient, images []string, forcePull bool, pull func(name string, authConfig docker.AuthConfiguration) error) error {
+	concurrency, err := prePullConcurrencyFromEnvironment()
+	if err != nil {
+		return err
+	}
+
+	var toPull []string
+	seen := map[string]struct{}{}
+	for _, imageName := range images {
+		if imageName == "scratch" {
+			continue
+		}
+		if _, duplicate := seen[imageName]; duplicate {
+			continue
+		}
+		seen[imageName] = struct{}{}
+		if !forcePull {
+			if _, err := dockerClient.InspectImage(imageName); err == nil {
+				glog.V(4).Infof("Image %s is already present, not pre-pulling", imageName)
+				continue
+			}
+		}
+		toPull = append(toPull, imageName)
+	}
+	if len(toPull) == 0 {
+		return nil
+	}
+
+	glog.V(0).Infof("Pre-pulling %d image(s), %d at a time ...", len(toPull), concurrency)
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	// Guards firstErr and the timing records in ctx.
+	var mutex sync.Mutex
+	var firstErr error
+	for _, imageName := range toPull {
+		wg.Add(1)
+		go func(imageName string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			authConfig, _ := dockercfg.NewHelper().GetDockerAuth(imageName, dockercfg.PullAuthType)
+			glog.V(0).Infof("Pre-pulling image %s ...", imageName)
+			startTime := metav1.Now()
+			err := pull(imageName, authConfig)
+			endTime := metav1.Now()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			timing.RecordNewStep(ctx, buildapiv1.StagePullImages, buildapiv1.StepPullBaseImage, startTime, endTime)
+			if err != nil {
+				glog.V(0).Infof("warning: Pre-pull of image %s failed: %v", imageName, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to pull image %s: %v", imageName, err)
+				}
+				return
+			}
+			glog.V(0).Infof("Pre-pulled image %s in %s", imageName, endTime.Sub(startTime.Time))
+		}(imageName)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	"github.com/openshift/builder/pkg/build/builder/timing"
+)
+
+func TestPrePullImages(t *testing.T) {
+	fake := &FakeDocker{
+		inspectImageFunc: func(name string) (*docker.Image, error) {
+			if name == "present" {
+				return &docker.Image{}, nil
+			}
+			return nil, docker.ErrNoSuchImage
+		},
+	}
+	var mutex sync.Mutex
+	pulled := map[string]int{}
+	pull := func(name string, authConfig docker.AuthConfiguration) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pulled[name]++
+		if name == "broken" {
+			return fmt.Errorf("no route to host")
+		}
+		return nil
+	}
+
+	ctx := timing.NewContext(context.Background())
+	images := []string{"scratch", "present", "missing", "missing", "other"}
+	if err := prePullImages(ctx, fake, images, false, pull); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pulled) != 2 || pulled["missing"] != 1 || pulled["other"] != 1 {
+		t.Errorf("unexpected pulls: %v", pulled)
+	}
+	if stages := timing.GetStages(ctx); len(stages) != 1 || len(stages[0].Steps) != 2 {
+		t.Errorf("expected one pull stage with two steps, got %v", stages)
+	}
+
+	// forcePull fetches images that are already present.
+	pulled = map[string]int{}
+	if err := prePullImages(ctx, fake, []string{"present"}, true, pull); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pulled["present"] != 1 {
+		t.Errorf("expected present to be pulled with forcePull, got %v", pulled)
+	}
+
+	// A failed pull surfaces as an error naming the image.
+	if err := prePullImages(ctx, fake, []string{"broken"}, false, pull); err == nil {
+		t.Errorf("expected an error for a failed pull")
+	}
+}
+
+func TestPrePullConcurrencyFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    int
+		expectError bool
+	}{
+		{value: "", expected: defaultPrePullConcurrency},
+		{value: "2", expected: 2},
+		{value: "0", expectError: true},
+		{value: "many", expectError: true},
+	}
+	defer os.Unsetenv(PrePullConcurrencyEnvVar)
+	for _, tc := range testCases {
+		os.Setenv(PrePullConcurrencyEnvVar, tc.value)
+		concurrency, err := prePullConcurrencyFromEnvironment()
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("%q: expected error, got %d", tc.value, concurrency)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if concurrency != tc.expected {
+			t.Errorf("%q: expected %d, got %d", tc.value, tc.expected, concurrency)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// Build annotations carrying promotion hints for downstream controllers. The
+// values may use Go template syntax over build and source metadata (see
+// buildTemplateData), e.g. "images/{{.BuildNamespace}}/{{.Commit}}".
+const (
+	// PromotionEnvironmentsAnnotation is a comma-separated list of
+	// environments the image is intended to be promoted to.
+	PromotionEnvironmentsAnnotation = "promotion.build.openshift.io/environments"
+	// PromotionTestRequiredAnnotation marks the image as requiring tests to
+	// pass before promotion.
+	PromotionTestRequiredAnnotation = "promotion.build.openshift.io/test-required"
+	// PromotionExpiryAnnotation carries an expiry for the image, after which
+	// it should not be promoted.
+	PromotionExpiryAnnotation = "promotion.build.openshift.io/expiry"
+)
+
+// promotionHintsFile is the name of the result JSON written next to
+// sourceinfo.json, so promotion controllers can read the hints without
+// re-querying the BuildConfig.
+const promotionHintsFile = "promotionhints.json"
+
+// PromotionHints is the structured form of the promotion annotations, written
+// both to image labels and to the result JSON.
+type PromotionHints struct {
+	Environments []string `json:"environments,omitempty"`
+	TestRequired bool     `json:"testRequired,omitempty"`
+	Expiry       string   `json:"expiry,omitempty"`
+}
+
+// buildTemplateData is the data available to templates in promotion
+// annotation and user label values.
+type buildTemplateData struct {
+	BuildName      string
+	BuildNamespace string
+	Commit         string
+	Ref            string
+	SourceURI      string
+	Timestamp      string
+}
+
+// buildTemplateDataFor collects the template data for a build and its
+// checked-out source.
+func buildTemplateDataFor(build *buildapiv1.Build, sourceInfo *git.SourceInfo) buildTemplateData {
+	data := buildTemplateData{
+		BuildName:      build.Name,
+		BuildNamespace: build.Namespace,
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if sourceInfo != nil {
+		data.Commit = sourceInfo.CommitID
+		data.Ref = sourceInfo.Ref
+	}
+	if build.Spec.Source.Git != nil {
+		data.SourceURI = build.Spec.Source.Git.URI
+		if len(build.Spec.Source.Git.Ref) > 0 {
+			data.Ref = build.Spec.Source.Git.Ref
+		}
+	}
+	return data
+}
+
+// promotionHintsFromBuild derives promotion hints from the build's
+// annotations, expanding templates over build and source metadata. It returns
+// nil when the build carries no promotion annotations.
+func promotionHintsFromBuild(build *buildapiv1.Build, sourceInfo *git.SourceInfo) (*PromotionHints, error) {
+	environments, hasEnvironments := build.Annotations[PromotionEnvironmentsAnnotation]
+	testRequired, hasTestRequired := build.Annotations[PromotionTestRequiredAnnotation]
+	expiry, hasExpiry := build.Annotations[PromotionExpiryAnnotation]
+	if !hasEnvironments && !hasTestRequired && !hasExpiry {
+		return nil, nil
+	}
+
+	data := buildTemplateDataFor(build, sourceInfo)
+
+	hints := &PromotionHints{}
+	if hasEnvironments {
+		expanded, err := expandBuildTemplate("annotation "+PromotionEnvironmentsAnnotation, environments, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, environment := range strings.Split(expanded, ",") {
+			if environment = strings.TrimSpace(environment); len(environment) > 0 {
+				hints.Environments = append(hints.Environments, environment)
+			}
+		}
+	}
+	if hasTestRequired {
+		expanded, err := expandBuildTemplate("annotation "+PromotionTestRequiredAnnotation, testRequired, data)
+		if err != nil {
+			return nil, err
+		}
+		hints.TestRequired, err = strconv.ParseBool(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s: %v", PromotionTestRequiredAnnotation, err)
+		}
+	}
+	if hasExpiry {
+		expanded, err := expandBuildTemplate("annotation "+PromotionExpiryAnnotation, expiry, data)
+		if err != nil {
+			return nil, err
+		}
+		hints.Expiry = expanded
+	}
+	return hints, nil
+}
+
+// expandBuildTemplate expands one value as a Go template over the build and
+// source metadata; name identifies the value in error messages.
+func expandBuildTemplate(name, value string, data buildTemplateData) (string, error) {
+	tmpl, err := template.New(name).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	buffer := &bytes.Buffer{}
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return buffer.String(), nil
+}
+
+// addPromotionHintLabels adds the promotion hints to the image labels under
+// well-known names. Bad annotation values only lose the hints, not the build.
+func addPromotionHintLabels(labels map[string]string, build *buildapiv1.Build, sourceInfo *git.SourceInfo) {
+	hints, err := promotionHintsFromBuild(build, sourceInfo)
+	if err != nil {
+		glog.V(0).Infof("warning: Ignoring promotion hints: %v", err)
+		return
+	}
+	if hints == nil {
+		return
+	}
+	if len(hints.Environments) > 0 {
+		labels[builderutil.DefaultDockerLabelNamespace+"build.promotion.environments"] = strings.Join(hints.Environments, ",")
+	}
+	labels[builderutil.DefaultDockerLabelNamespace+"build.promotion.test-required"] = strconv.FormatBool(hints.TestRequired)
+	if len(hints.Expiry) > 0 {
+		labels[builderutil.DefaultDockerLabelNamespace+"build.promotion.expiry"] = hints.Expiry
+	}
+}
+
+// WritePromotionHints persists the promotion hints as result JSON alongside
+// sourceinfo.json, for controllers that prefer a file over image labels.
+func WritePromotionHints(build *buildapiv1.Build, sourceInfo *git.SourceInfo) error {
+	hints, err := promotionHintsFromBuild(build, sourceInfo)
+	if err != nil {
+		return err
+	}
+	if hints == nil {
+		return nil
+	}
+	hintsJSON, err := json.Marshal(hints)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(buildWorkDirMount, promotionHintsFile), hintsJSON, 0644)
+}
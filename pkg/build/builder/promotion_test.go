@@ -0,0 +1,86 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPromotionHintsFromBuild(t *testing.T) {
+	sourceInfo := &git.SourceInfo{CommitID: "0123456789abcdef"}
+	sourceInfo.Ref = "main"
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    *PromotionHints
+		expectErr   bool
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name: "full hints",
+			annotations: map[string]string{
+				PromotionEnvironmentsAnnotation: "staging, production",
+				PromotionTestRequiredAnnotation: "true",
+				PromotionExpiryAnnotation:       "2026-12-31",
+			},
+			expected: &PromotionHints{
+				Environments: []string{"staging", "production"},
+				TestRequired: true,
+				Expiry:       "2026-12-31",
+			},
+		},
+		{
+			name: "templated environments",
+			annotations: map[string]string{
+				PromotionEnvironmentsAnnotation: "{{.BuildNamespace}}-{{.Ref}}",
+			},
+			expected: &PromotionHints{
+				Environments: []string{"testns-main"},
+			},
+		},
+		{
+			name: "bad template",
+			annotations: map[string]string{
+				PromotionExpiryAnnotation: "{{.Missing}",
+			},
+			expectErr: true,
+		},
+		{
+			name: "bad test-required value",
+			annotations: map[string]string{
+				PromotionTestRequiredAnnotation: "maybe",
+			},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			build := &buildapiv1.Build{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-build",
+					Namespace:   "testns",
+					Annotations: tc.annotations,
+				},
+			}
+			hints, err := promotionHintsFromBuild(build, sourceInfo)
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(hints, tc.expected) {
+				t.Errorf("got %#v; want %#v", hints, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"fmt"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// Standard OCI image annotations describing where the image was built from,
+// per the opencontainers image-spec annotation conventions.
+const (
+	ociCreatedAnnotation  = "org.opencontainers.image.created"
+	ociSourceAnnotation   = "org.opencontainers.image.source"
+	ociRevisionAnnotation = "org.opencontainers.image.revision"
+	ociAuthorsAnnotation  = "org.opencontainers.image.authors"
+)
+
+// sourceProvenanceAnnotations returns "name=value" manifest annotations
+// recording the source provenance of the build, using the standard
+// org.opencontainers.image names where they exist. The commit message has no
+// standard annotation, so it reuses the name of the matching image label.
+// Annotations are only carried by OCI image manifests; for other manifest
+// formats they are silently dropped.
+func sourceProvenanceAnnotations(build *buildapiv1.Build) []string {
+	created := time.Now().UTC()
+	if reproducibleBuild() {
+		created = reproducibleBuildTimestamp(build)
+	}
+	annotations := []string{
+		fmt.Sprintf("%s=%s", ociCreatedAnnotation, created.Format(time.RFC3339)),
+	}
+	if build == nil {
+		return annotations
+	}
+	if build.Spec.Source.Git != nil && len(build.Spec.Source.Git.URI) > 0 {
+		annotations = append(annotations, fmt.Sprintf("%s=%s", ociSourceAnnotation, build.Spec.Source.Git.URI))
+	}
+	if revision := build.Spec.Revision; revision != nil && revision.Git != nil {
+		if len(revision.Git.Commit) > 0 {
+			annotations = append(annotations, fmt.Sprintf("%s=%s", ociRevisionAnnotation, revision.Git.Commit))
+		}
+		if author := revision.Git.Author; len(author.Name) > 0 {
+			value := author.Name
+			if len(author.Email) > 0 {
+				value = fmt.Sprintf("%s <%s>", author.Name, author.Email)
+			}
+			annotations = append(annotations, fmt.Sprintf("%s=%s", ociAuthorsAnnotation, value))
+		}
+		if len(revision.Git.Message) > 0 {
+			annotations = append(annotations, fmt.Sprintf("%sbuild.commit.message=%s", builderutil.DefaultDockerLabelNamespace, revision.Git.Message))
+		}
+	}
+	return annotations
+}
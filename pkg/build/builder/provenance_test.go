@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestSourceProvenanceAnnotations(t *testing.T) {
+	build := &buildapiv1.Build{
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Source: buildapiv1.BuildSource{
+					Git: &buildapiv1.GitBuildSource{URI: "https://example.com/repo.git"},
+				},
+				Revision: &buildapiv1.SourceRevision{
+					Git: &buildapiv1.GitSourceRevision{
+						Commit:  "0123abcd",
+						Author:  buildapiv1.SourceControlUser{Name: "A U Thor", Email: "author@example.com"},
+						Message: "initial commit",
+					},
+				},
+			},
+		},
+	}
+
+	annotations := sourceProvenanceAnnotations(build)
+	expected := map[string]string{
+		ociSourceAnnotation:   "https://example.com/repo.git",
+		ociRevisionAnnotation: "0123abcd",
+		ociAuthorsAnnotation:  "A U Thor <author@example.com>",
+	}
+	seen := map[string]string{}
+	for _, annotation := range annotations {
+		parts := strings.SplitN(annotation, "=", 2)
+		if len(parts) != 2 {
+			t.Fatalf("annotation %q is not in name=value form", annotation)
+		}
+		seen[parts[0]] = parts[1]
+	}
+	for name, value := range expected {
+		if seen[name] != value {
+			t.Errorf("annotation %s: got %q; want %q", name, seen[name], value)
+		}
+	}
+	if len(seen[ociCreatedAnnotation]) == 0 {
+		t.Errorf("annotation %s is missing", ociCreatedAnnotation)
+	}
+}
@@ -0,0 +1,36 @@
+package builder
+
+import (
+	"os"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// SetupRegistryProxy exports the build-level proxy configuration into the
+// process environment. The containers/image docker transport builds its HTTP
+// client with http.ProxyFromEnvironment, so this makes registry pulls and
+// pushes honor the same proxy configuration that is already passed into the
+// git environment, without requiring node-level proxy settings.
+func SetupRegistryProxy(build *buildapiv1.Build) {
+	gitSource := build.Spec.Source.Git
+	if gitSource == nil {
+		return
+	}
+	if gitSource.HTTPProxy != nil && len(*gitSource.HTTPProxy) > 0 {
+		setProxyEnv("HTTP_PROXY", *gitSource.HTTPProxy)
+	}
+	if gitSource.HTTPSProxy != nil && len(*gitSource.HTTPSProxy) > 0 {
+		setProxyEnv("HTTPS_PROXY", *gitSource.HTTPSProxy)
+	}
+	if gitSource.NoProxy != nil && len(*gitSource.NoProxy) > 0 {
+		setProxyEnv("NO_PROXY", *gitSource.NoProxy)
+	}
+}
+
+// setProxyEnv sets both the upper- and lowercase form of a proxy variable,
+// since different HTTP client implementations check different spellings.
+func setProxyEnv(name, value string) {
+	os.Setenv(name, value)
+	os.Setenv(strings.ToLower(name), value)
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"os"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestSetupRegistryProxy(t *testing.T) {
+	proxyVars := []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"}
+	saved := map[string]string{}
+	for _, name := range proxyVars {
+		saved[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+	defer func() {
+		for _, name := range proxyVars {
+			if len(saved[name]) > 0 {
+				os.Setenv(name, saved[name])
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}()
+
+	httpProxy := "http://proxy.example.com:8080"
+	httpsProxy := "https://proxy.example.com:8443"
+	noProxy := ".cluster.local"
+	build := &buildapiv1.Build{
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Source: buildapiv1.BuildSource{
+					Git: &buildapiv1.GitBuildSource{
+						URI: "https://example.com/repo.git",
+						ProxyConfig: buildapiv1.ProxyConfig{
+							HTTPProxy:  &httpProxy,
+							HTTPSProxy: &httpsProxy,
+							NoProxy:    &noProxy,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	SetupRegistryProxy(build)
+
+	expected := map[string]string{
+		"HTTP_PROXY":  httpProxy,
+		"http_proxy":  httpProxy,
+		"HTTPS_PROXY": httpsProxy,
+		"https_proxy": httpsProxy,
+		"NO_PROXY":    noProxy,
+		"no_proxy":    noProxy,
+	}
+	for name, value := range expected {
+		if os.Getenv(name) != value {
+			t.Errorf("expected %s=%q, got %q", name, value, os.Getenv(name))
+		}
+	}
+}
+
+func TestSetupRegistryProxyNoGitSource(t *testing.T) {
+	// Must not panic or set anything when no git source is present.
+	SetupRegistryProxy(&buildapiv1.Build{})
+}
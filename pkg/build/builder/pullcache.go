@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/image/reference"
+)
+
+// PullThroughCacheEnvVar names a pull-through cache registry, a host with an
+// optional port. When set, image pulls are first attempted through the cache
+// by rewriting the image reference to point at it; when the cache cannot
+// serve the image, the pull falls back to the origin registry.
+const PullThroughCacheEnvVar = "BUILD_PULL_THROUGH_CACHE"
+
+// PullThroughCacheAnnotation records how the pull-through cache performed
+// during the build, as a JSON object with the cache registry and hit and
+// miss counts.
+const PullThroughCacheAnnotation = "build.openshift.io/pull-through-cache"
+
+// pullThroughCacheStats counts cache hits and misses for the build. Pulls
+// funnel through free functions, so the counters are package state, like the
+// event recorder.
+var pullThroughCacheStats = struct {
+	sync.Mutex
+	hits   int
+	misses int
+}{}
+
+// pullThroughCacheRegistry returns the configured cache registry, or an
+// empty string when none is configured or the value is not usable as a
+// registry host.
+func pullThroughCacheRegistry() string {
+	value := strings.TrimSpace(os.Getenv(PullThroughCacheEnvVar))
+	if len(value) == 0 {
+		return ""
+	}
+	if strings.Contains(value, "://") || strings.Contains(value, "/") {
+		glog.V(0).Infof("warning: ignoring %s=%q: expected a registry host with an optional port", PullThroughCacheEnvVar, value)
+		return ""
+	}
+	return value
+}
+
+// rewriteForPullThroughCache returns imageName with its registry replaced by
+// the cache registry, or an empty string when the reference cannot or should
+// not be rewritten (unparseable, or already pointing at the cache).
+func rewriteForPullThroughCache(imageName, cacheRegistry string) string {
+	ref, err := reference.Parse(imageName)
+	if err != nil {
+		glog.V(0).Infof("warning: not routing image %s through the pull-through cache: %v", imageName, err)
+		return ""
+	}
+	if len(ref.Registry) == 0 {
+		ref.Registry = reference.DockerDefaultRegistry
+	}
+	if len(ref.Namespace) == 0 && reference.IsRegistryDockerHub(ref.Registry) {
+		ref.Namespace = "library"
+	}
+	if ref.Registry == cacheRegistry {
+		return ""
+	}
+	ref.Registry = cacheRegistry
+	return ref.Exact()
+}
+
+// pullThroughCache attempts the pull through the configured cache registry,
+// tagging a cached image back to its original name, and falls back to the
+// origin registry when the cache cannot serve the image. Without a
+// configured cache it is a plain pull.
+func pullThroughCache(dockerClient DockerClient, name string, authConfig docker.AuthConfiguration) error {
+	cacheRegistry := pullThroughCacheRegistry()
+	if len(cacheRegistry) == 0 {
+		return PullImage(dockerClient, name, authConfig)
+	}
+	cachedName := rewriteForPullThroughCache(name, cacheRegistry)
+	if len(cachedName) == 0 {
+		return PullImage(dockerClient, name, authConfig)
+	}
+	glog.V(2).Infof("Pulling image %s through cache registry %s as %s", name, cacheRegistry, cachedName)
+	if err := PullImage(dockerClient, cachedName, authConfig); err != nil {
+		pullThroughCacheStats.Lock()
+		pullThroughCacheStats.misses++
+		pullThroughCacheStats.Unlock()
+		glog.V(0).Infof("warning: pull-through cache %s could not serve image %s, falling back to the origin registry: %v", cacheRegistry, name, err)
+		return PullImage(dockerClient, name, authConfig)
+	}
+	pullThroughCacheStats.Lock()
+	pullThroughCacheStats.hits++
+	pullThroughCacheStats.Unlock()
+	// Tag the cached image back to the original name so references in the
+	// Dockerfile and the rest of the build resolve to it.
+	if err := tagImage(dockerClient, cachedName, name); err != nil {
+		glog.V(0).Infof("warning: unable to tag cached image %s as %s, falling back to the origin registry: %v", cachedName, name, err)
+		return PullImage(dockerClient, name, authConfig)
+	}
+	return nil
+}
+
+// recordPullThroughCacheMetrics annotates the build with the cache hit and
+// miss counts, when a cache is configured and was used.
+func recordPullThroughCacheMetrics(build *buildapiv1.Build) {
+	cacheRegistry := pullThroughCacheRegistry()
+	if len(cacheRegistry) == 0 {
+		return
+	}
+	pullThroughCacheStats.Lock()
+	hits, misses := pullThroughCacheStats.hits, pullThroughCacheStats.misses
+	pullThroughCacheStats.Unlock()
+	if hits == 0 && misses == 0 {
+		return
+	}
+	metrics, err := json.Marshal(map[string]interface{}{
+		"registry": cacheRegistry,
+		"hits":     hits,
+		"misses":   misses,
+	})
+	if err != nil {
+		glog.V(0).Infof("warning: unable to serialize pull-through cache metrics: %v", err)
+		return
+	}
+	glog.V(0).Infof("Pull-through cache %s served %d of %d image pulls", cacheRegistry, hits, hits+misses)
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[PullThroughCacheAnnotation] = string(metrics)
+}
@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPullThroughCacheRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "unset",
+			value:    "",
+			expected: "",
+		},
+		{
+			name:     "host",
+			value:    "mirror.example.com",
+			expected: "mirror.example.com",
+		},
+		{
+			name:     "host with port",
+			value:    "mirror.example.com:5000",
+			expected: "mirror.example.com:5000",
+		},
+		{
+			name:     "scheme rejected",
+			value:    "https://mirror.example.com",
+			expected: "",
+		},
+		{
+			name:     "path rejected",
+			value:    "mirror.example.com/cache",
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if len(test.value) > 0 {
+				os.Setenv(PullThroughCacheEnvVar, test.value)
+				defer os.Unsetenv(PullThroughCacheEnvVar)
+			} else {
+				os.Unsetenv(PullThroughCacheEnvVar)
+			}
+			if result := pullThroughCacheRegistry(); result != test.expected {
+				t.Errorf("pullThroughCacheRegistry() = %q; expected %q", result, test.expected)
+			}
+		})
+	}
+}
+
+func TestRewriteForPullThroughCache(t *testing.T) {
+	cacheRegistry := "mirror.example.com:5000"
+	tests := []struct {
+		name      string
+		imageName string
+		expected  string
+	}{
+		{
+			name:      "fully qualified with tag",
+			imageName: "registry.example.com/group/app:v1",
+			expected:  "mirror.example.com:5000/group/app:v1",
+		},
+		{
+			name:      "docker hub shorthand",
+			imageName: "busybox",
+			expected:  "mirror.example.com:5000/library/busybox",
+		},
+		{
+			name:      "digest reference",
+			imageName: "registry.example.com/group/app@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+			expected:  "mirror.example.com:5000/group/app@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name:      "already pointing at the cache",
+			imageName: "mirror.example.com:5000/group/app:v1",
+			expected:  "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := rewriteForPullThroughCache(test.imageName, cacheRegistry); result != test.expected {
+				t.Errorf("rewriteForPullThroughCache(%q) = %q; expected %q", test.imageName, result, test.expected)
+			}
+		})
+	}
+}
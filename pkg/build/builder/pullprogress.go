@@ -0,0 +1,126 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containers/image/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// PullProgressIntervalEnvVar adjusts how often a pull reports its progress
+// to the build log, as a Go duration. Long pulls otherwise stay silent
+// between the start and end messages, which reads like a hung build to
+// someone following the log. A non-positive duration disables the reports.
+const PullProgressIntervalEnvVar = "BUILD_PULL_PROGRESS_INTERVAL"
+
+// defaultPullProgressInterval spaces the progress lines far enough apart to
+// keep the build log readable.
+const defaultPullProgressInterval = 10 * time.Second
+
+// copyProgressInterval is how often the image copy reports per-layer offsets
+// to the monitor; the monitor then rate-limits what reaches the log.
+const copyProgressInterval = time.Second
+
+// pullProgressIntervalFromEnvironment reads the report interval from the
+// environment, defaulting when unset.
+func pullProgressIntervalFromEnvironment() (time.Duration, error) {
+	value := os.Getenv(PullProgressIntervalEnvVar)
+	if len(value) == 0 {
+		return defaultPullProgressInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse %s: %v", PullProgressIntervalEnvVar, err)
+	}
+	return interval, nil
+}
+
+// pullProgressMonitor aggregates the per-layer reports from the image copy's
+// progress channel into periodic summary lines with an overall percentage.
+type pullProgressMonitor struct {
+	name     string
+	out      io.Writer
+	interval time.Duration
+	channel  chan types.ProgressProperties
+	wg       sync.WaitGroup
+}
+
+// newPullProgressMonitor starts a monitor for one pull. The caller must
+// Stop it after the copy finishes.
+func newPullProgressMonitor(name string, out io.Writer, interval time.Duration) *pullProgressMonitor {
+	m := &pullProgressMonitor{
+		name:     name,
+		out:      out,
+		interval: interval,
+		channel:  make(chan types.ProgressProperties, 16),
+	}
+	m.wg.Add(1)
+	go m.run()
+	return m
+}
+
+// Channel is the progress channel to hand to the image copy.
+func (m *pullProgressMonitor) Channel() chan types.ProgressProperties {
+	return m.channel
+}
+
+// Stop waits for the monitor to drain its channel. No reports may be sent
+// after calling it.
+func (m *pullProgressMonitor) Stop() {
+	close(m.channel)
+	m.wg.Wait()
+}
+
+func (m *pullProgressMonitor) run() {
+	defer m.wg.Done()
+	offsets := make(map[digest.Digest]uint64)
+	totals := make(map[digest.Digest]int64)
+	lastReport := time.Now()
+	for report := range m.channel {
+		offsets[report.Artifact.Digest] = report.Offset
+		totals[report.Artifact.Digest] = report.Artifact.Size
+		if time.Since(lastReport) < m.interval {
+			continue
+		}
+		lastReport = time.Now()
+		m.report(offsets, totals)
+	}
+}
+
+// report writes one summary line. Layers with an unknown size keep the
+// overall percentage honest by dropping it and reporting plain byte counts.
+func (m *pullProgressMonitor) report(offsets map[digest.Digest]uint64, totals map[digest.Digest]int64) {
+	var downloaded, total uint64
+	sizesKnown := true
+	for layer, offset := range offsets {
+		downloaded += offset
+		if size := totals[layer]; size < 0 {
+			sizesKnown = false
+		} else {
+			total += uint64(size)
+		}
+	}
+	if sizesKnown && total > 0 {
+		fmt.Fprintf(m.out, "Pulling %s: %d%% (%s / %s)\n", m.name, downloaded*100/total, formatBytes(downloaded), formatBytes(total))
+		return
+	}
+	fmt.Fprintf(m.out, "Pulling %s: %s downloaded\n", m.name, formatBytes(downloaded))
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(count uint64) string {
+	const unit = 1024
+	if count < unit {
+		return fmt.Sprintf("%d B", count)
+	}
+	div, exp := uint64(unit), 0
+	for n := count / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(count)/float64(div), "KMGTPE"[exp])
+}
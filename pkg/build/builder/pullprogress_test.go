@@ -0,0 +1,75 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+func TestPullProgressIntervalFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{value: "", expected: defaultPullProgressInterval},
+		{value: "30s", expected: 30 * time.Second},
+		{value: "0s", expected: 0},
+		{value: "often", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(PullProgressIntervalEnvVar, tc.value)
+		interval, err := pullProgressIntervalFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && interval != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, interval)
+		}
+	}
+	os.Unsetenv(PullProgressIntervalEnvVar)
+}
+
+func TestPullProgressMonitor(t *testing.T) {
+	out := &bytes.Buffer{}
+	// A nanosecond interval lets every report through, so the test does not
+	// depend on timing.
+	monitor := newPullProgressMonitor("registry.example.com/app:latest", out, time.Nanosecond)
+	layer1 := digest.FromString("layer1")
+	layer2 := digest.FromString("layer2")
+	monitor.Channel() <- types.ProgressProperties{Artifact: types.BlobInfo{Digest: layer1, Size: 1024}, Offset: 512}
+	monitor.Channel() <- types.ProgressProperties{Artifact: types.BlobInfo{Digest: layer1, Size: 1024}, Offset: 1024}
+	monitor.Channel() <- types.ProgressProperties{Artifact: types.BlobInfo{Digest: layer2, Size: 1024}, Offset: 512}
+	monitor.Stop()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 progress lines, got %d: %q", len(lines), out.String())
+	}
+	if expected := "Pulling registry.example.com/app:latest: 75% (1.5 KiB / 2.0 KiB)"; lines[2] != expected {
+		t.Errorf("expected %q, got %q", expected, lines[2])
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		count    uint64
+		expected string
+	}{
+		{count: 512, expected: "512 B"},
+		{count: 1536, expected: "1.5 KiB"},
+		{count: 5 * 1024 * 1024, expected: "5.0 MiB"},
+		{count: 3 * 1024 * 1024 * 1024, expected: "3.0 GiB"},
+	}
+	for _, tc := range testCases {
+		if formatted := formatBytes(tc.count); formatted != tc.expected {
+			t.Errorf("%d: expected %q, got %q", tc.count, tc.expected, formatted)
+		}
+	}
+}
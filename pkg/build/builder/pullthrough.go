@@ -0,0 +1,40 @@
+package builder
+
+import (
+	"os"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
+)
+
+// PullPreviousImageEnvVar enables fetching the image from the previous build
+// of the same output repository before building. With the previous layers in
+// local storage, unchanged instructions can reuse them, so they keep their
+// digests and the registry's blob-exists checks let the push skip uploading
+// them. Incremental rebuilds then upload only the layers that actually
+// changed.
+const PullPreviousImageEnvVar = "BUILD_PULL_PREVIOUS_IMAGE"
+
+func pullPreviousImageEnabled() bool {
+	return strings.EqualFold(os.Getenv(PullPreviousImageEnvVar), "true")
+}
+
+// pullPreviousImage best-effort fetches the previously pushed image of this
+// build's output reference. The previous image may be gone or this may be
+// the first build, so failures only cost the layer reuse and are logged
+// rather than failing the build.
+func pullPreviousImage(build *buildapiv1.Build, pushTag string, pull func(name string, authConfig docker.AuthConfiguration) error) {
+	if !pullPreviousImageEnabled() || len(pushTag) == 0 {
+		return
+	}
+	authConfig, _ := dockercfg.NewHelper().GetDockerAuth(pushTag, dockercfg.PullAuthType)
+	glog.V(0).Infof("Pulling previous image %s for layer reuse ...", pushTag)
+	if err := pull(pushTag, authConfig); err != nil {
+		glog.V(0).Infof("warning: Could not pull the previous image %s, all layers will be uploaded on push: %v", pushTag, err)
+		return
+	}
+	glog.V(2).Infof("Previous image %s is available for layer reuse", pushTag)
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestPullPreviousImage(t *testing.T) {
+	testCases := []struct {
+		name       string
+		envValue   string
+		pushTag    string
+		pullErr    error
+		expectPull bool
+	}{
+		{
+			name:     "disabled",
+			envValue: "",
+			pushTag:  "registry.example.com/app:latest",
+		},
+		{
+			name:     "enabled without push tag",
+			envValue: "true",
+			pushTag:  "",
+		},
+		{
+			name:       "enabled",
+			envValue:   "true",
+			pushTag:    "registry.example.com/app:latest",
+			expectPull: true,
+		},
+		{
+			name:       "pull failure is tolerated",
+			envValue:   "true",
+			pushTag:    "registry.example.com/app:latest",
+			pullErr:    fmt.Errorf("manifest unknown"),
+			expectPull: true,
+		},
+	}
+	for _, tc := range testCases {
+		os.Setenv(PullPreviousImageEnvVar, tc.envValue)
+		pulled := ""
+		pullPreviousImage(&buildapiv1.Build{}, tc.pushTag, func(name string, authConfig docker.AuthConfiguration) error {
+			pulled = name
+			return tc.pullErr
+		})
+		if tc.expectPull && pulled != tc.pushTag {
+			t.Errorf("%s: expected a pull of %q, got %q", tc.name, tc.pushTag, pulled)
+		}
+		if !tc.expectPull && pulled != "" {
+			t.Errorf("%s: unexpected pull of %q", tc.name, pulled)
+		}
+	}
+	os.Unsetenv(PullPreviousImageEnvVar)
+}
@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// pushFailureClass identifies the broad category of a push failure so the
+// build can report a targeted status reason and remediation instead of a
+// generic push error.
+type pushFailureClass string
+
+const (
+	pushFailureUnauthorized pushFailureClass = "unauthorized"
+	pushFailureForbidden    pushFailureClass = "forbidden"
+	pushFailureDNS          pushFailureClass = "dns"
+	pushFailureTLS          pushFailureClass = "tls"
+	pushFailureQuota        pushFailureClass = "quota"
+	pushFailureUnknown      pushFailureClass = "unknown"
+)
+
+// Status reasons for classified push failures. The generic
+// StatusReasonPushImageToRegistryFailed remains the fallback.
+const (
+	StatusReasonPushUnauthorized buildapiv1.StatusReason = "PushImageUnauthorized"
+	StatusReasonPushForbidden    buildapiv1.StatusReason = "PushImageForbidden"
+	StatusReasonPushDNSFailed    buildapiv1.StatusReason = "PushImageDNSResolutionFailed"
+	StatusReasonPushTLSFailed    buildapiv1.StatusReason = "PushImageTLSFailed"
+	StatusReasonPushQuotaFailed  buildapiv1.StatusReason = "PushImageQuotaExceeded"
+)
+
+// classifyPushFailure inspects a push error and assigns it to a failure
+// class. Registry errors reach us as strings from several different clients,
+// so this matches on well-known substrings.
+func classifyPushFailure(err error) pushFailureClass {
+	if err == nil {
+		return pushFailureUnknown
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "unauthorized") || strings.Contains(msg, "401") || strings.Contains(msg, "authentication required"):
+		return pushFailureUnauthorized
+	case strings.Contains(msg, "forbidden") || strings.Contains(msg, "403") || strings.Contains(msg, "denied"):
+		return pushFailureForbidden
+	case strings.Contains(msg, "no such host") || strings.Contains(msg, "server misbehaving"):
+		return pushFailureDNS
+	case strings.Contains(msg, "x509") || strings.Contains(msg, "tls") || strings.Contains(msg, "certificate"):
+		return pushFailureTLS
+	case strings.Contains(msg, "quota") || strings.Contains(msg, "denied: requested access to the resource exceeds"):
+		return pushFailureQuota
+	}
+	return pushFailureUnknown
+}
+
+// isPermanentPushFailure reports whether retrying the push cannot succeed
+// without operator intervention, so retries should be skipped.
+func isPermanentPushFailure(class pushFailureClass) bool {
+	switch class {
+	case pushFailureUnauthorized, pushFailureForbidden, pushFailureQuota:
+		return true
+	}
+	return false
+}
+
+// pushFailureStatus maps a push error to the status reason and message
+// recorded on the Build.
+func pushFailureStatus(err error) (buildapiv1.StatusReason, string) {
+	switch classifyPushFailure(err) {
+	case pushFailureUnauthorized:
+		return StatusReasonPushUnauthorized, "Registry rejected the push credentials."
+	case pushFailureForbidden:
+		return StatusReasonPushForbidden, "Registry refused the push to this repository."
+	case pushFailureDNS:
+		return StatusReasonPushDNSFailed, "The registry hostname could not be resolved."
+	case pushFailureTLS:
+		return StatusReasonPushTLSFailed, "TLS negotiation with the registry failed."
+	case pushFailureQuota:
+		return StatusReasonPushQuotaFailed, "The registry quota for this repository is exceeded."
+	}
+	return buildapiv1.StatusReasonPushImageToRegistryFailed, builderutil.StatusMessagePushImageToRegistryFailed
+}
+
+// pushFailureRemediation returns a human-oriented hint printed in the build
+// log alongside the raw error.
+func pushFailureRemediation(class pushFailureClass) string {
+	switch class {
+	case pushFailureUnauthorized:
+		return "Verify the push secret referenced by the build exists and its credentials are current."
+	case pushFailureForbidden:
+		return "Verify the push credentials grant write access to the target repository."
+	case pushFailureDNS:
+		return "Verify the registry hostname in the output image reference and the cluster DNS configuration."
+	case pushFailureTLS:
+		return "Verify the registry serves a certificate trusted by the cluster, or mark the registry insecure in the registries configuration."
+	case pushFailureQuota:
+		return "Free storage in the target repository or raise its quota before retrying the build."
+	}
+	return ""
+}
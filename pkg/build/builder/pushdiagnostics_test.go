@@ -0,0 +1,76 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestClassifyPushFailure(t *testing.T) {
+	testCases := []struct {
+		err       error
+		class     pushFailureClass
+		permanent bool
+	}{
+		{
+			err:       errors.New("unauthorized: authentication required"),
+			class:     pushFailureUnauthorized,
+			permanent: true,
+		},
+		{
+			err:       errors.New("denied: access to the requested resource is not authorized"),
+			class:     pushFailureForbidden,
+			permanent: true,
+		},
+		{
+			err:   errors.New("dial tcp: lookup registry.example.com: no such host"),
+			class: pushFailureDNS,
+		},
+		{
+			err:   errors.New("x509: certificate signed by unknown authority"),
+			class: pushFailureTLS,
+		},
+		{
+			err:       errors.New("denied: quota exceeded"),
+			class:     pushFailureForbidden,
+			permanent: true,
+		},
+		{
+			err:       errors.New("storage quota exceeded for repository"),
+			class:     pushFailureQuota,
+			permanent: true,
+		},
+		{
+			err:   errors.New("received unexpected HTTP status: 500 Internal Server Error"),
+			class: pushFailureUnknown,
+		},
+		{
+			err:   nil,
+			class: pushFailureUnknown,
+		},
+	}
+	for _, tc := range testCases {
+		if class := classifyPushFailure(tc.err); class != tc.class {
+			t.Errorf("classifyPushFailure(%v) = %q; want %q", tc.err, class, tc.class)
+		}
+		if permanent := isPermanentPushFailure(tc.class); permanent != tc.permanent {
+			t.Errorf("isPermanentPushFailure(%q) = %t; want %t", tc.class, permanent, tc.permanent)
+		}
+	}
+}
+
+func TestPushFailureStatus(t *testing.T) {
+	reason, message := pushFailureStatus(errors.New("unauthorized: authentication required"))
+	if reason != StatusReasonPushUnauthorized {
+		t.Errorf("unexpected reason %q", reason)
+	}
+	if len(message) == 0 {
+		t.Error("expected a non-empty message")
+	}
+
+	reason, _ = pushFailureStatus(errors.New("some unrecognized failure"))
+	if reason != buildapiv1.StatusReasonPushImageToRegistryFailed {
+		t.Errorf("unexpected fallback reason %q", reason)
+	}
+}
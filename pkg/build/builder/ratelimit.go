@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// RateLimitCredentialsEnvVar holds a JSON list of alternate pull
+// credentials, objects with username, password, and serveraddress fields.
+// When a registry rate-limits a pull, retries rotate through them, since
+// registry rate limits are commonly scoped per account.
+const RateLimitCredentialsEnvVar = "BUILD_RATE_LIMIT_CREDENTIALS"
+
+// StatusReasonRegistryRateLimited is the reason associated with a pull that
+// a registry refused due to rate limiting, as opposed to a missing image or
+// a network problem.
+const StatusReasonRegistryRateLimited buildapiv1.StatusReason = "RegistryRateLimited"
+
+// maxRetryAfterDelay caps how long a Retry-After header is honored; beyond
+// this the build is better off failing visibly than hanging.
+const maxRetryAfterDelay = 5 * time.Minute
+
+// rateLimitErrorFragments identify rate-limit responses: the HTTP status,
+// and the wording Docker Hub and the distribution registry use.
+var rateLimitErrorFragments = []string{
+	"429",
+	"Too Many Requests",
+	"toomanyrequests",
+	"rate limit",
+	"pull rate limit",
+}
+
+var retryAfterPattern = regexp.MustCompile(`(?i)retry-after:?\s*(\d+)`)
+
+// isRateLimitError reports whether a pull or push failed because the
+// registry rate-limited the client.
+func isRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	for _, fragment := range rateLimitErrorFragments {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfterDelay returns how long to wait before retrying a rate-limited
+// request: the Retry-After value when the error carries one, capped, and
+// the fallback otherwise.
+func retryAfterDelay(err error, fallback time.Duration) time.Duration {
+	if err == nil {
+		return fallback
+	}
+	match := retryAfterPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fallback
+	}
+	seconds, parseErr := strconv.Atoi(match[1])
+	if parseErr != nil || seconds <= 0 {
+		return fallback
+	}
+	delay := time.Duration(seconds) * time.Second
+	if delay > maxRetryAfterDelay {
+		return maxRetryAfterDelay
+	}
+	return delay
+}
+
+// alternatePullCredentials parses the configured credential rotation list.
+func alternatePullCredentials() ([]docker.AuthConfiguration, error) {
+	value := os.Getenv(RateLimitCredentialsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var credentials []docker.AuthConfiguration
+	if err := json.Unmarshal([]byte(value), &credentials); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", RateLimitCredentialsEnvVar, err)
+	}
+	return credentials, nil
+}
+
+// classifyRateLimitedPull refines the build status when a pull failed due to
+// registry rate limiting, so the failure is distinguishable from a missing
+// image or broken credentials.
+func classifyRateLimitedPull(build *buildapiv1.Build, imageName string, err error) {
+	if !isRateLimitError(err) {
+		return
+	}
+	build.Status.Reason = StatusReasonRegistryRateLimited
+	build.Status.Message = builderutil.StatusMessageRegistryRateLimited
+	glog.V(0).Infof("error: The registry rate limited pulling image %s: %v", imageName, err)
+}
@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+func TestIsRateLimitError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "nil error",
+			err:      nil,
+			expected: false,
+		},
+		{
+			name:     "status code",
+			err:      errors.New("received unexpected HTTP status: 429 Too Many Requests"),
+			expected: true,
+		},
+		{
+			name:     "docker hub wording",
+			err:      errors.New("toomanyrequests: You have reached your pull rate limit"),
+			expected: true,
+		},
+		{
+			name:     "generic rate limit wording",
+			err:      errors.New("registry refused: rate limit exceeded"),
+			expected: true,
+		},
+		{
+			name:     "unrelated error",
+			err:      errors.New("manifest unknown"),
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := isRateLimitError(test.err); result != test.expected {
+				t.Errorf("isRateLimitError(%v) = %v; expected %v", test.err, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	fallback := 10 * time.Second
+	tests := []struct {
+		name     string
+		err      error
+		expected time.Duration
+	}{
+		{
+			name:     "nil error",
+			err:      nil,
+			expected: fallback,
+		},
+		{
+			name:     "no retry-after",
+			err:      errors.New("429 Too Many Requests"),
+			expected: fallback,
+		},
+		{
+			name:     "retry-after header",
+			err:      errors.New("429 Too Many Requests, Retry-After: 30"),
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "lowercase without colon",
+			err:      errors.New("rate limited, retry-after 45"),
+			expected: 45 * time.Second,
+		},
+		{
+			name:     "capped",
+			err:      errors.New("429 Too Many Requests, Retry-After: 3600"),
+			expected: maxRetryAfterDelay,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := retryAfterDelay(test.err, fallback); result != test.expected {
+				t.Errorf("retryAfterDelay(%v) = %v; expected %v", test.err, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestAlternatePullCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    int
+		expectError bool
+	}{
+		{
+			name:     "unset",
+			value:    "",
+			expected: 0,
+		},
+		{
+			name:     "two credentials",
+			value:    `[{"username":"a","password":"x","serveraddress":"docker.io"},{"username":"b","password":"y","serveraddress":"docker.io"}]`,
+			expected: 2,
+		},
+		{
+			name:        "invalid JSON",
+			value:       "not json",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if len(test.value) > 0 {
+				os.Setenv(RateLimitCredentialsEnvVar, test.value)
+				defer os.Unsetenv(RateLimitCredentialsEnvVar)
+			} else {
+				os.Unsetenv(RateLimitCredentialsEnvVar)
+			}
+			credentials, err := alternatePullCredentials()
+			if test.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got credentials %v", credentials)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(credentials) != test.expected {
+				t.Errorf("got %d credentials; expected %d", len(credentials), test.expected)
+			}
+		})
+	}
+}
+
+func TestClassifyRateLimitedPull(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedReason  buildapiv1.StatusReason
+		expectedMessage string
+	}{
+		{
+			name:            "rate limited",
+			err:             errors.New("toomanyrequests: You have reached your pull rate limit"),
+			expectedReason:  StatusReasonRegistryRateLimited,
+			expectedMessage: builderutil.StatusMessageRegistryRateLimited,
+		},
+		{
+			name: "unrelated error left alone",
+			err:  errors.New("manifest unknown"),
+		},
+		{
+			name: "nil error left alone",
+			err:  nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			build := &buildapiv1.Build{}
+			classifyRateLimitedPull(build, "registry.example.com/app:latest", test.err)
+			if build.Status.Reason != test.expectedReason {
+				t.Errorf("reason = %q; expected %q", build.Status.Reason, test.expectedReason)
+			}
+			if build.Status.Message != test.expectedMessage {
+				t.Errorf("message = %q; expected %q", build.Status.Message, test.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDelayRegexIsAnchoredToDigits(t *testing.T) {
+	// A digit-free Retry-After must not panic or return a zero delay.
+	err := fmt.Errorf("429 Too Many Requests, Retry-After: soon")
+	if result := retryAfterDelay(err, time.Second); result != time.Second {
+		t.Errorf("retryAfterDelay = %v; expected fallback", result)
+	}
+}
@@ -0,0 +1,122 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	dockertransport "github.com/containers/image/docker"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	"github.com/containers/storage"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// CacheFromEnvVar is the name of the environment variable naming a registry
+// repository to import the instruction cache from before a daemonless
+// Docker strategy build, like the --cache-from option of command-line
+// builders. Cached step images pulled from it let the build skip unchanged
+// steps even though the build pod started with an empty store.
+const CacheFromEnvVar = "BUILD_CACHE_FROM"
+
+// CacheToEnvVar is the name of the environment variable naming a registry
+// repository to export the instruction cache to after a successful
+// daemonless Docker strategy build, like the --cache-to option of
+// command-line builders.
+const CacheToEnvVar = "BUILD_CACHE_TO"
+
+// cacheTagPrefix marks the tags used for exported cache images in the cache
+// repository, so unrelated tags there are left alone.
+const cacheTagPrefix = "cache-"
+
+// cacheFromRepository returns the configured cache import repository.
+func cacheFromRepository() string {
+	return strings.TrimSpace(os.Getenv(CacheFromEnvVar))
+}
+
+// cacheToRepository returns the configured cache export repository.
+func cacheToRepository() string {
+	return strings.TrimSpace(os.Getenv(CacheToEnvVar))
+}
+
+// ImportBuildCache pulls previously exported instruction cache images from
+// the given repository into the build store. The cache is an optimization,
+// so every failure only produces a warning and the build proceeds with
+// whatever could be imported.
+func ImportBuildCache(ctx context.Context, sc types.SystemContext, store storage.Store, repository string) {
+	tags, err := listCacheTags(ctx, sc, repository)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to list the build cache repository %s: %v", repository, err)
+		return
+	}
+	imported := 0
+	for _, tag := range tags {
+		name := repository + ":" + tag
+		if err := pullDaemonlessImage(sc, store, name, docker.AuthConfiguration{}); err != nil {
+			glog.V(0).Infof("warning: Unable to import the cached image %s: %v", name, err)
+			continue
+		}
+		imported++
+	}
+	glog.V(0).Infof("Imported %d cached images from %s", imported, repository)
+}
+
+// listCacheTags returns the cache tags present in the given repository. An
+// empty list and no error means the repository holds no cache.
+func listCacheTags(ctx context.Context, sc types.SystemContext, repository string) ([]string, error) {
+	ref, err := alltransports.ParseImageName("docker://" + repository)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cache repository name %s: %v", repository, err)
+	}
+	systemContext := sc
+	systemContext.AuthFilePath = "/tmp/config.json"
+	allTags, err := dockertransport.GetRepositoryTags(ctx, &systemContext, ref)
+	if err != nil {
+		return nil, err
+	}
+	return filterCacheTags(allTags), nil
+}
+
+// filterCacheTags keeps only the tags that exported cache images use.
+func filterCacheTags(allTags []string) []string {
+	var tags []string
+	for _, tag := range allTags {
+		if strings.HasPrefix(tag, cacheTagPrefix) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ExportBuildCache pushes the intermediate step images the build committed
+// to the given repository, tagged by image ID so later builds can import
+// them. Like the import, it is best-effort: failures are logged and do not
+// fail the build that already produced its output image.
+func ExportBuildCache(sc types.SystemContext, store storage.Store, repository string) {
+	images, err := store.Images()
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to list the build store for cache export: %v", err)
+		return
+	}
+	exported := 0
+	for _, image := range images {
+		// Intermediate step images are the unnamed ones; named images are
+		// the build output and the pulled bases, which live in the regular
+		// registries already.
+		if len(image.Names) != 0 {
+			continue
+		}
+		name := fmt.Sprintf("%s:%s%.12s", repository, cacheTagPrefix, image.ID)
+		if err := tagDaemonlessImage(sc, store, image.ID, name); err != nil {
+			glog.V(0).Infof("warning: Unable to tag the cached image %s: %v", name, err)
+			continue
+		}
+		if err := pushDaemonlessImage(sc, store, name, docker.AuthConfiguration{}); err != nil {
+			glog.V(0).Infof("warning: Unable to export the cached image %s: %v", name, err)
+			continue
+		}
+		exported++
+	}
+	glog.V(0).Infof("Exported %d cached images to %s", exported, repository)
+}
@@ -0,0 +1,28 @@
+package builder
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCacheRepositoriesFromEnvironment(t *testing.T) {
+	os.Setenv(CacheFromEnvVar, " registry.example.com/builds/cache ")
+	os.Setenv(CacheToEnvVar, "")
+	defer os.Unsetenv(CacheFromEnvVar)
+	defer os.Unsetenv(CacheToEnvVar)
+	if actual := cacheFromRepository(); actual != "registry.example.com/builds/cache" {
+		t.Errorf("unexpected cache import repository %q", actual)
+	}
+	if actual := cacheToRepository(); len(actual) != 0 {
+		t.Errorf("expected no cache export repository, got %q", actual)
+	}
+}
+
+func TestFilterCacheTags(t *testing.T) {
+	tags := filterCacheTags([]string{"latest", "cache-0123456789ab", "v1", "cache-fedcba987654"})
+	expected := []string{"cache-0123456789ab", "cache-fedcba987654"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %v, got %v", expected, tags)
+	}
+}
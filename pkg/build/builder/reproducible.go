@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// ReproducibleBuildEnvVar enables reproducible build mode: all timestamps
+// that the builder controls are fixed to the Build creation timestamp, so
+// building the same commit twice yields identical layer digests. The fixed
+// timestamp is also exported to the build as the conventional
+// SOURCE_DATE_EPOCH argument for build steps that produce timestamped output.
+// File ordering needs no special handling: the vendored archive code walks
+// directories in sorted order, so context tarballs are already deterministic.
+const ReproducibleBuildEnvVar = "BUILD_REPRODUCIBLE"
+
+// sourceDateEpochArg is the de-facto standard variable name for a fixed
+// build timestamp, expressed in seconds since the epoch.
+const sourceDateEpochArg = "SOURCE_DATE_EPOCH"
+
+func reproducibleBuild() bool {
+	return strings.EqualFold(os.Getenv(ReproducibleBuildEnvVar), "true")
+}
+
+// reproducibleBuildTimestamp returns the fixed timestamp used for
+// reproducible builds, taken from the Build creation timestamp.
+func reproducibleBuildTimestamp(build *buildapiv1.Build) time.Time {
+	if build != nil && !build.CreationTimestamp.IsZero() {
+		return build.CreationTimestamp.Time.UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// normalizeContextTimestamps sets the modification time of everything in the
+// build context to the fixed timestamp, so file times copied into layers do
+// not depend on when the source was cloned.
+func normalizeContextTimestamps(dir string, timestamp time.Time) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Chtimes follows symlinks; skip them rather than touch targets
+		// that may be outside the context.
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return os.Chtimes(path, timestamp, timestamp)
+	})
+}
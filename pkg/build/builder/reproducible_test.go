@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReproducibleBuildTimestamp(t *testing.T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	build := &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: created}},
+	}
+	if got := reproducibleBuildTimestamp(build); !got.Equal(created) {
+		t.Errorf("got %s; want %s", got, created)
+	}
+	if got := reproducibleBuildTimestamp(&buildapiv1.Build{}); !got.Equal(time.Unix(0, 0)) {
+		t.Errorf("got %s; want the epoch", got)
+	}
+}
+
+func TestNormalizeContextTimestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reproducible-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"top", "sub/nested"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	timestamp := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := normalizeContextTimestamps(dir, timestamp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"top", "sub", "sub/nested"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !info.ModTime().Equal(timestamp) {
+			t.Errorf("%s: modification time %s; want %s", name, info.ModTime(), timestamp)
+		}
+	}
+}
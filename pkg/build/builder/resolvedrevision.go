@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+// ResolvedRevisionAnnotation records, as a JSON object, what the clone
+// actually checked out - full commit SHA, branch, tag, and commit timestamp -
+// so provenance is complete even for builds that did not request a specific
+// revision.
+const ResolvedRevisionAnnotation = "build.openshift.io/resolved-revision"
+
+// resolvedRevision is the annotation payload.
+type resolvedRevision struct {
+	Commit    string `json:"commit,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// gitOutput runs one git query against the checkout and returns its trimmed
+// output.
+func gitOutput(dir string, args ...string) (string, error) {
+	gitArgs := append([]string{"-C", dir}, args...)
+	out, err := exec.Command("git", gitArgs...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolveRevisionDetails fills in what the source info does not carry by
+// asking the checkout itself. Every field is best-effort: a field that
+// cannot be resolved is simply left empty.
+func resolveRevisionDetails(dir string, sourceInfo *git.SourceInfo) resolvedRevision {
+	resolved := resolvedRevision{
+		Commit:    sourceInfo.CommitID,
+		Branch:    sourceInfo.Ref,
+		Timestamp: sourceInfo.Date,
+	}
+	if len(resolved.Commit) == 0 {
+		resolved.Commit, _ = gitOutput(dir, "rev-parse", "HEAD")
+	}
+	if len(resolved.Branch) == 0 {
+		branch, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+		// A detached HEAD reports the literal name "HEAD", which names no
+		// branch.
+		if err == nil && branch != "HEAD" {
+			resolved.Branch = branch
+		}
+	}
+	if len(resolved.Timestamp) == 0 {
+		resolved.Timestamp, _ = gitOutput(dir, "log", "-1", "--format=%cI")
+	}
+	// An error just means HEAD carries no tag.
+	resolved.Tag, _ = gitOutput(dir, "describe", "--tags", "--exact-match", "HEAD")
+	return resolved
+}
+
+// RecordResolvedRevision annotates the build with the revision the clone
+// resolved to. It complements GetSourceRevision: the SourceRevision API only
+// carries the commit, while the annotation also names the branch, tag, and
+// commit timestamp.
+func RecordResolvedRevision(build *buildapiv1.Build, dir string, sourceInfo *git.SourceInfo) {
+	if sourceInfo == nil {
+		return
+	}
+	resolved := resolveRevisionDetails(dir, sourceInfo)
+	resolvedJSON, err := json.Marshal(resolved)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to encode the resolved revision: %v", err)
+		return
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[ResolvedRevisionAnnotation] = string(resolvedJSON)
+	glog.V(2).Infof("Resolved source revision: %s", string(resolvedJSON))
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"os/exec"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+// initTestRepo creates a git repository with one tagged commit.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	commands := [][]string{
+		{"init", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "initial"},
+		{"tag", "v1.0.0"},
+	}
+	for _, args := range commands {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestResolveRevisionDetails(t *testing.T) {
+	dir := initTestRepo(t)
+	resolved := resolveRevisionDetails(dir, &git.SourceInfo{})
+	if len(resolved.Commit) != 40 {
+		t.Errorf("expected a full commit SHA, got %q", resolved.Commit)
+	}
+	if resolved.Branch != "main" {
+		t.Errorf("expected branch main, got %q", resolved.Branch)
+	}
+	if resolved.Tag != "v1.0.0" {
+		t.Errorf("expected tag v1.0.0, got %q", resolved.Tag)
+	}
+	if len(resolved.Timestamp) == 0 {
+		t.Errorf("expected a commit timestamp")
+	}
+}
+
+func TestResolveRevisionDetailsPrefersSourceInfo(t *testing.T) {
+	dir := initTestRepo(t)
+	sourceInfo := &git.SourceInfo{CommitID: "0123456789", Ref: "feature", Date: "2026-01-01"}
+	resolved := resolveRevisionDetails(dir, sourceInfo)
+	if resolved.Commit != "0123456789" || resolved.Branch != "feature" || resolved.Timestamp != "2026-01-01" {
+		t.Errorf("expected the source info values to win, got %+v", resolved)
+	}
+}
+
+func TestRecordResolvedRevision(t *testing.T) {
+	dir := initTestRepo(t)
+	build := &buildapiv1.Build{}
+	RecordResolvedRevision(build, dir, &git.SourceInfo{})
+	if _, annotated := build.Annotations[ResolvedRevisionAnnotation]; !annotated {
+		t.Fatalf("expected the resolved revision annotation to be set")
+	}
+	RecordResolvedRevision(&buildapiv1.Build{}, dir, nil)
+}
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// ResourceUsageAnnotation is the Build annotation under which the builder
+// records a JSON resource-usage summary for capacity planning.
+const ResourceUsageAnnotation = "build.openshift.io/resource-usage"
+
+// ResourceUsage summarizes the resources consumed by the build pod's cgroup.
+type ResourceUsage struct {
+	// CPUSeconds is the total CPU time consumed, in seconds.
+	CPUSeconds float64 `json:"cpuSeconds,omitempty"`
+	// PeakMemoryBytes is the high-water mark of memory usage.
+	PeakMemoryBytes int64 `json:"peakMemoryBytes,omitempty"`
+	// BlkioBytes is the total bytes transferred to and from block devices.
+	BlkioBytes int64 `json:"blkioBytes,omitempty"`
+}
+
+// ReadResourceUsage collects cgroup statistics for the build pod from the
+// local /sys/fs/cgroup filesystem. On systems without cgroups it returns nil
+// without error, mirroring GetCGroupLimits.
+func ReadResourceUsage() (*ResourceUsage, error) {
+	if _, err := os.Stat("/sys/fs/cgroup"); os.IsNotExist(err) {
+		return nil, nil
+	}
+	usage := &ResourceUsage{}
+	if cpuNanos, err := readInt64("/sys/fs/cgroup/cpu,cpuacct/cpuacct.usage"); err == nil {
+		usage.CPUSeconds = time.Duration(cpuNanos).Seconds()
+	} else if cpuNanos, err := readInt64("/sys/fs/cgroup/cpuacct/cpuacct.usage"); err == nil {
+		usage.CPUSeconds = time.Duration(cpuNanos).Seconds()
+	}
+	if peak, err := readInt64("/sys/fs/cgroup/memory/memory.max_usage_in_bytes"); err == nil {
+		usage.PeakMemoryBytes = peak
+	}
+	if lines, err := ReadLines("/sys/fs/cgroup/blkio/blkio.throttle.io_service_bytes"); err == nil {
+		usage.BlkioBytes = parseBlkioTotal(lines)
+	}
+	return usage, nil
+}
+
+// parseBlkioTotal extracts the Total entry from a blkio service-bytes file.
+func parseBlkioTotal(lines []string) int64 {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "Total" {
+			if total, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return total
+			}
+		}
+	}
+	return 0
+}
+
+// RecordResourceUsage collects the build pod's resource usage and records it
+// as an annotation on the build, so HandleBuildStatusUpdate carries it to the
+// API server along with the final status.
+func RecordResourceUsage(build *buildapiv1.Build) {
+	usage, err := ReadResourceUsage()
+	if err != nil || usage == nil {
+		return
+	}
+	glog.V(0).Infof("Build resource usage: cpu=%.2fs peakMemory=%d bytes blkio=%d bytes", usage.CPUSeconds, usage.PeakMemoryBytes, usage.BlkioBytes)
+	summary, err := json.Marshal(usage)
+	if err != nil {
+		glog.V(4).Infof("unable to serialize resource usage: %v", err)
+		return
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[ResourceUsageAnnotation] = string(summary)
+}
@@ -0,0 +1,20 @@
+package builder
+
+import (
+	"testing"
+)
+
+func TestParseBlkioTotal(t *testing.T) {
+	lines := []string{
+		"8:0 Read 131072",
+		"8:0 Write 4096",
+		"8:0 Sync 0",
+		"Total 135168",
+	}
+	if total := parseBlkioTotal(lines); total != 135168 {
+		t.Errorf("expected 135168, got %d", total)
+	}
+	if total := parseBlkioTotal(nil); total != 0 {
+		t.Errorf("expected 0 for empty input, got %d", total)
+	}
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+// EnforceRevisionEnvVar, when set to true, makes builds with a commit in
+// Spec.Revision fail unless the checked-out HEAD matches that commit exactly,
+// protecting audited pipelines against ref mutation between trigger and clone.
+const EnforceRevisionEnvVar = "BUILD_ENFORCE_REVISION"
+
+// StatusReasonSourceRevisionMismatch is the reason associated with builds
+// rejected because the checked-out commit differs from the one recorded in
+// the build spec.
+const StatusReasonSourceRevisionMismatch buildapiv1.StatusReason = "SourceRevisionMismatch"
+
+// RevisionVerifiedAnnotation records the commit the builder verified against
+// Spec.Revision, as provenance that enforcement took place.
+const RevisionVerifiedAnnotation = "build.openshift.io/revision-verified"
+
+// revisionEnforced reports whether the cluster requires the checked-out
+// commit to match Spec.Revision.
+func revisionEnforced() bool {
+	return strings.EqualFold(os.Getenv(EnforceRevisionEnvVar), "true")
+}
+
+// VerifySourceRevision compares the checked-out commit against the commit in
+// Spec.Revision when enforcement is enabled, recording a successful check in
+// the build's annotations. Builds without a spec revision commit are left
+// alone: there is nothing to verify against.
+func VerifySourceRevision(build *buildapiv1.Build, sourceInfo *git.SourceInfo) error {
+	if !revisionEnforced() {
+		return nil
+	}
+	revision := build.Spec.Revision
+	if revision == nil || revision.Git == nil || len(revision.Git.Commit) == 0 {
+		return nil
+	}
+	if sourceInfo == nil {
+		return fmt.Errorf("cannot verify source revision: no git source information available")
+	}
+	expected := revision.Git.Commit
+	actual := sourceInfo.CommitID
+	// The spec may carry an abbreviated commit; an abbreviation must still be
+	// an exact prefix of HEAD.
+	if actual != expected && !(len(expected) < len(actual) && strings.HasPrefix(actual, expected)) {
+		return fmt.Errorf("checked-out commit %s does not match the commit %s recorded in the build spec", actual, expected)
+	}
+	glog.V(0).Infof("Verified checked-out commit %s matches the build spec revision", actual)
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[RevisionVerifiedAnnotation] = actual
+	return nil
+}
@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"os"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+func TestVerifySourceRevision(t *testing.T) {
+	buildWithCommit := func(commit string) *buildapiv1.Build {
+		return &buildapiv1.Build{
+			Spec: buildapiv1.BuildSpec{
+				CommonSpec: buildapiv1.CommonSpec{
+					Revision: &buildapiv1.SourceRevision{
+						Git: &buildapiv1.GitSourceRevision{Commit: commit},
+					},
+				},
+			},
+		}
+	}
+	const head = "0123456789abcdef0123456789abcdef01234567"
+
+	testCases := []struct {
+		name       string
+		enforce    string
+		build      *buildapiv1.Build
+		sourceInfo *git.SourceInfo
+		expectErr  bool
+		annotated  bool
+	}{
+		{
+			name:       "enforcement disabled",
+			build:      buildWithCommit("deadbeef"),
+			sourceInfo: &git.SourceInfo{CommitID: head},
+		},
+		{
+			name:    "no spec revision",
+			enforce: "true",
+			build:   &buildapiv1.Build{},
+		},
+		{
+			name:       "exact match",
+			enforce:    "true",
+			build:      buildWithCommit(head),
+			sourceInfo: &git.SourceInfo{CommitID: head},
+			annotated:  true,
+		},
+		{
+			name:       "abbreviated match",
+			enforce:    "true",
+			build:      buildWithCommit(head[:12]),
+			sourceInfo: &git.SourceInfo{CommitID: head},
+			annotated:  true,
+		},
+		{
+			name:       "mismatch",
+			enforce:    "true",
+			build:      buildWithCommit("deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"),
+			sourceInfo: &git.SourceInfo{CommitID: head},
+			expectErr:  true,
+		},
+		{
+			name:      "missing source info",
+			enforce:   "true",
+			build:     buildWithCommit(head),
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv(EnforceRevisionEnvVar, tc.enforce)
+			defer os.Unsetenv(EnforceRevisionEnvVar)
+			err := VerifySourceRevision(tc.build, tc.sourceInfo)
+			if tc.expectErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			_, annotated := tc.build.Annotations[RevisionVerifiedAnnotation]
+			if annotated != tc.annotated {
+				t.Errorf("annotation present: %t; want %t", annotated, tc.annotated)
+			}
+		})
+	}
+}
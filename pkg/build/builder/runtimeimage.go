@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	s2iapi "github.com/openshift/source-to-image/pkg/api"
+)
+
+// Strategy environment variables enabling extended S2I builds. The runtime
+// image variable names a slim image for the final stage; the artifacts
+// variable lists "source:destination" pairs (separated by semicolons) to copy
+// from the assemble stage into it.
+const (
+	s2iRuntimeImageOverrideEnv     = "BUILD_S2I_RUNTIME_IMAGE"
+	s2iRuntimeArtifactsOverrideEnv = "BUILD_S2I_RUNTIME_ARTIFACTS"
+)
+
+// appendRuntimeStage rewrites the Dockerfile generated by source-to-image
+// into a multi-stage build whose final stage is the configured runtime image,
+// copying the declared artifacts out of the assemble stage. The image built
+// from the result carries only the runtime image content plus the artifacts,
+// without requiring a custom Dockerfile from the user.
+func appendRuntimeStage(dockerfilePath string, config *s2iapi.Config) error {
+	if len(config.RuntimeArtifacts) == 0 {
+		return fmt.Errorf("a runtime image build requires at least one artifact, set %s", s2iRuntimeArtifactsOverrideEnv)
+	}
+	dockerfile, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+
+	stage := bytes.NewBuffer(dockerfile)
+	fmt.Fprintf(stage, "\nFROM %s\n", config.RuntimeImage)
+	for _, artifact := range config.RuntimeArtifacts {
+		if len(artifact.Source) == 0 || len(artifact.Destination) == 0 {
+			return fmt.Errorf("runtime artifacts must declare a source and a destination, got %q:%q", artifact.Source, artifact.Destination)
+		}
+		fmt.Fprintf(stage, "COPY --from=0 %s %s\n", artifact.Source, artifact.Destination)
+	}
+	// Only the final stage's configuration ends up in the output image, so
+	// the build environment and labels have to be repeated there.
+	for _, env := range config.Environment {
+		fmt.Fprintf(stage, "ENV %s=%q\n", env.Name, env.Value)
+	}
+	keys := make([]string, 0, len(config.Labels))
+	for k := range config.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(stage, "LABEL %q=%q\n", k, config.Labels[k])
+	}
+
+	return ioutil.WriteFile(dockerfilePath, stage.Bytes(), 0660)
+}
@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	s2iapi "github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestAppendRuntimeStage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtimestage")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	original := "FROM builder:latest\nRUN /usr/libexec/s2i/assemble\n"
+	if err := ioutil.WriteFile(dockerfilePath, []byte(original), 0660); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := &s2iapi.Config{
+		RuntimeImage: "runtime:latest",
+		RuntimeArtifacts: s2iapi.VolumeList{
+			{Source: "/opt/app-root/output", Destination: "/opt/app-root"},
+		},
+		Environment: s2iapi.EnvironmentList{
+			{Name: "OPENSHIFT_BUILD_NAME", Value: "test-build"},
+		},
+		Labels: map[string]string{"io.openshift.build.name": "test-build"},
+	}
+	if err := appendRuntimeStage(dockerfilePath, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rewritten, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := string(rewritten)
+	if !strings.HasPrefix(content, original) {
+		t.Errorf("assemble stage was modified:\n%s", content)
+	}
+	for _, expected := range []string{
+		"FROM runtime:latest\n",
+		"COPY --from=0 /opt/app-root/output /opt/app-root\n",
+		"ENV OPENSHIFT_BUILD_NAME=\"test-build\"\n",
+		"LABEL \"io.openshift.build.name\"=\"test-build\"\n",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("missing %q in rewritten Dockerfile:\n%s", expected, content)
+		}
+	}
+
+	config.RuntimeArtifacts = nil
+	if err := appendRuntimeStage(dockerfilePath, config); err == nil {
+		t.Error("expected an error when no artifacts are declared")
+	}
+}
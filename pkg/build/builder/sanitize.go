@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StrictExtractionEnvVar is the name of the environment variable that, when
+// set to true, makes builds fail on any suspicious entry found in extracted
+// input content instead of removing the entry and continuing. bsdtar
+// already refuses `..` traversal and absolute paths while extracting; this
+// covers what only becomes visible afterwards, such as symlinks pointing
+// outside the build context and special files.
+const StrictExtractionEnvVar = "BUILD_STRICT_EXTRACTION"
+
+// strictExtractionEnabled reports whether suspicious extracted entries fail
+// the build.
+func strictExtractionEnabled() bool {
+	return strings.EqualFold(os.Getenv(StrictExtractionEnvVar), "true")
+}
+
+// sanitizeExtractedContent inspects the extracted build content under dir
+// and handles entries that have no business in a build context: symlinks
+// escaping the context and special files such as device nodes and pipes.
+// Offending entries are removed with a warning, or fail the build when
+// strict extraction is enabled.
+//
+// allowAbsoluteSymlinks preserves symlinks with absolute targets. Content
+// extracted from images routinely carries them - alternatives-style
+// /usr/... links, JDK trees - and they resolve against the composed image,
+// so only `..` traversal out of the context is treated as an escape there.
+// Binary input comes as a tar from an untrusted client and keeps the
+// aggressive default.
+func sanitizeExtractedContent(dir string, allowAbsoluteSymlinks bool) error {
+	strict := strictExtractionEnabled()
+	var removals []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		problem := describeSuspiciousEntry(dir, path, info, allowAbsoluteSymlinks)
+		if len(problem) == 0 {
+			return nil
+		}
+		if strict {
+			return fmt.Errorf("the build input contains %s; unset %s to have such entries removed instead", problem, StrictExtractionEnvVar)
+		}
+		glog.V(0).Infof("warning: Removing %s from the build input", problem)
+		removals = append(removals, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range removals {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeSuspiciousEntry returns a description of why the entry must not
+// remain in the build context, or an empty string if it is harmless.
+func describeSuspiciousEntry(dir, path string, info os.FileInfo, allowAbsoluteSymlinks bool) string {
+	mode := info.Mode()
+	switch {
+	case mode&os.ModeDevice != 0:
+		return fmt.Sprintf("a device node at %q", path)
+	case mode&os.ModeNamedPipe != 0:
+		return fmt.Sprintf("a named pipe at %q", path)
+	case mode&os.ModeSocket != 0:
+		return fmt.Sprintf("a socket at %q", path)
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Sprintf("an unreadable symlink at %q", path)
+		}
+		if filepath.IsAbs(target) {
+			if !allowAbsoluteSymlinks {
+				return fmt.Sprintf("a symlink at %q with the absolute target %q", path, target)
+			}
+		} else if !symlinkStaysWithin(dir, path, target) {
+			return fmt.Sprintf("a symlink at %q pointing outside the build context to %q", path, target)
+		}
+	}
+	return ""
+}
+
+// symlinkStaysWithin reports whether a relative symlink at path with the
+// given target resolves to a location inside dir. Whether absolute targets
+// are acceptable depends on where the content came from, so the caller
+// judges those.
+func symlinkStaysWithin(dir, path, target string) bool {
+	if filepath.IsAbs(target) {
+		return false
+	}
+	resolved := filepath.Clean(filepath.Join(filepath.Dir(path), target))
+	rel, err := filepath.Rel(dir, resolved)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
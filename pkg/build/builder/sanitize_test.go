@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSymlinkStaysWithin(t *testing.T) {
+	testCases := []struct {
+		path     string
+		target   string
+		expected bool
+	}{
+		{path: "/ctx/link", target: "file", expected: true},
+		{path: "/ctx/sub/link", target: "../file", expected: true},
+		{path: "/ctx/link", target: "../escape", expected: false},
+		{path: "/ctx/sub/link", target: "../../../etc/passwd", expected: false},
+		{path: "/ctx/link", target: "/etc/passwd", expected: false},
+		{path: "/ctx/link", target: "/ctx/file", expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := symlinkStaysWithin("/ctx", tc.path, tc.target); actual != tc.expected {
+			t.Errorf("%q -> %q: expected %v, got %v", tc.path, tc.target, tc.expected, actual)
+		}
+	}
+}
+
+func TestSanitizeExtractedContent(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "sanitize")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "kept.txt"), []byte("content"), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Symlink("kept.txt", filepath.Join(dir, "internal-link")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Symlink("../../etc/passwd", filepath.Join(dir, "escape-link")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Symlink("/usr/lib/jvm/java", filepath.Join(dir, "absolute-link")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := syscall.Mkfifo(filepath.Join(dir, "pipe"), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return dir
+	}
+
+	dir := setup(t)
+	defer os.RemoveAll(dir)
+	os.Unsetenv(StrictExtractionEnvVar)
+	if err := sanitizeExtractedContent(dir, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, removed := range []string{"escape-link", "absolute-link", "pipe"} {
+		if _, err := os.Lstat(filepath.Join(dir, removed)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", removed, err)
+		}
+	}
+	for _, kept := range []string{"kept.txt", "internal-link"} {
+		if _, err := os.Lstat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("expected %s to be kept, got %v", kept, err)
+		}
+	}
+
+	imageDir := setup(t)
+	defer os.RemoveAll(imageDir)
+	if err := sanitizeExtractedContent(imageDir, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(imageDir, "escape-link")); !os.IsNotExist(err) {
+		t.Errorf("expected escape-link to be removed from image content, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(imageDir, "absolute-link")); err != nil {
+		t.Errorf("expected absolute-link to be kept in image content, got %v", err)
+	}
+
+	strictDir := setup(t)
+	defer os.RemoveAll(strictDir)
+	os.Setenv(StrictExtractionEnvVar, "true")
+	defer os.Unsetenv(StrictExtractionEnvVar)
+	if err := sanitizeExtractedContent(strictDir, false); err == nil {
+		t.Errorf("expected strict extraction to fail on suspicious entries")
+	}
+	if _, err := os.Lstat(filepath.Join(strictDir, "escape-link")); err != nil {
+		t.Errorf("expected strict extraction not to modify the content, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	s2iapi "github.com/openshift/source-to-image/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+)
+
+// ScriptCommandsEnvVar is the name of the environment variable carrying a
+// JSON list of shell commands for a script build: each command runs inside
+// the builder image against the cloned source, and the result is committed
+// as the output image. This is a lightweight middle ground between S2I,
+// which prescribes the assemble flow, and a full Dockerfile.
+const ScriptCommandsEnvVar = "BUILD_SCRIPT_COMMANDS"
+
+// scriptDockerfileName is the generated Dockerfile a script build is
+// translated into, placed next to the source so the regular Docker build
+// pipeline picks it up.
+const scriptDockerfileName = ".openshift-script-build.Dockerfile"
+
+// scriptSourceDir is where the source ends up inside the image.
+const scriptSourceDir = "/tmp/src"
+
+// scriptCommandsFromEnvironment reads the declared command list. An unset
+// variable means this is not a script build.
+func scriptCommandsFromEnvironment() ([]string, error) {
+	value := os.Getenv(ScriptCommandsEnvVar)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var commands []string
+	if err := json.Unmarshal([]byte(value), &commands); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", ScriptCommandsEnvVar, err)
+	}
+	for _, command := range commands {
+		if len(command) == 0 {
+			return nil, fmt.Errorf("%s must not contain empty commands", ScriptCommandsEnvVar)
+		}
+	}
+	return commands, nil
+}
+
+// HasScriptCommands reports whether the environment declares a script
+// build.
+func HasScriptCommands() bool {
+	return len(os.Getenv(ScriptCommandsEnvVar)) != 0
+}
+
+// ScriptBuilder runs a script build by translating it into an equivalent
+// Docker build: a Dockerfile is generated that copies the source into the
+// builder image and runs the declared commands, and the regular Docker
+// build pipeline handles the rest, including pushing the output.
+type ScriptBuilder struct {
+	dockerClient DockerClient
+	build        *buildapiv1.Build
+	client       buildclientv1.BuildInterface
+	cgLimits     *s2iapi.CGroupLimits
+}
+
+// NewScriptBuilder creates a new instance of ScriptBuilder.
+func NewScriptBuilder(dockerClient DockerClient, buildsClient buildclientv1.BuildInterface, build *buildapiv1.Build, cgLimits *s2iapi.CGroupLimits) *ScriptBuilder {
+	return &ScriptBuilder{
+		dockerClient: dockerClient,
+		build:        build,
+		client:       buildsClient,
+		cgLimits:     cgLimits,
+	}
+}
+
+// Build executes the script build.
+func (s *ScriptBuilder) Build() error {
+	strategy := s.build.Spec.Strategy.SourceStrategy
+	if strategy == nil {
+		return fmt.Errorf("script builds require a Source strategy declaring the builder image")
+	}
+	commands, err := scriptCommandsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if len(commands) == 0 {
+		return fmt.Errorf("no script commands are declared in %s", ScriptCommandsEnvVar)
+	}
+	image := strategy.From.Name
+	if len(image) == 0 {
+		return fmt.Errorf("the build strategy does not name a builder image")
+	}
+
+	contextDir := InputContentPath
+	if len(s.build.Spec.Source.ContextDir) != 0 {
+		contextDir = filepath.Join(contextDir, filepath.Clean(s.build.Spec.Source.ContextDir))
+	}
+	dockerfile := scriptDockerfile(image, strategy.Env, commands)
+	if err := overwriteFile(filepath.Join(contextDir, scriptDockerfileName), dockerfile); err != nil {
+		return fmt.Errorf("unable to write the generated Dockerfile: %v", err)
+	}
+	glog.V(0).Infof("Running %d script commands in image %s", len(commands), image)
+	glog.V(4).Infof("Generated Dockerfile:\n%s", string(dockerfile))
+
+	// Hand the translated build to the Docker pipeline. The strategy is
+	// rewritten on the in-memory object only; the Build resource itself is
+	// untouched.
+	s.build.Spec.Strategy.SourceStrategy = nil
+	s.build.Spec.Strategy.DockerStrategy = &buildapiv1.DockerBuildStrategy{
+		DockerfilePath: scriptDockerfileName,
+		ForcePull:      strategy.ForcePull,
+		PullSecret:     strategy.PullSecret,
+	}
+	return NewDockerBuilder(s.dockerClient, s.client, s.build, s.cgLimits).Build()
+}
+
+// scriptDockerfile generates the Dockerfile a script build runs.
+func scriptDockerfile(image string, env []corev1.EnvVar, commands []string) []byte {
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "FROM %s\n", image)
+	fmt.Fprintf(out, "COPY . %s\n", scriptSourceDir)
+	fmt.Fprintf(out, "WORKDIR %s\n", scriptSourceDir)
+	for _, e := range env {
+		fmt.Fprintf(out, "ENV %s=%q\n", e.Name, e.Value)
+	}
+	for _, command := range commands {
+		fmt.Fprintf(out, "RUN %s\n", command)
+	}
+	return out.Bytes()
+}
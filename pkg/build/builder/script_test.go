@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestScriptCommandsFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []string
+		expectErr bool
+	}{
+		{value: "", expected: nil},
+		{value: `["make", "make test"]`, expected: []string{"make", "make test"}},
+		{value: `["make", ""]`, expectErr: true},
+		{value: `make`, expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(ScriptCommandsEnvVar, tc.value)
+		actual, err := scriptCommandsFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(ScriptCommandsEnvVar)
+}
+
+func TestScriptDockerfile(t *testing.T) {
+	dockerfile := scriptDockerfile(
+		"example/builder:latest",
+		[]corev1.EnvVar{{Name: "GOFLAGS", Value: "-mod=vendor"}},
+		[]string{"go build ./...", "go test ./..."},
+	)
+	expected := `FROM example/builder:latest
+COPY . /tmp/src
+WORKDIR /tmp/src
+ENV GOFLAGS="-mod=vendor"
+RUN go build ./...
+RUN go test ./...
+`
+	if string(dockerfile) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(dockerfile))
+	}
+}
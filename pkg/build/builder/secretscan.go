@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// SecretScanEnvVar enables scanning the fetched source for credential
+// patterns before any of it can be baked into image layers. The value is the
+// policy: "warn" logs findings, "fail" fails the build on them.
+const SecretScanEnvVar = "BUILD_SECRET_SCAN"
+
+// SecretScanPatternsPathEnvVar points at a JSON file with additional
+// patterns, a list of {"name": ..., "pattern": ...} objects, extending the
+// built-in ones.
+const SecretScanPatternsPathEnvVar = "BUILD_SECRET_SCAN_PATTERNS_PATH"
+
+// StatusReasonSecretDetectedInSource is the reason associated with a build
+// that was failed because the source contained credential material.
+const StatusReasonSecretDetectedInSource buildapiv1.StatusReason = "SecretDetectedInSource"
+
+const (
+	secretScanModeWarn = "warn"
+	secretScanModeFail = "fail"
+
+	// Files larger than this are skipped; credential material lives in
+	// text files, and unbounded scanning of large artifacts would slow
+	// every build down.
+	secretScanMaxFileSize = 1 << 20
+)
+
+// secretScanPattern is one named credential pattern.
+type secretScanPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+
+	compiled *regexp.Regexp
+}
+
+// builtinSecretScanPatterns covers credential formats that are recognizable
+// with high confidence; vaguer heuristics belong in the configurable
+// patterns file where the cluster decides the false-positive tradeoff.
+var builtinSecretScanPatterns = []secretScanPattern{
+	{Name: "AWS access key ID", Pattern: `\b(?:A3T[A-Z0-9]|AKIA|ASIA)[A-Z0-9]{16}\b`},
+	{Name: "private key", Pattern: `-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----`},
+	{Name: "GitHub token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{Name: "Slack token", Pattern: `\bxox[baprs]-[A-Za-z0-9-]{10,}\b`},
+}
+
+// secretFinding locates one match; the matched text itself is never stored
+// or logged, since it is presumably a credential.
+type secretFinding struct {
+	File    string
+	Line    int
+	Pattern string
+}
+
+// secretScanMode returns the configured policy, or empty when scanning is
+// disabled.
+func secretScanMode() (string, error) {
+	mode := os.Getenv(SecretScanEnvVar)
+	switch mode {
+	case "", secretScanModeWarn, secretScanModeFail:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value %q for %s, expected %q or %q", mode, SecretScanEnvVar, secretScanModeWarn, secretScanModeFail)
+}
+
+// secretScanPatterns compiles the built-in patterns plus any configured
+// additions.
+func secretScanPatterns() ([]secretScanPattern, error) {
+	patterns := append([]secretScanPattern{}, builtinSecretScanPatterns...)
+	if path := os.Getenv(SecretScanPatternsPathEnvVar); len(path) != 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the secret scan patterns file: %v", err)
+		}
+		var extra []secretScanPattern
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("unable to parse the secret scan patterns file %s: %v", path, err)
+		}
+		patterns = append(patterns, extra...)
+	}
+	for i := range patterns {
+		compiled, err := regexp.Compile(patterns[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret scan pattern %q: %v", patterns[i].Name, err)
+		}
+		patterns[i].compiled = compiled
+	}
+	return patterns, nil
+}
+
+// scanSourceForSecrets walks the source tree and reports every line matching
+// a pattern. The .git directory is skipped: its packed objects are binary
+// and the working tree is what ends up in the image.
+func scanSourceForSecrets(dir string, patterns []secretScanPattern) ([]secretFinding, error) {
+	var findings []secretFinding
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > secretScanMaxFileSize {
+			return nil
+		}
+		fileFindings, err := scanFileForSecrets(path, patterns)
+		if err != nil {
+			return err
+		}
+		for i := range fileFindings {
+			relPath, relErr := filepath.Rel(dir, fileFindings[i].File)
+			if relErr == nil {
+				fileFindings[i].File = relPath
+			}
+		}
+		findings = append(findings, fileFindings...)
+		return nil
+	})
+	return findings, err
+}
+
+// scanFileForSecrets reports pattern matches in one file. Binary files are
+// skipped.
+func scanFileForSecrets(path string, patterns []secretScanPattern) ([]secretFinding, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, nil
+	}
+	var findings []secretFinding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), secretScanMaxFileSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		for _, pattern := range patterns {
+			if pattern.compiled.Match(scanner.Bytes()) {
+				findings = append(findings, secretFinding{File: path, Line: line, Pattern: pattern.Name})
+			}
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// EnforceSecretScan scans the fetched source per the configured policy. With
+// the "fail" policy, findings fail the build; with "warn", they are logged
+// and the build proceeds.
+func EnforceSecretScan(dir string) error {
+	mode, err := secretScanMode()
+	if err != nil {
+		return err
+	}
+	if len(mode) == 0 {
+		return nil
+	}
+	patterns, err := secretScanPatterns()
+	if err != nil {
+		return err
+	}
+	findings, err := scanSourceForSecrets(dir, patterns)
+	if err != nil {
+		return fmt.Errorf("unable to scan the source for secrets: %v", err)
+	}
+	for _, finding := range findings {
+		glog.V(0).Infof("warning: %s:%d matches the %q secret pattern", finding.File, finding.Line, finding.Pattern)
+	}
+	if len(findings) != 0 && mode == secretScanModeFail {
+		return fmt.Errorf("the source contains %d line(s) matching secret patterns; remove the credentials or relax %s", len(findings), SecretScanEnvVar)
+	}
+	return nil
+}
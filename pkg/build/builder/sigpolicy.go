@@ -0,0 +1,176 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/signature"
+	"github.com/pkg/errors"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	builderutil "github.com/openshift/builder/pkg/build/builder/util"
+)
+
+// SignaturePolicyPathEnvVar names the containers/image signature policy
+// file applied to image pulls; it is consumed when the storage system
+// context is assembled. It is named here so the diagnostics below can
+// explain rejections in terms of the same file.
+const SignaturePolicyPathEnvVar = "BUILD_SIGNATURE_POLICY_PATH"
+
+// SignaturePolicyDebugEnvVar enables logging of the policy decision chain:
+// before each pull, the policy scope that applies to the image and its
+// requirements are reported. This is the first question when debugging an
+// unexpected rejection, so it can be answered without reproducing the pull
+// at high log verbosity.
+const SignaturePolicyDebugEnvVar = "BUILD_SIGNATURE_POLICY_DEBUG"
+
+// StatusReasonImageRejectedByPolicy is the reason associated with a base or
+// builder image that the signature policy refused.
+const StatusReasonImageRejectedByPolicy buildapiv1.StatusReason = "ImageRejectedBySignaturePolicy"
+
+// signaturePolicyDebug reports whether decision chain logging is enabled.
+func signaturePolicyDebug() bool {
+	value := strings.TrimSpace(os.Getenv(SignaturePolicyDebugEnvVar))
+	if len(value) == 0 {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, SignaturePolicyDebugEnvVar)
+		return false
+	}
+	return enabled
+}
+
+// IsPolicyRejection reports whether an image pull failed because the
+// signature policy refused the image, rather than because of an
+// infrastructure problem.
+func IsPolicyRejection(err error) bool {
+	for err != nil {
+		if _, ok := err.(signature.PolicyRequirementError); ok {
+			return true
+		}
+		cause := errors.Cause(err)
+		if cause == err {
+			break
+		}
+		err = cause
+	}
+	return false
+}
+
+// policyFileRequirement is the subset of a policy requirement needed to
+// explain a decision. The policy file format owns more fields, but only
+// these identify which trust anchor was consulted.
+type policyFileRequirement struct {
+	Type    string `json:"type"`
+	KeyPath string `json:"keyPath,omitempty"`
+	KeyType string `json:"keyType,omitempty"`
+}
+
+// policyFile mirrors the layout of a containers/image policy file closely
+// enough to resolve which scope governs an image.
+type policyFile struct {
+	Default    []policyFileRequirement                       `json:"default"`
+	Transports map[string]map[string][]policyFileRequirement `json:"transports"`
+}
+
+// resolvePolicyScope returns the policy scope that governs the image and
+// its requirements: the most specific matching docker transport scope, or
+// the default. It mirrors the prefix matching of containers/image.
+func (p *policyFile) resolvePolicyScope(imageName string) (string, []policyFileRequirement) {
+	scopes := p.Transports["docker"]
+	best := ""
+	for scope := range scopes {
+		if !policyScopeMatches(scope, imageName) {
+			continue
+		}
+		if len(scope) > len(best) {
+			best = scope
+		}
+	}
+	if len(best) != 0 {
+		return best, scopes[best]
+	}
+	return "(default)", p.Default
+}
+
+// policyScopeMatches reports whether a docker transport scope covers the
+// image: an exact match, or a prefix ending on a path boundary.
+func policyScopeMatches(scope, imageName string) bool {
+	if scope == imageName {
+		return true
+	}
+	return strings.HasPrefix(imageName, scope+"/") || strings.HasPrefix(imageName, scope+":")
+}
+
+// describeRequirements renders requirements for the log, naming the type
+// and the trusted key of each.
+func describeRequirements(requirements []policyFileRequirement) string {
+	if len(requirements) == 0 {
+		return "no requirements (accept anything)"
+	}
+	descriptions := make([]string, 0, len(requirements))
+	for _, requirement := range requirements {
+		description := requirement.Type
+		if len(requirement.KeyPath) != 0 {
+			description = fmt.Sprintf("%s (key %s)", description, requirement.KeyPath)
+		}
+		descriptions = append(descriptions, description)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// loadPolicyFile reads the configured signature policy for diagnostics.
+func loadPolicyFile() (*policyFile, error) {
+	path := os.Getenv(SignaturePolicyPathEnvVar)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("no signature policy is configured in %s", SignaturePolicyPathEnvVar)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &policyFile{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("unable to parse the signature policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// logSignaturePolicyDecision reports which policy scope will govern a pull,
+// when decision logging is enabled.
+func logSignaturePolicyDecision(imageName string) {
+	if !signaturePolicyDebug() {
+		return
+	}
+	policy, err := loadPolicyFile()
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to resolve the signature policy decision for %s: %v", imageName, err)
+		return
+	}
+	scope, requirements := policy.resolvePolicyScope(imageName)
+	glog.V(0).Infof("Signature policy for %s: scope %s requires %s", imageName, scope, describeRequirements(requirements))
+}
+
+// classifyPullFailure refines the build status when a pull was refused by
+// the signature policy, so the failure reads as a policy decision - naming
+// the governing scope and its trust requirements - rather than a generic
+// pull error.
+func classifyPullFailure(build *buildapiv1.Build, imageName string, err error) {
+	if !IsPolicyRejection(err) {
+		return
+	}
+	build.Status.Reason = StatusReasonImageRejectedByPolicy
+	build.Status.Message = builderutil.StatusMessageImageRejectedByPolicy
+	message := fmt.Sprintf("The signature policy rejected image %s: %v", imageName, err)
+	if policy, policyErr := loadPolicyFile(); policyErr == nil {
+		scope, requirements := policy.resolvePolicyScope(imageName)
+		message = fmt.Sprintf("%s (policy scope %s requires %s)", message, scope, describeRequirements(requirements))
+	}
+	glog.V(0).Infof("error: %s", message)
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/containers/image/signature"
+	"github.com/pkg/errors"
+)
+
+func TestIsPolicyRejection(t *testing.T) {
+	rejection := signature.PolicyRequirementError("Source image rejected: None of the signatures were accepted")
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: fmt.Errorf("connection refused"), expected: false},
+		{err: rejection, expected: true},
+		{err: errors.Wrap(rejection, "error copying image"), expected: true},
+	}
+	for _, tc := range testCases {
+		if actual := IsPolicyRejection(tc.err); actual != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.expected, actual)
+		}
+	}
+}
+
+func TestResolvePolicyScope(t *testing.T) {
+	policy := &policyFile{
+		Default: []policyFileRequirement{{Type: "insecureAcceptAnything"}},
+		Transports: map[string]map[string][]policyFileRequirement{
+			"docker": {
+				"registry.example.com":             {{Type: "signedBy", KeyPath: "/etc/keys/registry.gpg"}},
+				"registry.example.com/trusted":     {{Type: "signedBy", KeyPath: "/etc/keys/trusted.gpg"}},
+				"registry.example.com/trusted/app": {{Type: "reject"}},
+			},
+		},
+	}
+	testCases := []struct {
+		image         string
+		expectedScope string
+		expectedKey   string
+	}{
+		{image: "registry.example.com/other/image:latest", expectedScope: "registry.example.com", expectedKey: "/etc/keys/registry.gpg"},
+		{image: "registry.example.com/trusted/image:v1", expectedScope: "registry.example.com/trusted", expectedKey: "/etc/keys/trusted.gpg"},
+		{image: "registry.example.com/trusted/app:v1", expectedScope: "registry.example.com/trusted/app", expectedKey: ""},
+		{image: "other.example.com/image", expectedScope: "(default)", expectedKey: ""},
+	}
+	for _, tc := range testCases {
+		scope, requirements := policy.resolvePolicyScope(tc.image)
+		if scope != tc.expectedScope {
+			t.Errorf("%s: expected scope %q, got %q", tc.image, tc.expectedScope, scope)
+			continue
+		}
+		if len(requirements) != 1 {
+			t.Errorf("%s: expected one requirement, got %v", tc.image, requirements)
+			continue
+		}
+		if requirements[0].KeyPath != tc.expectedKey {
+			t.Errorf("%s: expected key %q, got %q", tc.image, tc.expectedKey, requirements[0].KeyPath)
+		}
+	}
+}
+
+func TestDescribeRequirements(t *testing.T) {
+	testCases := []struct {
+		requirements []policyFileRequirement
+		expected     string
+	}{
+		{requirements: nil, expected: "no requirements (accept anything)"},
+		{requirements: []policyFileRequirement{{Type: "reject"}}, expected: "reject"},
+		{
+			requirements: []policyFileRequirement{{Type: "signedBy", KeyPath: "/etc/keys/a.gpg"}, {Type: "reject"}},
+			expected:     "signedBy (key /etc/keys/a.gpg), reject",
+		},
+	}
+	for _, tc := range testCases {
+		if actual := describeRequirements(tc.requirements); actual != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package builder
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	docker "github.com/fsouza/go-dockerclient"
+	godigest "github.com/opencontainers/go-digest"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// SigstoreKeyPathEnvVar points at a PEM-encoded ECDSA public key, typically
+// mounted from a secret, used to verify sigstore (cosign) signatures on the
+// base and builder images before they are used.
+const SigstoreKeyPathEnvVar = "BUILD_SIGSTORE_KEY_PATH"
+
+// SigstoreRequiredEnvVar makes verification mandatory: builds whose base
+// images carry no signature, or whose signatures do not verify against the
+// configured key, fail instead of proceeding with a warning.
+const SigstoreRequiredEnvVar = "BUILD_SIGSTORE_REQUIRED"
+
+// SigstoreVerificationAnnotation records, as a JSON object, the verification
+// outcome for each checked image: "verified", or the reason it was not.
+const SigstoreVerificationAnnotation = "build.openshift.io/sigstore-verification"
+
+// StatusReasonBaseImageSignatureUnverified is the reason associated with a
+// build that used a base image whose sigstore signature could not be
+// verified while verification was required.
+const StatusReasonBaseImageSignatureUnverified buildapiv1.StatusReason = "BaseImageSignatureUnverified"
+
+// sigstoreSignatureAnnotation is the layer annotation cosign stores the
+// base64-encoded signature under in the signature artifact.
+const sigstoreSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// sigstoreVerification returns the configured verification key path (empty
+// when verification is disabled) and whether verification is mandatory.
+func sigstoreVerification() (string, bool) {
+	keyPath := strings.TrimSpace(os.Getenv(SigstoreKeyPathEnvVar))
+	if len(keyPath) == 0 {
+		return "", false
+	}
+	required := false
+	if value := os.Getenv(SigstoreRequiredEnvVar); len(value) != 0 {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, SigstoreRequiredEnvVar)
+		} else {
+			required = parsed
+		}
+	}
+	return keyPath, required
+}
+
+// loadSigstorePublicKey reads a PEM-encoded ECDSA public key.
+func loadSigstorePublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the public key in %s: %v", path, err)
+	}
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the key in %s is %T, not an ECDSA public key", path, key)
+	}
+	return publicKey, nil
+}
+
+// sigstorePayload is the simple signing document cosign signs: the digest it
+// names must be the manifest digest of the image being verified.
+type sigstorePayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// sigstoreArtifact is the subset of the signature artifact manifest needed
+// to locate the signed payloads and their signatures.
+type sigstoreArtifact struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// VerifySigstoreSignatures checks the sigstore signature of each image
+// against the configured key and records the outcomes on the build. When
+// verification is required, the first image that fails to verify fails the
+// call; otherwise failures are logged and the build proceeds.
+func VerifySigstoreSignatures(ctx context.Context, build *buildapiv1.Build, imageNames []string) error {
+	keyPath, required := sigstoreVerification()
+	if len(keyPath) == 0 {
+		return nil
+	}
+	publicKey, err := loadSigstorePublicKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("unable to load the sigstore verification key: %v", err)
+	}
+	results := map[string]string{}
+	var firstFailure error
+	for _, imageName := range imageNames {
+		if imageName == "scratch" {
+			continue
+		}
+		if err := verifySigstoreImage(ctx, publicKey, imageName); err != nil {
+			results[imageName] = err.Error()
+			if required && firstFailure == nil {
+				firstFailure = fmt.Errorf("signature verification of image %s failed: %v", imageName, err)
+			}
+			glog.V(0).Infof("warning: Signature verification of image %s failed: %v", imageName, err)
+			continue
+		}
+		results[imageName] = "verified"
+		glog.V(0).Infof("Verified the sigstore signature of image %s", imageName)
+	}
+	recordSigstoreVerification(build, results)
+	return firstFailure
+}
+
+// recordSigstoreVerification publishes the verification outcomes on the
+// build, so they are visible even when verification is only advisory.
+func recordSigstoreVerification(build *buildapiv1.Build, results map[string]string) {
+	if len(results) == 0 {
+		return
+	}
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to encode signature verification results: %v", err)
+		return
+	}
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[SigstoreVerificationAnnotation] = string(resultsJSON)
+}
+
+// verifySigstoreImage fetches the cosign signature artifact stored next to
+// the image and verifies at least one of its signatures against the key.
+func verifySigstoreImage(ctx context.Context, publicKey *ecdsa.PublicKey, imageName string) error {
+	systemContext := &types.SystemContext{AuthFilePath: "/tmp/config.json"}
+	ref, err := alltransports.ParseImageName("docker://" + imageName)
+	if err != nil {
+		return fmt.Errorf("error parsing image name: %v", err)
+	}
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return fmt.Errorf("error contacting the registry: %v", err)
+	}
+	defer src.Close()
+	manifestBytes, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error fetching the image manifest: %v", err)
+	}
+	imageDigest, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		return fmt.Errorf("error digesting the image manifest: %v", err)
+	}
+
+	sigRef, err := alltransports.ParseImageName("docker://" + sigstoreSignatureName(imageName, imageDigest.String()))
+	if err != nil {
+		return fmt.Errorf("error parsing the signature artifact name: %v", err)
+	}
+	sigSrc, err := sigRef.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return fmt.Errorf("error contacting the registry for the signature artifact: %v", err)
+	}
+	defer sigSrc.Close()
+	artifactBytes, _, err := sigSrc.GetManifest(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("the image is unsigned (no signature artifact for digest %s)", imageDigest)
+	}
+	artifact := &sigstoreArtifact{}
+	if err := json.Unmarshal(artifactBytes, artifact); err != nil {
+		return fmt.Errorf("error parsing the signature artifact: %v", err)
+	}
+
+	verified := 0
+	for _, layer := range artifact.Layers {
+		encodedSignature, ok := layer.Annotations[sigstoreSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		payload, err := readSigstorePayload(ctx, sigSrc, layer.Digest, layer.Size)
+		if err != nil {
+			glog.V(0).Infof("warning: Unable to read a signature payload for %s: %v", imageName, err)
+			continue
+		}
+		if err := verifySigstorePayload(publicKey, payload, encodedSignature, imageDigest.String()); err != nil {
+			glog.V(2).Infof("A signature on %s did not verify: %v", imageName, err)
+			continue
+		}
+		verified++
+	}
+	if verified == 0 {
+		return fmt.Errorf("no signature on the image verified against the configured key")
+	}
+	return nil
+}
+
+// sigstoreSignatureName returns the reference cosign stores the signature
+// artifact for the given image digest under.
+func sigstoreSignatureName(imageName, imageDigest string) string {
+	if i := strings.Index(imageName, "@"); i >= 0 {
+		imageName = imageName[:i]
+	}
+	repository, _ := docker.ParseRepositoryTag(imageName)
+	return repository + ":" + strings.Replace(imageDigest, ":", "-", 1) + ".sig"
+}
+
+// readSigstorePayload fetches one signed payload blob from the signature
+// artifact.
+func readSigstorePayload(ctx context.Context, src types.ImageSource, layerDigest string, size int64) ([]byte, error) {
+	reader, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: godigest.Digest(layerDigest), Size: size})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// verifySigstorePayload checks one signature: the ECDSA signature must cover
+// the payload, and the payload must name the manifest digest of the image
+// being verified, so a signature cannot be replayed from another image.
+func verifySigstorePayload(publicKey *ecdsa.PublicKey, payload []byte, encodedSignature, imageDigest string) error {
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return fmt.Errorf("unable to decode the signature: %v", err)
+	}
+	hashed := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(publicKey, hashed[:], signature) {
+		return fmt.Errorf("the signature does not verify against the key")
+	}
+	signed := &sigstorePayload{}
+	if err := json.Unmarshal(payload, signed); err != nil {
+		return fmt.Errorf("unable to parse the signed payload: %v", err)
+	}
+	if signed.Critical.Image.DockerManifestDigest != imageDigest {
+		return fmt.Errorf("the signature covers digest %s, not %s", signed.Critical.Image.DockerManifestDigest, imageDigest)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"testing"
+)
+
+func TestSigstoreVerificationFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		keyPath          string
+		required         string
+		expectedKeyPath  string
+		expectedRequired bool
+	}{
+		{keyPath: "", required: "", expectedKeyPath: "", expectedRequired: false},
+		{keyPath: "/etc/sigstore/key.pub", required: "", expectedKeyPath: "/etc/sigstore/key.pub", expectedRequired: false},
+		{keyPath: "/etc/sigstore/key.pub", required: "true", expectedKeyPath: "/etc/sigstore/key.pub", expectedRequired: true},
+		{keyPath: "/etc/sigstore/key.pub", required: "not-a-bool", expectedKeyPath: "/etc/sigstore/key.pub", expectedRequired: false},
+	}
+	for _, tc := range testCases {
+		os.Setenv(SigstoreKeyPathEnvVar, tc.keyPath)
+		os.Setenv(SigstoreRequiredEnvVar, tc.required)
+		keyPath, required := sigstoreVerification()
+		if keyPath != tc.expectedKeyPath || required != tc.expectedRequired {
+			t.Errorf("%q/%q: expected %q/%v, got %q/%v", tc.keyPath, tc.required, tc.expectedKeyPath, tc.expectedRequired, keyPath, required)
+		}
+	}
+	os.Unsetenv(SigstoreKeyPathEnvVar)
+	os.Unsetenv(SigstoreRequiredEnvVar)
+}
+
+func TestSigstoreSignatureName(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{image: "registry.example.com/app/image:v1", expected: "registry.example.com/app/image:sha256-0123.sig"},
+		{image: "registry.example.com/app/image", expected: "registry.example.com/app/image:sha256-0123.sig"},
+		{image: "registry.example.com/app/image@sha256:0123", expected: "registry.example.com/app/image:sha256-0123.sig"},
+	}
+	for _, tc := range testCases {
+		if actual := sigstoreSignatureName(tc.image, "sha256:0123"); actual != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.image, tc.expected, actual)
+		}
+	}
+}
+
+func TestVerifySigstorePayload(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageDigest := "sha256:0123456789abcdef"
+	payload := []byte(`{"critical":{"image":{"docker-manifest-digest":"` + imageDigest + `"}}}`)
+	hashed := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(signature)
+
+	if err := verifySigstorePayload(&key.PublicKey, payload, encoded, imageDigest); err != nil {
+		t.Errorf("expected the signature to verify, got %v", err)
+	}
+	if err := verifySigstorePayload(&key.PublicKey, payload, encoded, "sha256:other"); err == nil {
+		t.Errorf("expected a digest mismatch to be rejected")
+	}
+	if err := verifySigstorePayload(&key.PublicKey, append(payload, '\n'), encoded, imageDigest); err == nil {
+		t.Errorf("expected a modified payload to be rejected")
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySigstorePayload(&otherKey.PublicKey, payload, encoded, imageDigest); err == nil {
+		t.Errorf("expected verification with the wrong key to fail")
+	}
+}
@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+)
+
+// InputMaxBytesEnvVar is the name of the environment variable that caps the
+// total size of extracted build input content, expressed with an optional
+// unit suffix ("2g"). It protects the build pod against tar bombs and
+// runaway uploads exhausting its disk.
+const InputMaxBytesEnvVar = "BUILD_INPUT_MAX_BYTES"
+
+// InputMaxFilesEnvVar is the name of the environment variable that caps the
+// number of files extracted from the build input content.
+const InputMaxFilesEnvVar = "BUILD_INPUT_MAX_FILES"
+
+// inputSizeLimitsFromEnvironment returns the configured limits on extracted
+// build input content. A zero value means the corresponding limit is not
+// set.
+func inputSizeLimitsFromEnvironment() (maxBytes int64, maxFiles int64, err error) {
+	if value := strings.TrimSpace(os.Getenv(InputMaxBytesEnvVar)); len(value) != 0 {
+		maxBytes, err = units.RAMInBytes(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid value %q for %s: %v", value, InputMaxBytesEnvVar, err)
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(InputMaxFilesEnvVar)); len(value) != 0 {
+		maxFiles, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid value %q for %s: %v", value, InputMaxFilesEnvVar, err)
+		}
+	}
+	return maxBytes, maxFiles, nil
+}
+
+// limitedInputReader fails a read once more than the configured number of
+// bytes has streamed through it. It bounds the raw upload before anything
+// is extracted, so a runaway stream is stopped early.
+type limitedInputReader struct {
+	in        io.Reader
+	limit     int64
+	remaining int64
+}
+
+func newLimitedInputReader(in io.Reader, maxBytes int64) *limitedInputReader {
+	return &limitedInputReader{in: in, limit: maxBytes, remaining: maxBytes}
+}
+
+func (r *limitedInputReader) Read(p []byte) (int, error) {
+	n, err := r.in.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, fmt.Errorf("the binary build input exceeds the limit of %s set by %s", units.BytesSize(float64(r.limit)), InputMaxBytesEnvVar)
+	}
+	return n, err
+}
+
+// checkExtractedContentSize walks the extracted build content and fails if
+// it exceeds the configured size or file count limits.
+func checkExtractedContentSize(dir string, maxBytes, maxFiles int64) error {
+	if maxBytes == 0 && maxFiles == 0 {
+		return nil
+	}
+	var totalBytes, totalFiles int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		totalFiles++
+		if info.Mode().IsRegular() {
+			totalBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && totalBytes > maxBytes {
+		return fmt.Errorf("the extracted build input totals %s, exceeding the limit of %s set by %s", units.BytesSize(float64(totalBytes)), units.BytesSize(float64(maxBytes)), InputMaxBytesEnvVar)
+	}
+	if maxFiles > 0 && totalFiles > maxFiles {
+		return fmt.Errorf("the extracted build input contains %d files, exceeding the limit of %d set by %s", totalFiles, maxFiles, InputMaxFilesEnvVar)
+	}
+	return nil
+}
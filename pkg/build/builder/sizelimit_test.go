@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInputSizeLimitsFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		bytesValue string
+		filesValue string
+		maxBytes   int64
+		maxFiles   int64
+		expectErr  bool
+	}{
+		{bytesValue: "", filesValue: ""},
+		{bytesValue: "2g", filesValue: "", maxBytes: 2 * 1024 * 1024 * 1024},
+		{bytesValue: "", filesValue: "10000", maxFiles: 10000},
+		{bytesValue: "1048576", filesValue: "5", maxBytes: 1048576, maxFiles: 5},
+		{bytesValue: "lots", filesValue: "", expectErr: true},
+		{bytesValue: "", filesValue: "many", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(InputMaxBytesEnvVar, tc.bytesValue)
+		os.Setenv(InputMaxFilesEnvVar, tc.filesValue)
+		maxBytes, maxFiles, err := inputSizeLimitsFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q/%q: expected error %v, got %v", tc.bytesValue, tc.filesValue, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && (maxBytes != tc.maxBytes || maxFiles != tc.maxFiles) {
+			t.Errorf("%q/%q: expected (%d, %d), got (%d, %d)", tc.bytesValue, tc.filesValue, tc.maxBytes, tc.maxFiles, maxBytes, maxFiles)
+		}
+	}
+	os.Unsetenv(InputMaxBytesEnvVar)
+	os.Unsetenv(InputMaxFilesEnvVar)
+}
+
+func TestLimitedInputReader(t *testing.T) {
+	content := bytes.Repeat([]byte{'a'}, 1024)
+
+	in := newLimitedInputReader(bytes.NewBuffer(content), 2048)
+	if _, err := io.Copy(ioutil.Discard, in); err != nil {
+		t.Errorf("expected a stream within the limit to succeed, got %v", err)
+	}
+
+	in = newLimitedInputReader(bytes.NewBuffer(content), 512)
+	if _, err := io.Copy(ioutil.Discard, in); err == nil {
+		t.Errorf("expected a stream over the limit to fail")
+	}
+}
+
+func TestCheckExtractedContentSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sizelimit")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, name := range []string{"one", "two", filepath.Join("sub", "three")} {
+		content := bytes.Repeat([]byte{'x'}, 100*(i+1))
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		maxBytes  int64
+		maxFiles  int64
+		expectErr bool
+	}{
+		{name: "no limits"},
+		{name: "within limits", maxBytes: 1000, maxFiles: 10},
+		{name: "too large", maxBytes: 500, expectErr: true},
+		{name: "too many files", maxFiles: 2, expectErr: true},
+	}
+	for _, tc := range testCases {
+		err := checkExtractedContentSize(dir, tc.maxBytes, tc.maxFiles)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("[%s] expected error %v, got %v", tc.name, tc.expectErr, err)
+		}
+	}
+}
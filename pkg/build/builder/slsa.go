@@ -0,0 +1,192 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// ProvenanceFileEnvVar names a file the SLSA provenance document is written
+// to after a successful build, typically on a mounted volume a sidecar or a
+// later pipeline step picks up for signing and attachment.
+const ProvenanceFileEnvVar = "BUILD_PROVENANCE_FILE"
+
+// ProvenanceUploadURLEnvVar names an endpoint the provenance document is
+// POSTed to, for clusters that collect attestations centrally. Attaching the
+// document as an OCI referrer is not possible with the vendored image
+// libraries, which predate the referrers API; the upload endpoint is the
+// supported delivery path.
+const ProvenanceUploadURLEnvVar = "BUILD_PROVENANCE_UPLOAD_URL"
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaPredicateType   = "https://slsa.dev/provenance/v1"
+	openshiftBuildType  = "https://openshift.io/build/v1"
+	openshiftBuilderID  = "https://openshift.io/builder"
+)
+
+// provenanceSubject names one produced artifact.
+type provenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// provenanceDependency is one resolved input: the source repository or a
+// base image.
+type provenanceDependency struct {
+	Name   string            `json:"name"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// provenanceStatement is a SLSA v1 provenance in an in-toto envelope.
+type provenanceStatement struct {
+	Type          string              `json:"_type"`
+	Subject       []provenanceSubject `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     struct {
+		BuildDefinition struct {
+			BuildType            string                 `json:"buildType"`
+			ExternalParameters   map[string]interface{} `json:"externalParameters"`
+			ResolvedDependencies []provenanceDependency `json:"resolvedDependencies,omitempty"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+			Metadata struct {
+				InvocationID string `json:"invocationId,omitempty"`
+				StartedOn    string `json:"startedOn,omitempty"`
+				FinishedOn   string `json:"finishedOn,omitempty"`
+			} `json:"metadata"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+// generateProvenance assembles the SLSA statement from what the build
+// recorded: the pushed image digest, the resolved source revision, and the
+// pinned base image digests.
+func generateProvenance(build *buildapiv1.Build) *provenanceStatement {
+	statement := &provenanceStatement{
+		Type:          inTotoStatementType,
+		PredicateType: slsaPredicateType,
+	}
+
+	subject := provenanceSubject{Name: build.Status.OutputDockerImageReference}
+	if to := build.Status.Output.To; to != nil && len(to.ImageDigest) != 0 {
+		if parts := strings.SplitN(to.ImageDigest, ":", 2); len(parts) == 2 {
+			subject.Digest = map[string]string{parts[0]: parts[1]}
+		}
+	}
+	statement.Subject = []provenanceSubject{subject}
+
+	parameters := map[string]interface{}{
+		"strategy": strategyName(build),
+	}
+	if git := build.Spec.Source.Git; git != nil {
+		parameters["sourceURI"] = git.URI
+		if len(git.Ref) != 0 {
+			parameters["sourceRef"] = git.Ref
+		}
+	}
+	if len(build.Spec.Source.ContextDir) != 0 {
+		parameters["contextDir"] = build.Spec.Source.ContextDir
+	}
+	statement.Predicate.BuildDefinition.BuildType = openshiftBuildType
+	statement.Predicate.BuildDefinition.ExternalParameters = parameters
+	statement.Predicate.BuildDefinition.ResolvedDependencies = provenanceDependencies(build)
+
+	statement.Predicate.RunDetails.Builder.ID = openshiftBuilderID
+	statement.Predicate.RunDetails.Metadata.InvocationID = string(build.UID)
+	if build.Status.StartTimestamp != nil {
+		statement.Predicate.RunDetails.Metadata.StartedOn = build.Status.StartTimestamp.UTC().Format(time.RFC3339)
+	}
+	statement.Predicate.RunDetails.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+
+	return statement
+}
+
+// provenanceDependencies lists the resolved inputs: the source commit the
+// clone recorded, and the base images with the digests they were pinned to.
+func provenanceDependencies(build *buildapiv1.Build) []provenanceDependency {
+	var dependencies []provenanceDependency
+	if commit := resolvedCommitFor(build); len(commit) != 0 {
+		source := provenanceDependency{
+			Name:   "source",
+			Digest: map[string]string{"gitCommit": commit},
+		}
+		if git := build.Spec.Source.Git; git != nil {
+			source.URI = "git+" + git.URI
+		}
+		dependencies = append(dependencies, source)
+	}
+	if annotation, ok := build.Annotations[BaseImageDigestsAnnotation]; ok {
+		pinned := map[string]string{}
+		if err := json.Unmarshal([]byte(annotation), &pinned); err == nil {
+			for name, digested := range pinned {
+				dependency := provenanceDependency{Name: name, URI: digested}
+				if i := strings.LastIndex(digested, "@"); i >= 0 {
+					if parts := strings.SplitN(digested[i+1:], ":", 2); len(parts) == 2 {
+						dependency.Digest = map[string]string{parts[0]: parts[1]}
+					}
+				}
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies
+}
+
+// strategyName names the strategy that ran, for the provenance parameters.
+func strategyName(build *buildapiv1.Build) string {
+	switch {
+	case build.Spec.Strategy.DockerStrategy != nil:
+		return "Docker"
+	case build.Spec.Strategy.SourceStrategy != nil:
+		return "Source"
+	case build.Spec.Strategy.CustomStrategy != nil:
+		return "Custom"
+	}
+	return "Unknown"
+}
+
+// EmitProvenance writes and/or uploads the provenance document per the
+// configuration; when neither delivery is configured it does nothing.
+func EmitProvenance(build *buildapiv1.Build) error {
+	file := os.Getenv(ProvenanceFileEnvVar)
+	uploadURL := os.Getenv(ProvenanceUploadURLEnvVar)
+	if len(file) == 0 && len(uploadURL) == 0 {
+		return nil
+	}
+	statement := generateProvenance(build)
+	statementJSON, err := json.Marshal(statement)
+	if err != nil {
+		return fmt.Errorf("unable to encode the provenance statement: %v", err)
+	}
+	if len(file) != 0 {
+		if err := ioutil.WriteFile(file, statementJSON, 0644); err != nil {
+			return fmt.Errorf("unable to write the provenance statement: %v", err)
+		}
+		glog.V(0).Infof("Wrote the SLSA provenance statement to %s", file)
+	}
+	if len(uploadURL) != 0 {
+		response, err := http.Post(uploadURL, "application/json", bytes.NewReader(statementJSON))
+		if err != nil {
+			return fmt.Errorf("unable to upload the provenance statement: %v", err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode >= 300 {
+			body, _ := ioutil.ReadAll(response.Body)
+			return fmt.Errorf("the provenance endpoint returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+		}
+		glog.V(0).Infof("Uploaded the SLSA provenance statement to %s", uploadURL)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func provenanceBuild() *buildapiv1.Build {
+	build := &buildapiv1.Build{}
+	build.Namespace = "ns"
+	build.Name = "build-1"
+	build.UID = "build-uid"
+	build.Annotations = map[string]string{
+		BaseImageDigestsAnnotation: `{"registry.example.com/base:latest": "registry.example.com/base@sha256:abcd"}`,
+	}
+	build.Spec.Strategy.DockerStrategy = &buildapiv1.DockerBuildStrategy{}
+	build.Spec.Source.Git = &buildapiv1.GitBuildSource{URI: "https://my.host/owner/repo.git", Ref: "main"}
+	build.Spec.Revision = &buildapiv1.SourceRevision{
+		Git: &buildapiv1.GitSourceRevision{Commit: "0123456789abcdef"},
+	}
+	build.Status.OutputDockerImageReference = "registry.example.com/ns/app:latest"
+	build.Status.Output.To = &buildapiv1.BuildStatusOutputTo{ImageDigest: "sha256:ffff"}
+	return build
+}
+
+func TestGenerateProvenance(t *testing.T) {
+	statement := generateProvenance(provenanceBuild())
+	if statement.Type != inTotoStatementType || statement.PredicateType != slsaPredicateType {
+		t.Errorf("unexpected envelope types %q/%q", statement.Type, statement.PredicateType)
+	}
+	if len(statement.Subject) != 1 || statement.Subject[0].Digest["sha256"] != "ffff" {
+		t.Errorf("unexpected subject %+v", statement.Subject)
+	}
+	parameters := statement.Predicate.BuildDefinition.ExternalParameters
+	if parameters["strategy"] != "Docker" || parameters["sourceURI"] != "https://my.host/owner/repo.git" {
+		t.Errorf("unexpected parameters %v", parameters)
+	}
+	dependencies := statement.Predicate.BuildDefinition.ResolvedDependencies
+	if len(dependencies) != 2 {
+		t.Fatalf("expected the source and one base image, got %+v", dependencies)
+	}
+	if dependencies[0].Name != "source" || dependencies[0].Digest["gitCommit"] != "0123456789abcdef" {
+		t.Errorf("unexpected source dependency %+v", dependencies[0])
+	}
+	if dependencies[1].Digest["sha256"] != "abcd" {
+		t.Errorf("unexpected base image dependency %+v", dependencies[1])
+	}
+	if statement.Predicate.RunDetails.Metadata.InvocationID != "build-uid" {
+		t.Errorf("unexpected invocation ID %q", statement.Predicate.RunDetails.Metadata.InvocationID)
+	}
+}
+
+func TestEmitProvenance(t *testing.T) {
+	var uploaded provenanceStatement
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&uploaded)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "provenance.json")
+	os.Setenv(ProvenanceFileEnvVar, file)
+	os.Setenv(ProvenanceUploadURLEnvVar, server.URL)
+	defer os.Unsetenv(ProvenanceFileEnvVar)
+	defer os.Unsetenv(ProvenanceUploadURLEnvVar)
+
+	if err := EmitProvenance(provenanceBuild()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected the provenance file to be written: %v", err)
+	}
+	onDisk := provenanceStatement{}
+	if err := json.Unmarshal(written, &onDisk); err != nil {
+		t.Fatalf("the written provenance is not valid JSON: %v", err)
+	}
+	if uploaded.Type != inTotoStatementType {
+		t.Errorf("expected the statement to be uploaded, got %+v", uploaded)
+	}
+}
+
+func TestEmitProvenanceDisabled(t *testing.T) {
+	os.Unsetenv(ProvenanceFileEnvVar)
+	os.Unsetenv(ProvenanceUploadURLEnvVar)
+	if err := EmitProvenance(provenanceBuild()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
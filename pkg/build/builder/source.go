@@ -1,7 +1,10 @@
 package builder
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -28,6 +31,7 @@ import (
 	buildapiv1 "github.com/openshift/api/build/v1"
 	"github.com/openshift/builder/pkg/build/builder/cmd/dockercfg"
 	"github.com/openshift/builder/pkg/build/builder/timing"
+	"github.com/openshift/builder/pkg/imagereference"
 	"github.com/openshift/library-go/pkg/git"
 	"github.com/openshift/source-to-image/pkg/tar"
 
@@ -44,6 +48,11 @@ const (
 	// timeoutIncrementFactor is the factor to use when increasing
 	// the timeout after each unsuccessful try
 	timeoutIncrementFactor = 4
+
+	// PreserveImageSourceOwnershipEnvVar, when set to true, makes image
+	// source extraction preserve ownership, permissions, and timestamps of
+	// the copied content instead of the extracting user's defaults.
+	PreserveImageSourceOwnershipEnvVar = "BUILD_IMAGE_SOURCE_PRESERVE_OWNERSHIP"
 )
 
 type gitAuthError string
@@ -122,15 +131,62 @@ func ManageDockerfile(dir string, build *buildapiv1.Build) error {
 	// We only mutate the dockerfile if this is a docker strategy build, otherwise
 	// we leave it as it was provided.
 	if build.Spec.Strategy.DockerStrategy != nil {
+		if err := relocateDockerfile(dir, build); err != nil {
+			return err
+		}
+		if target := dockerfileTargetFromEnvironment(); len(target) != 0 {
+			if err := applyDockerfileTarget(getDockerfilePath(dir, build), target); err != nil {
+				return err
+			}
+		}
 		sourceInfo, err := readSourceInfo()
 		if err != nil {
 			return fmt.Errorf("error reading git source info: %v", err)
 		}
-		return addBuildParameters(dir, build, sourceInfo)
+		if err := addBuildParameters(dir, build, sourceInfo); err != nil {
+			return err
+		}
+		warnDeprecatedDockerfile(getDockerfilePath(dir, build))
+		return nil
 	}
 	return nil
 }
 
+// relocateDockerfile handles a dockerfilePath which points outside the
+// context directory (e.g. build/Dockerfile.prod at the repository root with
+// contextDir=services/api). The container engine requires the Dockerfile to
+// live under the build context, so the file is copied into the context
+// directory and the strategy path is updated to the copy. Paths escaping the
+// input sources entirely are rejected.
+func relocateDockerfile(dir string, build *buildapiv1.Build) error {
+	strategy := build.Spec.Strategy.DockerStrategy
+	if strategy == nil || len(strategy.DockerfilePath) == 0 {
+		return nil
+	}
+	contextDir := filepath.Join(dir, build.Spec.Source.ContextDir)
+	dockerfilePath := filepath.Join(contextDir, strategy.DockerfilePath)
+	if relative, err := filepath.Rel(contextDir, dockerfilePath); err == nil && !strings.HasPrefix(relative, "..") {
+		// Already inside the context directory.
+		return nil
+	}
+	if relative, err := filepath.Rel(dir, dockerfilePath); err != nil || strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("dockerfilePath %q points outside of the input sources", strategy.DockerfilePath)
+	}
+
+	in, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return fmt.Errorf("error reading dockerfile %s: %v", strategy.DockerfilePath, err)
+	}
+	// Use a name unlikely to collide with files the context already contains.
+	relocated := ".relocated-" + filepath.Base(dockerfilePath)
+	if err := ioutil.WriteFile(filepath.Join(contextDir, relocated), in, 0660); err != nil {
+		return err
+	}
+	glog.V(2).Infof("Copied dockerfile %s into the context directory as %s", strategy.DockerfilePath, relocated)
+	strategy.DockerfilePath = relocated
+	return nil
+}
+
 func ExtractImageContent(ctx context.Context, dockerClient DockerClient, store storage.Store, dir string, build *buildapiv1.Build) error {
 	os.MkdirAll(dir, 0777)
 	forcePull := false
@@ -214,13 +270,61 @@ func checkSourceURI(gitClient GitClient, rawurl string, timeout time.Duration) e
 }
 
 // ExtractInputBinary processes the provided input stream as directed by BinaryBuildSource
-// into dir.
+// into dir. When a checksum is declared in the environment, the stream is
+// verified against it while it is consumed.
 func ExtractInputBinary(in io.Reader, source *buildapiv1.BinaryBuildSource, dir string) error {
 	os.MkdirAll(dir, 0777)
 	if source == nil {
 		return nil
 	}
 
+	maxBytes, maxFiles, err := inputSizeLimitsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 {
+		in = newLimitedInputReader(in, maxBytes)
+	}
+
+	expected, err := inputChecksumFromEnvironment()
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if len(expected) != 0 {
+		in = io.TeeReader(in, hash)
+	}
+
+	if err := extractBinaryInput(in, source, dir); err != nil {
+		return err
+	}
+
+	if err := sanitizeExtractedContent(dir, false); err != nil {
+		return err
+	}
+
+	if err := checkExtractedContentSize(dir, maxBytes, maxFiles); err != nil {
+		return err
+	}
+
+	if len(expected) != 0 {
+		// The extractor may leave trailing padding unread; the declared
+		// digest covers the whole stream.
+		if _, err := io.Copy(ioutil.Discard, in); err != nil {
+			return err
+		}
+		actual := hex.EncodeToString(hash.Sum(nil))
+		if actual != expected {
+			return &inputChecksumMismatchError{expected: expected, actual: actual}
+		}
+		glog.V(2).Infof("Verified the binary build input digest sha256:%s", actual)
+	}
+	return nil
+}
+
+// extractBinaryInput unpacks the binary build input stream into dir, either
+// as a single file or as an archive.
+func extractBinaryInput(in io.Reader, source *buildapiv1.BinaryBuildSource, dir string) error {
 	var path string
 	if len(source.AsFile) > 0 {
 		glog.V(0).Infof("Receiving source from STDIN as file %s", source.AsFile)
@@ -231,7 +335,7 @@ func ExtractInputBinary(in io.Reader, source *buildapiv1.BinaryBuildSource, dir
 			return err
 		}
 		defer f.Close()
-		n, err := io.Copy(f, os.Stdin)
+		n, err := io.Copy(f, in)
 		if err != nil {
 			return err
 		}
@@ -239,8 +343,24 @@ func ExtractInputBinary(in io.Reader, source *buildapiv1.BinaryBuildSource, dir
 		return nil
 	}
 
+	buffered := bufio.NewReader(in)
+	if isDeltaContext(buffered) {
+		return extractDeltaContext(buffered, dir)
+	}
+
 	glog.V(0).Infof("Receiving source from STDIN as archive ...")
 
+	if command := decompressionCommand(buffered); command != nil {
+		glog.V(2).Infof("Decompressing the binary build input with %s", command[0])
+		return decompressArchive(buffered, dir, command)
+	}
+
+	return extractArchive(buffered, dir)
+}
+
+// extractArchive unpacks an archive stream in any format bsdtar understands
+// into dir.
+func extractArchive(in io.Reader, dir string) error {
 	cmd := exec.Command("bsdtar", "-x", "-o", "-m", "-f", "-", "-C", dir)
 	cmd.Stdin = in
 	out, err := cmd.CombinedOutput()
@@ -259,8 +379,19 @@ func extractGitSource(ctx context.Context, gitClient GitClient, gitSource *build
 
 	glog.V(0).Infof("Cloning %q ...", gitSource.URI)
 
-	// Check source URI by trying to connect to the server
-	if err := checkSourceURI(gitClient, gitSource.URI, timeout); err != nil {
+	cloneURI := gitSource.URI
+	if bundle := gitBundlePath(); len(bundle) != 0 {
+		glog.V(0).Infof("Cloning from the local bundle %s instead of the remote", bundle)
+		cloneURI = bundle
+	} else {
+		// Check source URI by trying to connect to the server
+		if err := checkSourceURI(gitClient, gitSource.URI, timeout); err != nil {
+			return true, err
+		}
+	}
+
+	submoduleDepth, err := submoduleDepthFromEnvironment()
+	if err != nil {
 		return true, err
 	}
 
@@ -271,7 +402,12 @@ func extractGitSource(ctx context.Context, gitClient GitClient, gitSource *build
 	// check if we specify a commit, ref, or branch to check out
 	// Recursive clone if we're not going to checkout a ref and submodule update later
 	if !usingRef {
-		cloneOptions = append(cloneOptions, "--recursive")
+		if !skipSubmodules() {
+			cloneOptions = append(cloneOptions, "--recursive")
+			if submoduleDepth > 0 {
+				cloneOptions = append(cloneOptions, "--shallow-submodules")
+			}
+		}
 		cloneOptions = append(cloneOptions, git.Shallow)
 	}
 
@@ -281,8 +417,11 @@ func extractGitSource(ctx context.Context, gitClient GitClient, gitSource *build
 	if !glog.Is(5) {
 		cloneOptions = append(cloneOptions, "--quiet")
 	}
+	if cloneURI == gitSource.URI {
+		cloneOptions = append(cloneOptions, gitMirrorOptions()...)
+	}
 	startTime := metav1.Now()
-	if err := gitClient.CloneWithOptions(dir, gitSource.URI, cloneOptions...); err != nil {
+	if err := cloneWithRetries(ctx, gitClient, dir, cloneURI, cloneOptions); err != nil {
 		return true, err
 	}
 
@@ -304,8 +443,17 @@ func extractGitSource(ctx context.Context, gitClient GitClient, gitSource *build
 		}
 
 		// Recursively update --init
-		if err := gitClient.SubmoduleUpdate(dir, true, true); err != nil {
-			return true, err
+		switch {
+		case skipSubmodules():
+			glog.V(2).Infof("Skipping submodule update: %s=true", SkipSubmodulesEnvVar)
+		case submoduleDepth > 0:
+			if err := updateSubmodulesWithDepth(dir, submoduleDepth); err != nil {
+				return true, err
+			}
+		default:
+			if err := gitClient.SubmoduleUpdate(dir, true, true); err != nil {
+				return true, err
+			}
 		}
 	}
 
@@ -368,7 +516,7 @@ func copyImageSource(dockerClient DockerClient, containerID, sourceDir, destDir
 	return tarHelper.ExtractTarStreamWithLogging(destDir, file, tarOutput)
 }
 
-func copyImageSourceFromFilesytem(sourceDir, destDir string) error {
+func copyImageSourceFromFilesytem(sourceDir, destDir string, preserveOwnership bool) error {
 	// Setup destination directory
 	fi, err := os.Stat(destDir)
 	if err != nil {
@@ -386,7 +534,11 @@ func copyImageSourceFromFilesytem(sourceDir, destDir string) error {
 		}
 	}
 
-	out, err := exec.Command("cp", "-vr", sourceDir, destDir).CombinedOutput()
+	args := []string{"-vr"}
+	if preserveOwnership {
+		args = append(args, "--preserve=all")
+	}
+	out, err := exec.Command("cp", append(args, sourceDir, destDir)...).CombinedOutput()
 	if err != nil {
 		return err
 	}
@@ -398,7 +550,9 @@ func extractSourceFromImage(ctx context.Context, dockerClient DockerClient, stor
 	glog.V(4).Infof("Extracting image source from image %s", image)
 
 	pullPolicy := buildah.PullIfMissing
-	if forcePull {
+	// Content pinned by digest is immutable, so a forced re-pull would only
+	// waste bandwidth.
+	if forcePull && !imagereference.IsDigested(image) {
 		pullPolicy = buildah.PullAlways
 	}
 
@@ -464,13 +618,46 @@ func extractSourceFromImage(ctx context.Context, dockerClient DockerClient, stor
 		return fmt.Errorf("error mounting image content from image %s: %v", image, err)
 	}
 
+	preserveOwnership := strings.EqualFold(os.Getenv(PreserveImageSourceOwnershipEnvVar), "true")
 	for _, path := range paths {
-		glog.V(4).Infof("Extracting path %s from image %s to %s", filepath.Join(mountPath, path.SourcePath), image, path.DestinationDir)
-		err := copyImageSourceFromFilesytem(filepath.Join(mountPath, path.SourcePath), filepath.Join(buildDir, path.DestinationDir))
+		sources, err := expandImageSourcePath(mountPath, path.SourcePath)
 		if err != nil {
-			return fmt.Errorf("error copying source path %s to %s: %v", path.SourcePath, path.DestinationDir, err)
+			return err
+		}
+		for _, source := range sources {
+			glog.V(4).Infof("Extracting path %s from image %s to %s", source, image, path.DestinationDir)
+			err := copyImageSourceFromFilesytem(source, filepath.Join(buildDir, path.DestinationDir), preserveOwnership)
+			if err != nil {
+				return fmt.Errorf("error copying source path %s to %s: %v", path.SourcePath, path.DestinationDir, err)
+			}
 		}
 	}
 
-	return nil
+	if err := sanitizeExtractedContent(buildDir, true); err != nil {
+		return err
+	}
+
+	maxBytes, maxFiles, err := inputSizeLimitsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	return checkExtractedContentSize(buildDir, maxBytes, maxFiles)
+}
+
+// expandImageSourcePath resolves an image source path against the mounted
+// image content. Paths may use glob patterns; a pattern matching nothing is
+// an error, so typos do not silently produce empty chained builds.
+func expandImageSourcePath(mountPath, sourcePath string) ([]string, error) {
+	pattern := filepath.Join(mountPath, sourcePath)
+	if !strings.ContainsAny(sourcePath, "*?[") {
+		return []string{pattern}, nil
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source path pattern %q: %v", sourcePath, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files in the source image match %q", sourcePath)
+	}
+	return matches, nil
 }
@@ -304,3 +304,89 @@ func TestCloneFromBranch(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandImageSourcePath(t *testing.T) {
+	mountPath, err := ioutil.TempDir("", "imagemount")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(mountPath)
+	for _, name := range []string{"app.jar", "lib.jar", "notes.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(mountPath, name), []byte(name), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// A literal path is passed through even if it does not exist.
+	sources, err := expandImageSourcePath(mountPath, "missing/dir")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sources) != 1 || sources[0] != filepath.Join(mountPath, "missing/dir") {
+		t.Errorf("unexpected sources %v", sources)
+	}
+
+	sources, err = expandImageSourcePath(mountPath, "*.jar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sources) != 2 {
+		t.Errorf("unexpected sources %v", sources)
+	}
+
+	if _, err := expandImageSourcePath(mountPath, "*.war"); err == nil {
+		t.Error("expected an error for a pattern matching nothing")
+	}
+}
+
+func TestRelocateDockerfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relocate-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, subdir := range []string{"build", "services/api"} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "build/Dockerfile.prod"), []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	build := &buildapiv1.Build{
+		Spec: buildapiv1.BuildSpec{
+			CommonSpec: buildapiv1.CommonSpec{
+				Source: buildapiv1.BuildSource{ContextDir: "services/api"},
+				Strategy: buildapiv1.BuildStrategy{
+					DockerStrategy: &buildapiv1.DockerBuildStrategy{DockerfilePath: "../../build/Dockerfile.prod"},
+				},
+			},
+		},
+	}
+	if err := relocateDockerfile(dir, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	relocated := build.Spec.Strategy.DockerStrategy.DockerfilePath
+	if strings.Contains(relocated, "..") {
+		t.Errorf("dockerfilePath %q was not rewritten", relocated)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "services/api", relocated)); err != nil {
+		t.Errorf("relocated dockerfile missing: %v", err)
+	}
+
+	// A path inside the context directory is left alone.
+	build.Spec.Strategy.DockerStrategy.DockerfilePath = "Dockerfile.api"
+	if err := relocateDockerfile(dir, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if build.Spec.Strategy.DockerStrategy.DockerfilePath != "Dockerfile.api" {
+		t.Errorf("dockerfilePath %q was unexpectedly rewritten", build.Spec.Strategy.DockerStrategy.DockerfilePath)
+	}
+
+	// A path escaping the input sources is rejected.
+	build.Spec.Strategy.DockerStrategy.DockerfilePath = "../../../etc/passwd"
+	if err := relocateDockerfile(dir, build); err == nil {
+		t.Error("expected an error for a path outside the input sources")
+	}
+}
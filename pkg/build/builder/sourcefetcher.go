@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+// SourceFetcherEnvVar selects a registered SourceFetcher by name. When unset,
+// sources are fetched with the built-in git fetcher.
+const SourceFetcherEnvVar = "BUILD_SOURCE_FETCHER"
+
+// GitSourceFetcherName is the name of the built-in git source fetcher.
+const GitSourceFetcherName = "git"
+
+// SourceFetcher fetches the input source of a build into a destination
+// directory. Implementations for SCM providers other than git (Perforce,
+// internal artifact stores, ...) can be compiled into downstream builders and
+// registered with RegisterSourceFetcher.
+type SourceFetcher interface {
+	// Name identifies the fetcher; builds select it via BUILD_SOURCE_FETCHER.
+	Name() string
+	// Fetch downloads the source of the given build into destDir and returns
+	// information about the fetched revision, or nil if no revision
+	// information is available.
+	Fetch(ctx context.Context, build *buildapiv1.Build, destDir string) (*git.SourceInfo, error)
+}
+
+var (
+	sourceFetchersMutex sync.Mutex
+	sourceFetchers      = map[string]SourceFetcher{}
+)
+
+// RegisterSourceFetcher makes a source fetcher selectable by its name.
+// It is expected to be called from an init function; registering two fetchers
+// with the same name is a programming error and panics.
+func RegisterSourceFetcher(fetcher SourceFetcher) {
+	sourceFetchersMutex.Lock()
+	defer sourceFetchersMutex.Unlock()
+	name := fetcher.Name()
+	if name == GitSourceFetcherName {
+		panic(fmt.Sprintf("source fetcher name %q is reserved for the built-in git fetcher", name))
+	}
+	if _, exists := sourceFetchers[name]; exists {
+		panic(fmt.Sprintf("source fetcher %q registered twice", name))
+	}
+	sourceFetchers[name] = fetcher
+}
+
+// sourceFetcherFor returns the registered fetcher with the given name.
+func sourceFetcherFor(name string) (SourceFetcher, error) {
+	sourceFetchersMutex.Lock()
+	defer sourceFetchersMutex.Unlock()
+	fetcher, exists := sourceFetchers[name]
+	if !exists {
+		names := []string{GitSourceFetcherName}
+		for registered := range sourceFetchers {
+			names = append(names, registered)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown source fetcher %q, available fetchers: %s", name, strings.Join(names, ", "))
+	}
+	return fetcher, nil
+}
+
+// gitSourceFetcher adapts GitClone to the SourceFetcher interface.
+type gitSourceFetcher struct {
+	gitClient GitClient
+}
+
+func (f gitSourceFetcher) Name() string {
+	return GitSourceFetcherName
+}
+
+func (f gitSourceFetcher) Fetch(ctx context.Context, build *buildapiv1.Build, destDir string) (*git.SourceInfo, error) {
+	return GitClone(ctx, f.gitClient, build.Spec.Source.Git, build.Spec.Revision, destDir)
+}
+
+// FetchSource fetches the build source with the fetcher selected through
+// BUILD_SOURCE_FETCHER, defaulting to the built-in git fetcher.
+func FetchSource(ctx context.Context, gitClient GitClient, build *buildapiv1.Build, destDir string) (*git.SourceInfo, error) {
+	name := os.Getenv(SourceFetcherEnvVar)
+	if len(name) == 0 || name == GitSourceFetcherName {
+		return gitSourceFetcher{gitClient: gitClient}.Fetch(ctx, build, destDir)
+	}
+	fetcher, err := sourceFetcherFor(name)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("Fetching source with the %q fetcher", name)
+	return fetcher.Fetch(ctx, build, destDir)
+}
@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/git"
+)
+
+type fakeSourceFetcher struct {
+	name    string
+	fetched bool
+}
+
+func (f *fakeSourceFetcher) Name() string {
+	return f.name
+}
+
+func (f *fakeSourceFetcher) Fetch(ctx context.Context, build *buildapiv1.Build, destDir string) (*git.SourceInfo, error) {
+	f.fetched = true
+	return nil, nil
+}
+
+func TestFetchSourceDispatch(t *testing.T) {
+	fetcher := &fakeSourceFetcher{name: "fake"}
+	RegisterSourceFetcher(fetcher)
+	defer func() {
+		sourceFetchersMutex.Lock()
+		delete(sourceFetchers, fetcher.name)
+		sourceFetchersMutex.Unlock()
+	}()
+	defer os.Unsetenv(SourceFetcherEnvVar)
+
+	os.Setenv(SourceFetcherEnvVar, "fake")
+	if _, err := FetchSource(context.Background(), nil, &buildapiv1.Build{}, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !fetcher.fetched {
+		t.Errorf("expected the registered fetcher to be used")
+	}
+
+	os.Setenv(SourceFetcherEnvVar, "perforce")
+	_, err := FetchSource(context.Background(), nil, &buildapiv1.Build{}, "")
+	if err == nil || !strings.Contains(err.Error(), `unknown source fetcher "perforce"`) {
+		t.Errorf("expected an unknown fetcher error, got %v", err)
+	}
+}
+
+func TestRegisterSourceFetcherConflicts(t *testing.T) {
+	expectPanic := func(name string, register func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected a panic", name)
+			}
+		}()
+		register()
+	}
+
+	fetcher := &fakeSourceFetcher{name: "duplicate"}
+	RegisterSourceFetcher(fetcher)
+	defer func() {
+		sourceFetchersMutex.Lock()
+		delete(sourceFetchers, fetcher.name)
+		sourceFetchersMutex.Unlock()
+	}()
+
+	expectPanic("duplicate name", func() { RegisterSourceFetcher(&fakeSourceFetcher{name: "duplicate"}) })
+	expectPanic("reserved name", func() { RegisterSourceFetcher(&fakeSourceFetcher{name: GitSourceFetcherName}) })
+}
@@ -0,0 +1,308 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/imagebuilder"
+
+	"github.com/openshift/builder/pkg/build/builder/util/dockerfile"
+)
+
+// StageConcurrencyEnvVar is the name of the environment variable that sets
+// how many independent Dockerfile stages may build at the same time. The
+// default of 1 keeps the traditional sequential build; higher values build
+// stages that do not depend on each other concurrently before the final
+// build assembles them from the cache, which can substantially reduce the
+// wall-clock time of fan-in multi-stage builds.
+const StageConcurrencyEnvVar = "BUILD_STAGE_CONCURRENCY"
+
+// stageConcurrencyFromEnvironment returns the configured stage concurrency.
+func stageConcurrencyFromEnvironment() (int, error) {
+	value := strings.TrimSpace(os.Getenv(StageConcurrencyEnvVar))
+	if len(value) == 0 {
+		return 1, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("invalid value %q for %s: must be a positive integer", value, StageConcurrencyEnvVar)
+	}
+	return concurrency, nil
+}
+
+// dockerfileStage is one stage of a multi-stage Dockerfile.
+type dockerfileStage struct {
+	// name is the lowercased AS alias, or the stage index as a string when
+	// the stage is unnamed.
+	name string
+	// end is the index just past the stage's last instruction in the
+	// Dockerfile's child list.
+	end int
+	// deps are the indices of earlier stages this stage builds FROM or
+	// COPYs from.
+	deps []int
+}
+
+// parseDockerfileStages splits a parsed Dockerfile into its stages and
+// records which earlier stages each one depends on, through its FROM image
+// and any COPY --from references.
+func parseDockerfileStages(node *parser.Node) []dockerfileStage {
+	var stages []dockerfileStage
+	byName := map[string]int{}
+	for i, child := range node.Children {
+		switch child.Value {
+		case dockercmd.From:
+			if current := len(stages) - 1; current >= 0 {
+				stages[current].end = i
+			}
+			stage := dockerfileStage{name: strconv.Itoa(len(stages)), end: len(node.Children)}
+			if alias := stageAlias(child); len(alias) != 0 {
+				stage.name = strings.ToLower(alias)
+			}
+			if child.Next != nil {
+				if dep, ok := resolveStageRef(byName, child.Next.Value, len(stages)); ok {
+					stage.deps = append(stage.deps, dep)
+				}
+			}
+			byName[stage.name] = len(stages)
+			stages = append(stages, stage)
+		case dockercmd.Copy:
+			current := len(stages) - 1
+			if current < 0 {
+				continue
+			}
+			for _, flag := range child.Flags {
+				if !strings.HasPrefix(flag, "--from=") {
+					continue
+				}
+				if dep, ok := resolveStageRef(byName, strings.TrimPrefix(flag, "--from="), current); ok {
+					stages[current].deps = append(stages[current].deps, dep)
+				}
+			}
+		}
+	}
+	return stages
+}
+
+// resolveStageRef resolves a stage reference - an AS alias or a numeric
+// stage index - against the stages declared so far.
+func resolveStageRef(byName map[string]int, ref string, limit int) (int, bool) {
+	if dep, ok := byName[strings.ToLower(ref)]; ok {
+		return dep, true
+	}
+	if dep, err := strconv.Atoi(ref); err == nil && dep >= 0 && dep < limit {
+		return dep, true
+	}
+	return 0, false
+}
+
+// stageBatches groups all stages but the final one into batches that can
+// build concurrently: every stage in a batch only depends on stages in
+// earlier batches. The final stage is left to the regular build, which
+// assembles the output image from the pre-built stages.
+func stageBatches(stages []dockerfileStage) [][]int {
+	var batches [][]int
+	scheduled := make([]bool, len(stages))
+	remaining := len(stages) - 1
+	for remaining > 0 {
+		var batch []int
+		for i := 0; i < len(stages)-1; i++ {
+			if scheduled[i] {
+				continue
+			}
+			ready := true
+			for _, dep := range stages[i].deps {
+				if !scheduled[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				batch = append(batch, i)
+			}
+		}
+		if len(batch) == 0 {
+			// A dependency cycle; the regular build will report it.
+			return nil
+		}
+		for _, i := range batch {
+			scheduled[i] = true
+		}
+		remaining -= len(batch)
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// prebuildDockerfileStages builds the independent stages of a multi-stage
+// Dockerfile concurrently, so the subsequent full build finds them in the
+// layer cache. Stages are built in dependency order, at most concurrency at
+// a time; batches with a single stage are skipped because the regular build
+// handles them just as fast.
+func (d *DockerBuilder) prebuildDockerfileStages(dir string, dockerfilePath string, concurrency int, opts docker.BuildImageOptions) error {
+	in, err := ioutil.ReadFile(filepath.Join(dir, dockerfilePath))
+	if err != nil {
+		return err
+	}
+	node, err := imagebuilder.ParseDockerfile(bytes.NewReader(in))
+	if err != nil {
+		return err
+	}
+	stages := parseDockerfileStages(node)
+	if len(stages) < 3 {
+		// With fewer than two non-final stages there is nothing to overlap.
+		return nil
+	}
+	if _, daemonless := d.dockerClient.(*DaemonlessClient); daemonless && !instructionCacheFromEnvironment() {
+		// Without per-step commits the full build cannot find the
+		// prebuilt stages in the cache, so every batched stage would
+		// simply build twice.
+		glog.V(0).Infof("warning: %s has no effect without %s; building stages sequentially", StageConcurrencyEnvVar, InstructionCacheEnvVar)
+		return nil
+	}
+	for _, batch := range stageBatches(stages) {
+		if len(batch) < 2 {
+			continue
+		}
+		glog.V(0).Infof("Building %d independent Dockerfile stages concurrently", len(batch))
+		if err := d.buildStageBatch(dir, node, stages, batch, concurrency, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildStageBatch builds the given independent stages, at most concurrency
+// at a time. Each stage builds from a truncated copy of the Dockerfile into
+// a throwaway tag; the per-build store is discarded with the pod, so the
+// tags are not cleaned up individually.
+func (d *DockerBuilder) buildStageBatch(dir string, node *parser.Node, stages []dockerfileStage, batch []int, concurrency int, opts docker.BuildImageOptions) error {
+	// The per-stage Dockerfiles must stay out of the context the main build
+	// sees: COPY . . would bake them into the output image and perturb its
+	// cache keys. The daemonless path accepts a Dockerfile path outside the
+	// context directory; the daemon path tars up the context, so there the
+	// files are written into it and removed again before this returns.
+	_, daemonless := d.dockerClient.(*DaemonlessClient)
+	stageFileDir := dir
+	if daemonless {
+		tempDir, err := ioutil.TempDir("", "openshift-build-stages")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		stageFileDir = tempDir
+	}
+	var contextFiles []string
+	defer func() {
+		for _, name := range contextFiles {
+			if err := os.Remove(name); err != nil {
+				glog.V(0).Infof("warning: Unable to remove the temporary stage Dockerfile %s: %v", name, err)
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	var outputMutex sync.Mutex
+	slots := make(chan struct{}, concurrency)
+	errs := make(chan error, len(batch))
+	for _, index := range batch {
+		stage := stages[index]
+		truncated := *node
+		truncated.Children = node.Children[:stage.end]
+		stageDockerfile := fmt.Sprintf(".%s.stage-%d", filepath.Base(dockerfilePathOf(opts)), index)
+		stagePath := filepath.Join(stageFileDir, stageDockerfile)
+		if err := overwriteFile(stagePath, dockerfile.Write(&truncated)); err != nil {
+			return err
+		}
+		stageOpts := opts
+		stageOpts.Name = fmt.Sprintf("openshift-build-stage-%d", index)
+		if daemonless {
+			stageOpts.Dockerfile = stagePath
+		} else {
+			contextFiles = append(contextFiles, stagePath)
+			stageOpts.Dockerfile = stageDockerfile
+		}
+		stageOpts.OutputStream = &prefixWriter{mutex: &outputMutex, out: opts.OutputStream, prefix: fmt.Sprintf("[stage %s] ", stage.name)}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(name string, stageOpts docker.BuildImageOptions) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			glog.V(2).Infof("Building Dockerfile stage %q", name)
+			if err := d.buildStageImage(dir, &stageOpts); err != nil {
+				errs <- fmt.Errorf("stage %q: %v", name, err)
+			}
+		}(stage.name, stageOpts)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// buildStageImage runs one stage build through the same backend the full
+// build will use.
+func (d *DockerBuilder) buildStageImage(dir string, opts *docker.BuildImageOptions) error {
+	if dc, ok := d.dockerClient.(*DaemonlessClient); ok {
+		return buildDaemonlessImage(dc.SystemContext, dc.Store, dc.Isolation, dir, buildapiv1.ImageOptimizationNone, nil, opts)
+	}
+	return dockerBuildImage(d.dockerClient, dir, d.tar, opts)
+}
+
+// dockerfilePathOf returns the Dockerfile name from build options, for
+// deriving per-stage Dockerfile names.
+func dockerfilePathOf(opts docker.BuildImageOptions) string {
+	if len(opts.Dockerfile) != 0 {
+		return opts.Dockerfile
+	}
+	return defaultDockerfilePath
+}
+
+// prefixWriter marks each output line with the stage that produced it, so
+// interleaved concurrent stage logs stay readable. All writers of one batch
+// share a mutex, keeping whole writes to the underlying stream atomic.
+type prefixWriter struct {
+	mutex   *sync.Mutex
+	out     io.Writer
+	prefix  string
+	midline bool
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	total := len(p)
+	for len(p) > 0 {
+		if !w.midline {
+			if _, err := io.WriteString(w.out, w.prefix); err != nil {
+				return total - len(p), err
+			}
+			w.midline = true
+		}
+		newline := bytes.IndexByte(p, '\n')
+		if newline < 0 {
+			if _, err := w.out.Write(p); err != nil {
+				return total - len(p), err
+			}
+			break
+		}
+		if _, err := w.out.Write(p[:newline+1]); err != nil {
+			return total - len(p), err
+		}
+		w.midline = false
+		p = p[newline+1:]
+	}
+	return total, nil
+}
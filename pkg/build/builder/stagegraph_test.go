@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openshift/imagebuilder"
+)
+
+func TestStageConcurrencyFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  int
+		expectErr bool
+	}{
+		{value: "", expected: 1},
+		{value: "1", expected: 1},
+		{value: "4", expected: 4},
+		{value: "0", expectErr: true},
+		{value: "-2", expectErr: true},
+		{value: "many", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(StageConcurrencyEnvVar, tc.value)
+		actual, err := stageConcurrencyFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if err == nil && actual != tc.expected {
+			t.Errorf("%q: expected %d, got %d", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(StageConcurrencyEnvVar)
+}
+
+const fanInDockerfile = `FROM golang AS backend
+RUN go build -o /app ./...
+
+FROM node AS frontend
+RUN npm run build
+
+FROM backend AS packaged
+COPY --from=frontend /dist /srv
+
+FROM scratch
+COPY --from=packaged /app /app
+COPY --from=0 /etc/passwd /etc/passwd
+`
+
+func TestParseDockerfileStages(t *testing.T) {
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader(fanInDockerfile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stages := parseDockerfileStages(node)
+	if len(stages) != 4 {
+		t.Fatalf("expected 4 stages, got %d: %#v", len(stages), stages)
+	}
+	expected := []struct {
+		name string
+		deps []int
+	}{
+		{name: "backend"},
+		{name: "frontend"},
+		{name: "packaged", deps: []int{0, 1}},
+		{name: "3", deps: []int{2, 0}},
+	}
+	for i, e := range expected {
+		if stages[i].name != e.name {
+			t.Errorf("stage %d: expected name %q, got %q", i, e.name, stages[i].name)
+		}
+		if !reflect.DeepEqual(stages[i].deps, e.deps) {
+			t.Errorf("stage %d: expected deps %v, got %v", i, e.deps, stages[i].deps)
+		}
+	}
+}
+
+func TestStageBatches(t *testing.T) {
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader(fanInDockerfile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	batches := stageBatches(parseDockerfileStages(node))
+	expected := [][]int{{0, 1}, {2}}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("expected batches %v, got %v", expected, batches)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := &prefixWriter{mutex: &sync.Mutex{}, out: out, prefix: "[stage a] "}
+	w.Write([]byte("first line\nsec"))
+	w.Write([]byte("ond line\n"))
+	expected := "[stage a] first line\n[stage a] second line\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
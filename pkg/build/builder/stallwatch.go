@@ -0,0 +1,121 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// StallTimeoutEnvVar configures the stall detection window for network
+// transfers as a Go duration (e.g. "5m"). If a monitored transfer reports no
+// progress for that long, it is aborted with a "stalled transfer" error.
+// Stall detection is disabled when unset. Git operations have their own
+// timeout handling (see checkSourceURI); this watchdog covers the image pull
+// and push transfers, whose progress is visible through their report writers.
+const StallTimeoutEnvVar = "BUILD_STALL_TIMEOUT"
+
+// stallTimeoutFromEnvironment parses BUILD_STALL_TIMEOUT, returning zero when
+// stall detection is not configured.
+func stallTimeoutFromEnvironment() (time.Duration, error) {
+	value := os.Getenv(StallTimeoutEnvVar)
+	if len(value) == 0 {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", StallTimeoutEnvVar, value)
+	}
+	return timeout, nil
+}
+
+// stallWatcher aborts a transfer's context when its progress writer sees no
+// output for the configured window. With no window configured it degrades to
+// a transparent pass-through.
+type stallWatcher struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	writer       io.Writer
+	operation    string
+	timeout      time.Duration
+	lastProgress int64
+	stalled      int32
+	done         chan struct{}
+}
+
+// newStallWatcher starts watching the given operation. Callers must use
+// Context() and Writer() for the transfer and call Stop() when it finishes.
+func newStallWatcher(ctx context.Context, writer io.Writer, operation string) (*stallWatcher, error) {
+	timeout, err := stallTimeoutFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	w := &stallWatcher{
+		ctx:          watchCtx,
+		cancel:       cancel,
+		writer:       writer,
+		operation:    operation,
+		timeout:      timeout,
+		lastProgress: time.Now().UnixNano(),
+		done:         make(chan struct{}),
+	}
+	if timeout > 0 {
+		go w.watch()
+	}
+	return w, nil
+}
+
+func (w *stallWatcher) Context() context.Context {
+	return w.ctx
+}
+
+func (w *stallWatcher) Writer() io.Writer {
+	return w
+}
+
+// Write records forward progress and passes the report output through.
+func (w *stallWatcher) Write(p []byte) (int, error) {
+	atomic.StoreInt64(&w.lastProgress, time.Now().UnixNano())
+	return w.writer.Write(p)
+}
+
+func (w *stallWatcher) watch() {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			lastProgress := time.Unix(0, atomic.LoadInt64(&w.lastProgress))
+			if time.Since(lastProgress) >= w.timeout {
+				atomic.StoreInt32(&w.stalled, 1)
+				glog.V(0).Infof("Aborting %s: no progress within %s", w.operation, w.timeout)
+				w.cancel()
+				return
+			}
+		}
+	}
+}
+
+// Stop shuts the watchdog down.
+func (w *stallWatcher) Stop() {
+	close(w.done)
+	w.cancel()
+}
+
+// Err rewrites the transfer error if the watchdog aborted the transfer, so
+// users see the stall rather than a generic cancellation. The message
+// matches RetriableErrors, making the failure retriable where retries are
+// safe.
+func (w *stallWatcher) Err(err error) error {
+	if atomic.LoadInt32(&w.stalled) != 0 {
+		return fmt.Errorf("stalled transfer: no progress in %s within %s", w.operation, w.timeout)
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStallWatcher(t *testing.T) {
+	defer os.Unsetenv(StallTimeoutEnvVar)
+
+	os.Setenv(StallTimeoutEnvVar, "not-a-duration")
+	if _, err := newStallWatcher(context.Background(), os.Stderr, "test"); err == nil {
+		t.Error("expected an error for an invalid timeout")
+	}
+
+	// Without a configured timeout the watcher passes everything through.
+	os.Unsetenv(StallTimeoutEnvVar)
+	buffer := &bytes.Buffer{}
+	watcher, err := newStallWatcher(context.Background(), buffer, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := watcher.Writer().Write([]byte("progress")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buffer.String() != "progress" {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	transferErr := errors.New("transfer failed")
+	if got := watcher.Err(transferErr); got != transferErr {
+		t.Errorf("unexpected error %v", got)
+	}
+	watcher.Stop()
+
+	// A transfer with no progress is aborted and reported as stalled.
+	os.Setenv(StallTimeoutEnvVar, "20ms")
+	watcher, err = newStallWatcher(context.Background(), buffer, "pull of example")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer watcher.Stop()
+	select {
+	case <-watcher.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not abort a stalled transfer")
+	}
+	got := watcher.Err(context.Canceled)
+	if got == nil || !strings.Contains(got.Error(), "stalled transfer") {
+		t.Errorf("unexpected error %v", got)
+	}
+}
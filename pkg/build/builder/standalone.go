@@ -0,0 +1,126 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// StandaloneEnvVar is the name of the environment variable that runs the
+// builder without an API server connection. Status updates are logged
+// instead of being posted, which makes it possible to exercise the builder
+// commands locally when developing or debugging a BuildConfig.
+const StandaloneEnvVar = "BUILD_STANDALONE"
+
+// StandaloneStatusFileEnvVar is the name of the environment variable that,
+// in standalone mode, appends each build status update as a JSON line to the
+// named file in addition to logging it.
+const StandaloneStatusFileEnvVar = "BUILD_STANDALONE_STATUS_FILE"
+
+// StandaloneFromEnvironment reports whether the builder should run in
+// standalone mode. An unparsable value counts as false, with a warning, so
+// a typo cannot silently skip status reporting to the API server.
+func StandaloneFromEnvironment() bool {
+	value := strings.TrimSpace(os.Getenv(StandaloneEnvVar))
+	if len(value) == 0 {
+		return false
+	}
+	standalone, err := strconv.ParseBool(value)
+	if err != nil {
+		glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, StandaloneEnvVar)
+		return false
+	}
+	return standalone
+}
+
+// standaloneBuildsClient satisfies the builds client interface without an
+// API server. It keeps the latest build state in memory so the status
+// update flow works unchanged, and surfaces each update in the build log.
+type standaloneBuildsClient struct {
+	mutex      sync.Mutex
+	build      *buildapiv1.Build
+	statusFile *os.File
+}
+
+// NewStandaloneBuildsClient returns a builds client that records status
+// updates locally instead of posting them to an API server. If a status
+// file is configured in the environment, each update is also appended to it
+// as a JSON line.
+func NewStandaloneBuildsClient(build *buildapiv1.Build) (*standaloneBuildsClient, error) {
+	client := &standaloneBuildsClient{build: build.DeepCopy()}
+	if path := os.Getenv(StandaloneStatusFileEnvVar); len(path) != 0 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open standalone status file: %v", err)
+		}
+		client.statusFile = f
+	}
+	return client, nil
+}
+
+func (c *standaloneBuildsClient) Get(name string, options metav1.GetOptions) (*buildapiv1.Build, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.build.DeepCopy(), nil
+}
+
+func (c *standaloneBuildsClient) UpdateDetails(buildName string, build *buildapiv1.Build) (*buildapiv1.Build, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.build = build.DeepCopy()
+	glog.V(0).Infof("Standalone build status: phase %s, reason %q, message %q", build.Status.Phase, build.Status.Reason, build.Status.Message)
+	if c.statusFile != nil {
+		line, err := json.Marshal(build.Status)
+		if err == nil {
+			_, err = fmt.Fprintf(c.statusFile, "%s\n", line)
+		}
+		if err != nil {
+			glog.V(0).Infof("warning: Unable to record the build status update: %v", err)
+		}
+	}
+	return c.build.DeepCopy(), nil
+}
+
+func (c *standaloneBuildsClient) Update(build *buildapiv1.Build) (*buildapiv1.Build, error) {
+	return c.UpdateDetails(build.Name, build)
+}
+
+func (c *standaloneBuildsClient) UpdateStatus(build *buildapiv1.Build) (*buildapiv1.Build, error) {
+	return c.UpdateDetails(build.Name, build)
+}
+
+func (c *standaloneBuildsClient) Create(build *buildapiv1.Build) (*buildapiv1.Build, error) {
+	return nil, fmt.Errorf("creating builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) Delete(name string, options *metav1.DeleteOptions) error {
+	return fmt.Errorf("deleting builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	return fmt.Errorf("deleting builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) List(opts metav1.ListOptions) (*buildapiv1.BuildList, error) {
+	return nil, fmt.Errorf("listing builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return nil, fmt.Errorf("watching builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*buildapiv1.Build, error) {
+	return nil, fmt.Errorf("patching builds is not supported in standalone mode")
+}
+
+func (c *standaloneBuildsClient) Clone(buildName string, buildRequest *buildapiv1.BuildRequest) (*buildapiv1.Build, error) {
+	return nil, fmt.Errorf("cloning builds is not supported in standalone mode")
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStandaloneFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "", expected: false},
+		{value: "true", expected: true},
+		{value: "1", expected: true},
+		{value: "false", expected: false},
+		{value: "maybe", expected: false},
+	}
+	for _, tc := range testCases {
+		os.Setenv(StandaloneEnvVar, tc.value)
+		if actual := StandaloneFromEnvironment(); actual != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(StandaloneEnvVar)
+}
+
+func TestStandaloneBuildsClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "standalone-test")
+	if err != nil {
+		t.Fatalf("failed to create tmpdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	statusFile := filepath.Join(dir, "status")
+	os.Setenv(StandaloneStatusFileEnvVar, statusFile)
+	defer os.Unsetenv(StandaloneStatusFileEnvVar)
+
+	build := &buildapiv1.Build{}
+	build.Name = "standalone-build"
+	client, err := NewStandaloneBuildsClient(build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	build.Status.Phase = buildapiv1.BuildPhaseRunning
+	if _, err := client.UpdateDetails(build.Name, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	build.Status.Phase = buildapiv1.BuildPhaseComplete
+	if _, err := client.UpdateDetails(build.Name, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latest, err := client.Get(build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latest.Status.Phase != buildapiv1.BuildPhaseComplete {
+		t.Errorf("expected phase %s, got %s", buildapiv1.BuildPhaseComplete, latest.Status.Phase)
+	}
+
+	content, err := ioutil.ReadFile(statusFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Errorf("expected 2 status lines, got %d: %q", len(lines), string(content))
+	}
+	if !strings.Contains(lines[1], string(buildapiv1.BuildPhaseComplete)) {
+		t.Errorf("expected the last status line to record the final phase: %q", lines[1])
+	}
+
+	if _, err := client.List(metav1.ListOptions{}); err == nil {
+		t.Errorf("expected listing builds to fail in standalone mode")
+	}
+}
@@ -0,0 +1,156 @@
+package builder
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// StatusUpdateIntervalEnvVar is the name of the environment variable that
+// sets the minimum interval between non-critical build status updates, as a
+// Go duration ("2s"). Frequent stage changes would otherwise hammer the API
+// server with updates that only refresh timing details. A value of 0
+// disables the rate limiting.
+const StatusUpdateIntervalEnvVar = "BUILD_STATUS_UPDATE_INTERVAL"
+
+// defaultStatusUpdateInterval is the rate limit applied when no interval is
+// configured.
+const defaultStatusUpdateInterval = time.Second
+
+// statusUpdateIntervalFromEnvironment returns the configured minimum
+// interval between non-critical status updates. An unparsable value falls
+// back to the default, so a typo cannot stop status reporting entirely.
+func statusUpdateIntervalFromEnvironment() time.Duration {
+	value := strings.TrimSpace(os.Getenv(StatusUpdateIntervalEnvVar))
+	if len(value) == 0 {
+		return defaultStatusUpdateInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		glog.V(0).Infof("warning: Ignoring invalid value %q for %s", value, StatusUpdateIntervalEnvVar)
+		return defaultStatusUpdateInterval
+	}
+	return interval
+}
+
+// statusUpdateLimiter rate-limits build status updates. Updates that change
+// the phase, record a revision, or carry the output image reference always
+// go through; anything else is coalesced into the next allowed update,
+// which sends the accumulated state anyway because callers mutate the same
+// build object.
+type statusUpdateLimiter struct {
+	mutex       sync.Mutex
+	initialized bool
+	interval    time.Duration
+	now         func() time.Time
+	last        time.Time
+	lastPhase   buildapiv1.BuildPhase
+	merged      int64
+}
+
+// currentStatusUpdateLimiter throttles HandleBuildStatusUpdate for the
+// lifetime of the builder process.
+var currentStatusUpdateLimiter = &statusUpdateLimiter{now: time.Now}
+
+// allow reports whether the update should be sent now. Coalesced updates
+// are counted so the builder can report how many it merged.
+func (l *statusUpdateLimiter) allow(build *buildapiv1.Build, sourceRev *buildapiv1.SourceRevision) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.initialized {
+		l.interval = statusUpdateIntervalFromEnvironment()
+		l.initialized = true
+	}
+
+	critical := sourceRev != nil ||
+		build.Status.Phase != l.lastPhase ||
+		build.Status.Phase == buildapiv1.BuildPhaseComplete ||
+		build.Status.Phase == buildapiv1.BuildPhaseFailed ||
+		build.Status.Output.To != nil
+	if !critical && l.now().Sub(l.last) < l.interval {
+		l.merged++
+		glog.V(4).Infof("Coalescing build status update (%d merged so far)", l.merged)
+		return false
+	}
+
+	l.last = l.now()
+	l.lastPhase = build.Status.Phase
+	return true
+}
+
+// mergedCount returns how many status updates were coalesced instead of
+// being sent.
+func (l *statusUpdateLimiter) mergedCount() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.merged
+}
+
+// CoalescedStatusUpdates returns the number of build status updates that
+// were merged into later ones instead of being sent to the API server.
+func CoalescedStatusUpdates() int64 {
+	return currentStatusUpdateLimiter.mergedCount()
+}
+
+// statusUpdateBuffer remembers that a status update could not be delivered.
+// Because callers accumulate state in the build object, "buffering" the
+// failed update amounts to making sure another update is sent once the API
+// server is reachable again.
+type statusUpdateBuffer struct {
+	mutex    sync.Mutex
+	pending  bool
+	failures int64
+}
+
+// currentStatusUpdateBuffer tracks undelivered status updates for the
+// lifetime of the builder process.
+var currentStatusUpdateBuffer = &statusUpdateBuffer{}
+
+func (b *statusUpdateBuffer) markFailed() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = true
+	b.failures++
+}
+
+func (b *statusUpdateBuffer) markSent() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = false
+}
+
+func (b *statusUpdateBuffer) hasPending() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.pending
+}
+
+// FlushBuildStatusUpdates retries delivery of any status update that failed
+// earlier, backing off between attempts. It runs as the builder exits, so
+// transient control-plane blips do not lose timing data or the final build
+// status.
+func FlushBuildStatusUpdates(build *buildapiv1.Build, client buildclientv1.BuildInterface) {
+	if !currentStatusUpdateBuffer.hasPending() {
+		return
+	}
+	glog.V(0).Infof("Retrying undelivered build status updates ...")
+	flushBackoff := wait.Backoff{
+		Steps:    6,
+		Duration: 500 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+	wait.ExponentialBackoff(flushBackoff, func() (bool, error) {
+		HandleBuildStatusUpdate(build, client, nil)
+		return !currentStatusUpdateBuffer.hasPending(), nil
+	})
+	if currentStatusUpdateBuffer.hasPending() {
+		glog.V(0).Infof("error: Giving up on delivering the final build status update")
+	}
+}
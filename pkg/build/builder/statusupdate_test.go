@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestStatusUpdateIntervalFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: defaultStatusUpdateInterval},
+		{value: "5s", expected: 5 * time.Second},
+		{value: "0", expected: 0},
+		{value: "soon", expected: defaultStatusUpdateInterval},
+		{value: "-1s", expected: defaultStatusUpdateInterval},
+	}
+	for _, tc := range testCases {
+		os.Setenv(StatusUpdateIntervalEnvVar, tc.value)
+		if actual := statusUpdateIntervalFromEnvironment(); actual != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.value, tc.expected, actual)
+		}
+	}
+	os.Unsetenv(StatusUpdateIntervalEnvVar)
+}
+
+func TestStatusUpdateLimiter(t *testing.T) {
+	clock := time.Now()
+	limiter := &statusUpdateLimiter{
+		initialized: true,
+		interval:    time.Second,
+		now:         func() time.Time { return clock },
+	}
+	build := &buildapiv1.Build{}
+	build.Status.Phase = buildapiv1.BuildPhaseRunning
+
+	if !limiter.allow(build, nil) {
+		t.Fatalf("expected the first update to be allowed")
+	}
+	if limiter.allow(build, nil) {
+		t.Errorf("expected a rapid successive update to be coalesced")
+	}
+	if limiter.mergedCount() != 1 {
+		t.Errorf("expected 1 merged update, got %d", limiter.mergedCount())
+	}
+
+	clock = clock.Add(2 * time.Second)
+	if !limiter.allow(build, nil) {
+		t.Errorf("expected an update after the interval to be allowed")
+	}
+
+	// Phase changes, revisions, terminal phases, and digests go through
+	// regardless of the interval.
+	build.Status.Phase = buildapiv1.BuildPhaseComplete
+	if !limiter.allow(build, nil) {
+		t.Errorf("expected a phase change to be allowed immediately")
+	}
+	if !limiter.allow(build, nil) {
+		t.Errorf("expected a terminal phase update to be allowed immediately")
+	}
+	build.Status.Phase = buildapiv1.BuildPhaseRunning
+	if !limiter.allow(build, &buildapiv1.SourceRevision{}) {
+		t.Errorf("expected an update with a revision to be allowed immediately")
+	}
+	build.Status.Output.To = &buildapiv1.BuildStatusOutputTo{ImageDigest: "sha256:abc"}
+	if !limiter.allow(build, nil) {
+		t.Errorf("expected an update with an output digest to be allowed immediately")
+	}
+}
+
+func TestStatusUpdateBuffer(t *testing.T) {
+	buffer := &statusUpdateBuffer{}
+	if buffer.hasPending() {
+		t.Errorf("expected a new buffer to have nothing pending")
+	}
+	buffer.markFailed()
+	buffer.markFailed()
+	if !buffer.hasPending() {
+		t.Errorf("expected failed updates to be pending")
+	}
+	if buffer.failures != 2 {
+		t.Errorf("expected 2 recorded failures, got %d", buffer.failures)
+	}
+	buffer.markSent()
+	if buffer.hasPending() {
+		t.Errorf("expected a successful update to clear the pending state")
+	}
+	if buffer.failures != 2 {
+		t.Errorf("expected the failure count to be preserved, got %d", buffer.failures)
+	}
+}
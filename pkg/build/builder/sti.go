@@ -8,7 +8,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 
 	dockerclient "github.com/fsouza/go-dockerclient"
@@ -140,6 +140,7 @@ func (s *S2IBuilder) Build() error {
 	ctx := timing.NewContext(context.Background())
 	defer func() {
 		s.build.Status.Stages = timing.AppendStageAndStepInfo(s.build.Status.Stages, timing.GetStages(ctx))
+		RecordResourceUsage(s.build)
 		HandleBuildStatusUpdate(s.build, s.client, nil)
 	}()
 
@@ -227,20 +228,74 @@ func (s *S2IBuilder) Build() error {
 		KeepSymlinks: true,
 	}
 
+	for _, entry := range s2iDependencyCacheEnvironment() {
+		if err := config.Environment.Set(entry); err != nil {
+			return fmt.Errorf("unable to set the dependency cache environment: %v", err)
+		}
+	}
+
+	if err := applyS2IStrategyOverrides(config, s.build); err != nil {
+		s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		s.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+		s.build.Status.Message = builderutil.StatusMessageGenericBuildFailed
+		HandleBuildStatusUpdate(s.build, s.client, nil)
+		return err
+	}
+
 	// If DockerCfgPath is provided in buildapiv1.Config, then attempt to read the
 	// dockercfg file and get the authentication for pulling the images.
 	t, _ := dockercfg.NewHelper().GetDockerAuth(config.BuilderImage, dockercfg.PullAuthType)
 	config.PullAuthentication = s2iapi.AuthConfig{Username: t.Username, Password: t.Password, Email: t.Email, ServerAddress: t.ServerAddress}
 
-	if s.build.Spec.Strategy.SourceStrategy.ForcePull || !isImagePresent(s.dockerClient, config.BuilderImage) {
+	prePulled := false
+	if prePullEnabled() {
+		prePullNames := []string{config.BuilderImage}
+		if len(config.RuntimeImage) != 0 {
+			prePullNames = append(prePullNames, config.RuntimeImage)
+		}
+		for _, imageSource := range s.build.Spec.Source.Images {
+			if imageSource.From.Kind == "DockerImage" && len(imageSource.From.Name) != 0 {
+				prePullNames = append(prePullNames, imageSource.From.Name)
+			}
+		}
+		RecordBuildProgress(buildapiv1.StagePullImages)
+		if err := prePullImages(ctx, s.dockerClient, prePullNames, s.build.Spec.Strategy.SourceStrategy.ForcePull, s.pullImage); err != nil {
+			RecordBuildEvent(eventTypeWarning, BuildEventReasonPullFailed, "%v", err)
+			s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			s.build.Status.Reason = buildapiv1.StatusReasonPullBuilderImageFailed
+			s.build.Status.Message = builderutil.StatusMessagePullBuilderImageFailed
+			classifyPullFailure(s.build, s.build.Spec.Strategy.SourceStrategy.From.Name, err)
+			classifyRateLimitedPull(s.build, s.build.Spec.Strategy.SourceStrategy.From.Name, err)
+			HandleBuildStatusUpdate(s.build, s.client, nil)
+			return err
+		}
+		prePulled = true
+	}
+
+	if err := VerifySigstoreSignatures(ctx, s.build, []string{config.BuilderImage}); err != nil {
+		s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		s.build.Status.Reason = StatusReasonBaseImageSignatureUnverified
+		s.build.Status.Message = builderutil.StatusMessageBaseImageSignatureUnverified
+		HandleBuildStatusUpdate(s.build, s.client, nil)
+		return err
+	}
+
+	if push {
+		pullPreviousImage(s.build, pushTag, s.pullImage)
+	}
+
+	if (s.build.Spec.Strategy.SourceStrategy.ForcePull && !prePulled) || !isImagePresent(s.dockerClient, config.BuilderImage) {
 		startTime := metav1.Now()
 		err = s.pullImage(config.BuilderImage, t)
 		timing.RecordNewStep(ctx, buildapiv1.StagePullImages, buildapiv1.StepPullBaseImage, startTime, metav1.Now())
 		if err != nil {
+			RecordBuildEvent(eventTypeWarning, BuildEventReasonPullFailed, "Failed to pull image %s: %v", config.BuilderImage, err)
 			return err
 		}
 	}
 
+	recordPullThroughCacheMetrics(s.build)
+
 	// Use builder image labels to override defaults if present
 	labels, err := getImageLabels(s.dockerClient, config.BuilderImage)
 	if err != nil {
@@ -297,10 +352,22 @@ func (s *S2IBuilder) Build() error {
 		return err
 	}
 
+	helperServices, err := helperServicesFromEnvironment()
+	if err != nil {
+		return err
+	}
+	helperIDs, err := startHelperServices(s.dockerClient, s.build, helperServices)
+	if err != nil {
+		return err
+	}
+
 	glog.V(4).Infof("Starting S2I build from %s/%s BuildConfig ...", s.build.Namespace, s.build.Name)
 	glog.Infof("Generating dockerfile with builder image %s", s.build.Spec.Strategy.SourceStrategy.From.Name)
 	result, err := builder.Build(config)
 
+	// Helper services must be gone before the assemble output is committed.
+	stopHelperServices(s.dockerClient, helperIDs)
+
 	for _, stage := range result.BuildInfo.Stages {
 		for _, step := range stage.Steps {
 			timing.RecordNewStep(ctx, buildapiv1.StageName(stage.Name), buildapiv1.StepName(step.Name), metav1.NewTime(step.StartTime), metav1.NewTime(step.StartTime.Add(time.Duration(step.DurationMilliseconds)*time.Millisecond)))
@@ -323,12 +390,29 @@ func (s *S2IBuilder) Build() error {
 		return err
 	}
 
+	if len(config.RuntimeImage) > 0 {
+		if err := appendRuntimeStage(config.AsDockerfile, config); err != nil {
+			s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			s.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+			s.build.Status.Message = builderutil.StatusMessageGenericBuildFailed
+			HandleBuildStatusUpdate(s.build, s.client, nil)
+			return err
+		}
+	}
+
+	maxLogBytes, maxLogLines, err := buildLogBudgetFromEnvironment()
+	if err != nil {
+		return err
+	}
+	buildLog := newBuildLogWriter(os.Stdout, maxLogBytes, maxLogLines)
+	defer buildLog.Flush()
+
 	opts := dockerclient.BuildImageOptions{
 		Context:             ctx,
 		Name:                buildTag,
 		RmTmpContainer:      true,
 		ForceRmTmpContainer: true,
-		OutputStream:        os.Stdout,
+		OutputStream:        buildLog,
 		Dockerfile:          defaultDockerfilePath,
 		NoCache:             false,
 		Pull:                s.build.Spec.Strategy.SourceStrategy.ForcePull,
@@ -354,6 +438,7 @@ func (s *S2IBuilder) Build() error {
 		opts.AuthConfigs = *pullAuthConfigs
 	}
 
+	RecordBuildProgress(buildapiv1.StageBuild)
 	startTime := metav1.Now()
 
 	err = s.buildImage("/tmp/dockercontext", buildapiv1.ImageOptimizationNone, &opts)
@@ -378,6 +463,46 @@ func (s *S2IBuilder) Build() error {
 		return err
 	}
 
+	artifactPaths, err := artifactPathsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if len(artifactPaths) > 0 {
+		cName := containerName("s2i", s.build.Name, s.build.Namespace, "extract-artifacts")
+		if err := extractBuildArtifacts(ctx, s.dockerClient, buildTag, cName, artifactPaths); err != nil {
+			return err
+		}
+	}
+
+	if err := applyImageConfigOverrides(s.dockerClient, buildTag); err != nil {
+		s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+		s.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+		s.build.Status.Message = builderutil.StatusMessageGenericBuildFailed
+		HandleBuildStatusUpdate(s.build, s.client, nil)
+		return err
+	}
+
+	if err := RunBuildHooks(HookStagePostCommit, s.build); err != nil {
+		return err
+	}
+
+	if path := exportImagePath(); len(path) != 0 {
+		glog.V(0).Infof("Exporting image as an oci-archive instead of pushing ...")
+		RecordBuildProgress(buildapiv1.StagePushImage)
+		if err := exportImage(s.dockerClient, buildTag, path); err != nil {
+			s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			s.build.Status.Reason = buildapiv1.StatusReasonGenericBuildFailed
+			s.build.Status.Message = builderutil.StatusMessageGenericBuildFailed
+			HandleBuildStatusUpdate(s.build, s.client, nil)
+			return err
+		}
+		if err = removeImage(s.dockerClient, buildTag); err != nil {
+			glog.V(0).Infof("warning: Failed to remove temporary build tag %v: %v", buildTag, err)
+		}
+		glog.V(0).Infof("Export successful")
+		return nil
+	}
+
 	if push {
 		if err = tagImage(s.dockerClient, buildTag, pushTag); err != nil {
 			return err
@@ -389,6 +514,9 @@ func (s *S2IBuilder) Build() error {
 	}
 
 	if push {
+		if err := RunBuildHooks(HookStagePrePush, s.build); err != nil {
+			return err
+		}
 		// Get the Docker push authentication
 		pushAuthConfig, authPresent := dockercfg.NewHelper().GetDockerAuth(
 			pushTag,
@@ -400,17 +528,29 @@ func (s *S2IBuilder) Build() error {
 			glog.V(3).Infof("No push secret provided")
 		}
 		glog.V(0).Infof("\nPushing image %s ...", pushTag)
+		RecordBuildProgress(buildapiv1.StagePushImage)
 		startTime := metav1.Now()
 		digest, err := s.pushImage(pushTag, pushAuthConfig)
 
 		timing.RecordNewStep(ctx, buildapiv1.StagePushImage, buildapiv1.StepPushImage, startTime, metav1.Now())
 
 		if err != nil {
+			recordPushResults(s.build, []PushDestinationResult{{Destination: pushTag, Error: err.Error()}})
+			s.build.Status.Phase = buildapiv1.BuildPhaseFailed
+			s.build.Status.Reason, s.build.Status.Message = pushFailureStatus(err)
+			HandleBuildStatusUpdate(s.build, s.client, nil)
+			return reportPushFailure(err, authPresent, pushAuthConfig)
+		}
+
+		results := []PushDestinationResult{{Destination: pushTag, Digest: digest, Success: true}}
+		additionalResults, pushErr := pushAdditionalDestinations(s.dockerClient, pushTag, s.pushImage)
+		recordPushResults(s.build, append(results, additionalResults...))
+		if pushErr != nil {
 			s.build.Status.Phase = buildapiv1.BuildPhaseFailed
 			s.build.Status.Reason = buildapiv1.StatusReasonPushImageToRegistryFailed
 			s.build.Status.Message = builderutil.StatusMessagePushImageToRegistryFailed
 			HandleBuildStatusUpdate(s.build, s.client, nil)
-			return reportPushFailure(err, authPresent, pushAuthConfig)
+			return pushErr
 		}
 
 		if len(digest) > 0 {
@@ -420,6 +560,10 @@ func (s *S2IBuilder) Build() error {
 			HandleBuildStatusUpdate(s.build, s.client, nil)
 		}
 		glog.V(0).Infof("Push successful")
+		RecordBuildEvent(eventTypeNormal, BuildEventReasonPushCompleted, "Successfully pushed %s", pushTag)
+		if err := RunBuildHooks(HookStagePostPush, s.build); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -445,40 +589,42 @@ func (s *S2IBuilder) setupPullSecret() (*dockerclient.AuthConfigurations, error)
 
 func (s *S2IBuilder) pullImage(name string, authConfig dockerclient.AuthConfiguration) error {
 	glog.V(2).Infof("Explicitly pulling image %s", name)
-	repository, tag := dockerclient.ParseRepositoryTag(name)
-	options := dockerclient.PullImageOptions{
-		Repository: repository,
-		Tag:        tag,
-	}
-
-	if options.Tag == "" && strings.Contains(name, "@") {
-		options.Repository = name
-	}
-
-	return s.dockerClient.PullImage(options, authConfig)
+	return pullThroughCache(s.dockerClient, name, authConfig)
 }
 
 func (s *S2IBuilder) buildImage(contextdir string, optimization buildapiv1.ImageOptimizationPolicy, opts *dockerclient.BuildImageOptions) error {
+	if reproducibleBuild() {
+		timestamp := reproducibleBuildTimestamp(s.build)
+		glog.V(0).Infof("Reproducible build: normalizing source timestamps to %s", timestamp.Format(time.RFC3339))
+		if err := normalizeContextTimestamps(contextdir, timestamp); err != nil {
+			return err
+		}
+		opts.BuildArgs = append(opts.BuildArgs, dockerclient.BuildArg{Name: sourceDateEpochArg, Value: strconv.FormatInt(timestamp.Unix(), 10)})
+		// Cached layers would carry the timestamps of the build that
+		// created them.
+		opts.NoCache = true
+	}
 	if _, ok := s.dockerClient.(*dockerclient.Client); ok {
 		glog.Infof("Using imagebuilder to create image %s", opts.Name)
+		if volumes, err := buildVolumesFromEnvironment(); err != nil {
+			return err
+		} else if len(volumes) > 0 {
+			// A daemon-side build cannot bind-mount pod paths into the
+			// assemble container.
+			return fmt.Errorf("build volumes are not supported when building through a container engine daemon")
+		}
 		return buildDirectImage(contextdir, false, opts)
 		// return buildImage(s.dockerClient, "/tmp/dockercontext", tar.New(s2ifs.NewFileSystem()), &opts)
 	}
 	if dc, ok := s.dockerClient.(*DaemonlessClient); ok {
 		glog.Infof("Using buildah to create image %s", opts.Name)
-		return buildDaemonlessImage(dc.SystemContext, dc.Store, dc.Isolation, contextdir, optimization, opts)
+		return buildDaemonlessImage(dc.SystemContext, dc.Store, dc.Isolation, contextdir, optimization, sourceProvenanceAnnotations(s.build), opts)
 	}
 	return s.dockerClient.BuildImage(*opts)
 }
 
 func (s *S2IBuilder) pushImage(name string, authConfig dockerclient.AuthConfiguration) (string, error) {
-	repository, tag := dockerclient.ParseRepositoryTag(name)
-	options := dockerclient.PushImageOptions{
-		Name: repository,
-		Tag:  tag,
-	}
-	err := s.dockerClient.PushImage(options, authConfig)
-	return "", err
+	return "", PushImage(s.dockerClient, name, authConfig)
 }
 
 // buildEnvVars returns a map with build metadata to be inserted into Docker
@@ -517,14 +663,51 @@ func s2iBuildLabels(build *buildapiv1.Build, sourceInfo *git.SourceInfo) map[str
 		// differ from git's view (see PotentialPRRetryAsFetch for details).
 		labels[builderutil.DefaultDockerLabelNamespace+"build.commit.ref"] = build.Spec.Source.Git.Ref
 	}
+	addPromotionHintLabels(labels, build, sourceInfo)
 
 	// override autogenerated labels
-	for _, lbl := range build.Spec.Output.ImageLabels {
+	for _, lbl := range userImageLabels(build, sourceInfo) {
 		labels[lbl.Name] = lbl.Value
 	}
 	return labels
 }
 
+// Strategy environment variables recognized by applyS2IStrategyOverrides.
+// They let users point at custom assemble scripts or run assemble as a
+// different user without rebuilding their builder images.
+const (
+	s2iScriptsURLOverrideEnv          = "BUILD_S2I_SCRIPTS_URL"
+	s2iAssembleUserOverrideEnv        = "BUILD_S2I_ASSEMBLE_USER"
+	s2iAssembleRuntimeUserOverrideEnv = "BUILD_S2I_ASSEMBLE_RUNTIME_USER"
+)
+
+// applyS2IStrategyOverrides updates config with the S2I overrides passed in
+// the strategy's environment.
+func applyS2IStrategyOverrides(config *s2iapi.Config, build *buildapiv1.Build) error {
+	for _, env := range build.Spec.Strategy.SourceStrategy.Env {
+		switch env.Name {
+		case s2iScriptsURLOverrideEnv:
+			glog.V(2).Infof("Overriding S2I scripts URL with %s", env.Value)
+			config.ScriptsURL = env.Value
+		case s2iAssembleUserOverrideEnv:
+			glog.V(2).Infof("Overriding S2I assemble user with %s", env.Value)
+			config.AssembleUser = env.Value
+		case s2iAssembleRuntimeUserOverrideEnv:
+			// The vendored source-to-image has no per-user knob for the
+			// runtime stage of extended builds.
+			glog.V(0).Infof("warning: %s is not supported by this builder and was ignored", s2iAssembleRuntimeUserOverrideEnv)
+		case s2iRuntimeImageOverrideEnv:
+			glog.V(2).Infof("Using runtime image %s for an extended build", env.Value)
+			config.RuntimeImage = env.Value
+		case s2iRuntimeArtifactsOverrideEnv:
+			if err := config.RuntimeArtifacts.Set(env.Value); err != nil {
+				return fmt.Errorf("invalid %s: %v", s2iRuntimeArtifactsOverrideEnv, err)
+			}
+		}
+	}
+	return nil
+}
+
 // scriptProxyConfig determines a proxy configuration for downloading
 // scripts from a URL. For now, it uses environment variables passed in
 // the strategy's environment. There is no preference given to either lowercase
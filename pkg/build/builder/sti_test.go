@@ -294,3 +294,40 @@ func TestScriptProxyConfig(t *testing.T) {
 		t.Errorf("Expected HTTPS Proxy path to be /secure, got: %v", resultedProxyConf.HTTPSProxy.Path)
 	}
 }
+
+func TestApplyS2IStrategyOverrides(t *testing.T) {
+	build := makeBuild()
+	build.Spec.Strategy.SourceStrategy.Scripts = "image:///usr/libexec/s2i"
+	build.Spec.Strategy.SourceStrategy.Env = append(build.Spec.Strategy.SourceStrategy.Env,
+		corev1.EnvVar{Name: s2iScriptsURLOverrideEnv, Value: "https://example.com/scripts"},
+		corev1.EnvVar{Name: s2iAssembleUserOverrideEnv, Value: "1001"},
+	)
+	config := &s2iapi.Config{ScriptsURL: build.Spec.Strategy.SourceStrategy.Scripts}
+	if err := applyS2IStrategyOverrides(config, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ScriptsURL != "https://example.com/scripts" {
+		t.Errorf("unexpected scripts URL %q", config.ScriptsURL)
+	}
+	if config.AssembleUser != "1001" {
+		t.Errorf("unexpected assemble user %q", config.AssembleUser)
+	}
+}
+
+func TestApplyS2IStrategyOverridesRuntimeImage(t *testing.T) {
+	build := makeBuild()
+	build.Spec.Strategy.SourceStrategy.Env = append(build.Spec.Strategy.SourceStrategy.Env,
+		corev1.EnvVar{Name: s2iRuntimeImageOverrideEnv, Value: "registry.example.com/runtime:latest"},
+		corev1.EnvVar{Name: s2iRuntimeArtifactsOverrideEnv, Value: "/opt/app-root/output:/opt/app-root"},
+	)
+	config := &s2iapi.Config{}
+	if err := applyS2IStrategyOverrides(config, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.RuntimeImage != "registry.example.com/runtime:latest" {
+		t.Errorf("unexpected runtime image %q", config.RuntimeImage)
+	}
+	if len(config.RuntimeArtifacts) != 1 || config.RuntimeArtifacts[0].Source != "/opt/app-root/output" {
+		t.Errorf("unexpected runtime artifacts %#v", config.RuntimeArtifacts)
+	}
+}
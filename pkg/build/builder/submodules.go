@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SkipSubmodulesEnvVar disables submodule fetching entirely when set to
+// "true". Builds that vendor large submodules they do not need during the
+// image build can skip the extra clones.
+const SkipSubmodulesEnvVar = "BUILD_SKIP_SUBMODULES"
+
+// SubmoduleDepthEnvVar limits the history depth of submodule clones to the
+// given positive number of commits. Unset or zero clones submodules with
+// full history.
+const SubmoduleDepthEnvVar = "BUILD_SUBMODULE_DEPTH"
+
+// skipSubmodules reports whether submodule fetching is disabled for this
+// build.
+func skipSubmodules() bool {
+	return strings.EqualFold(os.Getenv(SkipSubmodulesEnvVar), "true")
+}
+
+// submoduleDepthFromEnvironment parses BUILD_SUBMODULE_DEPTH, returning zero
+// when no depth limit is configured.
+func submoduleDepthFromEnvironment() (int, error) {
+	value := os.Getenv(SubmoduleDepthEnvVar)
+	if len(value) == 0 {
+		return 0, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", SubmoduleDepthEnvVar, value)
+	}
+	return depth, nil
+}
+
+// updateSubmodulesWithDepth updates submodules like GitClient.SubmoduleUpdate,
+// but limits each submodule clone to the given history depth. The GitClient
+// interface has no depth parameter, so this shells out to git directly.
+func updateSubmodulesWithDepth(dir string, depth int) error {
+	cmd := exec.Command("git", "submodule", "update", "--init", "--recursive", "--depth", strconv.Itoa(depth))
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("updating submodules with depth %d failed: %v\n%s", depth, err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSubmoduleDepthFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value         string
+		expectedDepth int
+		expectError   bool
+	}{
+		{value: "", expectedDepth: 0},
+		{value: "1", expectedDepth: 1},
+		{value: "25", expectedDepth: 25},
+		{value: "0", expectError: true},
+		{value: "-1", expectError: true},
+		{value: "full", expectError: true},
+	}
+	defer os.Unsetenv(SubmoduleDepthEnvVar)
+	for _, tc := range testCases {
+		os.Setenv(SubmoduleDepthEnvVar, tc.value)
+		depth, err := submoduleDepthFromEnvironment()
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("%q: expected error, got depth %d", tc.value, depth)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if depth != tc.expectedDepth {
+			t.Errorf("%q: expected depth %d, got %d", tc.value, tc.expectedDepth, depth)
+		}
+	}
+}
+
+func TestSkipSubmodules(t *testing.T) {
+	testCases := map[string]bool{
+		"":      false,
+		"true":  true,
+		"TRUE":  true,
+		"false": false,
+		"1":     false,
+	}
+	defer os.Unsetenv(SkipSubmodulesEnvVar)
+	for value, expected := range testCases {
+		os.Setenv(SkipSubmodulesEnvVar, value)
+		if actual := skipSubmodules(); actual != expected {
+			t.Errorf("%q: expected %v, got %v", value, expected, actual)
+		}
+	}
+}
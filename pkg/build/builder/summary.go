@@ -0,0 +1,136 @@
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+// BuildSummaryPathEnvVar overrides where the machine-readable build summary
+// is written. When unset the summary goes to DefaultBuildSummaryPath.
+const BuildSummaryPathEnvVar = "BUILD_SUMMARY_PATH"
+
+// BuildSummaryTerminationLogEnvVar, when "true", additionally writes the
+// summary to the container termination log, where automation can read it
+// from the pod status without access to the build pod's filesystem.
+const BuildSummaryTerminationLogEnvVar = "BUILD_SUMMARY_TERMINATION_LOG"
+
+// DefaultBuildSummaryPath is the well-known location of the build summary.
+const DefaultBuildSummaryPath = "/tmp/build-summary.json"
+
+// terminationLogPath is a variable so tests can redirect it.
+var terminationLogPath = "/dev/termination-log"
+
+// terminationLogSizeLimit is the kubelet's limit on termination log
+// content; longer summaries are truncated rather than rejected.
+const terminationLogSizeLimit = 4096
+
+// maxSummaryWarnings bounds the collected warnings so a build that retries
+// in a tight loop cannot grow the summary without limit.
+const maxSummaryWarnings = 50
+
+// BuildSummary is the machine-readable result of a build, written on exit
+// for automation that cannot parse the build log.
+type BuildSummary struct {
+	Name             string                  `json:"name,omitempty"`
+	Namespace        string                  `json:"namespace,omitempty"`
+	Phase            buildapiv1.BuildPhase   `json:"phase"`
+	Reason           buildapiv1.StatusReason `json:"reason,omitempty"`
+	Message          string                  `json:"message,omitempty"`
+	Error            string                  `json:"error,omitempty"`
+	OutputImage      string                  `json:"outputImage,omitempty"`
+	ImageDigest      string                  `json:"imageDigest,omitempty"`
+	BaseImageDigests map[string]string       `json:"baseImageDigests,omitempty"`
+	Stages           []buildapiv1.StageInfo  `json:"stages,omitempty"`
+	Warnings         []string                `json:"warnings,omitempty"`
+}
+
+var summaryWarnings = struct {
+	sync.Mutex
+	messages []string
+}{}
+
+// recordSummaryWarning collects a warning for the build summary. Warnings
+// funnel through RecordBuildEvent, so the collector is package state like
+// the event sink.
+func recordSummaryWarning(message string) {
+	summaryWarnings.Lock()
+	defer summaryWarnings.Unlock()
+	if len(summaryWarnings.messages) >= maxSummaryWarnings {
+		return
+	}
+	summaryWarnings.messages = append(summaryWarnings.messages, message)
+}
+
+// buildSummaryFor assembles the summary from the build's final status and
+// the warnings collected while it ran. buildErr is the error the builder is
+// about to exit with, if any.
+func buildSummaryFor(build *buildapiv1.Build, buildErr error) BuildSummary {
+	summary := BuildSummary{
+		Name:      build.Name,
+		Namespace: build.Namespace,
+		Phase:     build.Status.Phase,
+		Reason:    build.Status.Reason,
+		Message:   build.Status.Message,
+		Stages:    build.Status.Stages,
+	}
+	if len(summary.Phase) == 0 {
+		summary.Phase = buildapiv1.BuildPhaseComplete
+		if buildErr != nil {
+			summary.Phase = buildapiv1.BuildPhaseFailed
+		}
+	}
+	if buildErr != nil {
+		summary.Error = buildErr.Error()
+	}
+	summary.OutputImage = build.Status.OutputDockerImageReference
+	if build.Status.Output.To != nil {
+		summary.ImageDigest = build.Status.Output.To.ImageDigest
+	}
+	if pinned, exists := build.Annotations[BaseImageDigestsAnnotation]; exists {
+		digests := map[string]string{}
+		if err := json.Unmarshal([]byte(pinned), &digests); err == nil {
+			summary.BaseImageDigests = digests
+		}
+	}
+	summaryWarnings.Lock()
+	summary.Warnings = append([]string{}, summaryWarnings.messages...)
+	summaryWarnings.Unlock()
+	return summary
+}
+
+// WriteBuildSummary writes the machine-readable build summary to its
+// well-known path, and to the termination log when configured. It runs on
+// both success and failure, and its own problems are only logged - a build
+// must not fail over its summary.
+func WriteBuildSummary(build *buildapiv1.Build, buildErr error) {
+	if build == nil {
+		return
+	}
+	serialized, err := json.Marshal(buildSummaryFor(build, buildErr))
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to serialize the build summary: %v", err)
+		return
+	}
+	path := os.Getenv(BuildSummaryPathEnvVar)
+	if len(path) == 0 {
+		path = DefaultBuildSummaryPath
+	}
+	if err := ioutil.WriteFile(path, serialized, 0644); err != nil {
+		glog.V(0).Infof("warning: Unable to write the build summary to %s: %v", path, err)
+	} else {
+		glog.V(2).Infof("Wrote the build summary to %s", path)
+	}
+	if os.Getenv(BuildSummaryTerminationLogEnvVar) == "true" {
+		content := serialized
+		if len(content) > terminationLogSizeLimit {
+			content = content[:terminationLogSizeLimit]
+		}
+		if err := ioutil.WriteFile(terminationLogPath, content, 0644); err != nil {
+			glog.V(0).Infof("warning: Unable to write the build summary to the termination log: %v", err)
+		}
+	}
+}
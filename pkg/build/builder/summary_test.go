@@ -0,0 +1,146 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildapiv1 "github.com/openshift/api/build/v1"
+)
+
+func TestBuildSummaryFor(t *testing.T) {
+	build := &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-1",
+			Namespace: "project",
+			Annotations: map[string]string{
+				BaseImageDigestsAnnotation: `{"registry.example.com/base:latest":"registry.example.com/base@sha256:0000000000000000000000000000000000000000000000000000000000000001"}`,
+			},
+		},
+		Status: buildapiv1.BuildStatus{
+			Phase:                      buildapiv1.BuildPhaseFailed,
+			Reason:                     buildapiv1.StatusReasonPullBuilderImageFailed,
+			Message:                    "Failed pulling builder image.",
+			OutputDockerImageReference: "registry.example.com/project/app:latest",
+			Output: buildapiv1.BuildStatusOutput{
+				To: &buildapiv1.BuildStatusOutputTo{
+					ImageDigest: "sha256:0000000000000000000000000000000000000000000000000000000000000002",
+				},
+			},
+			Stages: []buildapiv1.StageInfo{
+				{Name: buildapiv1.StagePullImages, DurationMilliseconds: 1500},
+			},
+		},
+	}
+
+	summary := buildSummaryFor(build, errors.New("failed to pull image: no route to host"))
+	if summary.Name != "app-1" || summary.Namespace != "project" {
+		t.Errorf("unexpected identity: %q/%q", summary.Namespace, summary.Name)
+	}
+	if summary.Phase != buildapiv1.BuildPhaseFailed {
+		t.Errorf("phase = %q; expected %q", summary.Phase, buildapiv1.BuildPhaseFailed)
+	}
+	if summary.Reason != buildapiv1.StatusReasonPullBuilderImageFailed {
+		t.Errorf("reason = %q; expected %q", summary.Reason, buildapiv1.StatusReasonPullBuilderImageFailed)
+	}
+	if summary.Error != "failed to pull image: no route to host" {
+		t.Errorf("error = %q", summary.Error)
+	}
+	if summary.OutputImage != "registry.example.com/project/app:latest" {
+		t.Errorf("outputImage = %q", summary.OutputImage)
+	}
+	if summary.ImageDigest != "sha256:0000000000000000000000000000000000000000000000000000000000000002" {
+		t.Errorf("imageDigest = %q", summary.ImageDigest)
+	}
+	if len(summary.BaseImageDigests) != 1 {
+		t.Errorf("baseImageDigests = %v; expected one entry", summary.BaseImageDigests)
+	}
+	if len(summary.Stages) != 1 || summary.Stages[0].Name != buildapiv1.StagePullImages {
+		t.Errorf("stages = %v; expected the recorded pull stage", summary.Stages)
+	}
+}
+
+func TestBuildSummaryForDefaultsPhase(t *testing.T) {
+	tests := []struct {
+		name     string
+		buildErr error
+		expected buildapiv1.BuildPhase
+	}{
+		{
+			name:     "no error",
+			expected: buildapiv1.BuildPhaseComplete,
+		},
+		{
+			name:     "with error",
+			buildErr: errors.New("build error"),
+			expected: buildapiv1.BuildPhaseFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := buildSummaryFor(&buildapiv1.Build{}, test.buildErr)
+			if summary.Phase != test.expected {
+				t.Errorf("phase = %q; expected %q", summary.Phase, test.expected)
+			}
+		})
+	}
+}
+
+func TestBuildSummaryForIncludesWarnings(t *testing.T) {
+	RecordBuildEvent(eventTypeWarning, BuildEventReasonRetried, "Pull of image failed, retrying: %v", errors.New("i/o timeout"))
+	summary := buildSummaryFor(&buildapiv1.Build{}, nil)
+	found := false
+	for _, warning := range summary.Warnings {
+		if warning == "Pull of image failed, retrying: i/o timeout" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("warnings %v do not include the recorded warning", summary.Warnings)
+	}
+}
+
+func TestWriteBuildSummary(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.json")
+	os.Setenv(BuildSummaryPathEnvVar, summaryPath)
+	defer os.Unsetenv(BuildSummaryPathEnvVar)
+	os.Setenv(BuildSummaryTerminationLogEnvVar, "true")
+	defer os.Unsetenv(BuildSummaryTerminationLogEnvVar)
+	originalTerminationLogPath := terminationLogPath
+	terminationLogPath = filepath.Join(dir, "termination-log")
+	defer func() { terminationLogPath = originalTerminationLogPath }()
+
+	build := &buildapiv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1"},
+		Status: buildapiv1.BuildStatus{
+			Phase: buildapiv1.BuildPhaseComplete,
+		},
+	}
+	WriteBuildSummary(build, nil)
+
+	content, err := ioutil.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("unable to read the summary: %v", err)
+	}
+	summary := BuildSummary{}
+	if err := json.Unmarshal(content, &summary); err != nil {
+		t.Fatalf("summary %q is not JSON: %v", string(content), err)
+	}
+	if summary.Name != "app-1" || summary.Phase != buildapiv1.BuildPhaseComplete {
+		t.Errorf("unexpected summary: %#v", summary)
+	}
+
+	terminationContent, err := ioutil.ReadFile(terminationLogPath)
+	if err != nil {
+		t.Fatalf("unable to read the termination log: %v", err)
+	}
+	if string(terminationContent) != string(content) {
+		t.Errorf("termination log %q differs from the summary %q", string(terminationContent), string(content))
+	}
+}
@@ -0,0 +1,83 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	dockercmd "github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+
+	"github.com/openshift/builder/pkg/build/builder/util/dockerfile"
+	"github.com/openshift/imagebuilder"
+)
+
+// DockerfileTargetEnvVar names the stage of a multi-stage Dockerfile to
+// build, like the --target flag of a command-line build. Stages after the
+// named one are dropped, so an intermediate stage (e.g. one running tests)
+// can be built and pushed without editing the Dockerfile. The whole
+// Dockerfile is built when unset.
+const DockerfileTargetEnvVar = "BUILD_DOCKERFILE_TARGET"
+
+// dockerfileTargetFromEnvironment reads the requested target stage.
+func dockerfileTargetFromEnvironment() string {
+	return strings.TrimSpace(os.Getenv(DockerfileTargetEnvVar))
+}
+
+// applyDockerfileTarget truncates the Dockerfile after the named stage, so
+// the build's output image is that stage. Stage names match
+// case-insensitively, the way container engines treat them. A target that
+// does not name any stage is an error, caught here rather than deep in the
+// build.
+func applyDockerfileTarget(dockerfilePath string, target string) error {
+	in, err := ioutil.ReadFile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	node, err := imagebuilder.ParseDockerfile(strings.NewReader(string(in)))
+	if err != nil {
+		return err
+	}
+	end, err := targetStageEnd(node, target)
+	if err != nil {
+		return err
+	}
+	if end == len(node.Children) {
+		// The target already is the final stage.
+		return nil
+	}
+	glog.V(0).Infof("Building Dockerfile stage %q", target)
+	node.Children = node.Children[:end]
+	return overwriteFile(dockerfilePath, dockerfile.Write(node))
+}
+
+// targetStageEnd returns the index just past the last instruction of the
+// named stage.
+func targetStageEnd(node *parser.Node, target string) (int, error) {
+	stageStart := -1
+	for i, child := range node.Children {
+		if child.Value != dockercmd.From {
+			continue
+		}
+		if stageStart >= 0 {
+			// The previous FROM opened the target stage, which ends here.
+			return i, nil
+		}
+		if alias := stageAlias(child); len(alias) != 0 && strings.EqualFold(alias, target) {
+			stageStart = i
+		}
+	}
+	if stageStart >= 0 {
+		return len(node.Children), nil
+	}
+	return 0, fmt.Errorf("the requested build stage %q was not found in the Dockerfile", target)
+}
+
+// stageAlias returns the name a FROM instruction declares with AS, if any.
+func stageAlias(from *parser.Node) string {
+	if from.Next != nil && from.Next.Next != nil && strings.EqualFold(from.Next.Next.Value, "as") && from.Next.Next.Next != nil {
+		return from.Next.Next.Next.Value
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyDockerfileTarget(t *testing.T) {
+	dockerfile := `FROM base:v1 AS builder
+RUN make
+FROM builder AS test
+RUN make check
+FROM scratch
+COPY --from=builder /bin/app /app
+`
+	testCases := []struct {
+		name         string
+		target       string
+		expectErr    bool
+		expectLast   string
+		expectLength int
+	}{
+		{name: "intermediate stage", target: "test", expectLast: "RUN make check", expectLength: 4},
+		{name: "case-insensitive", target: "TEST", expectLast: "RUN make check", expectLength: 4},
+		{name: "first stage", target: "builder", expectLast: "RUN make", expectLength: 2},
+		{name: "final stage keeps the file", target: "", expectErr: true},
+		{name: "unknown stage", target: "release", expectErr: true},
+	}
+	for _, tc := range testCases {
+		dir, err := ioutil.TempDir("", "targetstage-test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		path := filepath.Join(dir, "Dockerfile")
+		if err := ioutil.WriteFile(path, []byte(dockerfile), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = applyDockerfileTarget(path, tc.target)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+			os.RemoveAll(dir)
+			continue
+		}
+		if !tc.expectErr {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", tc.name, err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			if len(lines) != tc.expectLength {
+				t.Errorf("%s: expected %d instructions, got %q", tc.name, tc.expectLength, string(content))
+			} else if lines[len(lines)-1] != tc.expectLast {
+				t.Errorf("%s: expected the file to end with %q, got %q", tc.name, tc.expectLast, lines[len(lines)-1])
+			}
+		}
+		os.RemoveAll(dir)
+	}
+}
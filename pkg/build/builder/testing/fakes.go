@@ -0,0 +1,185 @@
+// Package testing ships test doubles for the builder's dependencies, so
+// downstream contributors can exercise build flows in unit tests without a
+// container engine, a storage store, or an API server.
+package testing
+
+import (
+	"sync"
+
+	"github.com/containers/storage"
+	docker "github.com/fsouza/go-dockerclient"
+
+	bld "github.com/openshift/builder/pkg/build/builder"
+	buildfake "github.com/openshift/client-go/build/clientset/versioned/fake"
+	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+)
+
+// FakeDockerClient implements builder.DockerClient. Behavior is injected
+// through the *Func fields; unset functions succeed with zero values. Every
+// call is appended to Calls for assertions.
+type FakeDockerClient struct {
+	BuildImageFunc            func(opts docker.BuildImageOptions) error
+	PushImageFunc             func(opts docker.PushImageOptions, auth docker.AuthConfiguration) error
+	PullImageFunc             func(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	RemoveImageFunc           func(name string) error
+	InspectImageFunc          func(name string) (*docker.Image, error)
+	TagImageFunc              func(name string, opts docker.TagImageOptions) error
+	CreateContainerFunc       func(opts docker.CreateContainerOptions) (*docker.Container, error)
+	RemoveContainerFunc       func(opts docker.RemoveContainerOptions) error
+	DownloadFromContainerFunc func(id string, opts docker.DownloadFromContainerOptions) error
+
+	mutex sync.Mutex
+	calls []Call
+}
+
+var _ bld.DockerClient = &FakeDockerClient{}
+
+// Call records one method invocation on a FakeDockerClient.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Calls returns a copy of the recorded invocations.
+func (f *FakeDockerClient) Calls() []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Call{}, f.calls...)
+}
+
+func (f *FakeDockerClient) record(method string, args ...interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+}
+
+func (f *FakeDockerClient) BuildImage(opts docker.BuildImageOptions) error {
+	f.record("BuildImage", opts)
+	if f.BuildImageFunc != nil {
+		return f.BuildImageFunc(opts)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) PushImage(opts docker.PushImageOptions, auth docker.AuthConfiguration) error {
+	f.record("PushImage", opts, auth)
+	if f.PushImageFunc != nil {
+		return f.PushImageFunc(opts, auth)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	f.record("PullImage", opts, auth)
+	if f.PullImageFunc != nil {
+		return f.PullImageFunc(opts, auth)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) RemoveImage(name string) error {
+	f.record("RemoveImage", name)
+	if f.RemoveImageFunc != nil {
+		return f.RemoveImageFunc(name)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) InspectImage(name string) (*docker.Image, error) {
+	f.record("InspectImage", name)
+	if f.InspectImageFunc != nil {
+		return f.InspectImageFunc(name)
+	}
+	return &docker.Image{}, nil
+}
+
+func (f *FakeDockerClient) TagImage(name string, opts docker.TagImageOptions) error {
+	f.record("TagImage", name, opts)
+	if f.TagImageFunc != nil {
+		return f.TagImageFunc(name, opts)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	f.record("CreateContainer", opts)
+	if f.CreateContainerFunc != nil {
+		return f.CreateContainerFunc(opts)
+	}
+	return &docker.Container{}, nil
+}
+
+func (f *FakeDockerClient) RemoveContainer(opts docker.RemoveContainerOptions) error {
+	f.record("RemoveContainer", opts)
+	if f.RemoveContainerFunc != nil {
+		return f.RemoveContainerFunc(opts)
+	}
+	return nil
+}
+
+func (f *FakeDockerClient) DownloadFromContainer(id string, opts docker.DownloadFromContainerOptions) error {
+	f.record("DownloadFromContainer", id, opts)
+	if f.DownloadFromContainerFunc != nil {
+		return f.DownloadFromContainerFunc(id, opts)
+	}
+	return nil
+}
+
+// NewFakeBuildsClient returns an in-memory builds client seeded with the
+// given builds, for code paths that post build status updates.
+func NewFakeBuildsClient(namespace string) buildclientv1.BuildInterface {
+	client := &buildfake.Clientset{}
+	return client.Build().Builds(namespace)
+}
+
+// FakeStore is a minimal in-memory stand-in for storage.Store. Only the
+// image listing and naming methods commonly used by the builder are
+// implemented; calling anything else panics through the embedded nil
+// interface, which keeps unintended storage usage visible in tests.
+type FakeStore struct {
+	storage.Store
+
+	mutex  sync.Mutex
+	images []storage.Image
+}
+
+// NewFakeStore returns a FakeStore holding the given images.
+func NewFakeStore(images []storage.Image) *FakeStore {
+	return &FakeStore{images: append([]storage.Image{}, images...)}
+}
+
+func (f *FakeStore) Images() ([]storage.Image, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]storage.Image{}, f.images...), nil
+}
+
+func (f *FakeStore) Image(id string) (*storage.Image, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i := range f.images {
+		if f.images[i].ID == id {
+			image := f.images[i]
+			return &image, nil
+		}
+		for _, name := range f.images[i].Names {
+			if name == id {
+				image := f.images[i]
+				return &image, nil
+			}
+		}
+	}
+	return nil, storage.ErrImageUnknown
+}
+
+func (f *FakeStore) SetNames(id string, names []string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i := range f.images {
+		if f.images[i].ID == id {
+			f.images[i].Names = append([]string{}, names...)
+			return nil
+		}
+	}
+	return storage.ErrImageUnknown
+}
@@ -0,0 +1,81 @@
+package testing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/containers/storage"
+	docker "github.com/fsouza/go-dockerclient"
+
+	bld "github.com/openshift/builder/pkg/build/builder"
+)
+
+func TestFakeDockerClient(t *testing.T) {
+	testCases := []struct {
+		name      string
+		configure func(*FakeDockerClient)
+		operate   func(bld.DockerClient) error
+		expectErr bool
+	}{
+		{
+			name:    "default pull succeeds",
+			operate: func(c bld.DockerClient) error { return bld.PullImage(c, "busybox", docker.AuthConfiguration{}) },
+		},
+		{
+			name: "injected pull failure",
+			configure: func(f *FakeDockerClient) {
+				f.PullImageFunc = func(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+					return fmt.Errorf("no route to host")
+				}
+			},
+			operate:   func(c bld.DockerClient) error { return bld.PullImage(c, "busybox", docker.AuthConfiguration{}) },
+			expectErr: true,
+		},
+		{
+			name: "default push succeeds",
+			operate: func(c bld.DockerClient) error {
+				return bld.PushImage(c, "registry.example.com/app:latest", docker.AuthConfiguration{})
+			},
+		},
+	}
+	for _, tc := range testCases {
+		fake := &FakeDockerClient{}
+		if tc.configure != nil {
+			tc.configure(fake)
+		}
+		err := tc.operate(fake)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+		}
+		if len(fake.Calls()) == 0 {
+			t.Errorf("%s: expected the call to be recorded", tc.name)
+		}
+	}
+}
+
+func TestFakeStore(t *testing.T) {
+	store := NewFakeStore([]storage.Image{
+		{ID: "image1", Names: []string{"registry.example.com/app:latest"}},
+	})
+
+	if _, err := store.Image("image1"); err != nil {
+		t.Errorf("lookup by ID: unexpected error: %v", err)
+	}
+	if _, err := store.Image("registry.example.com/app:latest"); err != nil {
+		t.Errorf("lookup by name: unexpected error: %v", err)
+	}
+	if _, err := store.Image("absent"); err != storage.ErrImageUnknown {
+		t.Errorf("lookup of missing image: expected ErrImageUnknown, got %v", err)
+	}
+
+	if err := store.SetNames("image1", []string{"other:latest"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image, err := store.Image("other:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if image.ID != "image1" {
+		t.Errorf("expected image1, got %s", image.ID)
+	}
+}
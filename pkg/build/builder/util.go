@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -69,6 +70,13 @@ func reportPushFailure(err error, authPresent bool, pushAuthConfig docker.AuthCo
 		}
 		glog.V(0).Infof("Registry server Password: %s", passwordPresent)
 	}
+	class := classifyPushFailure(err)
+	if remediation := pushFailureRemediation(class); len(remediation) > 0 {
+		glog.V(0).Infof("%s", remediation)
+	}
+	if isPermanentPushFailure(class) {
+		glog.V(0).Infof("This failure is permanent; the push will not be retried.")
+	}
 	return fmt.Errorf("Failed to push image: %v", err)
 }
 
@@ -103,6 +111,48 @@ func readInt64(filePath string) (int64, error) {
 	return val, nil
 }
 
+// parseCgroupV2Limit parses a single-value cgroup v2 limit file such as
+// memory.max. The literal "max" means the limit is not set.
+func parseCgroupV2Limit(content string) (value int64, unlimited bool, err error) {
+	content = strings.TrimSpace(content)
+	if content == "max" {
+		return 0, true, nil
+	}
+	value, err = strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("unprocessable cgroup limit value %q: %v", content, err)
+	}
+	return value, false, nil
+}
+
+// parseCgroupV2CPUMax parses the "quota period" form of the cgroup v2
+// cpu.max file. An unset quota ("max") is reported as zero, matching how an
+// absent v1 cpu.cfs_quota_us is treated.
+func parseCgroupV2CPUMax(content string) (quota int64, period int64, err error) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unprocessable cgroup cpu.max value %q", strings.TrimSpace(content))
+	}
+	if fields[0] != "max" {
+		quota, err = strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unprocessable cgroup cpu.max quota %q: %v", fields[0], err)
+		}
+	}
+	period, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unprocessable cgroup cpu.max period %q: %v", fields[1], err)
+	}
+	return quota, period, nil
+}
+
+// cgroupV2WeightToShares converts a cgroup v2 cpu.weight value (1..10000)
+// back to the cgroup v1 shares range (2..262144) the container engines
+// accept, inverting the conversion the runtime applied on the way in.
+func cgroupV2WeightToShares(weight int64) int64 {
+	return ((weight-1)*262142)/9999 + 2
+}
+
 // readNetClsCGroup parses /proc/self/cgroup in order to determine the container id that can be used
 // the network namespace that this process is running on, it returns the cgroup and container type
 // (docker vs crio).
@@ -141,6 +191,11 @@ func readNetClsCGroup(reader io.Reader) (string, string) {
 // extractParentFromCgroupMap finds the cgroup parent in the cgroup map
 func extractParentFromCgroupMap(cgMap map[string]string) (string, error) {
 	memory, ok := cgMap["memory"]
+	if !ok {
+		// The cgroup v2 unified hierarchy records a single entry with an
+		// empty controller list instead of per-controller paths.
+		memory, ok = cgMap[""]
+	}
 	if !ok {
 		return "", fmt.Errorf("could not find memory cgroup subsystem in map %v", cgMap)
 	}
@@ -198,19 +253,44 @@ func SafeForLoggingS2IConfig(config *s2iapi.Config) *s2iapi.Config {
 }
 
 // GetDockerAuthConfiguration provides a Docker authentication configuration when the
-// PullSecret is specified.
+// PullSecret is specified. The path may name several mounted secrets,
+// separated like PATH; their entries are merged, with earlier paths taking
+// precedence per registry.
 func GetDockerAuthConfiguration(path string) (*docker.AuthConfigurations, error) {
 	glog.V(2).Infof("Checking for Docker config file for %s in path %s", dockercfg.PullAuthType, path)
-	dockercfgPath := dockercfg.GetDockercfgFile(path)
-	if len(dockercfgPath) == 0 {
-		return nil, fmt.Errorf("no docker config file found in '%s'", os.Getenv(dockercfg.PullAuthType))
+	merged := &docker.AuthConfigurations{Configs: map[string]docker.AuthConfiguration{}}
+	found := false
+	for _, searchPath := range filepath.SplitList(path) {
+		if len(searchPath) == 0 {
+			continue
+		}
+		dockercfgPath := dockercfg.GetDockercfgFile(searchPath)
+		if len(dockercfgPath) == 0 {
+			continue
+		}
+		glog.V(2).Infof("Using Docker config file %s", dockercfgPath)
+		r, err := os.Open(dockercfgPath)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %s", dockercfgPath, err)
+		}
+		auths, err := docker.NewAuthConfigurations(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %s", dockercfgPath, err)
+		}
+		found = true
+		for registry, conf := range auths.Configs {
+			if _, ok := merged.Configs[registry]; ok {
+				continue
+			}
+			merged.Configs[registry] = conf
+			glog.V(3).Infof("Using credentials from %s for registry %q", dockercfgPath, registry)
+		}
 	}
-	glog.V(2).Infof("Using Docker config file %s", dockercfgPath)
-	r, err := os.Open(dockercfgPath)
-	if err != nil {
-		return nil, fmt.Errorf("'%s': %s", dockercfgPath, err)
+	if !found {
+		return nil, fmt.Errorf("no docker config file found in '%s'", path)
 	}
-	return docker.NewAuthConfigurations(r)
+	return merged, nil
 }
 
 // ReadLines reads the content of the given file into a string slice
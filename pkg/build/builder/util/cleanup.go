@@ -0,0 +1,73 @@
+package util
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	utilglog "github.com/openshift/builder/pkg/build/builder/util/glog"
+)
+
+var cleanupGlog = utilglog.ToFile(os.Stderr, 2)
+
+// secretTempTracker registers secret-bearing temporary files and directories
+// so they are reliably removed when the build ends, replacing scattered
+// "defer os.RemoveAll" calls whose deferred functions never run on a panic in
+// another goroutine or on a termination signal.
+type secretTempTracker struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+var (
+	secretTemps          = &secretTempTracker{}
+	secretTempSignalOnce sync.Once
+)
+
+// TrackSecretTemp registers a secret-bearing temporary path for guaranteed
+// removal by CleanupSecretTemps. The first registration installs a signal
+// handler so the paths are also removed when the process is terminated.
+func TrackSecretTemp(path string) {
+	if len(path) == 0 {
+		return
+	}
+	secretTempSignalOnce.Do(installSecretTempSignalHandler)
+	secretTemps.mutex.Lock()
+	defer secretTemps.mutex.Unlock()
+	secretTemps.paths = append(secretTemps.paths, path)
+	cleanupGlog.V(5).Infof("Tracking secret temporary path %s", path)
+}
+
+// CleanupSecretTemps removes every registered path and logs an audit trail of
+// what was cleaned. It is safe to call multiple times; each path is removed
+// only once.
+func CleanupSecretTemps() {
+	secretTemps.mutex.Lock()
+	paths := secretTemps.paths
+	secretTemps.paths = nil
+	secretTemps.mutex.Unlock()
+
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			cleanupGlog.V(0).Infof("warning: Unable to remove secret temporary path %s: %v", path, err)
+			continue
+		}
+		cleanupGlog.V(2).Infof("Removed secret temporary path %s", path)
+	}
+}
+
+func installSecretTempSignalHandler() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		CleanupSecretTemps()
+		// Restore the default disposition and re-raise, so the process
+		// still terminates with the expected status.
+		signal.Stop(signals)
+		if process, err := os.FindProcess(os.Getpid()); err == nil {
+			process.Signal(sig)
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSecretTempCleanup(t *testing.T) {
+	file, err := ioutil.TempFile("", "cleanup-test-file")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file.Close()
+	dir, err := ioutil.TempDir("", "cleanup-test-dir")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(dir+"/secret", []byte("secret"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	TrackSecretTemp(file.Name())
+	TrackSecretTemp(dir)
+	TrackSecretTemp("")
+
+	CleanupSecretTemps()
+	for _, path := range []string{file.Name(), dir} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+
+	// A second call has nothing left to do.
+	CleanupSecretTemps()
+}
@@ -25,6 +25,10 @@ const (
 	StatusMessagePullBuilderImageFailed          = "Failed pulling builder image."
 	StatusMessageFetchSourceFailed               = "Failed to fetch the input source."
 	StatusMessageInvalidContextDirectory         = "The supplied context directory does not exist."
+	StatusMessageContextDirNotAllowed            = "The supplied context directory is not allowed by the cluster build policy."
+	StatusMessageWaitingForCacheLock             = "Waiting for the shared cache volume lock."
+	StatusMessageSourceRevisionMismatch          = "The checked-out commit does not match the revision in the build spec."
+	StatusMessageInvalidOutputReference          = "The output image reference in the build spec is not valid."
 	StatusMessageCancelledBuild                  = "The build was cancelled by the user."
 	StatusMessageDockerBuildFailed               = "Docker build strategy has failed."
 	StatusMessageBuildPodExists                  = "The pod for this build already exists and is older than the build."
@@ -33,4 +37,10 @@ const (
 	StatusMessageGenericBuildFailed              = "Generic Build failure - check logs for details."
 	StatusMessageUnresolvableEnvironmentVariable = "Unable to resolve build environment variable reference."
 	StatusMessageCannotRetrieveServiceAccount    = "Unable to look up the service account associated with this build."
+	StatusMessageOnBuildForbidden                = "ONBUILD instructions are not allowed in this cluster."
+	StatusMessageInputChecksumMismatch           = "The uploaded binary build input does not match the declared checksum."
+	StatusMessageImageRejectedByPolicy           = "An input image was rejected by the signature policy."
+	StatusMessageBaseImageSignatureUnverified    = "The sigstore signature of an input image could not be verified."
+	StatusMessageSecretDetectedInSource          = "The fetched source contains data matching secret patterns."
+	StatusMessageRegistryRateLimited             = "A registry rate limited pulling an image."
 )
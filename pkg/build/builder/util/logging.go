@@ -3,6 +3,7 @@ package util
 import (
 	"net/url"
 	"regexp"
+	"sync"
 
 	s2iapi "github.com/openshift/source-to-image/pkg/api"
 	s2iutil "github.com/openshift/source-to-image/pkg/util"
@@ -13,8 +14,27 @@ import (
 
 var (
 	proxyRegex = regexp.MustCompile("(?i)proxy")
+
+	secretEnvMutex sync.Mutex
+	secretEnvNames = map[string]struct{}{}
 )
 
+// MarkEnvVarSecret registers an environment variable name whose value came
+// from a Secret, so that SafeForLoggingEnvVar and SafeForLoggingEnvironmentList
+// redact it.
+func MarkEnvVarSecret(name string) {
+	secretEnvMutex.Lock()
+	defer secretEnvMutex.Unlock()
+	secretEnvNames[name] = struct{}{}
+}
+
+func isSecretEnvVar(name string) bool {
+	secretEnvMutex.Lock()
+	defer secretEnvMutex.Unlock()
+	_, exists := secretEnvNames[name]
+	return exists
+}
+
 // SafeForLoggingURL removes the user:password section of
 // a url if present.  If not present the value is returned unchanged.
 func SafeForLoggingURL(u *url.URL) *url.URL {
@@ -39,6 +59,10 @@ func SafeForLoggingEnvVar(env []corev1.EnvVar) []corev1.EnvVar {
 	newEnv := make([]corev1.EnvVar, len(env))
 	copy(newEnv, env)
 	for i, env := range newEnv {
+		if isSecretEnvVar(env.Name) {
+			newEnv[i].Value = "redacted"
+			continue
+		}
 		if proxyRegex.MatchString(env.Name) {
 			newEnv[i].Value, _ = s2iutil.SafeForLoggingURL(env.Value)
 		}
@@ -93,6 +117,10 @@ func SafeForLoggingEnvironmentList(env s2iapi.EnvironmentList) s2iapi.Environmen
 	copy(newEnv, env)
 	proxyRegex := regexp.MustCompile("(?i)proxy")
 	for i, env := range newEnv {
+		if isSecretEnvVar(env.Name) {
+			newEnv[i].Value = "redacted"
+			continue
+		}
 		if proxyRegex.MatchString(env.Name) {
 			newEnv[i].Value, _ = s2iutil.SafeForLoggingURL(env.Value)
 		}
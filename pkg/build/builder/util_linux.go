@@ -3,8 +3,10 @@ package builder
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	s2iapi "github.com/openshift/source-to-image/pkg/api"
@@ -48,10 +50,24 @@ func getContainerNetworkConfig() (string, string, error) {
 	return "", "", nil
 }
 
+// cgroupV2ControllersPath only exists on nodes running the cgroup v2
+// unified hierarchy.
+const cgroupV2ControllersPath = "/sys/fs/cgroup/cgroup.controllers"
+
+// cgroupsV2Enabled reports whether this process runs on the cgroup v2
+// unified hierarchy.
+func cgroupsV2Enabled() bool {
+	_, err := os.Stat(cgroupV2ControllersPath)
+	return err == nil
+}
+
 // GetCGroupLimits returns a struct populated with cgroup limit values gathered
 // from the local /sys/fs/cgroup filesystem.  Overflow values are set to
 // math.MaxInt64.
 func GetCGroupLimits() (*s2iapi.CGroupLimits, error) {
+	if cgroupsV2Enabled() {
+		return getCGroupV2Limits()
+	}
 	byteLimit, err := readInt64("/sys/fs/cgroup/memory/memory.limit_in_bytes")
 	if err != nil {
 		// for systems without cgroups builds should succeed
@@ -85,6 +101,57 @@ func GetCGroupLimits() (*s2iapi.CGroupLimits, error) {
 	}, nil
 }
 
+// getCGroupV2Limits reads the build pod's limits from the cgroup v2 unified
+// hierarchy. With a private cgroup namespace the pod's own limits appear at
+// the root of /sys/fs/cgroup.
+func getCGroupV2Limits() (*s2iapi.CGroupLimits, error) {
+	limits := &s2iapi.CGroupLimits{}
+
+	content, err := ioutil.ReadFile("/sys/fs/cgroup/memory.max")
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine cgroup limits: %v", err)
+	}
+	byteLimit, unlimited, err := parseCgroupV2Limit(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine cgroup limits: %v", err)
+	}
+	if !unlimited {
+		// math.MaxInt64 seems to give cgroups trouble, this value is
+		// still 92 terabytes, so it ought to be sufficiently large for
+		// our purposes.
+		if byteLimit > 92233720368547 {
+			byteLimit = 92233720368547
+		}
+		limits.MemoryLimitBytes = byteLimit
+		// Set memoryswap==memorylimit, this ensures no swapping occurs.
+		// see: https://docs.docker.com/engine/reference/run/#runtime-constraints-on-cpu-and-memory
+		limits.MemorySwap = byteLimit
+	}
+
+	if content, err := ioutil.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		quota, period, err := parseCgroupV2CPUMax(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine cgroup limits: %v", err)
+		}
+		limits.CPUQuota = quota
+		limits.CPUPeriod = period
+	}
+
+	if content, err := ioutil.ReadFile("/sys/fs/cgroup/cpu.weight"); err == nil {
+		if weight, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64); err == nil {
+			limits.CPUShares = cgroupV2WeightToShares(weight)
+		}
+	}
+
+	parent, err := getCgroupParent()
+	if err != nil {
+		return nil, fmt.Errorf("read cgroup parent: %v", err)
+	}
+	limits.Parent = parent
+
+	return limits, nil
+}
+
 // getCgroupParent determines the parent cgroup for a container from
 // within that container.
 func getCgroupParent() (string, error) {
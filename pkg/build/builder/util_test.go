@@ -186,6 +186,13 @@ func TestCGroupParentExtraction(t *testing.T) {
 			expect: "",
 			fail:   true,
 		},
+		{
+			name: "cgroupv2-unified",
+			input: map[string]string{
+				"": "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod8d369e32_521b_11e7_8df4_507b9d27b5d9.slice/crio-fbf6fe5e4effd80b6a9b3318dd0e5f538b9c4ba8918c174768720c83b338a41f.scope",
+			},
+			expect: "kubepods-besteffort-pod8d369e32_521b_11e7_8df4_507b9d27b5d9.slice",
+		},
 	}
 
 	for _, tc := range tcs {
@@ -201,3 +208,66 @@ func TestCGroupParentExtraction(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCgroupV2Limit(t *testing.T) {
+	testCases := []struct {
+		content   string
+		value     int64
+		unlimited bool
+		expectErr bool
+	}{
+		{content: "max\n", unlimited: true},
+		{content: "1073741824\n", value: 1073741824},
+		{content: "garbage\n", expectErr: true},
+	}
+	for _, tc := range testCases {
+		value, unlimited, err := parseCgroupV2Limit(tc.content)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.content, tc.expectErr, err)
+			continue
+		}
+		if value != tc.value || unlimited != tc.unlimited {
+			t.Errorf("%q: expected (%d, %v), got (%d, %v)", tc.content, tc.value, tc.unlimited, value, unlimited)
+		}
+	}
+}
+
+func TestParseCgroupV2CPUMax(t *testing.T) {
+	testCases := []struct {
+		content   string
+		quota     int64
+		period    int64
+		expectErr bool
+	}{
+		{content: "max 100000\n", quota: 0, period: 100000},
+		{content: "50000 100000\n", quota: 50000, period: 100000},
+		{content: "50000\n", expectErr: true},
+		{content: "fifty 100000\n", expectErr: true},
+	}
+	for _, tc := range testCases {
+		quota, period, err := parseCgroupV2CPUMax(tc.content)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.content, tc.expectErr, err)
+			continue
+		}
+		if quota != tc.quota || period != tc.period {
+			t.Errorf("%q: expected quota=%d period=%d, got quota=%d period=%d", tc.content, tc.quota, tc.period, quota, period)
+		}
+	}
+}
+
+func TestCgroupV2WeightToShares(t *testing.T) {
+	testCases := []struct {
+		weight   int64
+		expected int64
+	}{
+		{weight: 1, expected: 2},
+		{weight: 100, expected: 2597},
+		{weight: 10000, expected: 262144},
+	}
+	for _, tc := range testCases {
+		if shares := cgroupV2WeightToShares(tc.weight); shares != tc.expected {
+			t.Errorf("weight %d: expected %d shares, got %d", tc.weight, tc.expected, shares)
+		}
+	}
+}
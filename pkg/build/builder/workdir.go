@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	units "github.com/docker/go-units"
+)
+
+// BuildWorkDirEnvVar is the name of the environment variable that relocates
+// the build working directory - the place where the build inputs are
+// assembled - to an alternate mount, such as a generic ephemeral volume or a
+// local SSD. Because the vendored build API does not carry a field for this,
+// the value is read from the build pod's environment.
+const BuildWorkDirEnvVar = "BUILD_WORKDIR_PATH"
+
+// BuildWorkDirMinFreeEnvVar is the name of the environment variable that
+// sets the minimum free space the build working directory's filesystem must
+// offer before the build starts, expressed with an optional unit suffix
+// ("10g"). The build fails early instead of running out of space midway.
+const BuildWorkDirMinFreeEnvVar = "BUILD_WORKDIR_MIN_FREE"
+
+// buildWorkDirFromEnvironment returns the alternate build working directory
+// configured in the environment, if any. The path must be absolute so that
+// the containers sharing it agree on its location.
+func buildWorkDirFromEnvironment() (string, error) {
+	path := strings.TrimSpace(os.Getenv(BuildWorkDirEnvVar))
+	if len(path) == 0 {
+		return "", nil
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("the build working directory %q must be an absolute path", path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// buildWorkDirMinFreeFromEnvironment returns the configured minimum free
+// space for the build working directory in bytes, or zero if no check was
+// requested.
+func buildWorkDirMinFreeFromEnvironment() (int64, error) {
+	value := strings.TrimSpace(os.Getenv(BuildWorkDirMinFreeEnvVar))
+	if len(value) == 0 {
+		return 0, nil
+	}
+	minFree, err := units.RAMInBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for %s: %v", value, BuildWorkDirMinFreeEnvVar, err)
+	}
+	return minFree, nil
+}
+
+// ConfigureBuildWorkDir relocates the build working directory to the path
+// named by BUILD_WORKDIR_PATH, creating it if necessary, and verifies that
+// its filesystem offers at least BUILD_WORKDIR_MIN_FREE bytes of free space.
+// It must run before any build content is fetched.
+func ConfigureBuildWorkDir() error {
+	path, err := buildWorkDirFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if len(path) != 0 {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("cannot create the build working directory: %v", err)
+		}
+		buildWorkDirMount = path
+		InputContentPath = filepath.Join(path, "inputs")
+		glog.V(2).Infof("Relocated the build working directory to %s", path)
+	}
+
+	minFree, err := buildWorkDirMinFreeFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if minFree > 0 {
+		return checkWorkDirCapacity(buildWorkDirMount, minFree)
+	}
+	return nil
+}
+
+// checkWorkDirCapacity fails if the filesystem holding the build working
+// directory has less than minFree bytes available.
+func checkWorkDirCapacity(path string, minFree int64) error {
+	free, err := diskFreeBytes(path)
+	if err != nil {
+		glog.V(0).Infof("warning: Unable to check the free space of %s: %v", path, err)
+		return nil
+	}
+	if free < uint64(minFree) {
+		return fmt.Errorf("the build working directory %s has %s free, but at least %s is required", path, units.BytesSize(float64(free)), units.BytesSize(float64(minFree)))
+	}
+	return nil
+}
+
+// StorageRootOverride returns alternate graph and run root directories for
+// the container storage library, nested under the relocated build working
+// directory, or empty strings when the working directory was not relocated.
+func StorageRootOverride() (graphRoot, runRoot string) {
+	path, err := buildWorkDirFromEnvironment()
+	if err != nil || len(path) == 0 {
+		return "", ""
+	}
+	return filepath.Join(path, "storage"), filepath.Join(path, "storage-run")
+}
@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBuildWorkDirFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  string
+		expectErr bool
+	}{
+		{value: "", expected: ""},
+		{value: "/mnt/build", expected: "/mnt/build"},
+		{value: "/mnt/build/", expected: "/mnt/build"},
+		{value: "relative/path", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(BuildWorkDirEnvVar, tc.value)
+		path, err := buildWorkDirFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && path != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, path)
+		}
+	}
+	os.Unsetenv(BuildWorkDirEnvVar)
+}
+
+func TestBuildWorkDirMinFreeFromEnvironment(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  int64
+		expectErr bool
+	}{
+		{value: "", expected: 0},
+		{value: "10g", expected: 10 * 1024 * 1024 * 1024},
+		{value: "1048576", expected: 1048576},
+		{value: "plenty", expectErr: true},
+	}
+	for _, tc := range testCases {
+		os.Setenv(BuildWorkDirMinFreeEnvVar, tc.value)
+		minFree, err := buildWorkDirMinFreeFromEnvironment()
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%q: expected error %v, got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if !tc.expectErr && minFree != tc.expected {
+			t.Errorf("%q: expected %d, got %d", tc.value, tc.expected, minFree)
+		}
+	}
+	os.Unsetenv(BuildWorkDirMinFreeEnvVar)
+}
+
+func TestCheckWorkDirCapacity(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := checkWorkDirCapacity(dir, 1); err != nil {
+		t.Errorf("expected a 1 byte requirement to be satisfied, got %v", err)
+	}
+	// No filesystem can offer math.MaxInt64 bytes of free space.
+	if err := checkWorkDirCapacity(dir, int64(9223372036854775807)); err == nil {
+		t.Errorf("expected an unsatisfiable requirement to fail")
+	}
+}
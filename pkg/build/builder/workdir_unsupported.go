@@ -0,0 +1,13 @@
+// +build !linux
+
+package builder
+
+import (
+	"errors"
+)
+
+// diskFreeBytes returns the number of bytes available to unprivileged users
+// on the filesystem holding path.
+func diskFreeBytes(path string) (uint64, error) {
+	return 0, errors.New("free space checks are not supported on this platform")
+}
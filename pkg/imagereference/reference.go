@@ -0,0 +1,63 @@
+// Package imagereference exposes the image reference normalization and
+// validation rules applied by the builder, so that webhooks, controllers, and
+// CLI tooling can predict how the builder will interpret a given reference
+// without duplicating its logic.
+package imagereference
+
+import (
+	"fmt"
+	"strings"
+
+	imagereference "github.com/openshift/library-go/pkg/image/reference"
+)
+
+// DefaultRegistry is the registry the builder assumes when an image reference
+// does not name one explicitly.
+const DefaultRegistry = "docker.io"
+
+// Validate returns an error if name is not a valid image reference by the
+// rules the builder applies before pulling or pushing.
+func Validate(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("image reference must not be empty")
+	}
+	if _, err := imagereference.Parse(name); err != nil {
+		return fmt.Errorf("invalid image reference %q: %v", name, err)
+	}
+	return nil
+}
+
+// Normalize reduces an image reference to the minimal canonical form that the
+// builder passes to the container runtime, stripping the default registry and
+// namespace where the runtime would infer them. References that cannot be
+// parsed are returned unchanged, matching the builder's permissive handling of
+// Dockerfile FROM values.
+func Normalize(name string) string {
+	if ref, err := imagereference.Parse(name); err == nil {
+		return ref.DaemonMinimal().Exact()
+	}
+	return name
+}
+
+// Resolve parses an image reference and fills in the registry the builder
+// would contact for it, defaulting to DefaultRegistry when the reference does
+// not name one.
+func Resolve(name string) (imagereference.DockerImageReference, error) {
+	ref, err := imagereference.Parse(name)
+	if err != nil {
+		return imagereference.DockerImageReference{}, fmt.Errorf("error parsing image name %s: %v", name, err)
+	}
+	if ref.Registry == "" {
+		ref.Registry = DefaultRegistry
+	}
+	return ref, nil
+}
+
+// IsDigested returns true if the image reference pins its content by digest
+// rather than by tag.
+func IsDigested(name string) bool {
+	if ref, err := imagereference.Parse(name); err == nil {
+		return len(ref.ID) > 0
+	}
+	return strings.Contains(name, "@")
+}
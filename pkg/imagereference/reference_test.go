@@ -0,0 +1,65 @@
+package imagereference
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		expectErr bool
+	}{
+		{name: "", expectErr: true},
+		{name: "centos", expectErr: false},
+		{name: "docker.io/library/centos:7", expectErr: false},
+		{name: "registry.example.com:5000/myns/app@sha256:3c3a4604a545cdc127456d94e421cd355bca5b528f4a9c1905b15da2eb4a4c6b", expectErr: false},
+		{name: "registry.example.com/myns/app:tag:tag", expectErr: true},
+	}
+	for _, tc := range testCases {
+		err := Validate(tc.name)
+		if tc.expectErr && err == nil {
+			t.Errorf("%s: expected error, got none", tc.name)
+		}
+		if !tc.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "docker.io/library/centos:7", expected: "docker.io/centos:7"},
+		{name: "registry.example.com/myns/app:v1", expected: "registry.example.com/myns/app:v1"},
+		{name: "not a valid reference", expected: "not a valid reference"},
+	}
+	for _, tc := range testCases {
+		if result := Normalize(tc.name); result != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, result)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ref, err := Resolve("myns/app:v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ref.Registry != DefaultRegistry {
+		t.Errorf("expected registry %q, got %q", DefaultRegistry, ref.Registry)
+	}
+	if _, err := Resolve("not a valid reference"); err == nil {
+		t.Errorf("expected error for invalid reference, got none")
+	}
+}
+
+func TestIsDigested(t *testing.T) {
+	if IsDigested("docker.io/library/centos:7") {
+		t.Errorf("tagged reference reported as digested")
+	}
+	if !IsDigested("registry.example.com/myns/app@sha256:3c3a4604a545cdc127456d94e421cd355bca5b528f4a9c1905b15da2eb4a4c6b") {
+		t.Errorf("digested reference not reported as digested")
+	}
+}